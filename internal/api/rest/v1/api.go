@@ -22,14 +22,13 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ArtemVoronov/clearway-task-assets-service/internal/app"
 	"github.com/ArtemVoronov/clearway-task-assets-service/internal/services"
 )
 
-// TODO: add limits for users, i.e. max 100 files, max file size 4GB, for total files 15GB
-
 type LoggerHandler struct {
 	handler http.Handler
 }
@@ -48,6 +47,8 @@ type AuthenticateHandlerFunc func(http.ResponseWriter, *http.Request, *services.
 
 type AuthenicateHandler struct {
 	handler AuthenticateHandlerFunc
+	// scopes required from the API keys, the route without the scopes is not accessible by the API keys
+	scopes []string
 }
 
 func (h *AuthenicateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -56,6 +57,11 @@ func (h *AuthenicateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		processHttpError(w, err)
 		return
 	}
+	err = checkScopes(accessToken, h.scopes)
+	if err != nil {
+		processHttpError(w, err)
+		return
+	}
 
 	err = h.handler(w, r, accessToken)
 	if err != nil {
@@ -69,7 +75,12 @@ func CheckAuthorization(r *http.Request) (*services.AccessToken, error) {
 	if err != nil {
 		return nil, err
 	}
-	result, err := services.Instance().AuthService.GetToken(t)
+	var result services.AccessToken
+	if strings.HasPrefix(t, services.ApiKeyPrefix) {
+		result, err = services.Instance().ApiKeysService.GetToken(t, time.Now())
+	} else {
+		result, err = services.Instance().AuthService.GetToken(t)
+	}
 	if err != nil {
 		switch {
 		case errors.Is(err, services.ErrNotFoundAccessToken):
@@ -84,8 +95,32 @@ func CheckAuthorization(r *http.Request) (*services.AccessToken, error) {
 	return &result, nil
 }
 
-func AuthRequired(handlerToWrap AuthenticateHandlerFunc) *AuthenicateHandler {
-	return &AuthenicateHandler{handlerToWrap}
+// AuthRequired accepts both the access tokens and the API keys, the API keys should have all the scopes
+func AuthRequired(handlerToWrap AuthenticateHandlerFunc, scopes ...string) *AuthenicateHandler {
+	return &AuthenicateHandler{handlerToWrap, scopes}
+}
+
+// AssetPathSeparator separates the name of the asset, which could contain '/', from the path of its sub-resource
+const AssetPathSeparator = "/-/"
+
+// AssetPathHandler routes the requests of the assets with the multi-segment names, the 'path' path value is split
+// by AssetPathSeparator into the 'name' path value and the path which is routed by the wrapped handler,
+// e.g. '/api/asset/docs/report.csv/-/versions/2' is routed as '/versions/2' for the asset 'docs/report.csv'
+type AssetPathHandler struct {
+	handler http.Handler
+}
+
+func (h *AssetPathHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name, subPath, _ := strings.Cut(r.PathValue("path"), AssetPathSeparator)
+	routed := r.Clone(r.Context())
+	routed.URL.Path = "/" + subPath
+	routed.URL.RawPath = ""
+	routed.SetPathValue("name", name)
+	h.handler.ServeHTTP(w, routed)
+}
+
+func NewAssetPathHandler(handlerToWrap http.Handler) *AssetPathHandler {
+	return &AssetPathHandler{handlerToWrap}
 }
 
 type BodySizeLimitHandler struct {
@@ -221,10 +256,58 @@ func NewProcessOptionsRequestsFunc() http.HandlerFunc {
 // message for REST API users
 const InternalServerErrorMsg = "Internal Server Error"
 const AssetNotFoundMsg = "Asset not found"
+const AssetVersionNotFoundMsg = "Asset version not found"
+const AssetDuplicateMsg = "Asset exists already"
+const AssetNameReservedMsg = "Asset is being uploaded already"
 const UserDuplicateMsg = "User exists already"
 const InvalidCredentialsMsg = "Invalid credentials"
 const DuplicateAccessTokenMsg = "Duplicate access token generation"
 const UnauthorizedMsg = "Unauthorized"
+const PreconditionFailedMsg = "Precondition failed"
+const FilesCountQuotaExceededMsg = "Quota exceeded: max files count"
+const FileSizeQuotaExceededMsg = "Quota exceeded: max file size"
+const TotalSizeQuotaExceededMsg = "Quota exceeded: max total size of files"
+const MalformedMultipartBodyMsg = "Malformed multipart body"
+const UploadNotFoundMsg = "Upload not found"
+const UploadOffsetMismatchMsg = "Upload-Offset does not match the current offset of the upload"
+const UploadLengthExceededMsg = "Content exceeds Upload-Length"
+const SameAssetNameMsg = "Target name should differ from the asset name"
+const WrongAssetNameMsg = "Asset name should consist of non-empty segments separated by '/', the segments '.', '..' and '-' are not allowed"
+const FolderNotFoundMsg = "Folder not found"
+const FolderOverlapMsg = "Target folder should not be inside the folder and vice versa"
+const WrongContentRangeMsg = "Content-Range does not match the asset or the content"
+const DigestMismatchMsg = "Content does not match the declared digest"
+const ConflictingDigestsMsg = "Declared digests of the same algorithm differ"
+const UnknownArchiveFormatMsg = "Archive should be zip, tar or tar.gz"
+const MalformedArchiveMsg = "Malformed archive"
+const ArchiveLimitExceededMsg = "Archive exceeds the limits of the entries count, expanded size or compression ratio"
+const UnsafeArchiveEntryMsg = "Path of the archive entry should be relative and should not go out of the archive"
+const UnsupportedArchiveEntryMsg = "Only regular files and directories of the archive are supported"
+const UserNotFoundMsg = "User not found"
+const GrantNotFoundMsg = "Grant not found"
+const WrongGrantPermissionMsg = "Permission should be 'read' or 'read-write'"
+const SelfGrantMsg = "Grantee should differ from the owner"
+const ReadOnlyGrantMsg = "Asset is shared for reading only"
+const PresignDisabledMsg = "Presigned URLs are disabled"
+const WrongSignatureMsg = "Signature of the URL is wrong"
+const PresignedURLExpiredMsg = "URL is expired"
+const LinkNotFoundMsg = "Link not found"
+const LinkExpiredMsg = "Link is expired or has no downloads left"
+const LinkPasswordRequiredMsg = "Link is protected by the password"
+const LinkPasswordAttemptsMsg = "Too many password attempts, try again later"
+const WrongLinkOptionsMsg = "Expire date of the link should be in the future and max downloads should be positive"
+const ApiKeyNotFoundMsg = "API key not found"
+const ApiKeyNotAllowedMsg = "Route is not accessible by API keys"
+const ApiKeyScopeMsg = "API key does not have the required scope"
+const ApiKeyNamePrefixMsg = "Asset name is out of the name prefix of the API key"
+const WrongApiKeyOptionsMsg = "Scopes of the API key should be 'assets:read', 'assets:write' or 'assets:delete' and its expire date should be in the future"
+const GroupNotFoundMsg = "Group not found"
+const GroupRoleMsg = "Role in the group does not allow the action"
+const WrongGroupRoleMsg = "Role should be 'viewer', 'editor' or 'admin'"
+const WrongGroupNameMsg = "Name of the group should be non-empty and at most 256 characters"
+const LastGroupAdminMsg = "Group should keep at least one admin"
+const GroupNotEmptyMsg = "Group has assets, trashed assets or unfinished uploads"
+const WrongAssetLabelsMsg = "Tags should be printable and should not contain commas, metadata keys should contain only letters, digits, '.', '_' or '-', the asset could have at most 64 labels"
 
 // Common success response
 // swagger:response StatusResponse
@@ -242,14 +325,95 @@ type TokenResponse struct {
 	Token string `json:"token"`
 }
 
+// Metadata of the asset
+//
+// swagger:model AssetInfo
+type AssetInfo struct {
+	// name
+	// example: "file1.txt"
+	Name string `json:"name"`
+
+	// size in bytes
+	// example: 1024
+	Size int64 `json:"size"`
+
+	// declared on upload or sniffed content type
+	// example: "text/plain; charset=utf-8"
+	ContentType string `json:"content_type"`
+
+	// hex encoded SHA-256 of the content
+	// example: "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+	Checksum string `json:"checksum"`
+
+	// creation time
+	// example: "2024-07-01T10:00:00Z"
+	CreateDate time.Time `json:"create_date"`
+
+	// last modification time
+	// example: "2024-07-01T10:00:00Z"
+	UpdateDate time.Time `json:"update_date"`
+
+	// number of the current content
+	// example: 1
+	Version int `json:"version"`
+}
+
 // Success get assets list response
 // swagger:response AssetsListResponse
 type AssetsListResponse struct {
+	// assets
+	AssetsList []AssetInfo `json:"assets"`
+
+	// names of the folders up to the delimiter, they are returned only if the delimiter is requested
+	// example: ["docs/2024/"]
+	CommonPrefixes []string `json:"common_prefixes,omitempty"`
+
+	// cursor of the next page, it is missed for the last page
+	// example: "eyJzIjoibmFtZSIsImQiOmZhbHNlLCJ2IjoiIiwibiI6ImZpbGUzLnR4dCJ9"
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Success get assets list response of the first API version
+// swagger:response AssetsNamesListResponse
+type AssetsNamesListResponse struct {
 	// assets
 	// example: "[file1.txt, file2.txt, file3.txt]"
 	AssetsList []string `json:"assets"`
 }
 
+const (
+	MultipartModeAtomic     = "atomic"
+	MultipartModeBestEffort = "best-effort"
+
+	AssetUploadStatusStored    = "stored"
+	AssetUploadStatusDuplicate = "duplicate"
+	AssetUploadStatusFailed    = "failed"
+)
+
+// Result of storing one part of the multipart upload
+//
+// swagger:model AssetUploadResult
+type AssetUploadResult struct {
+	// asset name
+	// example: "file1.txt"
+	Name string `json:"name"`
+
+	// one of: stored, duplicate, failed
+	// example: "stored"
+	Status string `json:"status"`
+
+	// reason of the failure
+	// example: "Quota exceeded: max file size"
+	Reason string `json:"reason,omitempty"`
+}
+
+// Results of the best-effort multipart upload
+// swagger:response AssetUploadResultsResponse
+type AssetUploadResultsResponse struct {
+	// results in the order of the parts
+	Results []AssetUploadResult `json:"results"`
+}
+
 // Common error response
 // swagger:response ErrorResponse
 type ErrorResponse struct {
@@ -0,0 +1,322 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ArtemVoronov/clearway-task-assets-service/internal/services"
+)
+
+var ErrApiKeyNotAllowed = errors.New("route is not accessible by api keys")
+var ErrApiKeyScope = errors.New("api key does not have the scope")
+var ErrApiKeyNamePrefix = errors.New("name is out of the name prefix of api key")
+
+// Settings of the API key
+//
+// swagger:model ApiKeyRequest
+type ApiKeyRequest struct {
+	// label of the key
+	// example: "backup job"
+	Name string `json:"name"`
+
+	// scopes of the key: 'assets:read', 'assets:write', 'assets:delete'
+	//
+	// required: true
+	// example: ["assets:read"]
+	Scopes []string `json:"scopes"`
+
+	// the key gives access only to the assets which names start with the prefix, all assets are accessible if it is missed
+	// example: "backups/"
+	NamePrefix string `json:"name_prefix,omitempty"`
+
+	// expiration time of the key, the key does not expire if it is missed
+	// example: "2025-01-01T00:00:00Z"
+	ExpireDate *time.Time `json:"expire_date,omitempty"`
+}
+
+// API key without the key itself
+//
+// swagger:model ApiKeyInfo
+type ApiKeyInfo struct {
+	// id of the key
+	// example: 42
+	Id int64 `json:"id"`
+
+	// first characters of the key to recognize it
+	// example: "ak_2c26b46b"
+	KeyPrefix string `json:"key_prefix"`
+
+	// label of the key
+	// example: "backup job"
+	Name string `json:"name"`
+
+	// scopes of the key
+	// example: ["assets:read"]
+	Scopes []string `json:"scopes"`
+
+	// prefix of the names of the accessible assets
+	// example: "backups/"
+	NamePrefix string `json:"name_prefix,omitempty"`
+
+	// expiration time of the key
+	// example: "2025-01-01T00:00:00Z"
+	ExpireDate *time.Time `json:"expire_date,omitempty"`
+
+	// time of the last usage of the key
+	// example: "2024-07-02T10:00:00Z"
+	LastUsedDate *time.Time `json:"last_used_date,omitempty"`
+
+	// creation time
+	// example: "2024-07-01T10:00:00Z"
+	CreateDate time.Time `json:"create_date"`
+}
+
+// Success create API key response
+// swagger:response ApiKeyResponse
+type ApiKeyResponse struct {
+	ApiKeyInfo
+
+	// the key is shown only once, it is passed as 'Authorization: Bearer <key>'
+	// example: "ak_2c26b46b68ffc68ff99b453c1d304134"
+	Key string `json:"key"`
+}
+
+// Success get API keys response
+// swagger:response ApiKeysResponse
+type ApiKeysResponse struct {
+	// keys of the user in the order of creation
+	ApiKeys []ApiKeyInfo `json:"api_keys"`
+}
+
+// swagger:route POST /api/api-keys api-keys CreateApiKey
+//
+// # Create the API key of the user
+//
+// The key is returned only by this route. The API keys are not accepted by the routes of the API keys,
+// the grants, the links and the shared assets, they are managed with the access token of the login.
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Consumes:
+//   - application/json
+//
+// Security:
+// - Bearer: []
+//
+// responses:
+//   - 201: ApiKeyResponse
+//   - 400: ErrorResponse
+//   - 500: ErrorResponse
+func CreateApiKey(w http.ResponseWriter, r *http.Request, t *services.AccessToken) error {
+	request, err := parseApiKeyRequest(r)
+	if err != nil {
+		return err
+	}
+	slog.Info(fmt.Sprintf("attempt to create api key '%v' for user '%v'\n", request.Name, t.UserUUID))
+
+	apiKey, key, err := services.Instance().ApiKeysService.CreateApiKey(t.UserUUID, services.ApiKeyOptions{
+		Name:       request.Name,
+		Scopes:     request.Scopes,
+		NamePrefix: request.NamePrefix,
+		ExpireDate: request.ExpireDate,
+	}, time.Now())
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrWrongApiKeyOptions):
+			return WithStatus(err, WrongApiKeyOptionsMsg, http.StatusBadRequest)
+		default:
+			return WithStatus(err, InternalServerErrorMsg, http.StatusInternalServerError)
+		}
+	}
+
+	return WriteJSON(w, http.StatusCreated, ApiKeyResponse{ApiKeyInfo: toApiKeyInfo(apiKey), Key: key})
+}
+
+func parseApiKeyRequest(r *http.Request) (ApiKeyRequest, error) {
+	var request ApiKeyRequest
+	b, err := io.ReadAll(r.Body)
+	defer r.Body.Close()
+	if err != nil {
+		return request, WithStatus(err, InternalServerErrorMsg, http.StatusInternalServerError)
+	}
+	err = json.Unmarshal(b, &request)
+	if err != nil {
+		return request, WithStatus(err, "Expected json body with 'scopes' parameter", http.StatusBadRequest)
+	}
+	if len(request.Scopes) == 0 {
+		return request, WithStatus(fmt.Errorf("missed 'scopes' parameter"), "Missed 'scopes' parameter. Expected json body with it", http.StatusBadRequest)
+	}
+	return request, nil
+}
+
+// swagger:route GET /api/api-keys api-keys LoadApiKeys
+//
+// # Get the API keys of the user with their usage
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Security:
+// - Bearer: []
+//
+// responses:
+//   - 200: ApiKeysResponse
+//   - 500: ErrorResponse
+func LoadApiKeys(w http.ResponseWriter, r *http.Request, t *services.AccessToken) error {
+	slog.Info(fmt.Sprintf("attempt to load api keys of user '%v'\n", t.UserUUID))
+	apiKeys, err := services.Instance().ApiKeysService.GetApiKeys(t.UserUUID)
+	if err != nil {
+		return WithStatus(err, InternalServerErrorMsg, http.StatusInternalServerError)
+	}
+
+	result := make([]ApiKeyInfo, 0, len(apiKeys))
+	for _, apiKey := range apiKeys {
+		result = append(result, toApiKeyInfo(apiKey))
+	}
+
+	return WriteJSON(w, http.StatusOK, ApiKeysResponse{result})
+}
+
+// swagger:route DELETE /api/api-keys/{id} api-keys DeleteApiKey
+//
+// # Revoke the API key
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Security:
+// - Bearer: []
+//
+// responses:
+//   - 200: StatusResponse
+//   - 400: ErrorResponse
+//   - 404: ErrorResponse
+//   - 500: ErrorResponse
+func DeleteApiKey(w http.ResponseWriter, r *http.Request, t *services.AccessToken) error {
+	idStr := r.PathValue("id")
+	apiKeyId, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return WithStatus(err, "Id should be a number", http.StatusBadRequest)
+	}
+	slog.Info(fmt.Sprintf("attempt to delete api key %v\n", apiKeyId))
+
+	err = services.Instance().ApiKeysService.DeleteApiKey(t.UserUUID, apiKeyId)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrNotFoundApiKey):
+			return WithStatus(err, ApiKeyNotFoundMsg, http.StatusNotFound)
+		default:
+			return WithStatus(err, InternalServerErrorMsg, http.StatusInternalServerError)
+		}
+	}
+
+	return WriteJSON(w, http.StatusOK, StatusResponse{"ok"})
+}
+
+// checkScopes rejects the API keys without the scopes, the access tokens of the login are not limited by the scopes
+func checkScopes(t *services.AccessToken, scopes []string) error {
+	if !t.IsApiKey() {
+		return nil
+	}
+	if len(scopes) == 0 {
+		return WithStatus(ErrApiKeyNotAllowed, ApiKeyNotAllowedMsg, http.StatusForbidden)
+	}
+	for _, scope := range scopes {
+		if !t.HasScope(scope) {
+			return WithStatus(fmt.Errorf("%w '%v'", ErrApiKeyScope, scope), ApiKeyScopeMsg, http.StatusForbidden)
+		}
+	}
+	return nil
+}
+
+// checkNamePrefix rejects the names of the assets out of the name prefix of the API key
+func checkNamePrefix(t *services.AccessToken, names ...string) error {
+	for _, name := range names {
+		if !t.AllowsName(name) {
+			return WithStatus(fmt.Errorf("%w: '%v'", ErrApiKeyNamePrefix, name), ApiKeyNamePrefixMsg, http.StatusForbidden)
+		}
+	}
+	return nil
+}
+
+// checkFolderPrefix rejects the folders which could contain the assets out of the name prefix of the API key
+func checkFolderPrefix(t *services.AccessToken, folders ...string) error {
+	for _, folder := range folders {
+		if !t.AllowsFolder(folder) {
+			return WithStatus(fmt.Errorf("%w: '%v'", ErrApiKeyNamePrefix, folder), ApiKeyNamePrefixMsg, http.StatusForbidden)
+		}
+	}
+	return nil
+}
+
+func toApiKeyInfo(apiKey services.ApiKey) ApiKeyInfo {
+	return ApiKeyInfo{
+		Id:           apiKey.Id,
+		KeyPrefix:    apiKey.KeyPrefix,
+		Name:         apiKey.Name,
+		Scopes:       apiKey.Scopes,
+		NamePrefix:   apiKey.NamePrefix,
+		ExpireDate:   apiKey.ExpireDate,
+		LastUsedDate: apiKey.LastUsedDate,
+		CreateDate:   apiKey.CreateDate,
+	}
+}
+
+// swagger:parameters DeleteApiKey
+type ApiKeyRequestParams struct {
+	// id of the API key
+	//
+	// in: path
+	// required: true
+	Id int64 `json:"id"`
+}
+
+// swagger:parameters CreateApiKey
+type CreateApiKeyParams struct {
+	// settings of the API key
+	//
+	// in: body
+	// required: true
+	// example: {"name": "backup job", "scopes": ["assets:read", "assets:write"], "name_prefix": "backups/"}
+	ApiKey *ApiKeyRequest `json:"ApiKey"`
+}
+
+// narrowPrefix narrows the prefix of the listed names to the name prefix of the API key,
+// the prefix out of the name prefix is rejected
+func narrowPrefix(t *services.AccessToken, prefix string) (string, error) {
+	if t.AllowsName(prefix) {
+		return prefix, nil
+	}
+	if strings.HasPrefix(t.NamePrefix, prefix) {
+		return t.NamePrefix, nil
+	}
+	return "", checkNamePrefix(t, prefix)
+}
+
+// checkTrashedAssetPrefix rejects the trashed asset out of the name prefix of the API key
+func checkTrashedAssetPrefix(t *services.AccessToken, trashId int64) error {
+	if len(t.NamePrefix) == 0 {
+		return nil
+	}
+	trash, err := services.Instance().AssetsService.GetTrash(t.UserUUID)
+	if err != nil {
+		return WithStatus(err, InternalServerErrorMsg, http.StatusInternalServerError)
+	}
+	for _, item := range trash {
+		if item.Id == trashId {
+			return checkNamePrefix(t, item.Asset.Name)
+		}
+	}
+	return WithStatus(fmt.Errorf("trashed asset %v error: %w", trashId, services.ErrNotFoundTrashedAsset), AssetNotFoundMsg, http.StatusNotFound)
+}
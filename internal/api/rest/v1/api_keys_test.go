@@ -0,0 +1,48 @@
+package v1
+
+import (
+	"testing"
+
+	"github.com/ArtemVoronov/clearway-task-assets-service/internal/services"
+)
+
+func TestCheckScopes(t *testing.T) {
+	login := &services.AccessToken{Value: "b5a302e740d0d84bbdc2254c97f1427b"}
+	if checkScopes(login, nil) != nil {
+		t.Errorf("expected the access token of the login to be accepted by the route without the scopes")
+	}
+
+	apiKey := &services.AccessToken{ApiKeyId: 1, Scopes: []string{services.ScopeAssetsRead, services.ScopeAssetsWrite}}
+	if checkScopes(apiKey, nil) == nil {
+		t.Errorf("expected the API key to be rejected by the route without the scopes")
+	}
+	if checkScopes(apiKey, []string{services.ScopeAssetsWrite}) != nil {
+		t.Errorf("expected the API key to be accepted by the route with its scope")
+	}
+	if checkScopes(apiKey, []string{services.ScopeAssetsWrite, services.ScopeAssetsDelete}) == nil {
+		t.Errorf("expected the API key to be rejected by the route with the scope it does not have")
+	}
+}
+
+func TestNarrowPrefix(t *testing.T) {
+	apiKey := &services.AccessToken{ApiKeyId: 1, NamePrefix: "backups/"}
+	tests := []struct {
+		prefix   string
+		expected string
+		rejected bool
+	}{
+		{"", "backups/", false},
+		{"back", "backups/", false},
+		{"backups/2024/", "backups/2024/", false},
+		{"docs/", "", true},
+	}
+	for _, test := range tests {
+		actual, err := narrowPrefix(apiKey, test.prefix)
+		if (err != nil) != test.rejected {
+			t.Errorf("expected prefix '%v' to be rejected: %v, actual error: %v", test.prefix, test.rejected, err)
+		}
+		if actual != test.expected {
+			t.Errorf("expected narrowed prefix of '%v': '%v', actual: '%v'", test.prefix, test.expected, actual)
+		}
+	}
+}
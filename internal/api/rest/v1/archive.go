@@ -0,0 +1,400 @@
+package v1
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ArtemVoronov/clearway-task-assets-service/internal/services"
+)
+
+// the segment '-' is not allowed in the names of the assets, so the manifest never collides with them
+const ArchiveManifestName = "-/manifest.json"
+
+var archiveContentTypes = map[string]string{
+	services.ArchiveFormatZip:   "application/zip",
+	services.ArchiveFormatTarGz: "application/gzip",
+}
+
+// the media types of the 'Accept' header which are served by the archive formats
+var archiveAcceptedTypes = map[string]string{
+	"application/zip":    services.ArchiveFormatZip,
+	"application/gzip":   services.ArchiveFormatTarGz,
+	"application/x-gtar": services.ArchiveFormatTarGz,
+}
+
+// Selection of the assets for the archive, either the names or the prefix is required
+//
+// swagger:model ArchiveSelection
+type ArchiveSelection struct {
+	// names of the assets
+	// example: ["docs/report.csv", "docs/summary.txt"]
+	Names []string `json:"names,omitempty"`
+
+	// prefix of the names of the assets, the empty prefix selects all assets
+	// example: "docs/"
+	Prefix *string `json:"prefix,omitempty"`
+}
+
+// Manifest of the archive, it is the last entry of the archive
+//
+// swagger:model ArchiveManifest
+type ArchiveManifest struct {
+	// creation time
+	// example: "2024-07-01T10:00:00Z"
+	CreateDate time.Time `json:"create_date"`
+
+	// assets of the archive, the checksums are calculated by the archived content
+	Assets []AssetInfo `json:"assets"`
+}
+
+// swagger:route POST /api/assets/archive assets LoadAssetsArchive
+//
+// # Download users's assets as a single archive
+//
+// The archive is streamed while the assets are read, its format is chosen by 'format' parameter or by 'Accept' header,
+// zip is used by default. The archive ends with the manifest '-/manifest.json' which lists the assets and their checksums.
+//
+// ---
+// Produces:
+//   - application/zip
+//   - application/gzip
+//
+// Consumes:
+//   - application/json
+//
+// Security:
+// - Bearer: []
+//
+// responses:
+//   - 200: StatusResponse
+//   - 400: ErrorResponse
+//   - 404: ErrorResponse
+//   - 406: ErrorResponse
+//   - 500: ErrorResponse
+func LoadAssetsArchive(w http.ResponseWriter, r *http.Request, t *services.AccessToken) error {
+	format, err := parseArchiveFormat(r)
+	if err != nil {
+		return err
+	}
+	selection, err := parseArchiveSelection(r)
+	if err != nil {
+		return err
+	}
+	if len(selection.Names) > 0 {
+		err = checkNamePrefix(t, selection.Names...)
+	} else {
+		selection.Prefix, err = narrowPrefix(t, selection.Prefix)
+	}
+	if err != nil {
+		return err
+	}
+	slog.Info(fmt.Sprintf("attempt to load %v archive of assets for user '%v'\n", format, t.UserUUID))
+
+	assets, err := services.Instance().AssetsService.SelectAssets(t.UserUUID, selection)
+	if err != nil {
+		return processArchiveError(err)
+	}
+	names := make([]string, 0, len(assets))
+	for _, asset := range assets {
+		names = append(names, asset.Name)
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", archiveContentTypes[format])
+	h.Set("Content-Disposition", contentDisposition(DispositionAttachment, "assets."+format))
+	h.Set("Vary", "Accept")
+	w.WriteHeader(http.StatusOK)
+
+	archive := newArchiveWriter(format, w)
+	manifest := ArchiveManifest{CreateDate: time.Now().UTC(), Assets: make([]AssetInfo, 0, len(assets))}
+	err = services.Instance().AssetsService.ReadAssets(t.UserUUID, names, func(asset services.Asset, content io.Reader) error {
+		info, internalErr := writeArchiveAsset(archive, asset, content)
+		if internalErr != nil {
+			return internalErr
+		}
+		manifest.Assets = append(manifest.Assets, info)
+		return nil
+	})
+	if err == nil {
+		err = writeArchiveManifest(archive, manifest)
+	}
+	if err == nil {
+		err = archive.Close()
+	}
+	if err != nil {
+		slog.Error(fmt.Sprintf("unable to stream archive of assets for user '%v': %v", t.UserUUID, err))
+		// the status is sent already, so the connection is aborted to let the client know that the archive is incomplete
+		panic(http.ErrAbortHandler)
+	}
+	return nil
+}
+
+// writeArchiveAsset writes the content into the archive and returns the metadata with the checksum of the written content
+func writeArchiveAsset(archive archiveWriter, asset services.Asset, content io.Reader) (AssetInfo, error) {
+	hasher := sha256.New()
+	err := archive.WriteEntry(asset.Name, asset.Size, asset.UpdateDate, io.TeeReader(content, hasher))
+	if err != nil {
+		return AssetInfo{}, fmt.Errorf("unable to archive asset '%v': %w", asset.Name, err)
+	}
+	info := toAssetInfo(asset)
+	info.Checksum = hex.EncodeToString(hasher.Sum(nil))
+	if len(asset.Checksum) > 0 && asset.Checksum != info.Checksum {
+		slog.Error(fmt.Sprintf("checksum mismatch of asset '%v', expected: %v, actual: %v", asset.Name, asset.Checksum, info.Checksum))
+	}
+	return info, nil
+}
+
+func writeArchiveManifest(archive archiveWriter, manifest ArchiveManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to marshal archive manifest: %w", err)
+	}
+	return archive.WriteEntry(ArchiveManifestName, int64(len(data)), manifest.CreateDate, bytes.NewReader(data))
+}
+
+func parseArchiveSelection(r *http.Request) (services.AssetsSelection, error) {
+	var selection ArchiveSelection
+	defer r.Body.Close()
+	err := json.NewDecoder(r.Body).Decode(&selection)
+	if err != nil {
+		return services.AssetsSelection{}, WithStatus(err, "Expected json body with 'names' or 'prefix' parameter", http.StatusBadRequest)
+	}
+	if (len(selection.Names) > 0) == (selection.Prefix != nil) {
+		return services.AssetsSelection{}, WithStatus(fmt.Errorf("wrong archive selection"), "Expected either 'names' or 'prefix' parameter", http.StatusBadRequest)
+	}
+	if selection.Prefix != nil {
+		return services.AssetsSelection{Prefix: *selection.Prefix}, nil
+	}
+	return services.AssetsSelection{Names: selection.Names}, nil
+}
+
+// parseArchiveFormat prefers 'format' parameter to 'Accept' header
+func parseArchiveFormat(r *http.Request) (string, error) {
+	format := r.URL.Query().Get("format")
+	if len(format) > 0 {
+		if _, ok := archiveContentTypes[format]; !ok {
+			return "", WithStatus(fmt.Errorf("wrong 'format' parameter: %v", format), "Parameter 'format' should be 'zip' or 'tar.gz'", http.StatusBadRequest)
+		}
+		return format, nil
+	}
+	format, ok := negotiateArchiveFormat(r.Header.Get("Accept"))
+	if !ok {
+		return "", WithStatus(fmt.Errorf("unsupported 'Accept' header: %v", r.Header.Get("Accept")), "Archive is available as 'application/zip' or 'application/gzip'", http.StatusNotAcceptable)
+	}
+	return format, nil
+}
+
+// negotiateArchiveFormat chooses the format by 'Accept' header (see RFC 9110, section 12.5.1), zip is preferred for the wildcards
+func negotiateArchiveFormat(header string) (string, bool) {
+	if len(strings.TrimSpace(header)) == 0 {
+		return services.ArchiveFormatZip, true
+	}
+	type candidate struct {
+		format  string
+		quality float64
+	}
+	candidates := []candidate{}
+	for _, item := range strings.Split(header, ",") {
+		mediaType, params, _ := strings.Cut(strings.TrimSpace(item), ";")
+		mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+		quality := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if value, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				parsed, err := strconv.ParseFloat(value, 64)
+				if err == nil {
+					quality = parsed
+				}
+			}
+		}
+		if quality <= 0 {
+			continue
+		}
+		switch {
+		case mediaType == "*/*" || mediaType == "application/*":
+			candidates = append(candidates, candidate{services.ArchiveFormatZip, quality})
+		case len(archiveAcceptedTypes[mediaType]) > 0:
+			candidates = append(candidates, candidate{archiveAcceptedTypes[mediaType], quality})
+		}
+	}
+	if len(candidates) == 0 {
+		return "", false
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].quality > candidates[j].quality
+	})
+	return candidates[0].format, true
+}
+
+func processArchiveError(err error) error {
+	switch {
+	case errors.Is(err, services.ErrNotFoundAsset):
+		return WithStatus(err, AssetNotFoundMsg, http.StatusNotFound)
+	case errors.Is(err, services.ErrTooManyArchiveAssets):
+		return WithStatus(err, fmt.Sprintf("Archive could contain at most %v assets", services.MaxArchiveAssetsCount), http.StatusBadRequest)
+	default:
+		return WithStatus(err, InternalServerErrorMsg, http.StatusInternalServerError)
+	}
+}
+
+// archiveWriter writes the entries of the archive one by one without buffering them
+type archiveWriter interface {
+	WriteEntry(name string, size int64, modified time.Time, content io.Reader) error
+	Close() error
+}
+
+func newArchiveWriter(format string, w io.Writer) archiveWriter {
+	if format == services.ArchiveFormatTarGz {
+		gz := gzip.NewWriter(w)
+		return &tarGzArchiveWriter{gz: gz, tw: tar.NewWriter(gz)}
+	}
+	return &zipArchiveWriter{zw: zip.NewWriter(w)}
+}
+
+type zipArchiveWriter struct {
+	zw *zip.Writer
+}
+
+func (a *zipArchiveWriter) WriteEntry(name string, size int64, modified time.Time, content io.Reader) error {
+	entry, err := a.zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: modified,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(entry, content)
+	return err
+}
+
+func (a *zipArchiveWriter) Close() error {
+	return a.zw.Close()
+}
+
+// tarGzArchiveWriter needs the size of the entry before its content, so the size of the asset has to be exact
+type tarGzArchiveWriter struct {
+	gz *gzip.Writer
+	tw *tar.Writer
+}
+
+func (a *tarGzArchiveWriter) WriteEntry(name string, size int64, modified time.Time, content io.Reader) error {
+	err := a.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     size,
+		Mode:     0o644,
+		ModTime:  modified,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(a.tw, content)
+	return err
+}
+
+func (a *tarGzArchiveWriter) Close() error {
+	err := a.tw.Close()
+	if err != nil {
+		return err
+	}
+	return a.gz.Close()
+}
+
+// swagger:route POST /api/upload-archive assets StoreAssetsArchive
+//
+// # Store each file of the archive as the asset
+//
+// Accepts zip, tar or tar.gz archive, the format is detected by the content. The paths of the files become the names of the assets.
+// Each file is stored independently and the status of each file is returned.
+// The labels of the request headers are set for each stored asset.
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Consumes:
+//   - application/zip
+//   - application/x-tar
+//   - application/gzip
+//
+// Security:
+// - Bearer: []
+//
+// responses:
+//   - 207: AssetUploadResultsResponse
+//   - 400: ErrorResponse
+//   - 415: ErrorResponse
+//   - 500: ErrorResponse
+func StoreAssetsArchive(w http.ResponseWriter, r *http.Request, t *services.AccessToken) error {
+	slog.Info(fmt.Sprintf("attempt to store archive of assets for user '%v'\n", t.UserUUID))
+	defer r.Body.Close()
+	// the names of the entries are known only after the expansion, so the archive is stored only by the unrestricted keys
+	err := checkNamePrefix(t, "")
+	if err != nil {
+		return err
+	}
+
+	labels, err := parseAssetLabels(r.Header)
+	if err != nil {
+		return err
+	}
+
+	entries, err := services.Instance().AssetsService.ExpandArchive(t.UserUUID, r.Body, labels)
+	if err != nil {
+		return processStoreAsserError(err)
+	}
+
+	results := make([]AssetUploadResult, 0, len(entries))
+	for _, entry := range entries {
+		results = append(results, toAssetUploadResult(entry.Name, entry.Err))
+	}
+	return WriteJSON(w, http.StatusMultiStatus, AssetUploadResultsResponse{results})
+}
+
+// swagger:parameters LoadAssetsArchive
+type ArchiveRequest struct {
+	// format of the archive: zip or tar.gz
+	//
+	// in: query
+	Format string `json:"format"`
+
+	// selection of the assets
+	//
+	// in: body
+	// required: true
+	// example: {"prefix": "docs/"}
+	Selection *ArchiveSelection `json:"Selection"`
+}
+
+// swagger:parameters StoreAssetsArchive
+type StoreAssetsArchiveParams struct {
+	// comma-separated tags of each stored asset
+	//
+	// in: header
+	Tags string `json:"X-Asset-Tags"`
+
+	// metadata of each stored asset, one header per key, e.g. 'X-Asset-Meta-Build: 42'
+	//
+	// in: header
+	Meta string `json:"X-Asset-Meta-<key>"`
+
+	// zip, tar or tar.gz archive
+	//
+	// in: body
+	// required: true
+	Data *bytes.Buffer `json:"data"`
+}
@@ -0,0 +1,103 @@
+package v1
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ArtemVoronov/clearway-task-assets-service/internal/services"
+)
+
+func TestNegotiateArchiveFormat(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected string
+		ok       bool
+	}{
+		{"", services.ArchiveFormatZip, true},
+		{"*/*", services.ArchiveFormatZip, true},
+		{"application/gzip", services.ArchiveFormatTarGz, true},
+		{"application/zip;q=0.5, application/x-gtar", services.ArchiveFormatTarGz, true},
+		{"application/gzip;q=0, */*;q=0.1", services.ArchiveFormatZip, true},
+		{"text/html", "", false},
+	}
+	for _, test := range tests {
+		actual, ok := negotiateArchiveFormat(test.header)
+		if actual != test.expected || ok != test.ok {
+			t.Errorf("expected %v (%v) for header '%v', actual: %v (%v)", test.expected, test.ok, test.header, actual, ok)
+		}
+	}
+}
+
+func writeTestArchive(t *testing.T, format string, entries map[string]string) []byte {
+	var buf bytes.Buffer
+	archive := newArchiveWriter(format, &buf)
+	for name, content := range entries {
+		err := archive.WriteEntry(name, int64(len(content)), time.Now(), strings.NewReader(content))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	err := archive.Close()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return buf.Bytes()
+}
+
+func TestZipArchiveWriter(t *testing.T) {
+	entries := map[string]string{"docs/report.csv": "a,b\n1,2\n", ArchiveManifestName: "{}"}
+	data := writeTestArchive(t, services.ArchiveFormatZip, entries)
+
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(reader.File) != len(entries) {
+		t.Errorf("expected entries count: %v, actual: %v", len(entries), len(reader.File))
+	}
+	for _, file := range reader.File {
+		entry, err := file.Open()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		actual, err := io.ReadAll(entry)
+		if err != nil || string(actual) != entries[file.Name] {
+			t.Errorf("expected content of '%v': %v, actual: %v, error: %v", file.Name, entries[file.Name], string(actual), err)
+		}
+	}
+}
+
+func TestTarGzArchiveWriter(t *testing.T) {
+	entries := map[string]string{"docs/report.csv": "a,b\n1,2\n", ArchiveManifestName: "{}"}
+	data := writeTestArchive(t, services.ArchiveFormatTarGz, entries)
+
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	reader := tar.NewReader(gz)
+	count := 0
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		actual, err := io.ReadAll(reader)
+		if err != nil || string(actual) != entries[header.Name] {
+			t.Errorf("expected content of '%v': %v, actual: %v, error: %v", header.Name, entries[header.Name], string(actual), err)
+		}
+		count++
+	}
+	if count != len(entries) {
+		t.Errorf("expected entries count: %v, actual: %v", len(entries), count)
+	}
+}
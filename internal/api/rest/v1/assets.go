@@ -6,11 +6,12 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"mime"
 	"mime/multipart"
 	"net/http"
 	"regexp"
+	"strconv"
 	"strings"
-	"time"
 
 	"github.com/ArtemVoronov/clearway-task-assets-service/internal/services"
 )
@@ -30,15 +31,109 @@ var regExpBoundaryString = regexp.MustCompile("^.+boundary=(.+)$")
 //
 // responses:
 //   - 200: AssetsListResponse
+//   - 400: ErrorResponse
+//   - 404: ErrorResponse
 //   - 500: ErrorResponse
 func LoadAssetsList(w http.ResponseWriter, r *http.Request, t *services.AccessToken) error {
 	slog.Info(fmt.Sprintf("attempt to load assets list for user '%v'\n", t.UserUUID))
-	list, err := services.Instance().AssetsService.GetAssetList(t.UserUUID)
+	query, err := parseAssetsListQuery(r)
+	if err != nil {
+		return err
+	}
+	query.Prefix, err = narrowPrefix(t, query.Prefix)
+	if err != nil {
+		return err
+	}
+	ownerUuid, err := resolveFolderOwner(r, t, query.Prefix)
 	if err != nil {
+		return err
+	}
+
+	page, err := services.Instance().AssetsService.GetAssetList(ownerUuid, query)
+	if err != nil {
+		return processAssetsListError(err)
+	}
+
+	result := make([]AssetInfo, 0, len(page.Assets))
+	for _, asset := range page.Assets {
+		result = append(result, toAssetInfo(asset))
+	}
+
+	return WriteJSON(w, http.StatusOK, AssetsListResponse{AssetsList: result, CommonPrefixes: page.CommonPrefixes, NextCursor: page.NextCursor})
+}
+
+// LoadAssetsNamesList keeps the first version of the assets list format (all names of the assets at once), it is enabled by 'API_ASSETS_LIST_V1_COMPATIBILITY' parameter
+func LoadAssetsNamesList(w http.ResponseWriter, r *http.Request, t *services.AccessToken) error {
+	slog.Info(fmt.Sprintf("attempt to load assets names list for user '%v'\n", t.UserUUID))
+	result := []string{}
+	// the name prefix of the API key is always the prefix of itself
+	query := services.AssetsListQuery{Limit: services.MaxAssetsListLimit, Prefix: t.NamePrefix}
+	for {
+		page, err := services.Instance().AssetsService.GetAssetList(t.UserUUID, query)
+		if err != nil {
+			return processAssetsListError(err)
+		}
+		for _, asset := range page.Assets {
+			result = append(result, asset.Name)
+		}
+		if len(page.NextCursor) == 0 {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+
+	return WriteJSON(w, http.StatusOK, AssetsNamesListResponse{result})
+}
+
+func parseAssetsListQuery(r *http.Request) (services.AssetsListQuery, error) {
+	params := r.URL.Query()
+	query := services.AssetsListQuery{
+		Cursor:    params.Get("cursor"),
+		Prefix:    params.Get("prefix"),
+		Delimiter: params.Get("delimiter"),
+		SortBy:    params.Get("sort"),
+	}
+
+	limitStr := params.Get("limit")
+	if len(limitStr) > 0 {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			return query, WithStatus(fmt.Errorf("wrong 'limit' parameter: %v", limitStr), "Parameter 'limit' should be a positive number", http.StatusBadRequest)
+		}
+		query.Limit = limit
+	}
+
+	switch params.Get("order") {
+	case "", "asc":
+		query.Descending = false
+	case "desc":
+		query.Descending = true
+	default:
+		return query, WithStatus(fmt.Errorf("wrong 'order' parameter: %v", params.Get("order")), "Parameter 'order' should be 'asc' or 'desc'", http.StatusBadRequest)
+	}
+
+	return query, nil
+}
+
+func processAssetsListError(err error) error {
+	switch {
+	case errors.Is(err, services.ErrWrongAssetsListQuery):
+		return WithStatus(err, err.Error(), http.StatusBadRequest)
+	default:
 		return WithStatus(err, InternalServerErrorMsg, http.StatusInternalServerError)
 	}
+}
 
-	return WriteJSON(w, http.StatusOK, AssetsListResponse{list})
+func toAssetInfo(asset services.Asset) AssetInfo {
+	return AssetInfo{
+		Name:        asset.Name,
+		Size:        asset.Size,
+		ContentType: asset.ContentType,
+		Checksum:    asset.Checksum,
+		CreateDate:  asset.CreateDate,
+		UpdateDate:  asset.UpdateDate,
+		Version:     asset.Version,
+	}
 }
 
 // swagger:route GET /api/asset/{name} assets LoadAsset
@@ -54,28 +149,54 @@ func LoadAssetsList(w http.ResponseWriter, r *http.Request, t *services.AccessTo
 //
 // responses:
 //   - 200: StatusResponse
+//   - 206: StatusResponse
+//   - 304: StatusResponse
 //   - 404: ErrorResponse
 //   - 500: ErrorResponse
 func LoadAsset(w http.ResponseWriter, r *http.Request, t *services.AccessToken) error {
 	assetName := r.PathValue("name")
 	slog.Info(fmt.Sprintf("attempt to load asset '%v'\n", assetName))
+	disposition, err := parseDisposition(r)
+	if err != nil {
+		return err
+	}
 
-	var startStreaming services.StartStreamingFunc = func(content io.ReadSeeker) {
-		http.ServeContent(w, r, assetName, time.Now(), content)
+	ownerUuid, err := resolveAssetOwner(r, t, assetName, services.GrantPermissionRead)
+	if err != nil {
+		return err
 	}
-	err := services.Instance().AssetsService.GetAsset(assetName, t.UserUUID, startStreaming)
+
+	// the conditional requests are answered by the metadata without opening the content
+	asset, err := services.Instance().AssetsService.GetAssetInfo(assetName, ownerUuid)
 	if err != nil {
-		switch {
-		case errors.Is(err, services.ErrNotFoundAsset):
-			return WithStatus(err, AssetNotFoundMsg, http.StatusNotFound)
-		default:
-			return WithStatus(err, InternalServerErrorMsg, http.StatusInternalServerError)
-		}
+		return processLoadAssetError(err)
+	}
+	if isNotModified(r, asset.ETag(), asset.UpdateDate) {
+		setAssetValidators(w, asset)
+		writeNotModified(w)
+		return nil
+	}
+
+	var startStreaming services.StartStreamingFunc = func(asset services.Asset, content io.ReadSeeker) {
+		serveAssetContent(w, r, asset, content, disposition)
+	}
+	err = services.Instance().AssetsService.GetAsset(assetName, ownerUuid, startStreaming)
+	if err != nil {
+		return processLoadAssetError(err)
 	}
 	// correct status code will be returned by http.ServeContent
 	return nil
 }
 
+func processLoadAssetError(err error) error {
+	switch {
+	case errors.Is(err, services.ErrNotFoundAsset):
+		return WithStatus(err, AssetNotFoundMsg, http.StatusNotFound)
+	default:
+		return WithStatus(err, InternalServerErrorMsg, http.StatusInternalServerError)
+	}
+}
+
 // swagger:route DELETE /api/asset/{name} assets DeleteAsset
 //
 // # Delete users's asset by name
@@ -94,8 +215,12 @@ func LoadAsset(w http.ResponseWriter, r *http.Request, t *services.AccessToken)
 func DeleteAsset(w http.ResponseWriter, r *http.Request, t *services.AccessToken) error {
 	assetName := r.PathValue("name")
 	slog.Info(fmt.Sprintf("attempt to delete asset '%v'\n", assetName))
+	err := checkNamePrefix(t, assetName)
+	if err != nil {
+		return err
+	}
 
-	err := services.Instance().AssetsService.DeleteAsset(assetName, t.UserUUID)
+	err = services.Instance().AssetsService.DeleteAsset(assetName, t.UserUUID)
 	if err != nil {
 		switch {
 		case errors.Is(err, services.ErrNotFoundAsset):
@@ -112,6 +237,9 @@ func DeleteAsset(w http.ResponseWriter, r *http.Request, t *services.AccessToken
 //
 // # Store asset
 //
+// Multipart uploads are atomic by default: either all parts are stored or none of them.
+// With 'mode=best-effort' each part is stored independently and the status of each part is returned.
+//
 // ---
 // Produces:
 //   - application/json
@@ -124,21 +252,44 @@ func DeleteAsset(w http.ResponseWriter, r *http.Request, t *services.AccessToken
 //
 // responses:
 //   - 201: StatusResponse
+//   - 207: AssetUploadResultsResponse
 //   - 400: ErrorResponse
+//   - 403: ErrorResponse
+//   - 413: ErrorResponse
 //   - 500: ErrorResponse
 func StoreAsset(w http.ResponseWriter, r *http.Request, t *services.AccessToken) error {
 	assetName := r.PathValue("name")
 	contentType := r.Header.Get("Content-Type")
 	if strings.HasPrefix(contentType, "multipart/form-data") {
 		slog.Info("special case of mime type: multipart/form-data")
-		err := storeMultipartedAssets(contentType, r, t)
-		if err != nil {
-			return processStoreAsserError(err)
+		mode := r.URL.Query().Get("mode")
+		switch mode {
+		case "", MultipartModeAtomic:
+			err := storeMultipartedAssets(contentType, r, t)
+			if err != nil {
+				return processStoreAsserError(err)
+			}
+		case MultipartModeBestEffort:
+			results, err := storeMultipartedAssetsBestEffort(contentType, r, t)
+			if err != nil {
+				return processStoreAsserError(err)
+			}
+			return WriteJSON(w, http.StatusMultiStatus, AssetUploadResultsResponse{results})
+		default:
+			return WithStatus(fmt.Errorf("unknown multipart mode '%v'", mode), "Unknown multipart mode", http.StatusBadRequest)
 		}
 	} else {
 		slog.Info("default case for others mime types")
 		slog.Info(fmt.Sprintf("attempt to store asset '%v'\n", assetName))
-		err := storeOneAsset(assetName, r.Body, t)
+		digests, err := parseContentDigests(r.Header)
+		if err != nil {
+			return err
+		}
+		labels, err := parseAssetLabels(r.Header)
+		if err != nil {
+			return err
+		}
+		err = storeOneAsset(assetName, contentType, digests, labels, r.Body, t)
 		if err != nil {
 			return processStoreAsserError(err)
 		}
@@ -147,16 +298,165 @@ func StoreAsset(w http.ResponseWriter, r *http.Request, t *services.AccessToken)
 	return WriteJSON(w, http.StatusCreated, StatusResponse{"ok"})
 }
 
-func storeOneAsset(assetName string, reader io.Reader, t *services.AccessToken) error {
-	return services.Instance().AssetsService.CreateAsset(assetName, t.UserUUID, reader)
+// swagger:route PUT /api/asset/{name} assets ReplaceAsset
+//
+// # Create or atomically replace asset
+//
+// Supports 'If-Match' and 'If-None-Match: *' preconditions.
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Consumes:
+//   - any
+//
+// Security:
+// - Bearer: []
+//
+// responses:
+//   - 200: StatusResponse
+//   - 201: StatusResponse
+//   - 403: ErrorResponse
+//   - 409: ErrorResponse
+//   - 412: ErrorResponse
+//   - 413: ErrorResponse
+//   - 500: ErrorResponse
+func ReplaceAsset(w http.ResponseWriter, r *http.Request, t *services.AccessToken) error {
+	assetName := r.PathValue("name")
+	slog.Info(fmt.Sprintf("attempt to replace asset '%v'\n", assetName))
+	digests, err := parseContentDigests(r.Header)
+	if err != nil {
+		return err
+	}
+	labels, err := parseAssetLabels(r.Header)
+	if err != nil {
+		return err
+	}
+	ownerUuid, err := resolveAssetOwner(r, t, assetName, services.GrantPermissionReadWrite)
+	if err != nil {
+		return err
+	}
+
+	upload := services.AssetUpload{
+		Name:        assetName,
+		ContentType: r.Header.Get("Content-Type"),
+		Content:     r.Body,
+		Digests:     digests,
+		Labels:      labels,
+	}
+	asset, created, err := services.Instance().AssetsService.ReplaceAsset(ownerUuid, upload, parseAssetPrecondition(r))
+	if err != nil {
+		return processReplaceAssetError(err)
+	}
+
+	setAssetValidators(w, asset)
+	if created {
+		return WriteJSON(w, http.StatusCreated, StatusResponse{"ok"})
+	}
+	return WriteJSON(w, http.StatusOK, StatusResponse{"ok"})
+}
+
+// parseAssetPrecondition makes the precondition from 'If-Match' and 'If-None-Match' headers, it is nil if there are no such headers
+func parseAssetPrecondition(r *http.Request) services.AssetPrecondition {
+	ifMatch := r.Header.Get("If-Match")
+	ifNoneMatch := r.Header.Get("If-None-Match")
+	if len(ifMatch) == 0 && len(ifNoneMatch) == 0 {
+		return nil
+	}
+	return func(current *services.Asset) bool {
+		if len(ifMatch) > 0 && (current == nil || !etagListMatches(ifMatch, current.ETag(), false)) {
+			return false
+		}
+		if len(ifNoneMatch) > 0 && current != nil && etagListMatches(ifNoneMatch, current.ETag(), true) {
+			return false
+		}
+		return true
+	}
+}
+
+func processReplaceAssetError(err error) error {
+	switch {
+	case errors.Is(err, services.ErrDigestMismatch):
+		return WithStatus(err, DigestMismatchMsg, http.StatusBadRequest)
+	case errors.Is(err, services.ErrWrongAssetName):
+		return WithStatus(err, WrongAssetNameMsg, http.StatusBadRequest)
+	case errors.Is(err, services.ErrWrongAssetLabels):
+		return WithStatus(err, WrongAssetLabelsMsg, http.StatusBadRequest)
+	case errors.Is(err, services.ErrPreconditionFailed):
+		return WithStatus(err, PreconditionFailedMsg, http.StatusPreconditionFailed)
+	case errors.Is(err, services.ErrDuplicateAsset):
+		return WithStatus(err, err.Error(), http.StatusConflict)
+	case errors.Is(err, services.ErrDeletedOwner):
+		return WithStatus(err, GroupNotFoundMsg, http.StatusNotFound)
+	case errors.Is(err, services.ErrQuotaExceeded):
+		return processQuotaError(err)
+	default:
+		return WithStatus(err, InternalServerErrorMsg, http.StatusInternalServerError)
+	}
+}
+
+func storeOneAsset(assetName string, contentType string, digests services.ContentDigests, labels *services.AssetLabels, reader io.Reader, t *services.AccessToken) error {
+	err := checkNamePrefix(t, assetName)
+	if err != nil {
+		return err
+	}
+	_, err = services.Instance().AssetsService.CreateAsset(t.UserUUID, services.AssetUpload{
+		Name:        assetName,
+		ContentType: contentType,
+		Content:     reader,
+		Digests:     digests,
+		Labels:      labels,
+	})
+	return err
 }
 
+// storeMultipartedAssets stores all parts in the single transaction
 func storeMultipartedAssets(contentType string, r *http.Request, t *services.AccessToken) error {
 	boundaryString, err := parseBoundaryString(contentType)
 	if err != nil {
 		return err
 	}
 
+	partReader := multipart.NewReader(r.Body, boundaryString)
+	_, err = services.Instance().AssetsService.CreateAssets(t.UserUUID, func() (services.AssetUpload, error) {
+		p, err := partReader.NextPart()
+		if err != nil {
+			return services.AssetUpload{}, err
+		}
+		miltipartedAssetName := parseMultipartAssetName(p)
+		slog.Info(fmt.Sprintf("attempt to store mutliparted asset '%v'\n", miltipartedAssetName))
+		err = checkNamePrefix(t, miltipartedAssetName)
+		if err != nil {
+			return services.AssetUpload{}, err
+		}
+		digests, err := parseContentDigests(http.Header(p.Header))
+		if err != nil {
+			return services.AssetUpload{}, err
+		}
+		labels, err := parseAssetLabels(http.Header(p.Header))
+		if err != nil {
+			return services.AssetUpload{}, err
+		}
+		return services.AssetUpload{
+			Name:        miltipartedAssetName,
+			ContentType: p.Header.Get("Content-Type"),
+			Content:     p,
+			Digests:     digests,
+			Labels:      labels,
+		}, nil
+	})
+	return err
+}
+
+// storeMultipartedAssetsBestEffort stores each part in its own transaction, the failed parts do not prevent storing the next ones
+func storeMultipartedAssetsBestEffort(contentType string, r *http.Request, t *services.AccessToken) ([]AssetUploadResult, error) {
+	boundaryString, err := parseBoundaryString(contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []AssetUploadResult{}
 	partReader := multipart.NewReader(r.Body, boundaryString)
 	for {
 		p, err := partReader.NextPart()
@@ -164,31 +464,83 @@ func storeMultipartedAssets(contentType string, r *http.Request, t *services.Acc
 			break
 		}
 		if err != nil {
-			return err
+			slog.Error(fmt.Sprintf("unable to read multipart body: %v", err))
+			results = append(results, AssetUploadResult{Status: AssetUploadStatusFailed, Reason: MalformedMultipartBodyMsg})
+			break
 		}
 		miltipartedAssetName := parseMultipartAssetName(p)
 		slog.Info(fmt.Sprintf("attempt to store mutliparted asset '%v'\n", miltipartedAssetName))
-		err = storeOneAsset(miltipartedAssetName, p, t)
-		if err != nil {
-			return err
+		digests, err := parseContentDigests(http.Header(p.Header))
+		var labels *services.AssetLabels
+		if err == nil {
+			labels, err = parseAssetLabels(http.Header(p.Header))
+		}
+		if err == nil {
+			err = storeOneAsset(miltipartedAssetName, p.Header.Get("Content-Type"), digests, labels, p, t)
 		}
+		results = append(results, toAssetUploadResult(miltipartedAssetName, err))
 	}
-	return nil
+	return results, nil
+}
+
+func toAssetUploadResult(assetName string, err error) AssetUploadResult {
+	result := AssetUploadResult{Name: assetName, Status: AssetUploadStatusStored}
+	if err == nil {
+		return result
+	}
+	if errors.Is(err, services.ErrDuplicateAsset) {
+		result.Status = AssetUploadStatusDuplicate
+		result.Reason = AssetDuplicateMsg
+		return result
+	}
+	result.Status = AssetUploadStatusFailed
+	result.Reason = InternalServerErrorMsg
+	var statusErr statusError
+	if errors.As(processStoreAsserError(err), &statusErr) {
+		if statusErr.Status() == http.StatusInternalServerError {
+			slog.Error(err.Error())
+		}
+		result.Reason = statusErr.message
+	}
+	return result
 }
 
+// parseMultipartAssetName keeps the folders of the file name, which are dropped by multipart.Part.FileName
 func parseMultipartAssetName(p *multipart.Part) string {
-	fileName := p.FileName()
-	if len(fileName) > 0 {
-		return fileName
-	} else {
-		return p.FormName()
+	_, params, err := mime.ParseMediaType(p.Header.Get("Content-Disposition"))
+	if err == nil && len(params["filename"]) > 0 {
+		return params["filename"]
 	}
+	return p.FormName()
 }
 
 func processStoreAsserError(err error) error {
 	switch {
+	case errors.Is(err, services.ErrDigestMismatch):
+		return WithStatus(err, DigestMismatchMsg, http.StatusBadRequest)
+	case errors.Is(err, services.ErrWrongAssetName):
+		return WithStatus(err, WrongAssetNameMsg, http.StatusBadRequest)
+	case errors.Is(err, services.ErrWrongAssetLabels):
+		return WithStatus(err, WrongAssetLabelsMsg, http.StatusBadRequest)
 	case errors.Is(err, services.ErrDuplicateAsset):
 		return WithStatus(err, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, services.ErrDeletedOwner):
+		return WithStatus(err, GroupNotFoundMsg, http.StatusNotFound)
+	case errors.Is(err, services.ErrQuotaExceeded):
+		return processQuotaError(err)
+	case errors.Is(err, services.ErrUnknownArchiveFormat):
+		return WithStatus(err, UnknownArchiveFormatMsg, http.StatusUnsupportedMediaType)
+	case errors.Is(err, services.ErrMalformedArchive):
+		return WithStatus(err, MalformedArchiveMsg, http.StatusBadRequest)
+	case errors.Is(err, services.ErrArchiveLimitExceeded):
+		return WithStatus(err, ArchiveLimitExceededMsg, http.StatusBadRequest)
+	case errors.Is(err, services.ErrUnsafeArchiveEntry):
+		return WithStatus(err, UnsafeArchiveEntryMsg, http.StatusBadRequest)
+	case errors.Is(err, services.ErrUnsupportedArchiveEntry):
+		return WithStatus(err, UnsupportedArchiveEntryMsg, http.StatusBadRequest)
+	case errors.As(err, new(StatusError)):
+		// the errors of the parts, e.g. the malformed digests, have the status already
+		return err
 	default:
 		return WithStatus(err, InternalServerErrorMsg, http.StatusInternalServerError)
 	}
@@ -205,7 +557,47 @@ func parseBoundaryString(contentTypeString string) (string, error) {
 	return result, nil
 }
 
-// swagger:parameters LoadAsset DeleteAsset
+// swagger:parameters LoadAssetsList
+type AssetsListParams struct {
+	// max count of the assets on the page, 100 by default, 1000 at most
+	//
+	// in: query
+	Limit int `json:"limit"`
+
+	// value of 'next_cursor' from the previous page
+	//
+	// in: query
+	Cursor string `json:"cursor"`
+
+	// only the assets which names start with the prefix
+	//
+	// in: query
+	Prefix string `json:"prefix"`
+
+	// the names which contain the delimiter after the prefix are rolled up into 'common_prefixes', e.g. '/' lists the folder,
+	// it is supported only for sorting by name
+	//
+	// in: query
+	Delimiter string `json:"delimiter"`
+
+	// sort field: 'name' (default), 'size' or 'create_date'
+	//
+	// in: query
+	Sort string `json:"sort"`
+
+	// sort order: 'asc' (default) or 'desc'
+	//
+	// in: query
+	Order string `json:"order"`
+
+	// login of the owner who shared the folder, the prefix should be inside the shared folder.
+	// The user's own assets are listed if it is missed
+	//
+	// in: query
+	Owner string `json:"owner"`
+}
+
+// swagger:parameters DeleteAsset
 type AssetsRequest struct {
 	// asset name
 	//
@@ -214,6 +606,74 @@ type AssetsRequest struct {
 	AssetName string `json:"name"`
 }
 
+// swagger:parameters LoadAsset
+type LoadAssetParams struct {
+	// asset name
+	//
+	// in: path
+	// required: true
+	AssetName string `json:"name"`
+
+	// 'Content-Disposition' of the content: 'inline' (default) or 'attachment'
+	//
+	// in: query
+	Disposition string `json:"disposition"`
+	// login of the owner who shared the asset, the user's own asset is loaded if it is missed
+	//
+	// in: query
+	Owner string `json:"owner"`
+}
+
+// swagger:parameters ReplaceAsset
+type ReplaceAssetParams struct {
+	// asset name
+	//
+	// in: path
+	// required: true
+	AssetName string `json:"name"`
+
+	// the asset is replaced only if its current ETag matches one of the listed ones
+	//
+	// in: header
+	IfMatch string `json:"If-Match"`
+
+	// '*' means that the asset is created only if it does not exist
+	//
+	// in: header
+	IfNoneMatch string `json:"If-None-Match"`
+
+	// login of the owner who shared the asset for reading and writing, the user's own asset is replaced if it is missed
+	//
+	// in: query
+	Owner string `json:"owner"`
+
+	// base64 encoded MD5 of the content
+	//
+	// in: header
+	ContentMD5 string `json:"Content-MD5"`
+
+	// digests of the content, e.g. 'sha-256=:base64:'
+	//
+	// in: header
+	ReprDigest string `json:"Repr-Digest"`
+
+	// comma-separated tags of the asset, the tags and the metadata of the replaced asset are kept if the labels headers are missed
+	//
+	// in: header
+	Tags string `json:"X-Asset-Tags"`
+
+	// metadata of the asset, one header per key, e.g. 'X-Asset-Meta-Build: 42'
+	//
+	// in: header
+	Meta string `json:"X-Asset-Meta-<key>"`
+
+	// asset data
+	//
+	// in: body
+	// required: true
+	Data *bytes.Buffer `json:"data"`
+}
+
 // swagger:parameters StoreAsset
 type CreateAssetParams struct {
 	// asset name
@@ -222,6 +682,31 @@ type CreateAssetParams struct {
 	// required: true
 	AssetName string `json:"name"`
 
+	// mode of the multipart upload: atomic (by default) or best-effort
+	//
+	// in: query
+	Mode string `json:"mode"`
+
+	// base64 encoded MD5 of the content
+	//
+	// in: header
+	ContentMD5 string `json:"Content-MD5"`
+
+	// digests of the content, e.g. 'sha-256=:base64:'
+	//
+	// in: header
+	ReprDigest string `json:"Repr-Digest"`
+
+	// comma-separated tags of the asset, the parts of the multipart upload have their own labels headers
+	//
+	// in: header
+	Tags string `json:"X-Asset-Tags"`
+
+	// metadata of the asset, one header per key, e.g. 'X-Asset-Meta-Build: 42'
+	//
+	// in: header
+	Meta string `json:"X-Asset-Meta-<key>"`
+
 	// asset data
 	//
 	// in: formData
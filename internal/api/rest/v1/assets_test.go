@@ -1,7 +1,13 @@
 package v1
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+
+	"github.com/ArtemVoronov/clearway-task-assets-service/internal/services"
 )
 
 func TestParseBoundaryString(t *testing.T) {
@@ -16,3 +22,95 @@ func TestParseBoundaryString(t *testing.T) {
 		t.Errorf("expected boundary string: %v", expectedBoundaryString)
 	}
 }
+
+func TestParseAssetPrecondition(t *testing.T) {
+	current := &services.Asset{Name: "file.txt", Checksum: "abc"}
+
+	r := httptest.NewRequest(http.MethodPut, "/api/asset/file.txt", nil)
+	if parseAssetPrecondition(r) != nil {
+		t.Errorf("expected empty precondition without headers")
+	}
+
+	r.Header.Set("If-Match", `"abc"`)
+	precondition := parseAssetPrecondition(r)
+	if !precondition(current) {
+		t.Errorf("expected 'If-Match' to match the current asset")
+	}
+	if precondition(nil) {
+		t.Errorf("expected 'If-Match' to fail for the missed asset")
+	}
+
+	r.Header.Del("If-Match")
+	r.Header.Set("If-None-Match", "*")
+	precondition = parseAssetPrecondition(r)
+	if precondition(current) {
+		t.Errorf("expected 'If-None-Match: *' to fail for the existing asset")
+	}
+	if !precondition(nil) {
+		t.Errorf("expected 'If-None-Match: *' to match the missed asset")
+	}
+}
+
+func TestToAssetUploadResult(t *testing.T) {
+	tests := []struct {
+		err            error
+		expectedStatus string
+		expectedReason string
+	}{
+		{nil, AssetUploadStatusStored, ""},
+		{fmt.Errorf("upload asset 'file.txt' error: %w", services.ErrDuplicateAsset), AssetUploadStatusDuplicate, AssetDuplicateMsg},
+		{fmt.Errorf("unable to store: %w", services.ErrFileSizeQuotaExceeded), AssetUploadStatusFailed, FileSizeQuotaExceededMsg},
+		{errors.New("connection lost"), AssetUploadStatusFailed, InternalServerErrorMsg},
+	}
+
+	for _, test := range tests {
+		actual := toAssetUploadResult("file.txt", test.err)
+		if actual.Name != "file.txt" {
+			t.Errorf("expected name: file.txt, actual: %v", actual.Name)
+		}
+		if actual.Status != test.expectedStatus {
+			t.Errorf("expected status: %v, actual: %v", test.expectedStatus, actual.Status)
+		}
+		if actual.Reason != test.expectedReason {
+			t.Errorf("expected reason: %v, actual: %v", test.expectedReason, actual.Reason)
+		}
+	}
+}
+
+func TestAssetPathHandler(t *testing.T) {
+	assetRoutes := http.NewServeMux()
+	assetRoutes.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "asset %v", r.PathValue("name"))
+	})
+	assetRoutes.HandleFunc("GET /versions/{version}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "version %v of %v", r.PathValue("version"), r.PathValue("name"))
+	})
+	routes := http.NewServeMux()
+	routes.Handle("/api/asset/{path...}", NewAssetPathHandler(assetRoutes))
+	routes.HandleFunc("GET /api/asset/{name}/versions/{version}", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "single segment version %v of %v", r.PathValue("version"), r.PathValue("name"))
+	})
+
+	cases := []struct {
+		path     string
+		expected string
+	}{
+		{"/api/asset/file.txt", "asset file.txt"},
+		{"/api/asset/docs/2024/report.csv", "asset docs/2024/report.csv"},
+		{"/api/asset/docs/2024/report.csv/-/versions/2", "version 2 of docs/2024/report.csv"},
+		{"/api/asset/file.txt/versions/2", "single segment version 2 of file.txt"},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+		if w.Body.String() != c.expected {
+			t.Errorf("expected response for %v: %v, actual: %v", c.path, c.expected, w.Body.String())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	routes.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/asset/docs/report.csv", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status: %v, actual: %v", http.StatusMethodNotAllowed, w.Code)
+	}
+}
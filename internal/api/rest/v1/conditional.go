@@ -0,0 +1,76 @@
+package v1
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ArtemVoronov/clearway-task-assets-service/internal/services"
+)
+
+func setAssetValidators(w http.ResponseWriter, asset services.Asset) {
+	h := w.Header()
+	etag := asset.ETag()
+	if len(etag) > 0 {
+		h.Set("ETag", etag)
+	}
+	if !asset.UpdateDate.IsZero() {
+		h.Set("Last-Modified", asset.UpdateDate.UTC().Format(http.TimeFormat))
+	}
+}
+
+// isNotModified evaluates 'If-None-Match' and 'If-Modified-Since' preconditions of GET and HEAD requests (see RFC 9110, section 13.2.2)
+func isNotModified(r *http.Request, etag string, modtime time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	ifNoneMatch := r.Header.Get("If-None-Match")
+	if len(ifNoneMatch) > 0 {
+		return etagListMatches(ifNoneMatch, etag, true)
+	}
+	ifModifiedSince := r.Header.Get("If-Modified-Since")
+	if len(ifModifiedSince) == 0 || modtime.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(ifModifiedSince)
+	if err != nil {
+		return false
+	}
+	// the precision of 'Last-Modified' is one second
+	return !modtime.Truncate(time.Second).After(since)
+}
+
+// etagListMatches checks the value of 'If-Match' or 'If-None-Match' header against the current entity tag,
+// the weak comparison is used for 'If-None-Match' and the strong one for 'If-Match'
+func etagListMatches(headerValue string, etag string, weakComparison bool) bool {
+	if strings.TrimSpace(headerValue) == "*" {
+		return true
+	}
+	if len(etag) == 0 {
+		return false
+	}
+	for _, candidate := range strings.Split(headerValue, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if strings.HasPrefix(candidate, "W/") {
+			if !weakComparison {
+				continue
+			}
+			candidate = candidate[2:]
+		}
+		if candidate == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+func writeNotModified(w http.ResponseWriter) {
+	h := w.Header()
+	h.Del("Content-Type")
+	h.Del("Content-Length")
+	h.Del("Content-Encoding")
+	if len(h.Get("ETag")) > 0 {
+		h.Del("Last-Modified")
+	}
+	w.WriteHeader(http.StatusNotModified)
+}
@@ -0,0 +1,51 @@
+package v1
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestEtagListMatches(t *testing.T) {
+	etag := `"2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"`
+	cases := []struct {
+		header   string
+		weak     bool
+		expected bool
+	}{
+		{`*`, false, true},
+		{`"other", ` + etag, false, true},
+		{`W/` + etag, true, true},
+		{`W/` + etag, false, false},
+		{`"other"`, true, false},
+	}
+	for _, c := range cases {
+		actual := etagListMatches(c.header, etag, c.weak)
+		if actual != c.expected {
+			t.Errorf("header: %v, weak: %v, expected: %v, actual: %v", c.header, c.weak, c.expected, actual)
+		}
+	}
+}
+
+func TestIsNotModified(t *testing.T) {
+	etag := `"abc"`
+	modtime := time.Date(2024, 7, 1, 10, 0, 0, 500, time.UTC)
+
+	r := httptest.NewRequest(http.MethodGet, "/api/asset/file.txt", nil)
+	r.Header.Set("If-Modified-Since", modtime.Format(http.TimeFormat))
+	if !isNotModified(r, etag, modtime) {
+		t.Errorf("expected not modified by 'If-Modified-Since'")
+	}
+
+	// 'If-None-Match' takes precedence over 'If-Modified-Since'
+	r.Header.Set("If-None-Match", `"other"`)
+	if isNotModified(r, etag, modtime) {
+		t.Errorf("expected modified by 'If-None-Match'")
+	}
+
+	r.Header.Set("If-None-Match", etag)
+	if !isNotModified(r, etag, modtime) {
+		t.Errorf("expected not modified by 'If-None-Match'")
+	}
+}
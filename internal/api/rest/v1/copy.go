@@ -0,0 +1,177 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ArtemVoronov/clearway-task-assets-service/internal/services"
+)
+
+// Target of the copying or the renaming of the asset
+//
+// swagger:model AssetTarget
+type AssetTarget struct {
+	// name of the new asset
+	//
+	// required: true
+	// example: "report-copy.csv"
+	Name string `json:"name"`
+
+	// replace the existing asset with the same name, otherwise the name collision is an error
+	// example: false
+	Overwrite bool `json:"overwrite"`
+}
+
+// swagger:route POST /api/asset/{name}/copy assets CopyAsset
+//
+// # Copy users's asset without downloading it
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Consumes:
+//   - application/json
+//
+// Security:
+// - Bearer: []
+//
+// responses:
+//   - 200: StatusResponse
+//   - 201: StatusResponse
+//   - 400: ErrorResponse
+//   - 403: ErrorResponse
+//   - 404: ErrorResponse
+//   - 409: ErrorResponse
+//   - 413: ErrorResponse
+//   - 500: ErrorResponse
+func CopyAsset(w http.ResponseWriter, r *http.Request, t *services.AccessToken) error {
+	assetName := r.PathValue("name")
+	target, err := parseAssetTarget(r)
+	if err != nil {
+		return err
+	}
+	slog.Info(fmt.Sprintf("attempt to copy asset '%v' to '%v'\n", assetName, target.Name))
+	err = checkNamePrefix(t, assetName, target.Name)
+	if err != nil {
+		return err
+	}
+
+	asset, created, err := services.Instance().AssetsService.CopyAsset(assetName, t.UserUUID, target.Name, target.Overwrite)
+	if err != nil {
+		return processAssetTargetError(err)
+	}
+
+	setAssetValidators(w, asset)
+	w.Header().Set("Location", assetLocation(r, target.Name))
+	if created {
+		return WriteJSON(w, http.StatusCreated, StatusResponse{"ok"})
+	}
+	return WriteJSON(w, http.StatusOK, StatusResponse{"ok"})
+}
+
+// swagger:route POST /api/asset/{name}/rename assets RenameAsset
+//
+// # Rename users's asset
+//
+// The history of the versions is moved together with the asset, it follows the history of the new name if the name has it.
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Consumes:
+//   - application/json
+//
+// Security:
+// - Bearer: []
+//
+// responses:
+//   - 200: StatusResponse
+//   - 400: ErrorResponse
+//   - 404: ErrorResponse
+//   - 409: ErrorResponse
+//   - 500: ErrorResponse
+func RenameAsset(w http.ResponseWriter, r *http.Request, t *services.AccessToken) error {
+	assetName := r.PathValue("name")
+	target, err := parseAssetTarget(r)
+	if err != nil {
+		return err
+	}
+	slog.Info(fmt.Sprintf("attempt to rename asset '%v' to '%v'\n", assetName, target.Name))
+	err = checkNamePrefix(t, assetName, target.Name)
+	if err != nil {
+		return err
+	}
+
+	asset, err := services.Instance().AssetsService.RenameAsset(assetName, t.UserUUID, target.Name, target.Overwrite)
+	if err != nil {
+		return processAssetTargetError(err)
+	}
+
+	setAssetValidators(w, asset)
+	w.Header().Set("Location", assetLocation(r, target.Name))
+	return WriteJSON(w, http.StatusOK, StatusResponse{"ok"})
+}
+
+// assetLocation returns the path of the asset of the user or of the group of the request
+func assetLocation(r *http.Request, name string) string {
+	groupId := r.PathValue(GroupPathValue)
+	if len(groupId) > 0 {
+		return "/api/groups/" + url.PathEscape(groupId) + "/assets/" + escapeAssetName(name)
+	}
+	return "/api/asset/" + escapeAssetName(name)
+}
+
+func parseAssetTarget(r *http.Request) (AssetTarget, error) {
+	var target AssetTarget
+	defer r.Body.Close()
+	err := json.NewDecoder(r.Body).Decode(&target)
+	if err != nil {
+		return target, WithStatus(err, "Expected json body with 'name' parameter", http.StatusBadRequest)
+	}
+	if len(strings.TrimSpace(target.Name)) == 0 {
+		return target, WithStatus(fmt.Errorf("missed target name"), "Missed 'name' parameter. Expected json body with it", http.StatusBadRequest)
+	}
+	return target, nil
+}
+
+func processAssetTargetError(err error) error {
+	switch {
+	case errors.Is(err, services.ErrNotFoundAsset):
+		return WithStatus(err, AssetNotFoundMsg, http.StatusNotFound)
+	case errors.Is(err, services.ErrDuplicateAsset):
+		return WithStatus(err, AssetDuplicateMsg, http.StatusConflict)
+	case errors.Is(err, services.ErrWrongAssetName):
+		return WithStatus(err, WrongAssetNameMsg, http.StatusBadRequest)
+	case errors.Is(err, services.ErrSameAssetName):
+		return WithStatus(err, SameAssetNameMsg, http.StatusBadRequest)
+	case errors.Is(err, services.ErrDeletedOwner):
+		return WithStatus(err, GroupNotFoundMsg, http.StatusNotFound)
+	case errors.Is(err, services.ErrQuotaExceeded):
+		return processQuotaError(err)
+	default:
+		return WithStatus(err, InternalServerErrorMsg, http.StatusInternalServerError)
+	}
+}
+
+// swagger:parameters CopyAsset RenameAsset
+type AssetTargetRequest struct {
+	// asset name
+	//
+	// in: path
+	// required: true
+	AssetName string `json:"name"`
+
+	// target of the asset
+	//
+	// in: body
+	// required: true
+	// example: {"name": "report-copy.csv", "overwrite": false}
+	Target *AssetTarget `json:"Target"`
+}
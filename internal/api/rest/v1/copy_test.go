@@ -0,0 +1,45 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseAssetTarget(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/api/asset/file.txt/copy", strings.NewReader(`{"name": "copy.txt", "overwrite": true}`))
+	target, err := parseAssetTarget(r)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if target.Name != "copy.txt" || !target.Overwrite {
+		t.Errorf("expected target: copy.txt with overwrite, actual: %v", target)
+	}
+
+	for _, body := range []string{"", "not json", `{"overwrite": true}`, `{"name": "  "}`} {
+		r = httptest.NewRequest(http.MethodPost, "/api/asset/file.txt/copy", strings.NewReader(body))
+		_, err = parseAssetTarget(r)
+		var statusErr StatusError
+		if !errors.As(err, &statusErr) || statusErr.Status() != http.StatusBadRequest {
+			t.Errorf("expected bad request for body '%v', actual: %v", body, err)
+		}
+	}
+}
+
+func TestAssetLocation(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/api/asset/report.csv/rename", nil)
+	expected := "/api/asset/docs/report%201.csv%3F%23"
+	actual := assetLocation(r, "docs/report 1.csv?#")
+	if actual != expected {
+		t.Errorf("expected location: %v, actual: %v", expected, actual)
+	}
+
+	r.SetPathValue(GroupPathValue, "group-1")
+	expected = "/api/groups/group-1/assets/docs/report%201.csv%3F%23"
+	actual = assetLocation(r, "docs/report 1.csv?#")
+	if actual != expected {
+		t.Errorf("expected location: %v, actual: %v", expected, actual)
+	}
+}
@@ -0,0 +1,105 @@
+package v1
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ArtemVoronov/clearway-task-assets-service/internal/services"
+)
+
+// parseContentDigests collects the digests of the uploaded content from 'Content-MD5' (RFC 1864), 'Digest' (RFC 3230)
+// and 'Repr-Digest' (RFC 9530) headers, the unknown algorithms are ignored
+func parseContentDigests(h http.Header) (services.ContentDigests, error) {
+	var result services.ContentDigests
+	contentMD5 := h.Get("Content-MD5")
+	if len(contentMD5) > 0 {
+		err := setDigest(&result.MD5, "Content-MD5", strings.TrimSpace(contentMD5), md5.Size)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	for _, item := range splitHeaderList(h.Values("Digest")) {
+		algorithm, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return result, wrongDigestError("Digest", item)
+		}
+		var err error
+		switch strings.ToLower(strings.TrimSpace(algorithm)) {
+		case "sha-256":
+			err = setDigest(&result.SHA256, "Digest", strings.TrimSpace(value), sha256.Size)
+		case "md5":
+			err = setDigest(&result.MD5, "Digest", strings.TrimSpace(value), md5.Size)
+		}
+		if err != nil {
+			return result, err
+		}
+	}
+
+	// the values of the structured dictionary are byte sequences, e.g. 'sha-256=:base64:'
+	for _, item := range splitHeaderList(h.Values("Repr-Digest")) {
+		algorithm, value, ok := strings.Cut(item, "=")
+		value, _, _ = strings.Cut(value, ";")
+		value = strings.TrimSpace(value)
+		if !ok || len(value) < 2 || value[0] != ':' || value[len(value)-1] != ':' {
+			return result, wrongDigestError("Repr-Digest", item)
+		}
+		value = value[1 : len(value)-1]
+		var err error
+		switch strings.TrimSpace(algorithm) {
+		case "sha-256":
+			err = setDigest(&result.SHA256, "Repr-Digest", value, sha256.Size)
+		case "md5":
+			err = setDigest(&result.MD5, "Repr-Digest", value, md5.Size)
+		}
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// setDigest decodes base64 digest, the digests of the same algorithm from the different headers have to be equal
+func setDigest(target *[]byte, header string, value string, size int) error {
+	digest, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(digest) != size {
+		return wrongDigestError(header, value)
+	}
+	if len(*target) > 0 && !bytes.Equal(*target, digest) {
+		return WithStatus(fmt.Errorf("conflicting digests in '%v' header: %v", header, value), ConflictingDigestsMsg, http.StatusBadRequest)
+	}
+	*target = digest
+	return nil
+}
+
+func wrongDigestError(header string, value string) error {
+	return WithStatus(fmt.Errorf("wrong '%v' header: %v", header, value), fmt.Sprintf("Header '%v' is malformed", header), http.StatusBadRequest)
+}
+
+func splitHeaderList(values []string) []string {
+	result := []string{}
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			item = strings.TrimSpace(item)
+			if len(item) > 0 {
+				result = append(result, item)
+			}
+		}
+	}
+	return result
+}
+
+// reprDigest makes 'Repr-Digest' header (see RFC 9530) by the hex encoded SHA-256 of the asset, it is empty if the checksum is unknown
+func reprDigest(checksum string) string {
+	digest, err := hex.DecodeString(checksum)
+	if err != nil || len(digest) != sha256.Size {
+		return ""
+	}
+	return fmt.Sprintf("sha-256=:%v:", base64.StdEncoding.EncodeToString(digest))
+}
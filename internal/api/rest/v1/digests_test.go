@@ -0,0 +1,58 @@
+package v1
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"testing"
+)
+
+func TestParseContentDigests(t *testing.T) {
+	sha := sha256.Sum256([]byte("content"))
+	md := md5.Sum([]byte("content"))
+	shaBase64 := base64.StdEncoding.EncodeToString(sha[:])
+	mdBase64 := base64.StdEncoding.EncodeToString(md[:])
+
+	h := http.Header{}
+	h.Set("Content-MD5", mdBase64)
+	h.Set("Digest", "SHA-256="+shaBase64+", unixsum=30637")
+	h.Set("Repr-Digest", "sha-256=:"+shaBase64+":, sha-512=:AAAA:")
+	digests, err := parseContentDigests(h)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if hex.EncodeToString(digests.SHA256) != hex.EncodeToString(sha[:]) || hex.EncodeToString(digests.MD5) != hex.EncodeToString(md[:]) {
+		t.Errorf("expected digests of the content, actual: %v", digests)
+	}
+
+	malformed := [][]string{
+		{"Content-MD5", "not base64"},
+		{"Digest", "SHA-256=" + mdBase64},
+		{"Repr-Digest", "sha-256=" + shaBase64},
+		{"Digest", "md5=" + mdBase64, "Content-MD5", base64.StdEncoding.EncodeToString(make([]byte, md5.Size))},
+	}
+	for _, headers := range malformed {
+		h := http.Header{}
+		for i := 0; i < len(headers); i += 2 {
+			h.Set(headers[i], headers[i+1])
+		}
+		_, err = parseContentDigests(h)
+		if err == nil {
+			t.Errorf("expected error for headers: %v", h)
+		}
+	}
+}
+
+func TestReprDigest(t *testing.T) {
+	sha := sha256.Sum256([]byte("content"))
+	expected := "sha-256=:" + base64.StdEncoding.EncodeToString(sha[:]) + ":"
+	actual := reprDigest(hex.EncodeToString(sha[:]))
+	if actual != expected {
+		t.Errorf("expected: %v, actual: %v", expected, actual)
+	}
+	if reprDigest("") != "" {
+		t.Errorf("expected empty digest for unknown checksum")
+	}
+}
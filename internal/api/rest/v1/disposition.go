@@ -0,0 +1,60 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+)
+
+const (
+	DispositionInline     = "inline"
+	DispositionAttachment = "attachment"
+)
+
+// parseDisposition reads 'disposition' parameter of the download, the content is shown inline by default
+func parseDisposition(r *http.Request) (string, error) {
+	disposition := r.URL.Query().Get("disposition")
+	switch disposition {
+	case "":
+		return DispositionInline, nil
+	case DispositionInline, DispositionAttachment:
+		return disposition, nil
+	default:
+		return "", WithStatus(fmt.Errorf("wrong 'disposition' parameter: %v", disposition), "Parameter 'disposition' should be 'inline' or 'attachment'", http.StatusBadRequest)
+	}
+}
+
+// contentDisposition makes 'Content-Disposition' header (see RFC 6266) with the last segment of the name as the file name.
+// The 'filename' parameter keeps only ASCII characters for the old clients, the exact name is passed by 'filename*' (see RFC 8187).
+func contentDisposition(disposition string, name string) string {
+	filename := path.Base(name)
+	return fmt.Sprintf("%v; filename=\"%v\"; filename*=UTF-8''%v", disposition, asciiFilename(filename), encodeExtValue(filename))
+}
+
+func asciiFilename(filename string) string {
+	var b strings.Builder
+	for _, r := range filename {
+		if r < 0x20 || r > 0x7e || r == '"' || r == '\\' {
+			b.WriteByte('_')
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// encodeExtValue percent-encodes all bytes except attr-char of RFC 8187
+func encodeExtValue(value string) string {
+	const attrChars = "!#$&+-.^_`|~"
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte(attrChars, c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
@@ -0,0 +1,22 @@
+package v1
+
+import "testing"
+
+func TestContentDisposition(t *testing.T) {
+	tests := []struct {
+		disposition string
+		name        string
+		expected    string
+	}{
+		{DispositionInline, "report.csv", `inline; filename="report.csv"; filename*=UTF-8''report.csv`},
+		{DispositionAttachment, "docs/2024/report final.csv", `attachment; filename="report final.csv"; filename*=UTF-8''report%20final.csv`},
+		{DispositionAttachment, "отчёт.txt", `attachment; filename="_____.txt"; filename*=UTF-8''%D0%BE%D1%82%D1%87%D1%91%D1%82.txt`},
+		{DispositionAttachment, `say "hi".txt`, `attachment; filename="say _hi_.txt"; filename*=UTF-8''say%20%22hi%22.txt`},
+	}
+	for _, test := range tests {
+		actual := contentDisposition(test.disposition, test.name)
+		if actual != test.expected {
+			t.Errorf("expected '%v' for name '%v', actual: '%v'", test.expected, test.name, actual)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package v1
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ArtemVoronov/clearway-task-assets-service/internal/services"
+)
+
+// serveAssetContent streams the content of the asset, the compressed content is passed as is if the client accepts its encoding,
+// otherwise it is decompressed on the fly. The range requests are always served by the decompressed content.
+// The stored content type is served as is, so http.ServeContent neither guesses it by the name nor sniffs it.
+func serveAssetContent(w http.ResponseWriter, r *http.Request, asset services.Asset, content io.ReadSeeker, disposition string) {
+	setAssetValidators(w, asset)
+	h := w.Header()
+	if len(asset.ContentType) > 0 {
+		h.Set("Content-Type", asset.ContentType)
+	}
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("Content-Disposition", contentDisposition(disposition, asset.Name))
+	encoded, ok := content.(services.EncodedContent)
+	if !ok {
+		setReprDigest(h, asset)
+		http.ServeContent(w, r, asset.Name, asset.UpdateDate, content)
+		return
+	}
+
+	h.Add("Vary", "Accept-Encoding")
+	raw, encoding := encoded.Raw()
+	if len(r.Header.Get("Range")) > 0 || !acceptsEncoding(r.Header.Get("Accept-Encoding"), encoding) {
+		setReprDigest(h, asset)
+		http.ServeContent(w, r, asset.Name, asset.UpdateDate, content)
+		return
+	}
+
+	// the checksum is calculated by the decoded content, so it is not the digest of the encoded representation
+	// and 'Repr-Digest' is omitted
+	h.Set("Content-Encoding", encoding)
+	// the compressed representation differs from the original one, so it has its own entity tag
+	if etag := asset.ETag(); len(etag) > 0 {
+		h.Set("ETag", strings.TrimSuffix(etag, "\"")+"-"+encoding+"\"")
+	}
+	http.ServeContent(w, r, asset.Name, asset.UpdateDate, raw)
+}
+
+// setReprDigest sets the digest of the whole decoded content regardless of the range
+func setReprDigest(h http.Header, asset services.Asset) {
+	if digest := reprDigest(asset.Checksum); len(digest) > 0 {
+		h.Set("Repr-Digest", digest)
+	}
+}
+
+// acceptsEncoding checks 'Accept-Encoding' header (see RFC 9110, section 12.5.3)
+func acceptsEncoding(header string, encoding string) bool {
+	accepted := false
+	for _, item := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(item), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != encoding && coding != "*" {
+			continue
+		}
+		quality := 1.0
+		params = strings.TrimSpace(params)
+		if value, ok := strings.CutPrefix(params, "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err == nil {
+				quality = parsed
+			}
+		}
+		// the exact coding overrides the wildcard
+		if coding == encoding {
+			return quality > 0
+		}
+		accepted = quality > 0
+	}
+	return accepted
+}
@@ -0,0 +1,68 @@
+package v1
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ArtemVoronov/clearway-task-assets-service/internal/services"
+)
+
+func TestAcceptsEncoding(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected bool
+	}{
+		{"", false},
+		{"gzip", true},
+		{"deflate, gzip;q=0.5", true},
+		{"br, deflate", false},
+		{"*", true},
+		{"*, gzip;q=0", false},
+		{"GZIP", true},
+	}
+	for _, test := range tests {
+		actual := acceptsEncoding(test.header, "gzip")
+		if actual != test.expected {
+			t.Errorf("expected %v for header '%v', actual: %v", test.expected, test.header, actual)
+		}
+	}
+}
+
+type encodedContentStub struct {
+	*bytes.Reader
+	raw *bytes.Reader
+}
+
+func (c encodedContentStub) Raw() (io.ReadSeeker, string) {
+	return c.raw, "gzip"
+}
+
+func TestServeAssetContentReprDigest(t *testing.T) {
+	asset := services.Asset{Name: "file.txt", Checksum: hex.EncodeToString(make([]byte, sha256.Size))}
+	tests := []struct {
+		acceptEncoding string
+		rangeHeader    string
+		expected       bool
+	}{
+		{"", "", true},
+		{"gzip", "", false},
+		{"gzip", "bytes=0-1", true},
+	}
+	for _, test := range tests {
+		content := encodedContentStub{bytes.NewReader([]byte("content")), bytes.NewReader([]byte("encoded"))}
+		r := httptest.NewRequest(http.MethodGet, "/api/asset/file.txt", nil)
+		r.Header.Set("Accept-Encoding", test.acceptEncoding)
+		r.Header.Set("Range", test.rangeHeader)
+		w := httptest.NewRecorder()
+		serveAssetContent(w, r, asset, content, "attachment")
+		actual := len(w.Header().Get("Repr-Digest")) > 0
+		if actual != test.expected {
+			t.Errorf("expected Repr-Digest presence %v for Accept-Encoding '%v' and Range '%v', actual: %v", test.expected, test.acceptEncoding, test.rangeHeader, actual)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/ArtemVoronov/clearway-task-assets-service/internal/services"
+)
+
+// Success folder change response
+// swagger:response FolderChangeResponse
+type FolderChangeResponse struct {
+	// count of the deleted or moved assets including the assets of the subfolders
+	// example: 12
+	Count int64 `json:"count"`
+}
+
+// swagger:route DELETE /api/folder/{name} folders DeleteFolder
+//
+// # Delete users's folder with its subfolders
+//
+// All assets which names start with the folder name and '/' are moved into the trash.
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Security:
+// - Bearer: []
+//
+// responses:
+//   - 200: FolderChangeResponse
+//   - 400: ErrorResponse
+//   - 404: ErrorResponse
+//   - 500: ErrorResponse
+func DeleteFolder(w http.ResponseWriter, r *http.Request, t *services.AccessToken) error {
+	folder := r.PathValue("name")
+	slog.Info(fmt.Sprintf("attempt to delete folder '%v'\n", folder))
+	err := checkFolderPrefix(t, folder)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := services.Instance().AssetsService.DeleteFolder(folder, t.UserUUID)
+	if err != nil {
+		return processFolderError(err)
+	}
+
+	return WriteJSON(w, http.StatusOK, FolderChangeResponse{deleted})
+}
+
+// swagger:route POST /api/folder/{name}/-/move folders MoveFolder
+//
+// # Move users's folder with its subfolders
+//
+// The assets keep their paths relative to the target folder, the target folder should not overlap the folder.
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Consumes:
+//   - application/json
+//
+// Security:
+// - Bearer: []
+//
+// responses:
+//   - 200: FolderChangeResponse
+//   - 400: ErrorResponse
+//   - 404: ErrorResponse
+//   - 409: ErrorResponse
+//   - 500: ErrorResponse
+func MoveFolder(w http.ResponseWriter, r *http.Request, t *services.AccessToken) error {
+	folder := r.PathValue("name")
+	target, err := parseAssetTarget(r)
+	if err != nil {
+		return err
+	}
+	slog.Info(fmt.Sprintf("attempt to move folder '%v' to '%v'\n", folder, target.Name))
+	err = checkFolderPrefix(t, folder, target.Name)
+	if err != nil {
+		return err
+	}
+
+	moved, err := services.Instance().AssetsService.MoveFolder(folder, t.UserUUID, target.Name, target.Overwrite)
+	if err != nil {
+		return processFolderError(err)
+	}
+
+	return WriteJSON(w, http.StatusOK, FolderChangeResponse{moved})
+}
+
+func processFolderError(err error) error {
+	switch {
+	case errors.Is(err, services.ErrNotFoundFolder):
+		return WithStatus(err, FolderNotFoundMsg, http.StatusNotFound)
+	case errors.Is(err, services.ErrWrongAssetName):
+		return WithStatus(err, WrongAssetNameMsg, http.StatusBadRequest)
+	case errors.Is(err, services.ErrFolderOverlap):
+		return WithStatus(err, FolderOverlapMsg, http.StatusBadRequest)
+	case errors.Is(err, services.ErrDuplicateAsset):
+		return WithStatus(err, AssetDuplicateMsg, http.StatusConflict)
+	default:
+		return WithStatus(err, InternalServerErrorMsg, http.StatusInternalServerError)
+	}
+}
+
+// swagger:parameters DeleteFolder
+type FolderRequest struct {
+	// folder name, it could contain '/'
+	//
+	// in: path
+	// required: true
+	FolderName string `json:"name"`
+}
+
+// swagger:parameters MoveFolder
+type MoveFolderRequest struct {
+	// folder name, it could contain '/'
+	//
+	// in: path
+	// required: true
+	FolderName string `json:"name"`
+
+	// target folder
+	//
+	// in: body
+	// required: true
+	// example: {"name": "archive/2024", "overwrite": false}
+	Target *AssetTarget `json:"Target"`
+}
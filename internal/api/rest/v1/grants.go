@@ -0,0 +1,356 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ArtemVoronov/clearway-task-assets-service/internal/services"
+)
+
+// Grant of the access to the asset or the folder for another user
+//
+// swagger:model GrantRequest
+type GrantRequest struct {
+	// name of the asset or the folder
+	//
+	// required: true
+	// example: "docs/report.csv"
+	Name string `json:"name"`
+
+	// the name is the folder, the grant covers all assets of the folder and its subfolders
+	// example: false
+	Folder bool `json:"folder"`
+
+	// login of the user
+	//
+	// required: true
+	// example: "bob"
+	Grantee string `json:"grantee"`
+
+	// 'read' or 'read-write'
+	//
+	// required: true
+	// example: "read"
+	Permission string `json:"permission"`
+}
+
+// Grant of the access to the asset or the folder
+//
+// swagger:model GrantInfo
+type GrantInfo struct {
+	// id of the grant
+	// example: 42
+	Id int64 `json:"id"`
+
+	// login of the owner of the asset
+	// example: "alice"
+	Owner string `json:"owner"`
+
+	// login of the user who is granted the access
+	// example: "bob"
+	Grantee string `json:"grantee"`
+
+	// name of the asset or the folder
+	// example: "docs/report.csv"
+	Name string `json:"name"`
+
+	// the name is the folder
+	// example: false
+	Folder bool `json:"folder"`
+
+	// 'read' or 'read-write'
+	// example: "read"
+	Permission string `json:"permission"`
+
+	// creation time
+	// example: "2024-07-01T10:00:00Z"
+	CreateDate time.Time `json:"create_date"`
+}
+
+// Asset or folder shared with the user
+//
+// swagger:model SharedItem
+type SharedItem struct {
+	GrantInfo
+
+	// the shared asset, it is missed for the folders and the assets deleted by the owner
+	Asset *AssetInfo `json:"asset,omitempty"`
+}
+
+// Success create grant response
+// swagger:response GrantResponse
+type GrantResponse struct {
+	GrantInfo
+}
+
+// Success get grants response
+// swagger:response GrantsResponse
+type GrantsResponse struct {
+	// grants of the user in the order of creation
+	Grants []GrantInfo `json:"grants"`
+}
+
+// Success get shared items response
+// swagger:response SharedResponse
+type SharedResponse struct {
+	// assets and folders shared with the user, sorted by the owner and the name
+	Items []SharedItem `json:"items"`
+}
+
+// swagger:route POST /api/grants grants CreateGrant
+//
+// # Share users's asset or folder with another user
+//
+// The repeated grant of the same asset or folder to the same user changes its permission.
+// The grant is bound to the name, so it covers the asset created with the same name later.
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Consumes:
+//   - application/json
+//
+// Security:
+// - Bearer: []
+//
+// responses:
+//   - 200: GrantResponse
+//   - 400: ErrorResponse
+//   - 404: ErrorResponse
+//   - 500: ErrorResponse
+func CreateGrant(w http.ResponseWriter, r *http.Request, t *services.AccessToken) error {
+	request, err := parseGrantRequest(r)
+	if err != nil {
+		return err
+	}
+	slog.Info(fmt.Sprintf("attempt to grant '%v' access to '%v' for user '%v'\n", request.Permission, request.Name, request.Grantee))
+
+	if !request.Folder {
+		_, err = services.Instance().AssetsService.GetAssetInfo(request.Name, t.UserUUID)
+		if err != nil {
+			return processLoadAssetError(err)
+		}
+	}
+
+	grant, err := services.Instance().GrantsService.CreateGrant(t.UserUUID, request.Grantee, request.Name, request.Folder, request.Permission)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrWrongGrantPermission):
+			return WithStatus(err, WrongGrantPermissionMsg, http.StatusBadRequest)
+		case errors.Is(err, services.ErrWrongAssetName):
+			return WithStatus(err, WrongAssetNameMsg, http.StatusBadRequest)
+		case errors.Is(err, services.ErrSelfGrant):
+			return WithStatus(err, SelfGrantMsg, http.StatusBadRequest)
+		case errors.Is(err, services.ErrUserNotFound):
+			return WithStatus(err, UserNotFoundMsg, http.StatusNotFound)
+		default:
+			return WithStatus(err, InternalServerErrorMsg, http.StatusInternalServerError)
+		}
+	}
+
+	return WriteJSON(w, http.StatusOK, GrantResponse{toGrantInfo(grant)})
+}
+
+func parseGrantRequest(r *http.Request) (GrantRequest, error) {
+	var request GrantRequest
+	b, err := io.ReadAll(r.Body)
+	defer r.Body.Close()
+	if err != nil {
+		return request, WithStatus(err, InternalServerErrorMsg, http.StatusInternalServerError)
+	}
+	err = json.Unmarshal(b, &request)
+	if err != nil {
+		return request, WithStatus(err, "Expected json body with 'name', 'grantee' and 'permission' parameters", http.StatusBadRequest)
+	}
+	if len(strings.TrimSpace(request.Name)) == 0 {
+		return request, WithStatus(fmt.Errorf("missed 'name' parameter"), "Missed 'name' parameter. Expected json body with it", http.StatusBadRequest)
+	}
+	if len(strings.TrimSpace(request.Grantee)) == 0 {
+		return request, WithStatus(fmt.Errorf("missed 'grantee' parameter"), "Missed 'grantee' parameter. Expected json body with it", http.StatusBadRequest)
+	}
+	return request, nil
+}
+
+// swagger:route GET /api/grants grants LoadGrants
+//
+// # Get the grants of users's assets and folders to other users
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Security:
+// - Bearer: []
+//
+// responses:
+//   - 200: GrantsResponse
+//   - 500: ErrorResponse
+func LoadGrants(w http.ResponseWriter, r *http.Request, t *services.AccessToken) error {
+	slog.Info(fmt.Sprintf("attempt to load grants of user '%v'\n", t.UserUUID))
+	grants, err := services.Instance().GrantsService.GetGrants(t.UserUUID)
+	if err != nil {
+		return WithStatus(err, InternalServerErrorMsg, http.StatusInternalServerError)
+	}
+
+	result := make([]GrantInfo, 0, len(grants))
+	for _, grant := range grants {
+		result = append(result, toGrantInfo(grant))
+	}
+
+	return WriteJSON(w, http.StatusOK, GrantsResponse{result})
+}
+
+// swagger:route DELETE /api/grants/{id} grants DeleteGrant
+//
+// # Revoke the grant of users's asset or folder
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Security:
+// - Bearer: []
+//
+// responses:
+//   - 200: StatusResponse
+//   - 400: ErrorResponse
+//   - 404: ErrorResponse
+//   - 500: ErrorResponse
+func DeleteGrant(w http.ResponseWriter, r *http.Request, t *services.AccessToken) error {
+	idStr := r.PathValue("id")
+	grantId, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return WithStatus(err, "Id should be a number", http.StatusBadRequest)
+	}
+	slog.Info(fmt.Sprintf("attempt to delete grant %v\n", grantId))
+
+	err = services.Instance().GrantsService.DeleteGrant(t.UserUUID, grantId)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrNotFoundGrant):
+			return WithStatus(err, GrantNotFoundMsg, http.StatusNotFound)
+		default:
+			return WithStatus(err, InternalServerErrorMsg, http.StatusInternalServerError)
+		}
+	}
+
+	return WriteJSON(w, http.StatusOK, StatusResponse{"ok"})
+}
+
+// swagger:route GET /api/shared grants LoadShared
+//
+// # Get the assets and folders shared with the user
+//
+// The shared assets are loaded by the routes of the assets with 'owner' parameter, e.g. '/api/asset/{name}?owner=alice'.
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Security:
+// - Bearer: []
+//
+// responses:
+//   - 200: SharedResponse
+//   - 500: ErrorResponse
+func LoadShared(w http.ResponseWriter, r *http.Request, t *services.AccessToken) error {
+	slog.Info(fmt.Sprintf("attempt to load assets shared with user '%v'\n", t.UserUUID))
+	grants, err := services.Instance().GrantsService.GetSharedGrants(t.UserUUID)
+	if err != nil {
+		return WithStatus(err, InternalServerErrorMsg, http.StatusInternalServerError)
+	}
+
+	result := make([]SharedItem, 0, len(grants))
+	for _, grant := range grants {
+		item := SharedItem{GrantInfo: toGrantInfo(grant)}
+		if !grant.Folder {
+			// the asset is kept on the shard of the owner
+			asset, err := services.Instance().AssetsService.GetAssetInfo(grant.Name, grant.OwnerUuid)
+			switch {
+			case err == nil:
+				info := toAssetInfo(asset)
+				item.Asset = &info
+			case !errors.Is(err, services.ErrNotFoundAsset):
+				return WithStatus(err, InternalServerErrorMsg, http.StatusInternalServerError)
+			}
+		}
+		result = append(result, item)
+	}
+
+	return WriteJSON(w, http.StatusOK, SharedResponse{result})
+}
+
+// resolveAssetOwner returns the uuid of the owner set by 'owner' parameter, the user is the owner if the parameter is missed.
+// The assets of another user are accessible only by the grant, the missed grant looks like the missed asset.
+func resolveAssetOwner(r *http.Request, t *services.AccessToken, name string, permission string) (string, error) {
+	return resolveOwner(r, t, name, false, permission)
+}
+
+// resolveFolderOwner is the same as resolveAssetOwner for the prefix of the names, only the grants of the folders cover it
+func resolveFolderOwner(r *http.Request, t *services.AccessToken, prefix string) (string, error) {
+	return resolveOwner(r, t, prefix, true, services.GrantPermissionRead)
+}
+
+func resolveOwner(r *http.Request, t *services.AccessToken, name string, folderOnly bool, permission string) (string, error) {
+	err := checkNamePrefix(t, name)
+	if err != nil {
+		return "", err
+	}
+	ownerLogin := r.URL.Query().Get("owner")
+	if len(ownerLogin) == 0 {
+		return t.UserUUID, nil
+	}
+	ownerUuid, err := services.Instance().GrantsService.CheckAccess(ownerLogin, t.UserUUID, name, folderOnly, permission)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrNotFoundGrant) && folderOnly:
+			return "", WithStatus(err, FolderNotFoundMsg, http.StatusNotFound)
+		case errors.Is(err, services.ErrNotFoundGrant):
+			return "", WithStatus(err, AssetNotFoundMsg, http.StatusNotFound)
+		case errors.Is(err, services.ErrReadOnlyGrant):
+			return "", WithStatus(err, ReadOnlyGrantMsg, http.StatusForbidden)
+		default:
+			return "", WithStatus(err, InternalServerErrorMsg, http.StatusInternalServerError)
+		}
+	}
+	return ownerUuid, nil
+}
+
+func toGrantInfo(grant services.Grant) GrantInfo {
+	return GrantInfo{
+		Id:         grant.Id,
+		Owner:      grant.OwnerLogin,
+		Grantee:    grant.GranteeLogin,
+		Name:       grant.Name,
+		Folder:     grant.Folder,
+		Permission: grant.Permission,
+		CreateDate: grant.CreateDate,
+	}
+}
+
+// swagger:parameters DeleteGrant
+type GrantRequestParams struct {
+	// id of the grant
+	//
+	// in: path
+	// required: true
+	Id int64 `json:"id"`
+}
+
+// swagger:parameters CreateGrant
+type CreateGrantParams struct {
+	// grant of the asset or the folder
+	//
+	// in: body
+	// required: true
+	// example: {"name": "docs/report.csv", "folder": false, "grantee": "bob", "permission": "read"}
+	Grant *GrantRequest `json:"Grant"`
+}
@@ -0,0 +1,16 @@
+package v1
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ArtemVoronov/clearway-task-assets-service/internal/services"
+)
+
+func TestResolveOwnAsset(t *testing.T) {
+	r := httptest.NewRequest("GET", "/api/asset/docs/report.csv", nil)
+	owner, err := resolveAssetOwner(r, &services.AccessToken{UserUUID: "user"}, "docs/report.csv", services.GrantPermissionReadWrite)
+	if err != nil || owner != "user" {
+		t.Errorf("expected owner: user, actual: %v, error: %v", owner, err)
+	}
+}
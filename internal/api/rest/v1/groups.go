@@ -0,0 +1,447 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ArtemVoronov/clearway-task-assets-service/internal/services"
+)
+
+// GroupPathValue is the path value of the group id, it is kept by GroupPathHandler for the nested routes
+const GroupPathValue = "group"
+
+// Settings of the group
+//
+// swagger:model GroupRequest
+type GroupRequest struct {
+	// name of the group
+	//
+	// required: true
+	// example: "design team"
+	Name string `json:"name"`
+}
+
+// Group of the users
+//
+// swagger:model GroupInfo
+type GroupInfo struct {
+	// id of the group
+	// example: "0b2c7c3e-5d1a-4f8e-9a51-0c6d2f3e4a5b"
+	Id string `json:"id"`
+
+	// name of the group
+	// example: "design team"
+	Name string `json:"name"`
+
+	// role of the user in the group: 'viewer', 'editor' or 'admin'
+	// example: "admin"
+	Role string `json:"role"`
+
+	// creation time
+	// example: "2024-07-01T10:00:00Z"
+	CreateDate time.Time `json:"create_date"`
+}
+
+// Role of the member of the group
+//
+// swagger:model GroupMemberRequest
+type GroupMemberRequest struct {
+	// 'viewer', 'editor' or 'admin'
+	//
+	// required: true
+	// example: "editor"
+	Role string `json:"role"`
+}
+
+// Member of the group
+//
+// swagger:model GroupMemberInfo
+type GroupMemberInfo struct {
+	// login of the user
+	// example: "bob"
+	Login string `json:"login"`
+
+	// 'viewer', 'editor' or 'admin'
+	// example: "editor"
+	Role string `json:"role"`
+
+	// time of joining the group
+	// example: "2024-07-01T10:00:00Z"
+	CreateDate time.Time `json:"create_date"`
+}
+
+// Success create group response
+// swagger:response GroupResponse
+type GroupResponse struct {
+	GroupInfo
+}
+
+// Success get groups response
+// swagger:response GroupsResponse
+type GroupsResponse struct {
+	// groups of the user sorted by the name
+	Groups []GroupInfo `json:"groups"`
+}
+
+// Success set group member response
+// swagger:response GroupMemberResponse
+type GroupMemberResponse struct {
+	GroupMemberInfo
+}
+
+// Success get group members response
+// swagger:response GroupMembersResponse
+type GroupMembersResponse struct {
+	// members of the group sorted by the login
+	Members []GroupMemberInfo `json:"members"`
+}
+
+// swagger:route POST /api/groups groups CreateGroup
+//
+// # Create the group with the user as its admin
+//
+// The group has its own assets, they are accessible by the routes '/api/groups/{id}/assets' and '/api/groups/{id}/folder'
+// which are the same as the routes of the user's assets: the viewers read the assets, the editors change them too
+// and the admins manage the members of the group.
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Consumes:
+//   - application/json
+//
+// Security:
+// - Bearer: []
+//
+// responses:
+//   - 201: GroupResponse
+//   - 400: ErrorResponse
+//   - 500: ErrorResponse
+func CreateGroup(w http.ResponseWriter, r *http.Request, t *services.AccessToken) error {
+	var request GroupRequest
+	err := parseGroupJSON(r, &request, "name")
+	if err != nil {
+		return err
+	}
+	slog.Info(fmt.Sprintf("attempt to create group '%v' for user '%v'\n", request.Name, t.UserUUID))
+
+	group, err := services.Instance().GroupsService.CreateGroup(t.UserUUID, request.Name)
+	if err != nil {
+		return processGroupError(err)
+	}
+
+	return WriteJSON(w, http.StatusCreated, GroupResponse{toGroupInfo(group)})
+}
+
+// swagger:route GET /api/groups groups LoadGroups
+//
+// # Get the groups of the user with the user's roles
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Security:
+// - Bearer: []
+//
+// responses:
+//   - 200: GroupsResponse
+//   - 500: ErrorResponse
+func LoadGroups(w http.ResponseWriter, r *http.Request, t *services.AccessToken) error {
+	slog.Info(fmt.Sprintf("attempt to load groups of user '%v'\n", t.UserUUID))
+	groups, err := services.Instance().GroupsService.GetGroups(t.UserUUID)
+	if err != nil {
+		return WithStatus(err, InternalServerErrorMsg, http.StatusInternalServerError)
+	}
+
+	result := make([]GroupInfo, 0, len(groups))
+	for _, group := range groups {
+		result = append(result, toGroupInfo(group))
+	}
+
+	return WriteJSON(w, http.StatusOK, GroupsResponse{result})
+}
+
+// swagger:route DELETE /api/groups/{id} groups DeleteGroup
+//
+// # Delete the group
+//
+// Only the admins delete the group and only without the assets, the trashed assets and the unfinished uploads.
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Security:
+// - Bearer: []
+//
+// responses:
+//   - 200: StatusResponse
+//   - 403: ErrorResponse
+//   - 404: ErrorResponse
+//   - 409: ErrorResponse
+//   - 500: ErrorResponse
+func DeleteGroup(w http.ResponseWriter, r *http.Request, t *services.AccessToken) error {
+	groupId := r.PathValue("id")
+	slog.Info(fmt.Sprintf("attempt to delete group '%v'\n", groupId))
+
+	// the content is kept on the shard of the group, so it is checked and the group is marked as deleted there
+	// while the group is locked for the deletion
+	var deleteContent services.GroupContentDeletion = func(groupUuid string) (func(), error) {
+		err := services.Instance().AssetsService.DeleteOwner(groupUuid)
+		if errors.Is(err, services.ErrOwnerHasContent) {
+			return nil, fmt.Errorf("group '%v' error: %w", groupUuid, services.ErrGroupNotEmpty)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return func() {
+			restoreErr := services.Instance().AssetsService.RestoreOwner(groupUuid)
+			if restoreErr != nil {
+				slog.Error(fmt.Sprintf("unable to restore content of group '%v' after failed deletion: %v", groupUuid, restoreErr))
+			}
+		}, nil
+	}
+	err := services.Instance().GroupsService.DeleteGroup(t.UserUUID, groupId, deleteContent)
+	if err != nil {
+		return processGroupError(err)
+	}
+
+	return WriteJSON(w, http.StatusOK, StatusResponse{"ok"})
+}
+
+// swagger:route GET /api/groups/{id}/members groups LoadGroupMembers
+//
+// # Get the members of the group
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Security:
+// - Bearer: []
+//
+// responses:
+//   - 200: GroupMembersResponse
+//   - 404: ErrorResponse
+//   - 500: ErrorResponse
+func LoadGroupMembers(w http.ResponseWriter, r *http.Request, t *services.AccessToken) error {
+	groupId := r.PathValue("id")
+	slog.Info(fmt.Sprintf("attempt to load members of group '%v'\n", groupId))
+
+	members, err := services.Instance().GroupsService.GetMembers(t.UserUUID, groupId)
+	if err != nil {
+		return processGroupError(err)
+	}
+
+	result := make([]GroupMemberInfo, 0, len(members))
+	for _, member := range members {
+		result = append(result, toGroupMemberInfo(member))
+	}
+
+	return WriteJSON(w, http.StatusOK, GroupMembersResponse{result})
+}
+
+// swagger:route PUT /api/groups/{id}/members/{login} groups SetGroupMember
+//
+// # Add the user to the group or change the role of the member
+//
+// Only the admins manage the members, the last admin of the group could not lose the role.
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Consumes:
+//   - application/json
+//
+// Security:
+// - Bearer: []
+//
+// responses:
+//   - 200: GroupMemberResponse
+//   - 400: ErrorResponse
+//   - 403: ErrorResponse
+//   - 404: ErrorResponse
+//   - 409: ErrorResponse
+//   - 500: ErrorResponse
+func SetGroupMember(w http.ResponseWriter, r *http.Request, t *services.AccessToken) error {
+	groupId := r.PathValue("id")
+	login := r.PathValue("login")
+	var request GroupMemberRequest
+	err := parseGroupJSON(r, &request, "role")
+	if err != nil {
+		return err
+	}
+	slog.Info(fmt.Sprintf("attempt to set role '%v' of user '%v' in group '%v'\n", request.Role, login, groupId))
+
+	member, err := services.Instance().GroupsService.SetMember(t.UserUUID, groupId, login, request.Role)
+	if err != nil {
+		return processGroupError(err)
+	}
+
+	return WriteJSON(w, http.StatusOK, GroupMemberResponse{toGroupMemberInfo(member)})
+}
+
+// swagger:route DELETE /api/groups/{id}/members/{login} groups DeleteGroupMember
+//
+// # Remove the member from the group
+//
+// The admins remove any member, the other members could only leave the group themselves.
+// The last admin of the group could not leave it.
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Security:
+// - Bearer: []
+//
+// responses:
+//   - 200: StatusResponse
+//   - 403: ErrorResponse
+//   - 404: ErrorResponse
+//   - 409: ErrorResponse
+//   - 500: ErrorResponse
+func DeleteGroupMember(w http.ResponseWriter, r *http.Request, t *services.AccessToken) error {
+	groupId := r.PathValue("id")
+	login := r.PathValue("login")
+	slog.Info(fmt.Sprintf("attempt to remove user '%v' from group '%v'\n", login, groupId))
+
+	err := services.Instance().GroupsService.DeleteMember(t.UserUUID, groupId, login)
+	if err != nil {
+		return processGroupError(err)
+	}
+
+	return WriteJSON(w, http.StatusOK, StatusResponse{"ok"})
+}
+
+func parseGroupJSON(r *http.Request, request any, parameter string) error {
+	b, err := io.ReadAll(r.Body)
+	defer r.Body.Close()
+	if err != nil {
+		return WithStatus(err, InternalServerErrorMsg, http.StatusInternalServerError)
+	}
+	err = json.Unmarshal(b, request)
+	if err != nil {
+		return WithStatus(err, fmt.Sprintf("Expected json body with '%v' parameter", parameter), http.StatusBadRequest)
+	}
+	return nil
+}
+
+func processGroupError(err error) error {
+	switch {
+	case errors.Is(err, services.ErrNotFoundGroup):
+		return WithStatus(err, GroupNotFoundMsg, http.StatusNotFound)
+	case errors.Is(err, services.ErrUserNotFound):
+		return WithStatus(err, UserNotFoundMsg, http.StatusNotFound)
+	case errors.Is(err, services.ErrGroupRole):
+		return WithStatus(err, GroupRoleMsg, http.StatusForbidden)
+	case errors.Is(err, services.ErrWrongGroupRole):
+		return WithStatus(err, WrongGroupRoleMsg, http.StatusBadRequest)
+	case errors.Is(err, services.ErrWrongGroupName):
+		return WithStatus(err, WrongGroupNameMsg, http.StatusBadRequest)
+	case errors.Is(err, services.ErrLastGroupAdmin):
+		return WithStatus(err, LastGroupAdminMsg, http.StatusConflict)
+	case errors.Is(err, services.ErrGroupNotEmpty):
+		return WithStatus(err, GroupNotEmptyMsg, http.StatusConflict)
+	default:
+		return WithStatus(err, InternalServerErrorMsg, http.StatusInternalServerError)
+	}
+}
+
+// GroupPathHandler keeps the id of the group for the routes of the nested mux, which replaces the path values of the outer pattern
+type GroupPathHandler struct {
+	handler http.Handler
+}
+
+func (h *GroupPathHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	routed := r.Clone(r.Context())
+	routed.SetPathValue(GroupPathValue, r.PathValue("id"))
+	h.handler.ServeHTTP(w, routed)
+}
+
+func NewGroupPathHandler(handlerToWrap http.Handler) *GroupPathHandler {
+	return &GroupPathHandler{handlerToWrap}
+}
+
+// GroupRequired runs the handler of the user's assets for the assets of the group, the member of the group should have the role.
+// The uuid of the group takes the place of the uuid of the user, so the assets are kept on the shard of the group.
+func GroupRequired(handlerToWrap AuthenticateHandlerFunc, role string) AuthenticateHandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request, t *services.AccessToken) error {
+		groupUuid, err := services.Instance().GroupsService.CheckRole(r.PathValue(GroupPathValue), t.UserUUID, role)
+		if err != nil {
+			return processGroupError(err)
+		}
+		groupToken := *t
+		groupToken.UserUUID = groupUuid
+		return handlerToWrap(w, r, &groupToken)
+	}
+}
+
+func toGroupInfo(group services.Group) GroupInfo {
+	return GroupInfo{
+		Id:         group.Uuid,
+		Name:       group.Name,
+		Role:       group.Role,
+		CreateDate: group.CreateDate,
+	}
+}
+
+func toGroupMemberInfo(member services.GroupMember) GroupMemberInfo {
+	return GroupMemberInfo{
+		Login:      member.Login,
+		Role:       member.Role,
+		CreateDate: member.CreateDate,
+	}
+}
+
+// swagger:parameters DeleteGroup LoadGroupMembers
+type GroupRequestParams struct {
+	// id of the group
+	//
+	// in: path
+	// required: true
+	Id string `json:"id"`
+}
+
+// swagger:parameters SetGroupMember DeleteGroupMember
+type GroupMemberRequestParams struct {
+	// id of the group
+	//
+	// in: path
+	// required: true
+	Id string `json:"id"`
+
+	// login of the user
+	//
+	// in: path
+	// required: true
+	Login string `json:"login"`
+}
+
+// swagger:parameters CreateGroup
+type CreateGroupParams struct {
+	// settings of the group
+	//
+	// in: body
+	// required: true
+	// example: {"name": "design team"}
+	Group *GroupRequest `json:"Group"`
+}
+
+// swagger:parameters SetGroupMember
+type SetGroupMemberParams struct {
+	// role of the member
+	//
+	// in: body
+	// required: true
+	// example: {"role": "editor"}
+	Member *GroupMemberRequest `json:"Member"`
+}
@@ -0,0 +1,27 @@
+package v1
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGroupPathHandler(t *testing.T) {
+	var group, name string
+	inner := http.NewServeMux()
+	inner.HandleFunc("GET /versions", func(w http.ResponseWriter, r *http.Request) {
+		group = r.PathValue(GroupPathValue)
+		name = r.PathValue("name")
+	})
+	routes := http.NewServeMux()
+	routes.Handle("GET /api/groups/{id}/assets/{path...}", NewGroupPathHandler(NewAssetPathHandler(inner)))
+
+	r := httptest.NewRequest("GET", "/api/groups/0b2c7c3e-5d1a-4f8e-9a51-0c6d2f3e4a5b/assets/docs/report.csv/-/versions", nil)
+	routes.ServeHTTP(httptest.NewRecorder(), r)
+	if group != "0b2c7c3e-5d1a-4f8e-9a51-0c6d2f3e4a5b" {
+		t.Errorf("expected group id to be kept for the nested routes, actual: '%v'", group)
+	}
+	if name != "docs/report.csv" {
+		t.Errorf("expected asset name: 'docs/report.csv', actual: '%v'", name)
+	}
+}
@@ -0,0 +1,378 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/ArtemVoronov/clearway-task-assets-service/internal/services"
+)
+
+const (
+	// comma-separated tags of the uploaded asset
+	AssetTagsHeader = "X-Asset-Tags"
+	// the rest of the header name is the key of the metadata, e.g. 'X-Asset-Meta-Build: 42'
+	AssetMetaHeaderPrefix = "X-Asset-Meta-"
+
+	tagParam              = "tag"
+	tagPrefixParam        = "tag_prefix"
+	metaParamPrefix       = "meta."
+	metaPrefixParamPrefix = "meta_prefix."
+)
+
+// Tags and metadata of the asset
+//
+// swagger:response AssetLabelsResponse
+type AssetLabelsResponse struct {
+	// sorted tags
+	// example: ["nightly", "release"]
+	Tags []string `json:"tags"`
+
+	// user-defined metadata, the keys are lower case
+	// example: {"project": "billing", "build": "42"}
+	Metadata map[string]string `json:"metadata"`
+}
+
+// Change of the tags and the metadata of the asset
+//
+// swagger:model AssetLabelsPatchRequest
+type AssetLabelsPatchRequest struct {
+	// tags to add
+	// example: ["release"]
+	AddTags []string `json:"add_tags,omitempty"`
+
+	// tags to remove, they are removed after the added ones
+	// example: ["nightly"]
+	RemoveTags []string `json:"remove_tags,omitempty"`
+
+	// metadata to set, null value removes the key
+	// example: {"environment": "prod", "build": null}
+	Metadata map[string]*string `json:"metadata,omitempty"`
+}
+
+// Asset with its tags and metadata
+//
+// swagger:model LabeledAssetInfo
+type LabeledAssetInfo struct {
+	AssetInfo
+
+	// sorted tags
+	// example: ["nightly", "release"]
+	Tags []string `json:"tags"`
+
+	// user-defined metadata
+	// example: {"project": "billing", "build": "42"}
+	Metadata map[string]string `json:"metadata"`
+}
+
+// Success search assets response
+// swagger:response AssetsSearchResponse
+type AssetsSearchResponse struct {
+	// found assets
+	Assets []LabeledAssetInfo `json:"assets"`
+
+	// names of the folders up to the delimiter, they are returned only if the delimiter is requested
+	// example: ["builds/2024/"]
+	CommonPrefixes []string `json:"common_prefixes,omitempty"`
+
+	// cursor of the next page, it is missed for the last page
+	// example: "eyJzIjoibmFtZSIsImQiOmZhbHNlLCJ2IjoiIiwibiI6ImZpbGUzLnR4dCJ9"
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// swagger:route GET /api/asset/{name}/-/labels assets LoadAssetLabels
+//
+// # Get the tags and the metadata of users's asset
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Security:
+// - Bearer: []
+//
+// responses:
+//   - 200: AssetLabelsResponse
+//   - 403: ErrorResponse
+//   - 404: ErrorResponse
+//   - 500: ErrorResponse
+func LoadAssetLabels(w http.ResponseWriter, r *http.Request, t *services.AccessToken) error {
+	assetName := r.PathValue("name")
+	slog.Info(fmt.Sprintf("attempt to load labels of asset '%v'\n", assetName))
+	ownerUuid, err := resolveAssetOwner(r, t, assetName, services.GrantPermissionRead)
+	if err != nil {
+		return err
+	}
+
+	labels, err := services.Instance().AssetsService.GetAssetLabels(assetName, ownerUuid)
+	if err != nil {
+		return processLabelsError(err)
+	}
+
+	return WriteJSON(w, http.StatusOK, AssetLabelsResponse{Tags: labels.Tags, Metadata: labels.Metadata})
+}
+
+// swagger:route PATCH /api/asset/{name}/-/labels assets PatchAssetLabels
+//
+// # Change the tags and the metadata of users's asset
+//
+// The content of the asset and its version are not changed.
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Consumes:
+//   - application/json
+//
+// Security:
+// - Bearer: []
+//
+// responses:
+//   - 200: AssetLabelsResponse
+//   - 400: ErrorResponse
+//   - 403: ErrorResponse
+//   - 404: ErrorResponse
+//   - 500: ErrorResponse
+func PatchAssetLabels(w http.ResponseWriter, r *http.Request, t *services.AccessToken) error {
+	assetName := r.PathValue("name")
+	request, err := parseAssetLabelsPatch(r)
+	if err != nil {
+		return err
+	}
+	slog.Info(fmt.Sprintf("attempt to patch labels of asset '%v'\n", assetName))
+	ownerUuid, err := resolveAssetOwner(r, t, assetName, services.GrantPermissionReadWrite)
+	if err != nil {
+		return err
+	}
+
+	labels, err := services.Instance().AssetsService.PatchAssetLabels(assetName, ownerUuid, services.AssetLabelsPatch{
+		AddTags:    request.AddTags,
+		RemoveTags: request.RemoveTags,
+		Metadata:   request.Metadata,
+	})
+	if err != nil {
+		return processLabelsError(err)
+	}
+
+	return WriteJSON(w, http.StatusOK, AssetLabelsResponse{Tags: labels.Tags, Metadata: labels.Metadata})
+}
+
+func parseAssetLabelsPatch(r *http.Request) (AssetLabelsPatchRequest, error) {
+	var request AssetLabelsPatchRequest
+	b, err := io.ReadAll(r.Body)
+	defer r.Body.Close()
+	if err != nil {
+		return request, WithStatus(err, InternalServerErrorMsg, http.StatusInternalServerError)
+	}
+	err = json.Unmarshal(b, &request)
+	if err != nil {
+		return request, WithStatus(err, "Expected json body with 'add_tags', 'remove_tags' or 'metadata' parameters", http.StatusBadRequest)
+	}
+	return request, nil
+}
+
+func processLabelsError(err error) error {
+	switch {
+	case errors.Is(err, services.ErrNotFoundAsset):
+		return WithStatus(err, AssetNotFoundMsg, http.StatusNotFound)
+	case errors.Is(err, services.ErrWrongAssetLabels):
+		return WithStatus(err, WrongAssetLabelsMsg, http.StatusBadRequest)
+	default:
+		return WithStatus(err, InternalServerErrorMsg, http.StatusInternalServerError)
+	}
+}
+
+// swagger:route GET /api/assets/search assets SearchAssets
+//
+// # Search users's assets by the tags and the metadata
+//
+// All filters have to match. The page is the same as the page of the assets list, the assets are returned with their labels.
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Security:
+// - Bearer: []
+//
+// responses:
+//   - 200: AssetsSearchResponse
+//   - 400: ErrorResponse
+//   - 404: ErrorResponse
+//   - 500: ErrorResponse
+func SearchAssets(w http.ResponseWriter, r *http.Request, t *services.AccessToken) error {
+	slog.Info(fmt.Sprintf("attempt to search assets of user '%v'\n", t.UserUUID))
+	query, err := parseAssetsListQuery(r)
+	if err != nil {
+		return err
+	}
+	query.Tags, query.Metadata = parseLabelFilters(r.URL.Query())
+	query.Prefix, err = narrowPrefix(t, query.Prefix)
+	if err != nil {
+		return err
+	}
+	ownerUuid, err := resolveFolderOwner(r, t, query.Prefix)
+	if err != nil {
+		return err
+	}
+
+	page, err := services.Instance().AssetsService.GetAssetList(ownerUuid, query)
+	if err != nil {
+		return processAssetsListError(err)
+	}
+	names := make([]string, 0, len(page.Assets))
+	for _, asset := range page.Assets {
+		names = append(names, asset.Name)
+	}
+	labels, err := services.Instance().AssetsService.GetAssetsLabels(ownerUuid, names)
+	if err != nil {
+		return WithStatus(err, InternalServerErrorMsg, http.StatusInternalServerError)
+	}
+
+	result := make([]LabeledAssetInfo, 0, len(page.Assets))
+	for _, asset := range page.Assets {
+		result = append(result, LabeledAssetInfo{
+			AssetInfo: toAssetInfo(asset),
+			Tags:      labels[asset.Name].Tags,
+			Metadata:  labels[asset.Name].Metadata,
+		})
+	}
+
+	return WriteJSON(w, http.StatusOK, AssetsSearchResponse{Assets: result, CommonPrefixes: page.CommonPrefixes, NextCursor: page.NextCursor})
+}
+
+// parseLabelFilters collects the filters from 'tag', 'tag_prefix', 'meta.<key>' and 'meta_prefix.<key>' parameters,
+// the filters are validated by the service
+func parseLabelFilters(params url.Values) ([]services.TagFilter, []services.MetadataFilter) {
+	tags := []services.TagFilter{}
+	for _, tag := range params[tagParam] {
+		tags = append(tags, services.TagFilter{Tag: tag})
+	}
+	for _, tag := range params[tagPrefixParam] {
+		tags = append(tags, services.TagFilter{Tag: tag, Prefix: true})
+	}
+
+	metadata := []services.MetadataFilter{}
+	// the parameters are sorted to keep the same order of the conditions for the same request
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, param := range keys {
+		if key, ok := strings.CutPrefix(param, metaParamPrefix); ok {
+			for _, value := range params[param] {
+				metadata = append(metadata, services.MetadataFilter{Key: key, Value: value})
+			}
+		}
+		if key, ok := strings.CutPrefix(param, metaPrefixParamPrefix); ok {
+			for _, value := range params[param] {
+				metadata = append(metadata, services.MetadataFilter{Key: key, Value: value, Prefix: true})
+			}
+		}
+	}
+	return tags, metadata
+}
+
+// parseAssetLabels collects the labels of the uploaded asset from 'X-Asset-Tags' and 'X-Asset-Meta-<key>' headers,
+// they are nil if there are no such headers
+func parseAssetLabels(h http.Header) (*services.AssetLabels, error) {
+	var labels *services.AssetLabels
+	for name, values := range h {
+		name = http.CanonicalHeaderKey(name)
+		if name == AssetTagsHeader {
+			if labels == nil {
+				labels = &services.AssetLabels{Metadata: map[string]string{}}
+			}
+			labels.Tags = append(labels.Tags, splitHeaderList(values)...)
+			continue
+		}
+		key, ok := strings.CutPrefix(name, AssetMetaHeaderPrefix)
+		if !ok || len(values) == 0 {
+			continue
+		}
+		if len(values) > 1 {
+			return nil, WithStatus(fmt.Errorf("repeated '%v' header", name), fmt.Sprintf("Header '%v' should not be repeated", name), http.StatusBadRequest)
+		}
+		if labels == nil {
+			labels = &services.AssetLabels{Metadata: map[string]string{}}
+		}
+		labels.Metadata[strings.ToLower(key)] = strings.TrimSpace(values[0])
+	}
+	if labels == nil {
+		return nil, nil
+	}
+
+	normalized, err := services.NormalizeAssetLabels(*labels)
+	if err != nil {
+		return nil, WithStatus(err, WrongAssetLabelsMsg, http.StatusBadRequest)
+	}
+	return &normalized, nil
+}
+
+// swagger:parameters LoadAssetLabels
+type AssetLabelsRequest struct {
+	// asset name
+	//
+	// in: path
+	// required: true
+	AssetName string `json:"name"`
+
+	// login of the owner who shared the asset, the user's own asset is used if it is missed
+	//
+	// in: query
+	Owner string `json:"owner"`
+}
+
+// swagger:parameters PatchAssetLabels
+type PatchAssetLabelsParams struct {
+	// asset name
+	//
+	// in: path
+	// required: true
+	AssetName string `json:"name"`
+
+	// login of the owner who shared the asset for reading and writing, the user's own asset is changed if it is missed
+	//
+	// in: query
+	Owner string `json:"owner"`
+
+	// change of the labels
+	//
+	// in: body
+	// required: true
+	// example: {"add_tags": ["release"], "remove_tags": ["nightly"], "metadata": {"environment": "prod", "build": null}}
+	Patch *AssetLabelsPatchRequest `json:"Patch"`
+}
+
+// swagger:parameters SearchAssets
+type SearchAssetsParams struct {
+	AssetsListParams
+
+	// the assets with the tag, it could be repeated
+	//
+	// in: query
+	Tag []string `json:"tag"`
+
+	// the assets with any tag which starts with the value, it could be repeated
+	//
+	// in: query
+	TagPrefix []string `json:"tag_prefix"`
+
+	// the assets which metadata value of the key equals the parameter value, e.g. 'meta.project=billing'
+	//
+	// in: query
+	Meta string `json:"meta.<key>"`
+
+	// the assets which metadata value of the key starts with the parameter value, e.g. 'meta_prefix.build=42.'
+	//
+	// in: query
+	MetaPrefix string `json:"meta_prefix.<key>"`
+}
@@ -0,0 +1,58 @@
+package v1
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"testing"
+
+	"github.com/ArtemVoronov/clearway-task-assets-service/internal/services"
+)
+
+func TestParseAssetLabels(t *testing.T) {
+	labels, err := parseAssetLabels(http.Header{"Content-Type": {"text/plain"}})
+	if err != nil || labels != nil {
+		t.Errorf("expected nil labels without headers, actual: %v, error: %v", labels, err)
+	}
+
+	h := http.Header{}
+	h.Add("X-Asset-Tags", "release, nightly")
+	h.Add("X-Asset-Tags", "v1")
+	h.Set("X-Asset-Meta-Build-Number", " 42 ")
+	h.Set("X-Asset-Meta-Project", "billing")
+	labels, err = parseAssetLabels(h)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !slices.Equal(labels.Tags, []string{"nightly", "release", "v1"}) {
+		t.Errorf("unexpected tags: %v", labels.Tags)
+	}
+	if labels.Metadata["build-number"] != "42" || labels.Metadata["project"] != "billing" {
+		t.Errorf("unexpected metadata: %v", labels.Metadata)
+	}
+
+	h = http.Header{}
+	h.Add("X-Asset-Meta-Build", "42")
+	h.Add("X-Asset-Meta-Build", "43")
+	_, err = parseAssetLabels(h)
+	if err == nil {
+		t.Errorf("expected error for repeated metadata header")
+	}
+}
+
+func TestParseLabelFilters(t *testing.T) {
+	params, err := url.ParseQuery("tag=release&tag_prefix=v1.&meta.project=billing&meta_prefix.build=42.&prefix=builds/")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tags, metadata := parseLabelFilters(params)
+	expectedTags := []services.TagFilter{{Tag: "release"}, {Tag: "v1.", Prefix: true}}
+	if !slices.Equal(tags, expectedTags) {
+		t.Errorf("expected tags: %v, actual: %v", expectedTags, tags)
+	}
+	expectedMetadata := []services.MetadataFilter{{Key: "project", Value: "billing"}, {Key: "build", Value: "42.", Prefix: true}}
+	if !slices.Equal(metadata, expectedMetadata) {
+		t.Errorf("expected metadata: %v, actual: %v", expectedMetadata, metadata)
+	}
+}
@@ -0,0 +1,544 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ArtemVoronov/clearway-task-assets-service/internal/services"
+)
+
+// the cookie with the access token of the protected link, its path is the path of the link
+const linkAccessCookie = "link_access"
+
+// Settings of the public link
+//
+// swagger:model LinkRequest
+type LinkRequest struct {
+	// name of the asset or the folder
+	//
+	// required: true
+	// example: "docs/report.csv"
+	Name string `json:"name"`
+
+	// the name is the folder, the link gives access to all assets of the folder and its subfolders
+	// example: false
+	Folder bool `json:"folder"`
+
+	// password of the link, the link is not protected if it is missed
+	// example: "secret"
+	Password string `json:"password,omitempty"`
+
+	// expiration time of the link, the link does not expire if it is missed
+	// example: "2024-08-01T10:00:00Z"
+	ExpireDate *time.Time `json:"expire_date,omitempty"`
+
+	// max count of the downloads by the link, it is not limited if it is missed
+	// example: 10
+	MaxDownloads *int64 `json:"max_downloads,omitempty"`
+}
+
+// Public link to the asset or the folder
+//
+// swagger:model LinkInfo
+type LinkInfo struct {
+	// id of the link
+	// example: 42
+	Id int64 `json:"id"`
+
+	// public URL of the link
+	// example: "https://localhost:3005/api/public/2c26b46b68ffc68ff99b453c1d304134"
+	URL string `json:"url"`
+
+	// name of the asset or the folder
+	// example: "docs/report.csv"
+	Name string `json:"name"`
+
+	// the name is the folder
+	// example: false
+	Folder bool `json:"folder"`
+
+	// the link is protected by the password
+	// example: true
+	Protected bool `json:"protected"`
+
+	// expiration time of the link
+	// example: "2024-08-01T10:00:00Z"
+	ExpireDate *time.Time `json:"expire_date,omitempty"`
+
+	// max count of the downloads by the link
+	// example: 10
+	MaxDownloads *int64 `json:"max_downloads,omitempty"`
+
+	// count of the downloads by the link
+	// example: 3
+	Downloads int64 `json:"downloads"`
+
+	// time of the last download by the link
+	// example: "2024-07-02T10:00:00Z"
+	LastDownloadDate *time.Time `json:"last_download_date,omitempty"`
+
+	// creation time
+	// example: "2024-07-01T10:00:00Z"
+	CreateDate time.Time `json:"create_date"`
+}
+
+// Success create link response
+// swagger:response LinkResponse
+type LinkResponse struct {
+	LinkInfo
+}
+
+// Success get links response
+// swagger:response LinksResponse
+type LinksResponse struct {
+	// links of the user in the order of creation
+	Links []LinkInfo `json:"links"`
+}
+
+// swagger:route POST /api/links links CreateLink
+//
+// # Create the public link to users's asset or folder
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Consumes:
+//   - application/json
+//
+// Security:
+// - Bearer: []
+//
+// responses:
+//   - 201: LinkResponse
+//   - 400: ErrorResponse
+//   - 404: ErrorResponse
+//   - 500: ErrorResponse
+func CreateLink(w http.ResponseWriter, r *http.Request, t *services.AccessToken) error {
+	request, err := parseLinkRequest(r)
+	if err != nil {
+		return err
+	}
+	slog.Info(fmt.Sprintf("attempt to create link to '%v'\n", request.Name))
+
+	if !request.Folder {
+		_, err = services.Instance().AssetsService.GetAssetInfo(request.Name, t.UserUUID)
+		if err != nil {
+			return processLoadAssetError(err)
+		}
+	}
+
+	link, err := services.Instance().LinksService.CreateLink(t.UserUUID, services.LinkOptions{
+		Name:         request.Name,
+		Folder:       request.Folder,
+		Password:     request.Password,
+		ExpireDate:   request.ExpireDate,
+		MaxDownloads: request.MaxDownloads,
+	}, time.Now())
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrWrongAssetName):
+			return WithStatus(err, WrongAssetNameMsg, http.StatusBadRequest)
+		case errors.Is(err, services.ErrWrongLinkOptions):
+			return WithStatus(err, WrongLinkOptionsMsg, http.StatusBadRequest)
+		default:
+			return WithStatus(err, InternalServerErrorMsg, http.StatusInternalServerError)
+		}
+	}
+
+	return WriteJSON(w, http.StatusCreated, LinkResponse{toLinkInfo(r, link)})
+}
+
+func parseLinkRequest(r *http.Request) (LinkRequest, error) {
+	var request LinkRequest
+	b, err := io.ReadAll(r.Body)
+	defer r.Body.Close()
+	if err != nil {
+		return request, WithStatus(err, InternalServerErrorMsg, http.StatusInternalServerError)
+	}
+	err = json.Unmarshal(b, &request)
+	if err != nil {
+		return request, WithStatus(err, "Expected json body with 'name' parameter", http.StatusBadRequest)
+	}
+	if len(strings.TrimSpace(request.Name)) == 0 {
+		return request, WithStatus(fmt.Errorf("missed 'name' parameter"), "Missed 'name' parameter. Expected json body with it", http.StatusBadRequest)
+	}
+	return request, nil
+}
+
+// swagger:route GET /api/links links LoadLinks
+//
+// # Get the public links of users's assets and folders with their usage
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Security:
+// - Bearer: []
+//
+// responses:
+//   - 200: LinksResponse
+//   - 500: ErrorResponse
+func LoadLinks(w http.ResponseWriter, r *http.Request, t *services.AccessToken) error {
+	slog.Info(fmt.Sprintf("attempt to load links of user '%v'\n", t.UserUUID))
+	links, err := services.Instance().LinksService.GetLinks(t.UserUUID)
+	if err != nil {
+		return WithStatus(err, InternalServerErrorMsg, http.StatusInternalServerError)
+	}
+
+	result := make([]LinkInfo, 0, len(links))
+	for _, link := range links {
+		result = append(result, toLinkInfo(r, link))
+	}
+
+	return WriteJSON(w, http.StatusOK, LinksResponse{result})
+}
+
+// swagger:route DELETE /api/links/{id} links DeleteLink
+//
+// # Revoke the public link
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Security:
+// - Bearer: []
+//
+// responses:
+//   - 200: StatusResponse
+//   - 400: ErrorResponse
+//   - 404: ErrorResponse
+//   - 500: ErrorResponse
+func DeleteLink(w http.ResponseWriter, r *http.Request, t *services.AccessToken) error {
+	idStr := r.PathValue("id")
+	linkId, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return WithStatus(err, "Id should be a number", http.StatusBadRequest)
+	}
+	slog.Info(fmt.Sprintf("attempt to delete link %v\n", linkId))
+
+	err = services.Instance().LinksService.DeleteLink(t.UserUUID, linkId)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrNotFoundLink):
+			return WithStatus(err, LinkNotFoundMsg, http.StatusNotFound)
+		default:
+			return WithStatus(err, InternalServerErrorMsg, http.StatusInternalServerError)
+		}
+	}
+
+	return WriteJSON(w, http.StatusOK, StatusResponse{"ok"})
+}
+
+// swagger:route GET /api/public/{token}/{name} links LoadPublicAsset
+//
+// # Get the asset by the public link
+//
+// The name is missed for the link to the asset. For the link to the folder the name is relative to the folder,
+// the folder is listed if the name is missed (the parameters of the list are the same as for '/api/assets').
+// The password of the protected link is passed by the basic authentication with any user name. The checked password
+// is remembered by the 'link_access' cookie for an hour, the failed attempts are limited per link and per client address.
+// The response with the whole content or with any range which covers the start of the content is counted as the download,
+// so the resumed download is counted once. The responses without the content and HEAD requests are not counted.
+//
+// ---
+// Produces:
+//   - application/json
+//
+// responses:
+//   - 200: StatusResponse
+//   - 206: StatusResponse
+//   - 304: StatusResponse
+//   - 401: ErrorResponse
+//   - 404: ErrorResponse
+//   - 410: ErrorResponse
+//   - 429: ErrorResponse
+//   - 500: ErrorResponse
+func LoadPublicAsset(w http.ResponseWriter, r *http.Request) error {
+	now := time.Now()
+	link, err := services.Instance().LinksService.GetLink(r.PathValue("token"), now)
+	if err != nil {
+		return processLinkError(err)
+	}
+	err = authorizeLink(w, r, link, now)
+	if err != nil {
+		return err
+	}
+
+	assetName := link.Name
+	relativeName := r.PathValue("name")
+	if link.Folder {
+		if len(relativeName) == 0 {
+			return loadPublicFolder(w, r, link)
+		}
+		assetName = link.Name + services.FolderDelimiter + relativeName
+	} else if len(relativeName) > 0 {
+		return WithStatus(fmt.Errorf("link %v is not the folder", link.Id), AssetNotFoundMsg, http.StatusNotFound)
+	}
+	slog.Info(fmt.Sprintf("attempt to load asset '%v' by link %v\n", assetName, link.Id))
+	disposition, err := parseDisposition(r)
+	if err != nil {
+		return err
+	}
+
+	asset, err := services.Instance().AssetsService.GetAssetInfo(assetName, link.OwnerUuid)
+	if err != nil {
+		return processLoadAssetError(err)
+	}
+	if isNotModified(r, asset.ETag(), asset.UpdateDate) {
+		setAssetValidators(w, asset)
+		writeNotModified(w)
+		return nil
+	}
+
+	downloadWriter := &linkDownloadWriter{
+		ResponseWriter: w,
+		r:              r,
+		register: func() error {
+			return services.Instance().LinksService.RegisterDownload(link.Id, now)
+		},
+	}
+	var startStreaming services.StartStreamingFunc = func(asset services.Asset, content io.ReadSeeker) {
+		downloadWriter.size = asset.Size
+		serveAssetContent(downloadWriter, r, asset, content, disposition)
+	}
+	err = services.Instance().AssetsService.GetAsset(assetName, link.OwnerUuid, startStreaming)
+	if downloadWriter.err != nil {
+		// nothing is sent, so the headers of the content are replaced by the error
+		h := w.Header()
+		for _, key := range []string{"Content-Range", "Content-Encoding", "Content-Disposition", "Repr-Digest", "ETag", "Last-Modified", "Accept-Ranges"} {
+			h.Del(key)
+		}
+		return processLinkError(downloadWriter.err)
+	}
+	if err != nil {
+		return processLoadAssetError(err)
+	}
+	// correct status code will be returned by http.ServeContent
+	return nil
+}
+
+// linkDownloadWriter counts the download when http.ServeContent has chosen the response, so the download is counted
+// by what is sent rather than by the request headers which could be ignored (e.g. the ranges with the mismatching
+// 'If-Range' or with the sum over the size of the content). The response is not sent if the download is not registered.
+type linkDownloadWriter struct {
+	http.ResponseWriter
+	r        *http.Request
+	size     int64
+	register func() error
+	// the error of the registration, the response is dropped if it is set
+	err         error
+	wroteHeader bool
+}
+
+func (w *linkDownloadWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	if isLinkDownload(w.r.Method, status, w.r.Header.Get("Range"), w.size) {
+		w.err = w.register()
+		if w.err != nil {
+			return
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *linkDownloadWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *linkDownloadWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// authorizeLink checks the password of the protected link, the checked password is remembered by the cookie
+// of the link, so the range continuations and the pages of the folder do not check it again
+func authorizeLink(w http.ResponseWriter, r *http.Request, link services.Link, now time.Time) error {
+	if !link.Protected() {
+		return nil
+	}
+	cookie, err := r.Cookie(linkAccessCookie)
+	if err == nil && link.CheckAccessToken(cookie.Value, now) {
+		return nil
+	}
+
+	ipAddr, err := parseIpAddr(r.RemoteAddr)
+	if err != nil {
+		return WithStatus(err, InternalServerErrorMsg, http.StatusInternalServerError)
+	}
+	_, password, _ := r.BasicAuth()
+	err = services.Instance().LinksService.CheckPassword(link, password, ipAddr, now)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrLinkPasswordAttempts):
+			w.Header().Set("Retry-After", strconv.Itoa(int(services.LinkPasswordAttemptsWindow.Seconds())))
+			return WithStatus(err, LinkPasswordAttemptsMsg, http.StatusTooManyRequests)
+		case errors.Is(err, services.ErrWrongLinkPassword):
+			w.Header().Set("WWW-Authenticate", `Basic realm="share link", charset="UTF-8"`)
+			return WithStatus(err, LinkPasswordRequiredMsg, http.StatusUnauthorized)
+		default:
+			return WithStatus(err, InternalServerErrorMsg, http.StatusInternalServerError)
+		}
+	}
+
+	expiry := now.Add(services.LinkAccessPeriod)
+	http.SetCookie(w, &http.Cookie{
+		Name:     linkAccessCookie,
+		Value:    link.AccessToken(expiry),
+		Path:     "/api/public/" + link.Token,
+		Expires:  expiry,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// isLinkDownload checks that the response starts the download: it sends the whole content or a range which covers
+// the first byte of the content of the size, the other ranges continue the download which is already counted.
+// The ranges are checked by the request header only for '206 Partial Content', i.e. when they are served.
+func isLinkDownload(method string, status int, rangeHeader string, size int64) bool {
+	if method == http.MethodHead {
+		return false
+	}
+	switch status {
+	case http.StatusOK:
+		return true
+	case http.StatusPartialContent:
+		ranges, ok := strings.CutPrefix(strings.TrimSpace(rangeHeader), "bytes=")
+		if !ok {
+			// the ranges of the other units are not served, so the content is sent as is
+			return true
+		}
+		for _, item := range strings.Split(ranges, ",") {
+			start, end, ok := strings.Cut(strings.TrimSpace(item), "-")
+			if !ok {
+				continue
+			}
+			start = strings.TrimSpace(start)
+			if len(start) > 0 {
+				if strings.TrimLeft(start, "0") == "" {
+					return true
+				}
+				continue
+			}
+			// the suffix range of the last bytes covers the first byte if it is not shorter than the content
+			suffix, err := strconv.ParseInt(strings.TrimSpace(end), 10, 64)
+			if err == nil && suffix >= size {
+				return true
+			}
+		}
+		return false
+	default:
+		return false
+	}
+}
+
+// loadPublicFolder lists the folder of the link, the names are relative to the folder
+func loadPublicFolder(w http.ResponseWriter, r *http.Request, link services.Link) error {
+	query, err := parseAssetsListQuery(r)
+	if err != nil {
+		return err
+	}
+	folderPrefix := link.Name + services.FolderDelimiter
+	query.Prefix = folderPrefix + query.Prefix
+
+	page, err := services.Instance().AssetsService.GetAssetList(link.OwnerUuid, query)
+	if err != nil {
+		return processAssetsListError(err)
+	}
+
+	result := make([]AssetInfo, 0, len(page.Assets))
+	for _, asset := range page.Assets {
+		info := toAssetInfo(asset)
+		info.Name = strings.TrimPrefix(info.Name, folderPrefix)
+		result = append(result, info)
+	}
+	commonPrefixes := make([]string, 0, len(page.CommonPrefixes))
+	for _, prefix := range page.CommonPrefixes {
+		commonPrefixes = append(commonPrefixes, strings.TrimPrefix(prefix, folderPrefix))
+	}
+
+	return WriteJSON(w, http.StatusOK, AssetsListResponse{AssetsList: result, CommonPrefixes: commonPrefixes, NextCursor: page.NextCursor})
+}
+
+func processLinkError(err error) error {
+	switch {
+	case errors.Is(err, services.ErrNotFoundLink):
+		return WithStatus(err, LinkNotFoundMsg, http.StatusNotFound)
+	case errors.Is(err, services.ErrExpiredLink):
+		return WithStatus(err, LinkExpiredMsg, http.StatusGone)
+	default:
+		return WithStatus(err, InternalServerErrorMsg, http.StatusInternalServerError)
+	}
+}
+
+func toLinkInfo(r *http.Request, link services.Link) LinkInfo {
+	return LinkInfo{
+		Id:               link.Id,
+		URL:              fmt.Sprintf("https://%v/api/public/%v", r.Host, link.Token),
+		Name:             link.Name,
+		Folder:           link.Folder,
+		Protected:        link.Protected(),
+		ExpireDate:       link.ExpireDate,
+		MaxDownloads:     link.MaxDownloads,
+		Downloads:        link.Downloads,
+		LastDownloadDate: link.LastDownloadDate,
+		CreateDate:       link.CreateDate,
+	}
+}
+
+// swagger:parameters CreateLink
+type CreateLinkParams struct {
+	// settings of the link
+	//
+	// in: body
+	// required: true
+	// example: {"name": "docs/report.csv", "folder": false, "password": "secret", "expire_date": "2024-08-01T10:00:00Z", "max_downloads": 10}
+	Link *LinkRequest `json:"Link"`
+}
+
+// swagger:parameters DeleteLink
+type LinkRequestParams struct {
+	// id of the link
+	//
+	// in: path
+	// required: true
+	Id int64 `json:"id"`
+}
+
+// swagger:parameters LoadPublicAsset
+type LoadPublicAssetParams struct {
+	// token of the link
+	//
+	// in: path
+	// required: true
+	Token string `json:"token"`
+
+	// name of the asset relative to the folder of the link, it is missed for the link to the asset
+	//
+	// in: path
+	Name string `json:"name"`
+
+	// 'Content-Disposition' of the content: 'inline' (default) or 'attachment'
+	//
+	// in: query
+	Disposition string `json:"disposition"`
+}
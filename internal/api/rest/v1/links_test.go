@@ -0,0 +1,90 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsLinkDownload(t *testing.T) {
+	tests := []struct {
+		method   string
+		status   int
+		header   string
+		expected bool
+	}{
+		{http.MethodGet, http.StatusOK, "", true},
+		{http.MethodGet, http.StatusOK, "bytes=100-", true},
+		{http.MethodHead, http.StatusOK, "", false},
+		{http.MethodGet, http.StatusNotModified, "", false},
+		{http.MethodGet, http.StatusRequestedRangeNotSatisfiable, "bytes=2000-", false},
+		{http.MethodGet, http.StatusPartialContent, "bytes=0-", true},
+		{http.MethodGet, http.StatusPartialContent, "bytes=0-99, 200-299", true},
+		{http.MethodGet, http.StatusPartialContent, "bytes=00-99", true},
+		{http.MethodGet, http.StatusPartialContent, "bytes=100-199, 0-99", true},
+		{http.MethodGet, http.StatusPartialContent, "bytes=1-,0-0", true},
+		{http.MethodGet, http.StatusPartialContent, "bytes=-1000", true},
+		{http.MethodGet, http.StatusPartialContent, "bytes=-999999999", true},
+		{http.MethodGet, http.StatusPartialContent, "bytes=100-", false},
+		{http.MethodGet, http.StatusPartialContent, "bytes=100-199, 300-399", false},
+		{http.MethodGet, http.StatusPartialContent, "bytes=-100", false},
+	}
+	for _, test := range tests {
+		actual := isLinkDownload(test.method, test.status, test.header, 1000)
+		if actual != test.expected {
+			t.Errorf("expected %v for %v with status %v and Range '%v', actual: %v", test.expected, test.method, test.status, test.header, actual)
+		}
+	}
+}
+
+func TestLinkDownloadWriter(t *testing.T) {
+	content := strings.Repeat("0123456789", 100)
+	modified := time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		method   string
+		header   map[string]string
+		status   int
+		expected int
+	}{
+		{http.MethodGet, nil, http.StatusOK, 1},
+		{http.MethodHead, nil, http.StatusOK, 0},
+		{http.MethodGet, map[string]string{"Range": "bytes=100-"}, http.StatusPartialContent, 0},
+		{http.MethodGet, map[string]string{"Range": "bytes=-999999999"}, http.StatusPartialContent, 1},
+		{http.MethodGet, map[string]string{"Range": "bytes=1-,0-0"}, http.StatusPartialContent, 1},
+		{http.MethodGet, map[string]string{"Range": "bytes=-10"}, http.StatusPartialContent, 0},
+		{http.MethodGet, map[string]string{"Range": "bytes=100-", "If-Range": `"v1"`}, http.StatusPartialContent, 0},
+		{http.MethodGet, map[string]string{"Range": "bytes=100-", "If-Range": `"v2"`}, http.StatusOK, 1},
+		{http.MethodGet, map[string]string{"If-None-Match": `"v1"`}, http.StatusNotModified, 0},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(test.method, "/api/public/token", nil)
+		for key, value := range test.header {
+			r.Header.Set(key, value)
+		}
+		recorder := httptest.NewRecorder()
+		recorder.Header().Set("ETag", `"v1"`)
+		registered := 0
+		w := &linkDownloadWriter{ResponseWriter: recorder, r: r, size: int64(len(content)), register: func() error {
+			registered++
+			return nil
+		}}
+		http.ServeContent(w, r, "report.csv", modified, strings.NewReader(content))
+		if recorder.Code != test.status || registered != test.expected {
+			t.Errorf("expected status %v and %v downloads for %v %v, actual: %v and %v", test.status, test.expected, test.method, test.header, recorder.Code, registered)
+		}
+	}
+
+	expectedErr := errors.New("no downloads left")
+	recorder := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/public/token", nil)
+	w := &linkDownloadWriter{ResponseWriter: recorder, r: r, size: int64(len(content)), register: func() error {
+		return expectedErr
+	}}
+	http.ServeContent(w, r, "report.csv", modified, strings.NewReader(content))
+	if !errors.Is(w.err, expectedErr) || recorder.Body.Len() > 0 {
+		t.Errorf("expected nothing to be sent when the download is not registered, actual: %v, body of %v bytes", w.err, recorder.Body.Len())
+	}
+}
@@ -0,0 +1,149 @@
+package v1
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ArtemVoronov/clearway-task-assets-service/internal/services"
+)
+
+// swagger:route PATCH /api/asset/{name} assets PatchAsset
+//
+// # Write the range of users's asset or append to it
+//
+// The range is set by 'Content-Range: bytes <first>-<last>/<size or *>' header, the first byte should not be beyond the end of the asset.
+// The content is appended to the end of the asset if the header is missed. Supports 'If-Match' precondition.
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Consumes:
+//   - any
+//
+// Security:
+// - Bearer: []
+//
+// responses:
+//   - 200: StatusResponse
+//   - 400: ErrorResponse
+//   - 403: ErrorResponse
+//   - 404: ErrorResponse
+//   - 412: ErrorResponse
+//   - 413: ErrorResponse
+//   - 416: ErrorResponse
+//   - 500: ErrorResponse
+func PatchAsset(w http.ResponseWriter, r *http.Request, t *services.AccessToken) error {
+	assetName := r.PathValue("name")
+	patch, err := parseContentRange(r.Header.Get("Content-Range"))
+	if err != nil {
+		return err
+	}
+	slog.Info(fmt.Sprintf("attempt to patch asset '%v' at %v\n", assetName, patch.Offset))
+	ownerUuid, err := resolveAssetOwner(r, t, assetName, services.GrantPermissionReadWrite)
+	if err != nil {
+		return err
+	}
+	patch.Name = assetName
+	patch.Content = r.Body
+
+	asset, err := services.Instance().AssetsService.PatchAsset(ownerUuid, patch, parseAssetPrecondition(r))
+	if err != nil {
+		return processPatchAssetError(err)
+	}
+
+	setAssetValidators(w, asset)
+	return WriteJSON(w, http.StatusOK, StatusResponse{"ok"})
+}
+
+// parseContentRange makes the patch by 'Content-Range' header (see RFC 9110, section 14.4), the missed header means appending
+func parseContentRange(header string) (services.AssetPatch, error) {
+	result := services.AssetPatch{Offset: -1, Length: -1, Total: -1}
+	if len(header) == 0 {
+		return result, nil
+	}
+	wrongHeaderErr := WithStatus(fmt.Errorf("wrong 'Content-Range' header: %v", header), "Header 'Content-Range' should be 'bytes <first>-<last>/<size or *>'", http.StatusBadRequest)
+
+	spec, ok := strings.CutPrefix(header, "bytes ")
+	if !ok {
+		return result, wrongHeaderErr
+	}
+	byteRange, total, ok := strings.Cut(spec, "/")
+	if !ok {
+		return result, wrongHeaderErr
+	}
+	firstStr, lastStr, ok := strings.Cut(byteRange, "-")
+	if !ok {
+		return result, wrongHeaderErr
+	}
+	first, err := strconv.ParseInt(firstStr, 10, 64)
+	if err != nil || first < 0 {
+		return result, wrongHeaderErr
+	}
+	last, err := strconv.ParseInt(lastStr, 10, 64)
+	if err != nil || last < first {
+		return result, wrongHeaderErr
+	}
+	result.Offset = first
+	result.Length = last - first + 1
+
+	if total != "*" {
+		result.Total, err = strconv.ParseInt(total, 10, 64)
+		if err != nil || result.Total <= last {
+			return result, wrongHeaderErr
+		}
+	}
+	return result, nil
+}
+
+func processPatchAssetError(err error) error {
+	switch {
+	case errors.Is(err, services.ErrNotFoundAsset):
+		return WithStatus(err, AssetNotFoundMsg, http.StatusNotFound)
+	case errors.Is(err, services.ErrPreconditionFailed):
+		return WithStatus(err, PreconditionFailedMsg, http.StatusPreconditionFailed)
+	case errors.Is(err, services.ErrWrongContentRange):
+		return WithStatus(err, WrongContentRangeMsg, http.StatusRequestedRangeNotSatisfiable)
+	case errors.Is(err, services.ErrDeletedOwner):
+		return WithStatus(err, GroupNotFoundMsg, http.StatusNotFound)
+	case errors.Is(err, services.ErrQuotaExceeded):
+		return processQuotaError(err)
+	default:
+		return WithStatus(err, InternalServerErrorMsg, http.StatusInternalServerError)
+	}
+}
+
+// swagger:parameters PatchAsset
+type PatchAssetParams struct {
+	// asset name
+	//
+	// in: path
+	// required: true
+	AssetName string `json:"name"`
+
+	// range of the content, e.g. 'bytes 100-199/*', the content is appended if it is missed
+	//
+	// in: header
+	ContentRange string `json:"Content-Range"`
+
+	// the asset is patched only if its current ETag matches one of the listed ones
+	//
+	// in: header
+	IfMatch string `json:"If-Match"`
+
+	// login of the owner who shared the asset for reading and writing, the user's own asset is patched if it is missed
+	//
+	// in: query
+	Owner string `json:"owner"`
+
+	// content of the range
+	//
+	// in: body
+	// required: true
+	Data *bytes.Buffer `json:"data"`
+}
@@ -0,0 +1,32 @@
+package v1
+
+import "testing"
+
+func TestParseContentRange(t *testing.T) {
+	tests := []struct {
+		header string
+		offset int64
+		length int64
+		total  int64
+	}{
+		{"", -1, -1, -1},
+		{"bytes 0-99/*", 0, 100, -1},
+		{"bytes 100-199/200", 100, 100, 200},
+	}
+	for _, test := range tests {
+		patch, err := parseContentRange(test.header)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if patch.Offset != test.offset || patch.Length != test.length || patch.Total != test.total {
+			t.Errorf("expected offset %v, length %v, total %v for '%v', actual: %v, %v, %v", test.offset, test.length, test.total, test.header, patch.Offset, patch.Length, patch.Total)
+		}
+	}
+
+	for _, header := range []string{"bytes */100", "bytes 10-5/*", "bytes 0-99/50", "items 0-99/*", "bytes -5-10/*", "bytes 0-99"} {
+		_, err := parseContentRange(header)
+		if err == nil {
+			t.Errorf("expected error for header '%v'", header)
+		}
+	}
+}
@@ -0,0 +1,350 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ArtemVoronov/clearway-task-assets-service/internal/services"
+)
+
+// default lifetime of the presigned URL in seconds
+const defaultPresignExpiresIn = 15 * 60
+
+// query parameters of the presigned URLs
+const (
+	presignedUserParam      = "user"
+	presignedExpiresParam   = "expires"
+	presignedKeyParam       = "key"
+	presignedSignatureParam = "signature"
+)
+
+// Operation of the presigned URL
+//
+// swagger:model PresignRequest
+type PresignRequest struct {
+	// 'GET' to download the asset or 'PUT' to create or replace it
+	//
+	// required: true
+	// example: "GET"
+	Method string `json:"method"`
+
+	// lifetime of the URL in seconds, 900 by default, it is limited by the configuration
+	// example: 900
+	ExpiresIn int64 `json:"expires_in"`
+}
+
+// Success presign response
+// swagger:response PresignResponse
+type PresignResponse struct {
+	// URL of the operation, the access token is not needed for it
+	// example: "https://localhost:3005/api/presigned/docs/report.csv?expires=1719828000&key=k1&signature=...&user=..."
+	URL string `json:"url"`
+
+	// http method of the operation
+	// example: "GET"
+	Method string `json:"method"`
+
+	// expiration time of the URL
+	// example: "2024-07-01T10:00:00Z"
+	Expires time.Time `json:"expires"`
+}
+
+// swagger:route POST /api/asset/{name}/presign assets PresignAsset
+//
+// # Create the time-limited URL to download or upload users's asset without the access token
+//
+// The URL is bound to the operation, the asset and the user, the URLs are not created for the shared assets of the other users.
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Consumes:
+//   - application/json
+//
+// Security:
+// - Bearer: []
+//
+// responses:
+//   - 200: PresignResponse
+//   - 400: ErrorResponse
+//   - 404: ErrorResponse
+//   - 500: ErrorResponse
+//   - 501: ErrorResponse
+func PresignAsset(w http.ResponseWriter, r *http.Request, t *services.AccessToken) error {
+	assetName := r.PathValue("name")
+	request, err := parsePresignRequest(r)
+	if err != nil {
+		return err
+	}
+	slog.Info(fmt.Sprintf("attempt to presign %v of asset '%v'\n", request.Method, assetName))
+	err = checkNamePrefix(t, assetName)
+	if err != nil {
+		return err
+	}
+	if request.Method == http.MethodPut {
+		// the presigned upload is the write of the asset
+		err = checkScopes(t, []string{services.ScopeAssetsWrite})
+		if err != nil {
+			return err
+		}
+	}
+
+	signer := services.Instance().URLSigner
+	if signer == nil {
+		return WithStatus(fmt.Errorf("signing keys are not configured"), PresignDisabledMsg, http.StatusNotImplemented)
+	}
+	if request.Method == http.MethodGet {
+		_, err = services.Instance().AssetsService.GetAssetInfo(assetName, t.UserUUID)
+		if err != nil {
+			return processLoadAssetError(err)
+		}
+	} else {
+		err = services.ValidateAssetName(assetName)
+		if err != nil {
+			return WithStatus(err, WrongAssetNameMsg, http.StatusBadRequest)
+		}
+	}
+
+	now := time.Now()
+	presigned, err := signer.Sign(services.PresignedURL{
+		Method:    request.Method,
+		OwnerUuid: t.UserUUID,
+		Name:      assetName,
+		Expires:   presignExpires(t, now, request.ExpiresIn),
+	}, now)
+	if err != nil {
+		if errors.Is(err, services.ErrWrongPresignExpiry) {
+			return WithStatus(err, fmt.Sprintf("Parameter 'expires_in' should be a positive number of seconds up to %v", int64(signer.MaxExpiry().Seconds())), http.StatusBadRequest)
+		}
+		return WithStatus(err, InternalServerErrorMsg, http.StatusInternalServerError)
+	}
+
+	return WriteJSON(w, http.StatusOK, PresignResponse{
+		URL:     presignedURL(r, presigned),
+		Method:  presigned.Method,
+		Expires: presigned.Expires,
+	})
+}
+
+// presignExpires returns the expiry of the presigned URL, the URL issued by the API key expires not later than the key
+func presignExpires(t *services.AccessToken, now time.Time, expiresIn int64) time.Time {
+	expires := now.Add(time.Duration(expiresIn) * time.Second)
+	if t.ExpireDate != nil && t.ExpireDate.Before(expires) {
+		return *t.ExpireDate
+	}
+	return expires
+}
+
+func parsePresignRequest(r *http.Request) (PresignRequest, error) {
+	request := PresignRequest{ExpiresIn: defaultPresignExpiresIn}
+	b, err := io.ReadAll(r.Body)
+	defer r.Body.Close()
+	if err != nil {
+		return request, WithStatus(err, InternalServerErrorMsg, http.StatusInternalServerError)
+	}
+	err = json.Unmarshal(b, &request)
+	if err != nil {
+		return request, WithStatus(err, "Expected json body with 'method' parameter", http.StatusBadRequest)
+	}
+	request.Method = strings.ToUpper(request.Method)
+	switch request.Method {
+	case http.MethodGet, http.MethodPut:
+	default:
+		return request, WithStatus(fmt.Errorf("wrong 'method' parameter: %v", request.Method), "Parameter 'method' should be 'GET' or 'PUT'", http.StatusBadRequest)
+	}
+	return request, nil
+}
+
+// presignedURL makes the absolute URL of the presigned route, the segments of the name are escaped one by one to keep '/' between them
+func presignedURL(r *http.Request, presigned services.PresignedURL) string {
+	params := url.Values{}
+	params.Set(presignedUserParam, presigned.OwnerUuid)
+	params.Set(presignedExpiresParam, strconv.FormatInt(presigned.Expires.Unix(), 10))
+	params.Set(presignedKeyParam, presigned.KeyId)
+	params.Set(presignedSignatureParam, presigned.Signature)
+	return fmt.Sprintf("https://%v/api/presigned/%v?%v", r.Host, escapeAssetName(presigned.Name), params.Encode())
+}
+
+// escapeAssetName escapes each segment of the name of the asset, so the name is put into the URL path as is
+func escapeAssetName(name string) string {
+	segments := strings.Split(name, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// PresignedHandler checks the signature of the presigned URL in place of the access token
+type PresignedHandler struct {
+	handler AuthenticateHandlerFunc
+}
+
+func (h *PresignedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	accessToken, err := CheckPresignedURL(r)
+	if err != nil {
+		processHttpError(w, err)
+		return
+	}
+
+	// the grants of the user are not applied by the presigned URL, it is bound to the user's asset only
+	routed := r.Clone(r.Context())
+	params := routed.URL.Query()
+	params.Del("owner")
+	routed.URL.RawQuery = params.Encode()
+
+	err = h.handler(w, routed, accessToken)
+	if err != nil {
+		processHttpError(w, err)
+	}
+}
+
+// CheckPresignedURL verifies the signature of the request by the method, the 'name' path value and the query parameters,
+// the access token of the owner of the asset is returned on success
+func CheckPresignedURL(r *http.Request) (*services.AccessToken, error) {
+	signer := services.Instance().URLSigner
+	if signer == nil {
+		return nil, WithStatus(fmt.Errorf("signing keys are not configured"), PresignDisabledMsg, http.StatusNotImplemented)
+	}
+	params := r.URL.Query()
+	expires, err := strconv.ParseInt(params.Get(presignedExpiresParam), 10, 64)
+	if err != nil {
+		return nil, WithStatus(fmt.Errorf("%w: wrong '%v' parameter", services.ErrWrongSignature, presignedExpiresParam), WrongSignatureMsg, http.StatusForbidden)
+	}
+	method := r.Method
+	if method == http.MethodHead {
+		method = http.MethodGet
+	}
+
+	presigned := services.PresignedURL{
+		Method:    method,
+		OwnerUuid: params.Get(presignedUserParam),
+		Name:      r.PathValue("name"),
+		Expires:   time.Unix(expires, 0),
+		KeyId:     params.Get(presignedKeyParam),
+		Signature: params.Get(presignedSignatureParam),
+	}
+	err = signer.Verify(presigned, time.Now())
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrExpiredSignature):
+			return nil, WithStatus(err, PresignedURLExpiredMsg, http.StatusForbidden)
+		default:
+			return nil, WithStatus(err, WrongSignatureMsg, http.StatusForbidden)
+		}
+	}
+	return &services.AccessToken{UserUUID: presigned.OwnerUuid}, nil
+}
+
+func PresignRequired(handlerToWrap AuthenticateHandlerFunc) *PresignedHandler {
+	return &PresignedHandler{handlerToWrap}
+}
+
+// swagger:route GET /api/presigned/{name} assets LoadPresignedAsset
+//
+// # Get the content of the asset by the presigned URL
+//
+// The same as 'GET /api/asset/{name}', but the signature of the URL is checked instead of the access token.
+//
+// ---
+// Produces:
+//   - application/json
+//
+// responses:
+//   - 200: StatusResponse
+//   - 206: StatusResponse
+//   - 304: StatusResponse
+//   - 403: ErrorResponse
+//   - 404: ErrorResponse
+//   - 500: ErrorResponse
+//   - 501: ErrorResponse
+
+// swagger:route PUT /api/presigned/{name} assets ReplacePresignedAsset
+//
+// # Create or replace the asset by the presigned URL
+//
+// The same as 'PUT /api/asset/{name}', but the signature of the URL is checked instead of the access token.
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Consumes:
+//   - any
+//
+// responses:
+//   - 200: StatusResponse
+//   - 201: StatusResponse
+//   - 400: ErrorResponse
+//   - 403: ErrorResponse
+//   - 412: ErrorResponse
+//   - 413: ErrorResponse
+//   - 500: ErrorResponse
+//   - 501: ErrorResponse
+
+// swagger:parameters PresignAsset
+type PresignAssetParams struct {
+	// asset name
+	//
+	// in: path
+	// required: true
+	AssetName string `json:"name"`
+
+	// operation of the URL
+	//
+	// in: body
+	// required: true
+	// example: {"method": "GET", "expires_in": 900}
+	Request *PresignRequest `json:"Request"`
+}
+
+// swagger:parameters LoadPresignedAsset ReplacePresignedAsset
+type PresignedAssetParams struct {
+	// asset name
+	//
+	// in: path
+	// required: true
+	AssetName string `json:"name"`
+
+	// uuid of the owner of the asset
+	//
+	// in: query
+	// required: true
+	User string `json:"user"`
+
+	// expiration time of the URL in seconds since the Unix epoch
+	//
+	// in: query
+	// required: true
+	Expires int64 `json:"expires"`
+
+	// id of the signing key
+	//
+	// in: query
+	// required: true
+	Key string `json:"key"`
+
+	// base64url encoded HMAC-SHA256 of the operation, the asset, the owner and the expiration time
+	//
+	// in: query
+	// required: true
+	Signature string `json:"signature"`
+}
+
+// swagger:parameters ReplacePresignedAsset
+type ReplacePresignedAssetParams struct {
+	// asset data
+	//
+	// in: body
+	// required: true
+	Data *bytes.Buffer `json:"data"`
+}
@@ -0,0 +1,39 @@
+package v1
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ArtemVoronov/clearway-task-assets-service/internal/services"
+)
+
+func TestPresignedURL(t *testing.T) {
+	r := httptest.NewRequest("POST", "/api/asset/docs/report%201.csv/-/presign", nil)
+	r.Host = "localhost:3005"
+	presigned := services.PresignedURL{Method: "GET", OwnerUuid: "owner", Name: "docs/report 1.csv", Expires: time.Unix(1719828000, 0), KeyId: "k1", Signature: "abc"}
+	expected := "https://localhost:3005/api/presigned/docs/report%201.csv?expires=1719828000&key=k1&signature=abc&user=owner"
+	actual := presignedURL(r, presigned)
+	if actual != expected {
+		t.Errorf("expected URL: %v, actual: %v", expected, actual)
+	}
+}
+
+func TestPresignExpires(t *testing.T) {
+	now := time.Unix(1719828000, 0)
+	keyExpires := now.Add(time.Minute)
+	tests := []struct {
+		token    services.AccessToken
+		expected time.Time
+	}{
+		{services.AccessToken{}, now.Add(time.Hour)},
+		{services.AccessToken{ApiKeyId: 1}, now.Add(time.Hour)},
+		{services.AccessToken{ApiKeyId: 1, ExpireDate: &keyExpires}, keyExpires},
+	}
+	for _, test := range tests {
+		actual := presignExpires(&test.token, now, 3600)
+		if !actual.Equal(test.expected) {
+			t.Errorf("expected expiry %v for token %+v, actual: %v", test.expected, test.token, actual)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ArtemVoronov/clearway-task-assets-service/internal/services"
+)
+
+// Asset in the trash
+//
+// swagger:model TrashedAssetInfo
+type TrashedAssetInfo struct {
+	AssetInfo
+
+	// id of the asset in the trash
+	// example: 42
+	Id int64 `json:"id"`
+
+	// deletion time
+	// example: "2024-07-01T10:00:00Z"
+	DeleteDate time.Time `json:"delete_date"`
+}
+
+// Success get trash response
+// swagger:response TrashResponse
+type TrashResponse struct {
+	// deleted assets, the latest go first
+	Assets []TrashedAssetInfo `json:"assets"`
+}
+
+// swagger:route GET /api/trash trash LoadTrash
+//
+// # Get users's deleted assets, which are not purged yet
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Security:
+// - Bearer: []
+//
+// responses:
+//   - 200: TrashResponse
+//   - 500: ErrorResponse
+func LoadTrash(w http.ResponseWriter, r *http.Request, t *services.AccessToken) error {
+	slog.Info(fmt.Sprintf("attempt to load trash for user '%v'\n", t.UserUUID))
+	trash, err := services.Instance().AssetsService.GetTrash(t.UserUUID)
+	if err != nil {
+		return WithStatus(err, InternalServerErrorMsg, http.StatusInternalServerError)
+	}
+
+	result := make([]TrashedAssetInfo, 0, len(trash))
+	for _, item := range trash {
+		if !t.AllowsName(item.Asset.Name) {
+			continue
+		}
+		result = append(result, TrashedAssetInfo{
+			AssetInfo:  toAssetInfo(item.Asset),
+			Id:         item.Id,
+			DeleteDate: item.DeleteDate,
+		})
+	}
+
+	return WriteJSON(w, http.StatusOK, TrashResponse{result})
+}
+
+// swagger:route POST /api/trash/{id}/restore trash RestoreTrashedAsset
+//
+// # Restore users's deleted asset
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Security:
+// - Bearer: []
+//
+// responses:
+//   - 200: StatusResponse
+//   - 400: ErrorResponse
+//   - 403: ErrorResponse
+//   - 404: ErrorResponse
+//   - 409: ErrorResponse
+//   - 500: ErrorResponse
+func RestoreTrashedAsset(w http.ResponseWriter, r *http.Request, t *services.AccessToken) error {
+	trashId, err := parseTrashId(r)
+	if err != nil {
+		return err
+	}
+	slog.Info(fmt.Sprintf("attempt to restore trashed asset %v\n", trashId))
+	err = checkTrashedAssetPrefix(t, trashId)
+	if err != nil {
+		return err
+	}
+
+	asset, err := services.Instance().AssetsService.RestoreTrashedAsset(trashId, t.UserUUID)
+	if err != nil {
+		return processTrashError(err)
+	}
+
+	setAssetValidators(w, asset)
+	return WriteJSON(w, http.StatusOK, StatusResponse{"ok"})
+}
+
+func parseTrashId(r *http.Request) (int64, error) {
+	trashId, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		return 0, WithStatus(err, "Id should be a number", http.StatusBadRequest)
+	}
+	return trashId, nil
+}
+
+func processTrashError(err error) error {
+	switch {
+	case errors.Is(err, services.ErrNotFoundTrashedAsset):
+		return WithStatus(err, AssetNotFoundMsg, http.StatusNotFound)
+	case errors.Is(err, services.ErrDuplicateAsset):
+		return WithStatus(err, AssetDuplicateMsg, http.StatusConflict)
+	case errors.Is(err, services.ErrDeletedOwner):
+		return WithStatus(err, GroupNotFoundMsg, http.StatusNotFound)
+	case errors.Is(err, services.ErrQuotaExceeded):
+		return processQuotaError(err)
+	default:
+		return WithStatus(err, InternalServerErrorMsg, http.StatusInternalServerError)
+	}
+}
+
+// swagger:parameters RestoreTrashedAsset
+type TrashedAssetRequest struct {
+	// id of the asset in the trash
+	//
+	// in: path
+	// required: true
+	Id int64 `json:"id"`
+}
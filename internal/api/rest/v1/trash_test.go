@@ -0,0 +1,51 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ArtemVoronov/clearway-task-assets-service/internal/services"
+)
+
+func TestParseTrashId(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/api/trash/42/restore", nil)
+	r.SetPathValue("id", "42")
+	trashId, err := parseTrashId(r)
+	if err != nil || trashId != 42 {
+		t.Errorf("expected trash id: 42, actual: %v, error: %v", trashId, err)
+	}
+
+	r.SetPathValue("id", "file.txt")
+	_, err = parseTrashId(r)
+	var statusErr statusError
+	if !errors.As(err, &statusErr) || statusErr.status != http.StatusBadRequest {
+		t.Errorf("expected bad request for the wrong id, actual: %v", err)
+	}
+}
+
+func TestProcessTrashError(t *testing.T) {
+	tests := []struct {
+		err            error
+		expectedStatus int
+		expectedMsg    string
+	}{
+		{fmt.Errorf("restore trashed asset 1 error: %w", services.ErrNotFoundTrashedAsset), http.StatusNotFound, AssetNotFoundMsg},
+		{fmt.Errorf("restore trashed asset 1 error: %w", services.ErrDuplicateAsset), http.StatusConflict, AssetDuplicateMsg},
+		{fmt.Errorf("unable to restore: %w", services.ErrFilesCountQuotaExceeded), http.StatusForbidden, FilesCountQuotaExceededMsg},
+		{fmt.Errorf("owner 'group' error: %w", services.ErrDeletedOwner), http.StatusNotFound, GroupNotFoundMsg},
+		{errors.New("connection lost"), http.StatusInternalServerError, InternalServerErrorMsg},
+	}
+	for _, test := range tests {
+		var statusErr statusError
+		if !errors.As(processTrashError(test.err), &statusErr) {
+			t.Errorf("expected status error for: %v", test.err)
+			continue
+		}
+		if statusErr.status != test.expectedStatus || statusErr.message != test.expectedMsg {
+			t.Errorf("expected: %v '%v', actual: %v '%v'", test.expectedStatus, test.expectedMsg, statusErr.status, statusErr.message)
+		}
+	}
+}
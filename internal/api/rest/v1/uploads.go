@@ -0,0 +1,310 @@
+package v1
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ArtemVoronov/clearway-task-assets-service/internal/services"
+)
+
+// Resumable uploads follow tus protocol 1.0 (see for details https://tus.io/protocols/resumable-upload)
+const (
+	TusVersion    = "1.0.0"
+	TusExtensions = "creation,termination,expiration"
+
+	tusContentType = "application/offset+octet-stream"
+	// the keys of the upload metadata with the labels of the asset
+	uploadTagsKey       = "tags"
+	uploadMetaKeyPrefix = "meta-"
+)
+
+// swagger:route POST /api/uploads uploads CreateUpload
+//
+// # Create resumable upload of asset
+//
+// Headers 'Upload-Length' and 'Upload-Metadata' with the 'filename' key are required, the 'filetype' key is optional.
+// The labels of the asset are passed by the 'tags' key with the comma-separated tags and by the 'meta-<key>' keys of the metadata,
+// they are set when the upload is completed.
+// The URL of the upload is returned in the 'Location' header.
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Security:
+// - Bearer: []
+//
+// responses:
+//   - 201: StatusResponse
+//   - 400: ErrorResponse
+//   - 403: ErrorResponse
+//   - 409: ErrorResponse
+//   - 412: ErrorResponse
+//   - 413: ErrorResponse
+//   - 500: ErrorResponse
+func CreateUpload(w http.ResponseWriter, r *http.Request, t *services.AccessToken) error {
+	err := checkTusResumable(w, r)
+	if err != nil {
+		return err
+	}
+
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		return WithStatus(fmt.Errorf("wrong upload length '%v'", r.Header.Get("Upload-Length")), "Upload-Length should be a non-negative number", http.StatusBadRequest)
+	}
+	metadata, err := parseUploadMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		return WithStatus(err, "Upload-Metadata is malformed", http.StatusBadRequest)
+	}
+	assetName := metadata["filename"]
+	if len(assetName) == 0 {
+		return WithStatus(fmt.Errorf("missed filename in upload metadata"), "Upload-Metadata should contain filename", http.StatusBadRequest)
+	}
+	slog.Info(fmt.Sprintf("attempt to create upload of asset '%v'\n", assetName))
+	err = checkNamePrefix(t, assetName)
+	if err != nil {
+		return err
+	}
+
+	labels, err := parseUploadLabels(metadata)
+	if err != nil {
+		return err
+	}
+	upload, err := services.Instance().AssetsService.CreateUpload(t.UserUUID, assetName, metadata["filetype"], length, labels)
+	if err != nil {
+		return processUploadError(err)
+	}
+
+	h := w.Header()
+	h.Set("Location", "/api/uploads/"+upload.Id)
+	setUploadProgress(w, upload)
+	return WriteJSON(w, http.StatusCreated, StatusResponse{"ok"})
+}
+
+// swagger:route HEAD /api/uploads/{id} uploads LoadUploadProgress
+//
+// # Get offset of resumable upload
+//
+// ---
+// Security:
+// - Bearer: []
+//
+// responses:
+//   - 200: StatusResponse
+//   - 404: ErrorResponse
+//   - 412: ErrorResponse
+//   - 500: ErrorResponse
+func LoadUploadProgress(w http.ResponseWriter, r *http.Request, t *services.AccessToken) error {
+	err := checkTusResumable(w, r)
+	if err != nil {
+		return err
+	}
+
+	upload, err := services.Instance().AssetsService.GetUpload(r.PathValue("id"), t.UserUUID)
+	if err != nil {
+		return processUploadError(err)
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	setUploadProgress(w, upload)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// swagger:route PATCH /api/uploads/{id} uploads WriteUpload
+//
+// # Append chunk to resumable upload
+//
+// Header 'Upload-Offset' should be equal to the current offset of the upload.
+// The asset is created when the last byte of the declared length is received.
+//
+// ---
+// Consumes:
+//   - application/offset+octet-stream
+//
+// Security:
+// - Bearer: []
+//
+// responses:
+//   - 204: StatusResponse
+//   - 400: ErrorResponse
+//   - 403: ErrorResponse
+//   - 404: ErrorResponse
+//   - 409: ErrorResponse
+//   - 412: ErrorResponse
+//   - 413: ErrorResponse
+//   - 415: ErrorResponse
+//   - 500: ErrorResponse
+func WriteUpload(w http.ResponseWriter, r *http.Request, t *services.AccessToken) error {
+	err := checkTusResumable(w, r)
+	if err != nil {
+		return err
+	}
+	if r.Header.Get("Content-Type") != tusContentType {
+		return WithStatus(fmt.Errorf("wrong content type of chunk"), "Content-Type should be "+tusContentType, http.StatusUnsupportedMediaType)
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		return WithStatus(fmt.Errorf("wrong upload offset '%v'", r.Header.Get("Upload-Offset")), "Upload-Offset should be a non-negative number", http.StatusBadRequest)
+	}
+	uploadId := r.PathValue("id")
+	slog.Info(fmt.Sprintf("attempt to write upload '%v' at offset %v\n", uploadId, offset))
+
+	upload, err := services.Instance().AssetsService.WriteUpload(uploadId, t.UserUUID, offset, r.Body)
+	if err != nil {
+		return processUploadError(err)
+	}
+
+	setUploadProgress(w, upload)
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// swagger:route DELETE /api/uploads/{id} uploads DeleteUpload
+//
+// # Terminate resumable upload
+//
+// ---
+// Security:
+// - Bearer: []
+//
+// responses:
+//   - 204: StatusResponse
+//   - 404: ErrorResponse
+//   - 412: ErrorResponse
+//   - 500: ErrorResponse
+func DeleteUpload(w http.ResponseWriter, r *http.Request, t *services.AccessToken) error {
+	err := checkTusResumable(w, r)
+	if err != nil {
+		return err
+	}
+
+	err = services.Instance().AssetsService.DeleteUpload(r.PathValue("id"), t.UserUUID)
+	if err != nil {
+		return processUploadError(err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// NewProcessTusOptionsRequestsFunc reports the supported tus protocol in addition to CORS headers
+func NewProcessTusOptionsRequestsFunc(processOptionsRequestsFunc http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("Tus-Resumable", TusVersion)
+		h.Set("Tus-Version", TusVersion)
+		h.Set("Tus-Extension", TusExtensions)
+		processOptionsRequestsFunc(w, r)
+	}
+}
+
+func checkTusResumable(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Tus-Resumable", TusVersion)
+	version := r.Header.Get("Tus-Resumable")
+	if version != TusVersion {
+		w.Header().Set("Tus-Version", TusVersion)
+		return WithStatus(fmt.Errorf("unsupported tus version '%v'", version), "Tus-Resumable should be "+TusVersion, http.StatusPreconditionFailed)
+	}
+	return nil
+}
+
+func setUploadProgress(w http.ResponseWriter, upload services.Upload) {
+	h := w.Header()
+	h.Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	h.Set("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	if !upload.Completed() {
+		h.Set("Upload-Expires", upload.ExpireDate.UTC().Format(http.TimeFormat))
+	}
+}
+
+// parseUploadMetadata parses comma separated pairs of the key and the base64 encoded value, the value could be omitted
+func parseUploadMetadata(header string) (map[string]string, error) {
+	result := make(map[string]string)
+	if len(strings.TrimSpace(header)) == 0 {
+		return result, nil
+	}
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if len(key) == 0 {
+			return nil, fmt.Errorf("empty key of upload metadata")
+		}
+		value, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode upload metadata '%v': %w", key, err)
+		}
+		result[key] = string(value)
+	}
+	return result, nil
+}
+
+// parseUploadLabels collects the labels of the asset from the 'tags' and 'meta-<key>' keys of the upload metadata,
+// they are nil if there are no such keys
+func parseUploadLabels(metadata map[string]string) (*services.AssetLabels, error) {
+	var labels *services.AssetLabels
+	for key, value := range metadata {
+		if key == uploadTagsKey {
+			if labels == nil {
+				labels = &services.AssetLabels{Metadata: map[string]string{}}
+			}
+			labels.Tags = append(labels.Tags, splitHeaderList([]string{value})...)
+			continue
+		}
+		metaKey, ok := strings.CutPrefix(key, uploadMetaKeyPrefix)
+		if !ok {
+			continue
+		}
+		if labels == nil {
+			labels = &services.AssetLabels{Metadata: map[string]string{}}
+		}
+		labels.Metadata[strings.ToLower(metaKey)] = strings.TrimSpace(value)
+	}
+	if labels == nil {
+		return nil, nil
+	}
+
+	normalized, err := services.NormalizeAssetLabels(*labels)
+	if err != nil {
+		return nil, WithStatus(err, WrongAssetLabelsMsg, http.StatusBadRequest)
+	}
+	return &normalized, nil
+}
+
+func processUploadError(err error) error {
+	switch {
+	case errors.Is(err, services.ErrNotFoundUpload):
+		return WithStatus(err, UploadNotFoundMsg, http.StatusNotFound)
+	case errors.Is(err, services.ErrUploadOffsetMismatch):
+		return WithStatus(err, UploadOffsetMismatchMsg, http.StatusConflict)
+	case errors.Is(err, services.ErrUploadLengthExceeded):
+		return WithStatus(err, UploadLengthExceededMsg, http.StatusBadRequest)
+	case errors.Is(err, services.ErrAssetNameReserved):
+		return WithStatus(err, AssetNameReservedMsg, http.StatusConflict)
+	case errors.Is(err, services.ErrDuplicateAsset):
+		return WithStatus(err, AssetDuplicateMsg, http.StatusConflict)
+	case errors.Is(err, services.ErrWrongAssetName):
+		return WithStatus(err, WrongAssetNameMsg, http.StatusBadRequest)
+	case errors.Is(err, services.ErrWrongAssetLabels):
+		return WithStatus(err, WrongAssetLabelsMsg, http.StatusBadRequest)
+	case errors.Is(err, services.ErrDeletedOwner):
+		return WithStatus(err, GroupNotFoundMsg, http.StatusNotFound)
+	case errors.Is(err, services.ErrQuotaExceeded):
+		return processQuotaError(err)
+	default:
+		return WithStatus(err, InternalServerErrorMsg, http.StatusInternalServerError)
+	}
+}
+
+// swagger:parameters LoadUploadProgress WriteUpload DeleteUpload
+type UploadRequest struct {
+	// id of the upload
+	//
+	// in: path
+	// required: true
+	Id string `json:"id"`
+}
@@ -0,0 +1,95 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ArtemVoronov/clearway-task-assets-service/internal/services"
+)
+
+func TestParseUploadMetadata(t *testing.T) {
+	metadata, err := parseUploadMetadata("filename ZmlsZS50eHQ=, filetype dGV4dC9wbGFpbg==,is_confidential")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := map[string]string{"filename": "file.txt", "filetype": "text/plain", "is_confidential": ""}
+	if len(metadata) != len(expected) {
+		t.Errorf("expected metadata: %v, actual: %v", expected, metadata)
+	}
+	for key, value := range expected {
+		if metadata[key] != value {
+			t.Errorf("expected value of '%v': %v, actual: %v", key, value, metadata[key])
+		}
+	}
+
+	_, err = parseUploadMetadata("filename not-base64!")
+	if err == nil {
+		t.Errorf("expected error for malformed metadata")
+	}
+}
+
+func TestCheckTusResumable(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/api/uploads", nil)
+	w := httptest.NewRecorder()
+	err := checkTusResumable(w, r)
+	if err == nil {
+		t.Errorf("expected error without Tus-Resumable header")
+	}
+	if w.Header().Get("Tus-Version") != TusVersion {
+		t.Errorf("expected Tus-Version header: %v", TusVersion)
+	}
+
+	r.Header.Set("Tus-Resumable", TusVersion)
+	w = httptest.NewRecorder()
+	err = checkTusResumable(w, r)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if w.Header().Get("Tus-Resumable") != TusVersion {
+		t.Errorf("expected Tus-Resumable header: %v", TusVersion)
+	}
+}
+
+func TestProcessUploadErrorOfReservedName(t *testing.T) {
+	tests := []struct {
+		err         error
+		expectedMsg string
+	}{
+		{fmt.Errorf("upload asset 'file.txt' error: %w", services.ErrAssetNameReserved), AssetNameReservedMsg},
+		{fmt.Errorf("upload asset 'file.txt' error: %w", services.ErrDuplicateAsset), AssetDuplicateMsg},
+	}
+	for _, test := range tests {
+		var statusErr statusError
+		if !errors.As(processUploadError(test.err), &statusErr) || statusErr.status != http.StatusConflict || statusErr.message != test.expectedMsg {
+			t.Errorf("expected conflict '%v' for: %v", test.expectedMsg, test.err)
+		}
+	}
+	// the reserved name is reported as the duplicate by the other requests
+	if !errors.Is(services.ErrAssetNameReserved, services.ErrDuplicateAsset) {
+		t.Errorf("expected the reserved name to be the duplicate")
+	}
+}
+
+func TestParseUploadLabels(t *testing.T) {
+	labels, err := parseUploadLabels(map[string]string{"filename": "report.csv", "filetype": "text/csv"})
+	if err != nil || labels != nil {
+		t.Errorf("expected no labels, actual: %v, error: %v", labels, err)
+	}
+
+	labels, err = parseUploadLabels(map[string]string{"filename": "report.csv", "tags": "q3, draft", "meta-Build": " 42 "})
+	if err != nil || labels == nil {
+		t.Fatalf("expected labels, actual: %v, error: %v", labels, err)
+	}
+	if fmt.Sprint(labels.Tags) != "[draft q3]" || labels.Metadata["build"] != "42" {
+		t.Errorf("expected tags [draft q3] and metadata build=42, actual: %+v", *labels)
+	}
+
+	_, err = parseUploadLabels(map[string]string{"meta-wrong key": "value"})
+	var statusErr statusError
+	if !errors.As(err, &statusErr) || statusErr.status != http.StatusBadRequest {
+		t.Errorf("expected status %v, actual: %v", http.StatusBadRequest, err)
+	}
+}
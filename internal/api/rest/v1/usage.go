@@ -0,0 +1,77 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/ArtemVoronov/clearway-task-assets-service/internal/services"
+)
+
+// Storage consumption of the user against the limits, 0 limit means no limit
+//
+// swagger:response UsageResponse
+type UsageResponse struct {
+	// count of the assets
+	// example: 42
+	Files int64 `json:"files"`
+
+	// total size of the assets in bytes including their previous versions and the trash
+	// example: 1048576
+	TotalSize int64 `json:"total_size"`
+
+	// max count of the assets
+	// example: 100
+	MaxFiles int64 `json:"max_files"`
+
+	// max size of the single asset in bytes
+	// example: 4294967296
+	MaxFileSize int64 `json:"max_file_size"`
+
+	// max total size of the assets in bytes
+	// example: 16106127360
+	MaxTotalSize int64 `json:"max_total_size"`
+}
+
+// swagger:route GET /api/usage usage LoadUsage
+//
+// # Get users's storage consumption and quota
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Security:
+// - Bearer: []
+//
+// responses:
+//   - 200: UsageResponse
+//   - 500: ErrorResponse
+func LoadUsage(w http.ResponseWriter, r *http.Request, t *services.AccessToken) error {
+	slog.Info(fmt.Sprintf("attempt to load usage for user '%v'\n", t.UserUUID))
+	usage, err := services.Instance().AssetsService.GetUsage(t.UserUUID)
+	if err != nil {
+		return WithStatus(err, InternalServerErrorMsg, http.StatusInternalServerError)
+	}
+
+	return WriteJSON(w, http.StatusOK, UsageResponse{
+		Files:        usage.Files,
+		TotalSize:    usage.TotalSize,
+		MaxFiles:     usage.Limits.MaxFiles,
+		MaxFileSize:  usage.Limits.MaxFileSize,
+		MaxTotalSize: usage.Limits.MaxTotalSize,
+	})
+}
+
+// processQuotaError maps the exceeded quota: the count of files forbids any new asset, the size limits reject the content
+func processQuotaError(err error) error {
+	switch {
+	case errors.Is(err, services.ErrFilesCountQuotaExceeded):
+		return WithStatus(err, FilesCountQuotaExceededMsg, http.StatusForbidden)
+	case errors.Is(err, services.ErrFileSizeQuotaExceeded):
+		return WithStatus(err, FileSizeQuotaExceededMsg, http.StatusRequestEntityTooLarge)
+	default:
+		return WithStatus(err, TotalSizeQuotaExceededMsg, http.StatusRequestEntityTooLarge)
+	}
+}
@@ -0,0 +1,250 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ArtemVoronov/clearway-task-assets-service/internal/services"
+)
+
+// Version of the asset
+//
+// swagger:model AssetVersionInfo
+type AssetVersionInfo struct {
+	// number of the version
+	// example: 2
+	Version int `json:"version"`
+
+	// size in bytes
+	// example: 1024
+	Size int64 `json:"size"`
+
+	// content type
+	// example: "text/plain; charset=utf-8"
+	ContentType string `json:"content_type"`
+
+	// hex encoded SHA-256 of the content
+	// example: "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+	Checksum string `json:"checksum"`
+
+	// time when the content of the version was stored
+	// example: "2024-07-01T10:00:00Z"
+	CreateDate time.Time `json:"create_date"`
+
+	// true for the current version of the asset
+	// example: false
+	Current bool `json:"current"`
+}
+
+// Success get asset versions response
+// swagger:response AssetVersionsResponse
+type AssetVersionsResponse struct {
+	// versions, the newest go first
+	Versions []AssetVersionInfo `json:"versions"`
+}
+
+// swagger:route GET /api/asset/{name}/versions versions LoadAssetVersions
+//
+// # Get versions of users's asset
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Security:
+// - Bearer: []
+//
+// responses:
+//   - 200: AssetVersionsResponse
+//   - 404: ErrorResponse
+//   - 500: ErrorResponse
+func LoadAssetVersions(w http.ResponseWriter, r *http.Request, t *services.AccessToken) error {
+	assetName := r.PathValue("name")
+	slog.Info(fmt.Sprintf("attempt to load versions of asset '%v'\n", assetName))
+	ownerUuid, err := resolveAssetOwner(r, t, assetName, services.GrantPermissionRead)
+	if err != nil {
+		return err
+	}
+
+	versions, err := services.Instance().AssetsService.GetAssetVersions(assetName, ownerUuid)
+	if err != nil {
+		return processAssetVersionError(err)
+	}
+
+	result := make([]AssetVersionInfo, 0, len(versions))
+	for _, version := range versions {
+		result = append(result, AssetVersionInfo{
+			Version:     version.Version,
+			Size:        version.Size,
+			ContentType: version.ContentType,
+			Checksum:    version.Checksum,
+			CreateDate:  version.CreateDate,
+			Current:     version.Current,
+		})
+	}
+
+	return WriteJSON(w, http.StatusOK, AssetVersionsResponse{result})
+}
+
+// swagger:route GET /api/asset/{name}/versions/{version} versions LoadAssetVersion
+//
+// # Get the content of the version of users's asset
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Security:
+// - Bearer: []
+//
+// responses:
+//   - 200: StatusResponse
+//   - 206: StatusResponse
+//   - 400: ErrorResponse
+//   - 404: ErrorResponse
+//   - 500: ErrorResponse
+func LoadAssetVersion(w http.ResponseWriter, r *http.Request, t *services.AccessToken) error {
+	assetName := r.PathValue("name")
+	version, err := parseAssetVersion(r)
+	if err != nil {
+		return err
+	}
+	slog.Info(fmt.Sprintf("attempt to load version %v of asset '%v'\n", version, assetName))
+	disposition, err := parseDisposition(r)
+	if err != nil {
+		return err
+	}
+	ownerUuid, err := resolveAssetOwner(r, t, assetName, services.GrantPermissionRead)
+	if err != nil {
+		return err
+	}
+
+	var startStreaming services.StartStreamingFunc = func(asset services.Asset, content io.ReadSeeker) {
+		serveAssetContent(w, r, asset, content, disposition)
+	}
+	err = services.Instance().AssetsService.GetAssetVersion(assetName, ownerUuid, version, startStreaming)
+	if err != nil {
+		return processAssetVersionError(err)
+	}
+	// correct status code will be returned by http.ServeContent
+	return nil
+}
+
+// swagger:route POST /api/asset/{name}/versions/{version}/restore versions RestoreAssetVersion
+//
+// # Make the copy of the version the current content of users's asset
+//
+// ---
+// Produces:
+//   - application/json
+//
+// Security:
+// - Bearer: []
+//
+// responses:
+//   - 200: StatusResponse
+//   - 400: ErrorResponse
+//   - 404: ErrorResponse
+//   - 413: ErrorResponse
+//   - 500: ErrorResponse
+func RestoreAssetVersion(w http.ResponseWriter, r *http.Request, t *services.AccessToken) error {
+	assetName := r.PathValue("name")
+	version, err := parseAssetVersion(r)
+	if err != nil {
+		return err
+	}
+	slog.Info(fmt.Sprintf("attempt to restore version %v of asset '%v'\n", version, assetName))
+	err = checkNamePrefix(t, assetName)
+	if err != nil {
+		return err
+	}
+
+	asset, err := services.Instance().AssetsService.RestoreAssetVersion(assetName, t.UserUUID, version)
+	if err != nil {
+		return processAssetVersionError(err)
+	}
+
+	setAssetValidators(w, asset)
+	return WriteJSON(w, http.StatusOK, StatusResponse{"ok"})
+}
+
+func parseAssetVersion(r *http.Request) (int, error) {
+	versionStr := r.PathValue("version")
+	version, err := strconv.Atoi(versionStr)
+	if err != nil || version <= 0 {
+		return 0, WithStatus(fmt.Errorf("wrong version: %v", versionStr), "Version should be a positive number", http.StatusBadRequest)
+	}
+	return version, nil
+}
+
+func processAssetVersionError(err error) error {
+	switch {
+	case errors.Is(err, services.ErrNotFoundAsset):
+		return WithStatus(err, AssetNotFoundMsg, http.StatusNotFound)
+	case errors.Is(err, services.ErrNotFoundAssetVersion):
+		return WithStatus(err, AssetVersionNotFoundMsg, http.StatusNotFound)
+	case errors.Is(err, services.ErrDeletedOwner):
+		return WithStatus(err, GroupNotFoundMsg, http.StatusNotFound)
+	case errors.Is(err, services.ErrQuotaExceeded):
+		return processQuotaError(err)
+	default:
+		return WithStatus(err, InternalServerErrorMsg, http.StatusInternalServerError)
+	}
+}
+
+// swagger:parameters LoadAssetVersions
+type AssetVersionsRequest struct {
+	// asset name
+	//
+	// in: path
+	// required: true
+	AssetName string `json:"name"`
+	// login of the owner who shared the asset, the user's own asset is loaded if it is missed
+	//
+	// in: query
+	Owner string `json:"owner"`
+}
+
+// swagger:parameters RestoreAssetVersion
+type AssetVersionRequest struct {
+	// asset name
+	//
+	// in: path
+	// required: true
+	AssetName string `json:"name"`
+
+	// number of the version
+	//
+	// in: path
+	// required: true
+	Version int `json:"version"`
+}
+
+// swagger:parameters LoadAssetVersion
+type LoadAssetVersionParams struct {
+	// asset name
+	//
+	// in: path
+	// required: true
+	AssetName string `json:"name"`
+
+	// number of the version
+	//
+	// in: path
+	// required: true
+	Version int `json:"version"`
+
+	// 'Content-Disposition' of the content: 'inline' (default) or 'attachment'
+	//
+	// in: query
+	Disposition string `json:"disposition"`
+	// login of the owner who shared the asset, the user's own asset is loaded if it is missed
+	//
+	// in: query
+	Owner string `json:"owner"`
+}
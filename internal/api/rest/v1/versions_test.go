@@ -0,0 +1,65 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ArtemVoronov/clearway-task-assets-service/internal/services"
+)
+
+func TestParseAssetVersion(t *testing.T) {
+	tests := []struct {
+		version       string
+		expected      int
+		expectedError bool
+	}{
+		{"1", 1, false},
+		{"42", 42, false},
+		{"0", 0, true},
+		{"-1", 0, true},
+		{"latest", 0, true},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/api/asset/file.txt/-/versions/"+test.version+"/restore", nil)
+		r.SetPathValue("version", test.version)
+		actual, err := parseAssetVersion(r)
+		if test.expectedError {
+			var statusErr StatusError
+			if !errors.As(err, &statusErr) || statusErr.Status() != http.StatusBadRequest {
+				t.Errorf("expected bad request for version '%v', actual: %v", test.version, err)
+			}
+			continue
+		}
+		if err != nil || actual != test.expected {
+			t.Errorf("expected version: %v, actual: %v, error: %v", test.expected, actual, err)
+		}
+	}
+}
+
+func TestProcessAssetVersionError(t *testing.T) {
+	tests := []struct {
+		err            error
+		expectedStatus int
+		expectedMsg    string
+	}{
+		{fmt.Errorf("restore asset 'file.txt' error: %w", services.ErrNotFoundAsset), http.StatusNotFound, AssetNotFoundMsg},
+		{fmt.Errorf("restore asset 'file.txt' version 3 error: %w", services.ErrNotFoundAssetVersion), http.StatusNotFound, AssetVersionNotFoundMsg},
+		{errors.New("connection lost"), http.StatusInternalServerError, InternalServerErrorMsg},
+	}
+	for _, test := range tests {
+		var statusErr statusError
+		if !errors.As(processAssetVersionError(test.err), &statusErr) {
+			t.Errorf("expected status error for: %v", test.err)
+			continue
+		}
+		if statusErr.status != test.expectedStatus || statusErr.message != test.expectedMsg {
+			t.Errorf("expected: %v '%v', actual: %v '%v'", test.expectedStatus, test.expectedMsg, statusErr.status, statusErr.message)
+		}
+		if !errors.Is(statusErr, test.err) {
+			t.Errorf("expected the original error to be wrapped: %v", test.err)
+		}
+	}
+}
@@ -21,8 +21,22 @@ const (
 	DefaultAccessTokenTTL                    = "24h"
 	DefaultCORSAllowedOrigin                 = "*"
 	DefaultCORSAllowedHeaders                = "X-Requested-With"
-	DefaultCORSAllowedMethods                = "GET,POST,PUT,DELETE,OPTIONS"
+	DefaultCORSAllowedMethods                = "GET,HEAD,POST,PUT,PATCH,DELETE,OPTIONS"
 	DefaultShardsCount                       = 2
+	DefaultAssetsBlobStoreType               = "largeobjects"
+	DefaultAssetsBlobStoreDir                = "./data/assets"
+	DefaultAssetsVersioningMaxVersions       = 10
+	DefaultAssetsTrashRetention              = "720h"
+	DefaultAssetsTrashPurgeInterval          = "1h"
+	DefaultAssetsUploadsExpiration           = "24h"
+	DefaultAssetsCompression                 = "none"
+	DefaultAssetsArchiveMaxEntries           = 10000
+	DefaultAssetsArchiveMaxExpandedSize      = 1024 * 1024 * 1024 * 15 // 15 GB
+	DefaultAssetsArchiveMaxCompressionRatio  = 100
+	DefaultAssetsPresignMaxExpiry            = "168h"
+	DefaultQuotaMaxFiles                     = 100
+	DefaultQuotaMaxFileSize                  = 1024 * 1024 * 1024 * 4  // 4 GB
+	DefaultQuotaMaxTotalSize                 = 1024 * 1024 * 1024 * 15 // 15 GB
 
 	// Current implementation of assets storing is based on large objects (see for details https://www.postgresql.org/docs/current/largeobjects.html).
 	// A large object cannot exceed 4TB for PostgreSQL 9.3 or newer or 2GB for older versions.
@@ -107,6 +121,18 @@ func NewHttpServerConfig() (*HttpServerConfig, error) {
 	}, nil
 }
 
+func ParseAssetsListV1Compatibility() (bool, error) {
+	compatibilityStr, ok := os.LookupEnv("API_ASSETS_LIST_V1_COMPATIBILITY")
+	if !ok {
+		return false, nil
+	}
+	result, err := strconv.ParseBool(compatibilityStr)
+	if err != nil {
+		return false, fmt.Errorf("unable to parse 'API_ASSETS_LIST_V1_COMPATIBILITY' parameter: %w", err)
+	}
+	return result, nil
+}
+
 func ParseBodyMaxSize() (int, error) {
 	bodyMaxSize := DefaultBodyMaxSize
 	bodyMaxSizeStr, ok := os.LookupEnv("HTTP_REQUEST_BODY_MAX_SIZE_IN_BYTES")
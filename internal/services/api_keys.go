@@ -0,0 +1,246 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ArtemVoronov/clearway-task-assets-service/internal/app/utils"
+	pgx "github.com/jackc/pgx/v5"
+)
+
+// ApiKeyPrefix distinguishes the API keys from the access tokens in 'Authorization' header
+const ApiKeyPrefix = "ak_"
+
+const (
+	ScopeAssetsRead   = "assets:read"
+	ScopeAssetsWrite  = "assets:write"
+	ScopeAssetsDelete = "assets:delete"
+)
+
+var ApiKeyScopes = []string{ScopeAssetsRead, ScopeAssetsWrite, ScopeAssetsDelete}
+
+const (
+	apiKeyColumns     = `id, key_prefix, user_uuid, name, scopes, name_prefix, expire_date, last_used_date, create_date`
+	createApiKeyQuery = `INSERT INTO api_keys (key_hash, key_prefix, user_uuid, name, scopes, name_prefix, expire_date)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING ` + apiKeyColumns
+	getApiKeysQuery      = `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE user_uuid = $1 ORDER BY id`
+	deleteApiKeyQuery    = `DELETE FROM api_keys WHERE user_uuid = $1 and id = $2`
+	getApiKeyByHashQuery = `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE key_hash = $1 and (expire_date IS NULL or expire_date > $2)`
+	// the concurrent requests by the same key record its usage once
+	useApiKeyQuery = `UPDATE api_keys SET last_used_date = $2 WHERE id = $1 and (last_used_date IS NULL or last_used_date < $3)`
+
+	// count of the first characters of the key which are kept to recognize it in the list
+	apiKeyDisplayPrefixLength = len(ApiKeyPrefix) + 8
+	// the usage time of the key is recorded with this precision, so the requests by the key mostly do not write to the database
+	apiKeyUsagePrecision = time.Minute
+)
+
+var ErrNotFoundApiKey = errors.New("api key not found")
+var ErrWrongApiKeyOptions = errors.New("wrong api key options")
+
+// ApiKeysService keeps only SHA-256 of the keys, the key itself is returned once on creation.
+// The keys are random, so the hash without the salt is enough to prevent the usage of the leaked table.
+type ApiKeysService struct {
+	client *PostgreSQLService
+}
+
+type ApiKey struct {
+	Id int64
+	// first characters of the key
+	KeyPrefix string
+	UserUuid  string
+	// label of the key given by the user
+	Name   string
+	Scopes []string
+	// the key gives access only to the assets which names start with the prefix, empty prefix means all assets
+	NamePrefix string
+	// nil means no expiry
+	ExpireDate   *time.Time
+	LastUsedDate *time.Time
+	CreateDate   time.Time
+}
+
+func (k *ApiKey) scan(row pgx.Row) error {
+	var scopes string
+	err := row.Scan(&k.Id, &k.KeyPrefix, &k.UserUuid, &k.Name, &scopes, &k.NamePrefix, &k.ExpireDate, &k.LastUsedDate, &k.CreateDate)
+	if err != nil {
+		return err
+	}
+	k.Scopes = strings.Split(scopes, ",")
+	return nil
+}
+
+type ApiKeyOptions struct {
+	Name       string
+	Scopes     []string
+	NamePrefix string
+	ExpireDate *time.Time
+}
+
+// usageOutdated checks that the recorded usage time of the key is older than its precision
+func (k ApiKey) usageOutdated(now time.Time) bool {
+	return k.LastUsedDate == nil || k.LastUsedDate.Before(now.Add(-apiKeyUsagePrecision))
+}
+
+func hashApiKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+func CreateApiKeysService(client *PostgreSQLService) *ApiKeysService {
+	return &ApiKeysService{
+		client: client,
+	}
+}
+
+func (s *ApiKeysService) Shutdown() error {
+	return nil
+}
+
+// CreateApiKey generates the new key of the user, the key is returned only by this method
+func (s *ApiKeysService) CreateApiKey(userUuid string, options ApiKeyOptions, now time.Time) (ApiKey, string, error) {
+	var apiKey ApiKey
+	if len(options.Scopes) == 0 {
+		return apiKey, "", fmt.Errorf("%w: no scopes", ErrWrongApiKeyOptions)
+	}
+	scopes := []string{}
+	for _, scope := range options.Scopes {
+		if !slices.Contains(ApiKeyScopes, scope) {
+			return apiKey, "", fmt.Errorf("%w: unknown scope '%v'", ErrWrongApiKeyOptions, scope)
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	if options.ExpireDate != nil && !options.ExpireDate.After(now) {
+		return apiKey, "", fmt.Errorf("%w: expire date %v is in the past", ErrWrongApiKeyOptions, options.ExpireDate)
+	}
+	if len(options.NamePrefix) > maxAssetNameLength {
+		return apiKey, "", fmt.Errorf("%w: name prefix should be at most %v characters", ErrWrongApiKeyOptions, maxAssetNameLength)
+	}
+
+	key := ApiKeyPrefix + utils.GenerateToken()
+	err := s.client.TxVoid(
+		func(tx pgx.Tx, ctx context.Context, cancel context.CancelFunc) error {
+			row := tx.QueryRow(ctx, createApiKeyQuery, hashApiKey(key), key[:apiKeyDisplayPrefixLength], userUuid, options.Name, strings.Join(scopes, ","), options.NamePrefix, options.ExpireDate)
+			return apiKey.scan(row)
+		},
+		pgx.TxOptions{
+			IsoLevel: pgx.ReadCommitted,
+		})()
+
+	if err != nil {
+		return apiKey, "", fmt.Errorf("user '%v' unable to create api key: %w", userUuid, err)
+	}
+	return apiKey, key, nil
+}
+
+// GetApiKeys returns the keys of the user without the keys themselves
+func (s *ApiKeysService) GetApiKeys(userUuid string) ([]ApiKey, error) {
+	result, err := s.client.Tx(
+		func(tx pgx.Tx, ctx context.Context, cancel context.CancelFunc) (any, error) {
+			apiKeys := []ApiKey{}
+			rows, internalErr := tx.Query(ctx, getApiKeysQuery, userUuid)
+			if internalErr != nil {
+				return nil, internalErr
+			}
+
+			for rows.Next() {
+				var apiKey ApiKey
+				internalErr := apiKey.scan(rows)
+				if internalErr != nil {
+					return nil, fmt.Errorf("unable to scan api keys: %w", internalErr)
+				}
+				apiKeys = append(apiKeys, apiKey)
+			}
+
+			internalErr = rows.Err()
+			if internalErr != nil {
+				return nil, fmt.Errorf("unable to scan api keys: %w", internalErr)
+			}
+
+			return apiKeys, nil
+		},
+		pgx.TxOptions{
+			IsoLevel: pgx.ReadCommitted,
+		})()
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to get api keys: %w", err)
+	}
+
+	apiKeys, ok := result.([]ApiKey)
+	if !ok {
+		return nil, fmt.Errorf("unable to convert result into []ApiKey")
+	}
+
+	return apiKeys, nil
+}
+
+// DeleteApiKey revokes the key of the user
+func (s *ApiKeysService) DeleteApiKey(userUuid string, apiKeyId int64) error {
+	return s.client.TxVoid(
+		func(tx pgx.Tx, ctx context.Context, cancel context.CancelFunc) error {
+			tag, internalErr := tx.Exec(ctx, deleteApiKeyQuery, userUuid, apiKeyId)
+			if internalErr != nil {
+				return fmt.Errorf("unable to delete api key %v: %w", apiKeyId, internalErr)
+			}
+			if tag.RowsAffected() == 0 {
+				return fmt.Errorf("delete api key %v error: %w", apiKeyId, ErrNotFoundApiKey)
+			}
+			return nil
+		},
+		pgx.TxOptions{
+			IsoLevel: pgx.ReadCommitted,
+		})()
+}
+
+// GetToken returns the access token of the valid key with its scopes, the usage time of the key is recorded
+// if the previous one is older than a minute
+func (s *ApiKeysService) GetToken(key string, now time.Time) (AccessToken, error) {
+	result, err := s.client.Tx(
+		func(tx pgx.Tx, ctx context.Context, cancel context.CancelFunc) (any, error) {
+			var apiKey ApiKey
+			internalErr := apiKey.scan(tx.QueryRow(ctx, getApiKeyByHashQuery, hashApiKey(key), now))
+			if internalErr != nil {
+				return nil, internalErr
+			}
+			if apiKey.usageOutdated(now) {
+				_, internalErr = tx.Exec(ctx, useApiKeyQuery, apiKey.Id, now, now.Add(-apiKeyUsagePrecision))
+				if internalErr != nil {
+					return nil, fmt.Errorf("unable to record usage of api key %v: %w", apiKey.Id, internalErr)
+				}
+			}
+			return apiKey, nil
+		},
+		pgx.TxOptions{
+			IsoLevel: pgx.ReadCommitted,
+		})()
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return AccessToken{}, ErrNotFoundAccessToken
+		}
+		return AccessToken{}, fmt.Errorf("unable to get api key: %w", err)
+	}
+
+	apiKey, ok := result.(ApiKey)
+	if !ok {
+		return AccessToken{}, fmt.Errorf("unable to convert result into ApiKey")
+	}
+	return AccessToken{
+		Value:      apiKey.KeyPrefix,
+		UserUUID:   apiKey.UserUuid,
+		CreateDate: apiKey.CreateDate,
+		ApiKeyId:   apiKey.Id,
+		Scopes:     apiKey.Scopes,
+		NamePrefix: apiKey.NamePrefix,
+		ExpireDate: apiKey.ExpireDate,
+	}, nil
+}
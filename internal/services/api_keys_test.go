@@ -0,0 +1,73 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAccessTokenScopes(t *testing.T) {
+	token := AccessToken{Value: "b5a302e740d0d84bbdc2254c97f1427b"}
+	for _, scope := range ApiKeyScopes {
+		if !token.HasScope(scope) {
+			t.Errorf("expected the access token of the login to have scope '%v'", scope)
+		}
+	}
+
+	apiKey := AccessToken{ApiKeyId: 1, Scopes: []string{ScopeAssetsRead}}
+	if !apiKey.HasScope(ScopeAssetsRead) {
+		t.Errorf("expected the API key to have scope '%v'", ScopeAssetsRead)
+	}
+	if apiKey.HasScope(ScopeAssetsWrite) || apiKey.HasScope(ScopeAssetsDelete) {
+		t.Errorf("expected the API key to have only scope '%v'", ScopeAssetsRead)
+	}
+}
+
+func TestAccessTokenNamePrefix(t *testing.T) {
+	tests := []struct {
+		prefix string
+		name   string
+		folder bool
+		allows bool
+	}{
+		{"", "report.csv", false, true},
+		{"", "docs", true, true},
+		{"backups/", "backups/db.sql", false, true},
+		{"backups/", "backups.sql", false, false},
+		{"backups/", "docs/db.sql", false, false},
+		{"backups/", "backups", true, true},
+		{"backups/", "backups/2024/", true, true},
+		{"backups/", "back", true, false},
+		{"backups/2024", "backups", true, false},
+		{"backups/2024", "backups/2024-01", true, true},
+	}
+	for _, test := range tests {
+		token := AccessToken{ApiKeyId: 1, NamePrefix: test.prefix}
+		allows := token.AllowsName(test.name)
+		if test.folder {
+			allows = token.AllowsFolder(test.name)
+		}
+		if allows != test.allows {
+			t.Errorf("expected prefix '%v' allows '%v' (folder: %v): %v, actual: %v", test.prefix, test.name, test.folder, test.allows, allows)
+		}
+	}
+}
+
+func TestApiKeyUsageOutdated(t *testing.T) {
+	now := time.Now()
+	recent := now.Add(-30 * time.Second)
+	old := now.Add(-2 * time.Minute)
+	tests := []struct {
+		lastUsedDate *time.Time
+		expected     bool
+	}{
+		{nil, true},
+		{&recent, false},
+		{&old, true},
+	}
+	for _, test := range tests {
+		actual := ApiKey{LastUsedDate: test.lastUsedDate}.usageOutdated(now)
+		if actual != test.expected {
+			t.Errorf("expected %v for last usage %v, actual: %v", test.expected, test.lastUsedDate, actual)
+		}
+	}
+}
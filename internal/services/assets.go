@@ -1,41 +1,127 @@
 package services
 
 import (
+	"bufio"
 	"context"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
 
 	pgx "github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 )
 
 const (
-	createAssetQuery   = `INSERT INTO assets (name, user_uuid, file_id) VALUES ($1, $2, $3)`
-	getAssetQuery      = `SELECT file_id FROM assets WHERE user_uuid = $1 and name = $2`
-	getAssetsListQuery = `SELECT name FROM assets WHERE user_uuid = $1`
-	deleteAssetQuery   = `DELETE FROM assets WHERE user_uuid = $1 and name = $2`
+	assetColumns = `name, size, content_type, checksum, create_date, update_date, version, encoding, key_id, data_key`
+
+	// the version numbers continue the history of the name if it exists
+	createAssetQuery = `INSERT INTO assets (name, user_uuid, blob_id, size, content_type, checksum, encoding, key_id, data_key, version)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, COALESCE((SELECT MAX(version) FROM asset_versions WHERE user_uuid = $2 and name = $1), 0) + 1)
+		RETURNING create_date, update_date, version`
+	getAssetInfoQuery          = `SELECT blob_id, ` + assetColumns + ` FROM assets WHERE user_uuid = $1 and name = $2`
+	getAssetInfoForUpdateQuery = getAssetInfoQuery + ` FOR UPDATE`
+	getAssetInfoForShareQuery  = getAssetInfoQuery + ` FOR SHARE`
+	updateAssetContentQuery    = `UPDATE assets SET blob_id = $3, size = $4, content_type = $5, checksum = $6, encoding = $7, key_id = $8, data_key = $9, hash_state = NULL, update_date = NOW(), version = version + 1
+		WHERE user_uuid = $1 and name = $2 RETURNING create_date, update_date, version`
+	// the labels are moved into the row of the trash, so the new asset with the same name does not get them
+	moveAssetToTrashQuery = `WITH deleted AS (DELETE FROM assets WHERE user_uuid = $1 and name = $2 RETURNING *),
+		labels AS (DELETE FROM asset_labels WHERE user_uuid = $1 and name = $2 RETURNING ` + trashedLabelColumns + `)
+		INSERT INTO assets_trash (user_uuid, name, blob_id, size, content_type, checksum, encoding, key_id, data_key, create_date, update_date, version, labels)
+		SELECT user_uuid, name, blob_id, size, content_type, checksum, encoding, key_id, data_key, create_date, update_date, version,
+			(SELECT ` + trashedLabelsAggregate + ` FROM labels l) FROM deleted
+		RETURNING id`
 )
 
+// count of the first bytes of the content which are used for detecting its type
+const sniffLen = 512
+
 var ErrDuplicateAsset = errors.New("duplicate asset")
 var ErrNotFoundAsset = errors.New("asset not found")
+var ErrPreconditionFailed = errors.New("precondition failed")
+
+type Asset struct {
+	Name        string
+	Size        int64
+	ContentType string
+	// hex encoded SHA-256 of the content
+	Checksum   string
+	CreateDate time.Time
+	UpdateDate time.Time
+	// the number of the current content, it is increased by each replacement
+	Version int
+	// compression of the stored content, empty if the content is stored as is
+	Encoding string
+	// the master key which wraps the data key of the encrypted content, empty if the content is not encrypted
+	keyId   string
+	dataKey []byte
+}
+
+func (a *Asset) scanTargets() []any {
+	return []any{&a.Name, &a.Size, &a.ContentType, &a.Checksum, &a.CreateDate, &a.UpdateDate, &a.Version, &a.Encoding, &a.keyId, &a.dataKey}
+}
+
+// ETag returns the strong entity tag of the asset content, it is empty if the checksum is unknown
+func (a Asset) ETag() string {
+	if len(a.Checksum) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("\"%v\"", a.Checksum)
+}
+
+type AssetUpload struct {
+	Name string
+	// declared by the client, the type is detected by the content if it is empty
+	ContentType string
+	Content     io.Reader
+	// declared by the client, the upload is rolled back if the content does not match them
+	Digests ContentDigests
+	// the labels of the replaced asset are kept if they are nil
+	Labels *AssetLabels
+}
 
 type AssetsService struct {
 	shardedClients []*PostgreSQLService
 	ShardsNum      int
 	shardService   *ShardService
+	blobs          BlobStore
+	versioning     VersioningPolicy
+	trash          TrashPolicy
+	uploads        UploadsPolicy
+	archives       ArchivePolicy
+	defaultQuota   Quota
+	compression    string
+	keys           *KeyRing
+	stopPurge      chan struct{}
+	purgeWorkers   sync.WaitGroup
 }
 
-func CreateAssetsService(clients []*PostgreSQLService) *AssetsService {
+func CreateAssetsService(clients []*PostgreSQLService, blobs BlobStore, versioning VersioningPolicy, trash TrashPolicy, uploads UploadsPolicy, archives ArchivePolicy, defaultQuota Quota, compression string, keys *KeyRing) *AssetsService {
 	return &AssetsService{
 		shardedClients: clients,
 		ShardsNum:      len(clients),
 		shardService:   CreateShardService(len(clients)),
+		blobs:          blobs,
+		versioning:     versioning,
+		trash:          trash,
+		uploads:        uploads,
+		archives:       archives,
+		defaultQuota:   defaultQuota,
+		compression:    compression,
+		keys:           keys,
+		stopPurge:      make(chan struct{}),
 	}
 }
 
 func (s *AssetsService) Shutdown() error {
+	close(s.stopPurge)
+	s.purgeWorkers.Wait()
 	return nil
 }
 
@@ -45,155 +131,428 @@ func (s *AssetsService) client(userUuid string) *PostgreSQLService {
 	return s.shardedClients[bucket]
 }
 
-func (s *AssetsService) CreateAsset(name string, userUuid string, file io.Reader) error {
-	err := s.client(userUuid).TxVoid(
+type SqlQueryFuncWithBlobs func(tx pgx.Tx, ctx context.Context, blobs *blobSession) error
+
+// txWithBlobs runs the function in the transaction of the user's shard and keeps the blob store consistent with it
+func (s *AssetsService) txWithBlobs(userUuid string, f SqlQueryFuncWithBlobs) error {
+	return s.shardTxWithBlobs(s.client(userUuid), f)
+}
+
+func (s *AssetsService) shardTxWithBlobs(client *PostgreSQLService, f SqlQueryFuncWithBlobs) error {
+	var session *blobSession
+	err := client.TxVoid(
 		func(tx pgx.Tx, ctx context.Context, cancel context.CancelFunc) error {
-			lobs := tx.LargeObjects()
+			session = newBlobSession(ctx, tx, s.blobs)
+			defer session.closeOpened()
+			return f(tx, ctx, session)
+		},
+		pgx.TxOptions{
+			IsoLevel: pgx.ReadCommitted,
+		})()
+	if session != nil {
+		session.complete(err)
+	}
+	return err
+}
 
-			oid, internalErr := lobs.Create(ctx, 0)
+func (s *AssetsService) CreateAsset(userUuid string, upload AssetUpload) (Asset, error) {
+	err := ValidateAssetName(upload.Name)
+	if err != nil {
+		return Asset{}, err
+	}
+	var asset Asset
+	err = s.txWithBlobs(userUuid,
+		func(tx pgx.Tx, ctx context.Context, blobs *blobSession) error {
+			quota, internalErr := s.startQuotaGuard(ctx, tx, userUuid, 0)
 			if internalErr != nil {
 				return internalErr
 			}
-
-			_, internalErr = tx.Exec(ctx, createAssetQuery, name, userUuid, oid)
+			internalErr = quota.reserveFile()
 			if internalErr != nil {
-				var pgErr *pgconn.PgError
-				switch {
-				case errors.As(internalErr, &pgErr):
-					if pgErr.Code == DuplicateErrorCode {
-						return fmt.Errorf("upload asset '%v' error: %w", name, ErrDuplicateAsset)
-					} else {
-						return fmt.Errorf("user '%v' unable to insert assert with name '%v': %w", userUuid, name, internalErr)
-					}
-				default:
-					return fmt.Errorf("user '%v' unable to insert assert with name '%v': %w", userUuid, name, internalErr)
-				}
-
+				return internalErr
 			}
-
-			obj, internalErr := lobs.Open(ctx, oid, pgx.LargeObjectModeWrite)
+			content, internalErr := s.storeContent(blobs, upload, quota)
 			if internalErr != nil {
 				return internalErr
 			}
-
-			written, internalErr := io.Copy(obj, file)
+			asset, internalErr = insertAsset(ctx, tx, userUuid, upload.Name, content, upload.Labels)
 			if internalErr != nil {
 				return internalErr
 			}
-			if written == 0 {
-				slog.Info(fmt.Sprintf("Warning! Stored empty file '%v'\n", name))
-			}
-			return internalErr
-		},
-		pgx.TxOptions{
-			IsoLevel: pgx.ReadCommitted,
-		})()
+			return s.verifyQuota(ctx, tx, userUuid)
+		})
 
-	return err
+	return asset, err
 }
 
-type StartStreamingFunc func(content io.ReadSeeker)
+// NextAssetUploadFunc returns the next upload of the batch or io.EOF if there are no more uploads
+type NextAssetUploadFunc func() (AssetUpload, error)
 
-func (s *AssetsService) GetAsset(name string, userUuid string, startStreaming StartStreamingFunc) error {
-	err := s.client(userUuid).TxVoid(
-		func(tx pgx.Tx, ctx context.Context, cancel context.CancelFunc) error {
-			var oid uint32
-			internalErr := tx.QueryRow(ctx, getAssetQuery, userUuid, name).Scan(&oid)
+// CreateAssets stores all uploads of the batch in the single transaction, so either all of them are created or none of them
+func (s *AssetsService) CreateAssets(userUuid string, next NextAssetUploadFunc) ([]Asset, error) {
+	var assets []Asset
+	err := s.txWithBlobs(userUuid,
+		func(tx pgx.Tx, ctx context.Context, blobs *blobSession) error {
+			assets = []Asset{}
+			quota, internalErr := s.startQuotaGuard(ctx, tx, userUuid, 0)
 			if internalErr != nil {
 				return internalErr
 			}
-
-			lobs := tx.LargeObjects()
-			obj, internalErr := lobs.Open(ctx, oid, pgx.LargeObjectModeRead)
-			if internalErr != nil {
-				return internalErr
+			for {
+				upload, internalErr := next()
+				if internalErr == io.EOF {
+					break
+				}
+				if internalErr != nil {
+					return internalErr
+				}
+				internalErr = ValidateAssetName(upload.Name)
+				if internalErr != nil {
+					return internalErr
+				}
+				internalErr = quota.reserveFile()
+				if internalErr != nil {
+					return internalErr
+				}
+				content, internalErr := s.storeContent(blobs, upload, quota)
+				if internalErr != nil {
+					return internalErr
+				}
+				asset, internalErr := insertAsset(ctx, tx, userUuid, upload.Name, content, upload.Labels)
+				if internalErr != nil {
+					return internalErr
+				}
+				assets = append(assets, asset)
 			}
+			return s.verifyQuota(ctx, tx, userUuid)
+		})
 
-			startStreaming(obj)
+	return assets, err
+}
 
-			return nil
-		},
-		pgx.TxOptions{
-			IsoLevel: pgx.ReadCommitted,
-		})()
+// AssetPrecondition is checked against the current state of the asset under the row lock, current is nil if the asset does not exist
+type AssetPrecondition func(current *Asset) bool
 
+// ReplaceAsset atomically replaces the content of the asset or creates it if it does not exist, created is true in the last case
+func (s *AssetsService) ReplaceAsset(userUuid string, upload AssetUpload, precondition AssetPrecondition) (asset Asset, created bool, err error) {
+	err = ValidateAssetName(upload.Name)
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			return fmt.Errorf("get asset '%v' error: %w", name, ErrNotFoundAsset)
-		}
-		return fmt.Errorf("unable to get asset: %w", err)
+		return asset, false, err
 	}
+	err = s.txWithBlobs(userUuid,
+		func(tx pgx.Tx, ctx context.Context, blobs *blobSession) error {
+			oldBlobId, current, internalErr := scanAssetInfo(tx.QueryRow(ctx, getAssetInfoForUpdateQuery, userUuid, upload.Name))
+			if internalErr != nil && !errors.Is(internalErr, pgx.ErrNoRows) {
+				return internalErr
+			}
+			exists := internalErr == nil
 
-	return nil
-}
+			if precondition != nil {
+				var currentRef *Asset
+				if exists {
+					currentRef = &current
+				}
+				if !precondition(currentRef) {
+					return fmt.Errorf("replace asset '%v' error: %w", upload.Name, ErrPreconditionFailed)
+				}
+			}
 
-func (s *AssetsService) GetAssetList(userUuid string) ([]string, error) {
-	list, err := s.client(userUuid).Tx(
-		func(tx pgx.Tx, ctx context.Context, cancel context.CancelFunc) (any, error) {
-			assetsList := []string{}
-			rows, internalErr := tx.Query(ctx, getAssetsListQuery, userUuid)
+			var freedSize int64
+			if exists && !s.versioning.Enabled {
+				freedSize = current.Size
+			}
+			quota, internalErr := s.startQuotaGuard(ctx, tx, userUuid, freedSize)
 			if internalErr != nil {
-				return nil, internalErr
+				return internalErr
+			}
+			if !exists {
+				internalErr = quota.reserveFile()
+				if internalErr != nil {
+					return internalErr
+				}
 			}
 
-			for rows.Next() {
-				var assetName string
-				internalErr := rows.Scan(&assetName)
+			content, internalErr := s.storeContent(blobs, upload, quota)
+			if internalErr != nil {
+				return internalErr
+			}
+
+			if !exists {
+				created = true
+				asset, internalErr = insertAsset(ctx, tx, userUuid, upload.Name, content, upload.Labels)
+				if errors.Is(internalErr, ErrDuplicateAsset) && precondition != nil {
+					// the asset was created by the concurrent request after the precondition had been checked
+					return fmt.Errorf("replace asset '%v' error: %w", upload.Name, ErrPreconditionFailed)
+				}
 				if internalErr != nil {
-					return nil, fmt.Errorf("unable to scan assets list: %w", internalErr)
+					return internalErr
 				}
-				assetsList = append(assetsList, assetName)
+				return s.verifyQuota(ctx, tx, userUuid)
 			}
 
-			internalErr = rows.Err()
+			asset, internalErr = updateAssetContent(ctx, tx, userUuid, upload.Name, content)
+			if internalErr != nil {
+				return internalErr
+			}
+			if upload.Labels != nil {
+				internalErr = setAssetLabels(ctx, tx, userUuid, upload.Name, upload.Labels)
+				if internalErr != nil {
+					return internalErr
+				}
+			}
+			internalErr = s.retireContent(ctx, tx, blobs, userUuid, oldBlobId, current)
 			if internalErr != nil {
-				return nil, fmt.Errorf("unable to scan assets list: %w", internalErr)
+				return internalErr
 			}
+			return s.verifyQuota(ctx, tx, userUuid)
+		})
 
-			return assetsList, nil
-		},
-		pgx.TxOptions{
-			IsoLevel: pgx.ReadCommitted,
-		})()
+	return asset, created, err
+}
 
+// insertAsset creates the asset with the labels, the labels of the trashed assets with the same name are not inherited
+func insertAsset(ctx context.Context, tx pgx.Tx, userUuid string, name string, content storedContent, labels *AssetLabels) (Asset, error) {
+	if content.Size == 0 {
+		slog.Info(fmt.Sprintf("Warning! Stored empty file '%v'\n", name))
+	}
+	err := checkAssetNameReserved(ctx, tx, userUuid, name)
 	if err != nil {
-		return nil, fmt.Errorf("unable to get assets list: %w", err)
+		return Asset{}, err
 	}
+	asset := content.toAsset(name)
+	err = tx.QueryRow(ctx, createAssetQuery, name, userUuid, content.BlobId, content.Size, content.ContentType, content.Checksum, content.Encoding, content.KeyId, content.DataKey).
+		Scan(&asset.CreateDate, &asset.UpdateDate, &asset.Version)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		switch {
+		case errors.As(err, &pgErr):
+			if pgErr.Code == DuplicateErrorCode {
+				return asset, fmt.Errorf("upload asset '%v' error: %w", name, ErrDuplicateAsset)
+			} else {
+				return asset, fmt.Errorf("user '%v' unable to insert assert with name '%v': %w", userUuid, name, err)
+			}
+		default:
+			return asset, fmt.Errorf("user '%v' unable to insert assert with name '%v': %w", userUuid, name, err)
+		}
+	}
+	return asset, setAssetLabels(ctx, tx, userUuid, name, labels)
+}
 
-	result, ok := list.([]string)
-	if !ok {
-		return nil, fmt.Errorf("unable to convert result into []string")
+func updateAssetContent(ctx context.Context, tx pgx.Tx, userUuid string, name string, content storedContent) (Asset, error) {
+	asset := content.toAsset(name)
+	err := tx.QueryRow(ctx, updateAssetContentQuery, userUuid, name, content.BlobId, content.Size, content.ContentType, content.Checksum, content.Encoding, content.KeyId, content.DataKey).
+		Scan(&asset.CreateDate, &asset.UpdateDate, &asset.Version)
+	if err != nil {
+		return asset, fmt.Errorf("user '%v' unable to update assert with name '%v': %w", userUuid, name, err)
+	}
+	return asset, nil
+}
+
+type storedContent struct {
+	BlobId string
+	// size of the content before the compression
+	Size        int64
+	ContentType string
+	Checksum    string
+	Encoding    string
+	KeyId       string
+	DataKey     []byte
+}
+
+func (c storedContent) toAsset(name string) Asset {
+	return Asset{
+		Name:        name,
+		Size:        c.Size,
+		ContentType: c.ContentType,
+		Checksum:    c.Checksum,
+		Encoding:    c.Encoding,
+		keyId:       c.KeyId,
+		dataKey:     c.DataKey,
+	}
+}
+
+// storeContent puts the uploaded content into the blob store, detecting its type, calculating its checksum, compressing and encrypting it on the fly,
+// the upload is aborted as soon as the content exceeds the allowance of the quota or after the content if it does not match the declared digests
+func (s *AssetsService) storeContent(blobs *blobSession, upload AssetUpload, quota *quotaGuard) (storedContent, error) {
+	var result storedContent
+
+	content := bufio.NewReaderSize(quota.limit(upload.Content), sniffLen)
+	result.ContentType = normalizeContentType(upload.ContentType)
+	if len(result.ContentType) == 0 {
+		contentType, err := detectContentType(content)
+		if err != nil {
+			return result, fmt.Errorf("unable to detect content type of asset '%v': %w", upload.Name, err)
+		}
+		result.ContentType = contentType
 	}
 
+	hasher := newContentHasher(upload.Digests)
+	counter := &countingReader{reader: io.TeeReader(content, hasher)}
+	var stored io.Reader = counter
+	result.Encoding = s.encodingFor(result.ContentType)
+	if len(result.Encoding) > 0 {
+		encoded, release := encodeContent(result.Encoding, counter)
+		defer release()
+		stored = encoded
+	}
+	if s.keys != nil {
+		dataKey, keyId, wrappedKey, err := s.keys.newDataKey()
+		if err != nil {
+			return result, err
+		}
+		stored, err = newEncryptingReader(dataKey, stored)
+		if err != nil {
+			return result, err
+		}
+		result.KeyId = keyId
+		result.DataKey = wrappedKey
+	}
+
+	blobId, _, err := blobs.Put(stored)
+	if err != nil {
+		return result, err
+	}
+	result.BlobId = blobId
+	err = hasher.verify(upload.Digests)
+	if err != nil {
+		return result, fmt.Errorf("unable to store asset '%v': %w", upload.Name, err)
+	}
+	result.Size = counter.count
+	result.Checksum = hex.EncodeToString(hasher.sha256.Sum(nil))
+	quota.consume(counter.count)
+
 	return result, nil
 }
 
-func (s *AssetsService) DeleteAsset(name string, userUuid string) error {
-	err := s.client(userUuid).TxVoid(
-		func(tx pgx.Tx, ctx context.Context, cancel context.CancelFunc) error {
-			var oid uint32
-			internalErr := tx.QueryRow(ctx, getAssetQuery, userUuid, name).Scan(&oid)
-			if internalErr != nil {
-				return internalErr
-			}
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.count += int64(n)
+	return n, err
+}
+
+// normalizeContentType returns the canonical form of the declared content type,
+// it is empty if the declared type is missing or malformed, so the type is detected by the content
+func normalizeContentType(declared string) string {
+	if len(strings.TrimSpace(declared)) == 0 {
+		return ""
+	}
+	mediaType, params, err := mime.ParseMediaType(declared)
+	if err != nil || !strings.Contains(mediaType, "/") || strings.HasPrefix(mediaType, "multipart/") {
+		return ""
+	}
+	return mime.FormatMediaType(mediaType, params)
+}
+
+// detectContentType peeks the first bytes of the content, so they are still available for the reading
+func detectContentType(content *bufio.Reader) (string, error) {
+	head, err := content.Peek(sniffLen)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return http.DetectContentType(head), nil
+}
+
+type StartStreamingFunc func(asset Asset, content io.ReadSeeker)
 
-			lobs := tx.LargeObjects()
-			internalErr = lobs.Unlink(ctx, oid)
+func (s *AssetsService) GetAsset(name string, userUuid string, startStreaming StartStreamingFunc) error {
+	err := s.txWithBlobs(userUuid,
+		func(tx pgx.Tx, ctx context.Context, blobs *blobSession) error {
+			blobId, asset, internalErr := scanAssetInfo(tx.QueryRow(ctx, getAssetInfoQuery, userUuid, name))
 			if internalErr != nil {
 				return internalErr
 			}
 
-			_, internalErr = tx.Exec(ctx, deleteAssetQuery, userUuid, name)
+			content, internalErr := s.openContent(blobs, blobId, asset)
 			if internalErr != nil {
 				return internalErr
 			}
 
+			startStreaming(asset, content)
+
 			return nil
+		})
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return fmt.Errorf("get asset '%v' error: %w", name, ErrNotFoundAsset)
+		}
+		return fmt.Errorf("unable to get asset: %w", err)
+	}
+
+	return nil
+}
+
+// openContent returns the decrypted and decompressed content of the asset, the compressed assets implement EncodedContent
+func (s *AssetsService) openContent(blobs *blobSession, blobId string, asset Asset) (io.ReadSeeker, error) {
+	content, err := blobs.Get(blobId)
+	if err != nil {
+		return nil, err
+	}
+	if len(asset.keyId) > 0 {
+		if s.keys == nil {
+			return nil, fmt.Errorf("unable to decrypt asset '%v': %w", asset.Name, ErrUnknownEncryptionKey)
+		}
+		dataKey, err := s.keys.unwrap(asset.keyId, asset.dataKey)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decrypt asset '%v': %w", asset.Name, err)
+		}
+		content, err = newDecryptingReadSeeker(dataKey, content)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decrypt asset '%v': %w", asset.Name, err)
+		}
+	}
+	if len(asset.Encoding) == 0 {
+		return content, nil
+	}
+	return newDecodingReadSeeker(asset.Encoding, content, asset.Size)
+}
+
+// GetAssetInfo returns the metadata of the asset without touching its content
+func (s *AssetsService) GetAssetInfo(name string, userUuid string) (Asset, error) {
+	result, err := s.client(userUuid).Tx(
+		func(tx pgx.Tx, ctx context.Context, cancel context.CancelFunc) (any, error) {
+			_, asset, internalErr := scanAssetInfo(tx.QueryRow(ctx, getAssetInfoQuery, userUuid, name))
+			return asset, internalErr
 		},
 		pgx.TxOptions{
 			IsoLevel: pgx.ReadCommitted,
 		})()
 
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return Asset{}, fmt.Errorf("get asset '%v' error: %w", name, ErrNotFoundAsset)
+		}
+		return Asset{}, fmt.Errorf("unable to get asset: %w", err)
+	}
+
+	asset, ok := result.(Asset)
+	if !ok {
+		return Asset{}, fmt.Errorf("unable to convert result into Asset")
+	}
+
+	return asset, nil
+}
+
+func scanAssetInfo(row pgx.Row) (string, Asset, error) {
+	var blobId string
+	var asset Asset
+	err := row.Scan(append([]any{&blobId}, asset.scanTargets()...)...)
+	return blobId, asset, err
+}
+
+// DeleteAsset moves the asset into the trash, its content is kept until the trash is purged
+func (s *AssetsService) DeleteAsset(name string, userUuid string) error {
+	err := s.txWithBlobs(userUuid,
+		func(tx pgx.Tx, ctx context.Context, blobs *blobSession) error {
+			var trashId int64
+			return tx.QueryRow(ctx, moveAssetToTrashQuery, userUuid, name).Scan(&trashId)
+		})
+
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return fmt.Errorf("delete asset '%v' error: %w", name, ErrNotFoundAsset)
@@ -0,0 +1,355 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"os"
+	"path"
+	"strings"
+
+	pgx "github.com/jackc/pgx/v5"
+)
+
+const (
+	getAssetsByPrefixQuery = `SELECT ` + assetColumns + ` FROM assets WHERE user_uuid = $1 AND name LIKE $2 ORDER BY name LIMIT $3`
+
+	MaxArchiveAssetsCount = 10000
+
+	ArchiveFormatZip   = "zip"
+	ArchiveFormatTar   = "tar"
+	ArchiveFormatTarGz = "tar.gz"
+
+	// the content of the expanded archives is not limited by the compression ratio until it reaches this size
+	archiveMinExpandedSizeToCheckRatio = 1024 * 1024
+)
+
+var ErrTooManyArchiveAssets = errors.New("too many assets for archive")
+var ErrUnknownArchiveFormat = errors.New("unknown archive format")
+var ErrMalformedArchive = errors.New("malformed archive")
+var ErrArchiveLimitExceeded = errors.New("archive limit exceeded")
+var ErrUnsafeArchiveEntry = errors.New("unsafe path of archive entry")
+var ErrUnsupportedArchiveEntry = errors.New("unsupported type of archive entry")
+
+// ArchivePolicy limits the expanded archives, so the small archive could not produce the enormous count or size of the assets
+type ArchivePolicy struct {
+	MaxEntries      int64
+	MaxExpandedSize int64
+	// max ratio of the expanded size of the entries to the size of the archive
+	MaxCompressionRatio int64
+}
+
+// AssetsSelection chooses the assets of the archive either by the names or by the prefix of the names
+type AssetsSelection struct {
+	// the prefix is ignored if the names are set
+	Names []string
+	// the empty prefix selects all assets of the user
+	Prefix string
+}
+
+// SelectAssets returns the metadata of the selected assets ordered by the name, all of the listed names have to exist
+func (s *AssetsService) SelectAssets(userUuid string, selection AssetsSelection) ([]Asset, error) {
+	names := uniqueNames(selection.Names)
+	if len(names) > MaxArchiveAssetsCount {
+		return nil, fmt.Errorf("%w: %v assets are selected, max count: %v", ErrTooManyArchiveAssets, len(names), MaxArchiveAssetsCount)
+	}
+
+	result, err := s.client(userUuid).Tx(
+		func(tx pgx.Tx, ctx context.Context, cancel context.CancelFunc) (any, error) {
+			var rows pgx.Rows
+			var internalErr error
+			if len(names) > 0 {
+				rows, internalErr = tx.Query(ctx, fmt.Sprintf(getAssetsByNamesQuery, "ASC"), userUuid, names)
+			} else {
+				// one extra row is requested to find out whether the limit is exceeded
+				rows, internalErr = tx.Query(ctx, getAssetsByPrefixQuery, userUuid, escapeLikePattern(selection.Prefix)+"%", MaxArchiveAssetsCount+1)
+			}
+			if internalErr != nil {
+				return nil, internalErr
+			}
+			return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Asset, error) {
+				var asset Asset
+				scanErr := row.Scan(asset.scanTargets()...)
+				return asset, scanErr
+			})
+		},
+		pgx.TxOptions{
+			IsoLevel: pgx.ReadCommitted,
+		})()
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to select assets: %w", err)
+	}
+
+	assets, ok := result.([]Asset)
+	if !ok {
+		return nil, fmt.Errorf("unable to convert result into []Asset")
+	}
+	if len(assets) > MaxArchiveAssetsCount {
+		return nil, fmt.Errorf("%w: more than %v assets have prefix '%v'", ErrTooManyArchiveAssets, MaxArchiveAssetsCount, selection.Prefix)
+	}
+	if len(assets) < len(names) {
+		found := make(map[string]bool, len(assets))
+		for _, asset := range assets {
+			found[asset.Name] = true
+		}
+		for _, name := range names {
+			if !found[name] {
+				return nil, fmt.Errorf("select asset '%v' error: %w", name, ErrNotFoundAsset)
+			}
+		}
+	}
+
+	return assets, nil
+}
+
+func uniqueNames(names []string) []string {
+	result := make([]string, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		result = append(result, name)
+	}
+	return result
+}
+
+// ReadAssetFunc gets the decoded content of the asset, the content is valid only during the call
+type ReadAssetFunc func(asset Asset, content io.Reader) error
+
+// ReadAssets passes the content of the assets to the function one by one. Each asset is read in its own transaction,
+// so the count of the assets is not limited by the query timeout. The assets deleted after the selection are skipped.
+func (s *AssetsService) ReadAssets(userUuid string, names []string, f ReadAssetFunc) error {
+	for _, name := range names {
+		err := s.txWithBlobs(userUuid,
+			func(tx pgx.Tx, ctx context.Context, blobs *blobSession) error {
+				blobId, asset, internalErr := scanAssetInfo(tx.QueryRow(ctx, getAssetInfoQuery, userUuid, name))
+				if internalErr != nil {
+					return internalErr
+				}
+
+				content, internalErr := s.openContent(blobs, blobId, asset)
+				if internalErr != nil {
+					return internalErr
+				}
+
+				return f(asset, content)
+			})
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			slog.Info(fmt.Sprintf("asset '%v' of user '%v' is deleted during reading, it is skipped", name, userUuid))
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("unable to read asset '%v': %w", name, err)
+		}
+	}
+	return nil
+}
+
+// ArchiveEntryResult is the outcome of storing the entry of the archive, Err is nil if the asset is created
+type ArchiveEntryResult struct {
+	Name string
+	Err  error
+}
+
+// ExpandArchive stores each file of zip, tar or tar.gz archive as its own asset by CreateAsset, so the quotas and the duplicates
+// are checked the same way as for the single uploads. The failed entries do not prevent storing the next ones, but the expanding
+// stops at the first malformed entry or the exceeded limit of ArchivePolicy. The directories are skipped. The labels are set for each asset.
+func (s *AssetsService) ExpandArchive(userUuid string, archive io.Reader, labels *AssetLabels) ([]ArchiveEntryResult, error) {
+	compressed := &countingReader{reader: archive}
+	content := bufio.NewReader(compressed)
+	format, err := detectArchiveFormat(content)
+	if err != nil {
+		return nil, err
+	}
+	expander := &archiveExpander{
+		service:    s,
+		userUuid:   userUuid,
+		labels:     labels,
+		policy:     s.archives,
+		compressed: compressed,
+	}
+
+	switch format {
+	case ArchiveFormatZip:
+		return expander.expandZip(content)
+	case ArchiveFormatTarGz:
+		gz, err := gzip.NewReader(content)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrMalformedArchive, err)
+		}
+		defer gz.Close()
+		return expander.expandTar(tar.NewReader(gz)), nil
+	default:
+		return expander.expandTar(tar.NewReader(content)), nil
+	}
+}
+
+// detectArchiveFormat peeks the signature of the archive, so it is still available for the reading
+func detectArchiveFormat(content *bufio.Reader) (string, error) {
+	head, err := content.Peek(262)
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("unable to detect archive format: %w", err)
+	}
+	switch {
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")) || bytes.HasPrefix(head, []byte("PK\x05\x06")):
+		return ArchiveFormatZip, nil
+	case bytes.HasPrefix(head, []byte("\x1f\x8b")):
+		return ArchiveFormatTarGz, nil
+	case len(head) == 262 && string(head[257:262]) == "ustar":
+		return ArchiveFormatTar, nil
+	default:
+		return "", ErrUnknownArchiveFormat
+	}
+}
+
+type archiveExpander struct {
+	service    *AssetsService
+	userUuid   string
+	labels     *AssetLabels
+	policy     ArchivePolicy
+	compressed *countingReader
+	entries    int64
+	expanded   int64
+	results    []ArchiveEntryResult
+}
+
+// expandZip spools the archive into the temporary file, because the entries of zip are listed at the end of the archive
+func (e *archiveExpander) expandZip(content io.Reader) ([]ArchiveEntryResult, error) {
+	spool, err := os.CreateTemp("", "assets-archive-*.zip")
+	if err != nil {
+		return nil, fmt.Errorf("unable to create archive spool: %w", err)
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	size, err := io.Copy(spool, content)
+	if err != nil {
+		return nil, fmt.Errorf("unable to spool archive: %w", err)
+	}
+	reader, err := zip.NewReader(spool, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformedArchive, err)
+	}
+
+	for _, file := range reader.File {
+		if file.Mode().IsDir() {
+			continue
+		}
+		if !file.Mode().IsRegular() {
+			e.skip(file.Name, ErrUnsupportedArchiveEntry)
+			continue
+		}
+		if !e.store(file.Name, func() (io.ReadCloser, error) { return file.Open() }) {
+			break
+		}
+	}
+	return e.results, nil
+}
+
+func (e *archiveExpander) expandTar(reader *tar.Reader) []ArchiveEntryResult {
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			e.results = append(e.results, ArchiveEntryResult{Err: fmt.Errorf("%w: %w", ErrMalformedArchive, err)})
+			break
+		}
+		switch header.Typeflag {
+		case tar.TypeDir, tar.TypeXGlobalHeader:
+			continue
+		case tar.TypeReg:
+			if !e.store(header.Name, func() (io.ReadCloser, error) { return io.NopCloser(reader), nil }) {
+				return e.results
+			}
+		default:
+			e.skip(header.Name, ErrUnsupportedArchiveEntry)
+		}
+	}
+	return e.results
+}
+
+func (e *archiveExpander) skip(name string, err error) {
+	e.results = append(e.results, ArchiveEntryResult{Name: name, Err: fmt.Errorf("archive entry '%v': %w", name, err)})
+}
+
+// store creates the asset of the entry and reports whether the expanding could be continued
+func (e *archiveExpander) store(entryName string, open func() (io.ReadCloser, error)) bool {
+	e.entries++
+	if e.entries > e.policy.MaxEntries {
+		e.skip(entryName, fmt.Errorf("%w: max entries count %v", ErrArchiveLimitExceeded, e.policy.MaxEntries))
+		return false
+	}
+	name, err := archiveEntryAssetName(entryName)
+	if err != nil {
+		e.skip(entryName, err)
+		return true
+	}
+	content, err := open()
+	if err != nil {
+		e.skip(entryName, fmt.Errorf("%w: %w", ErrMalformedArchive, err))
+		return false
+	}
+	defer content.Close()
+
+	_, err = e.service.CreateAsset(e.userUuid, AssetUpload{
+		Name:        name,
+		ContentType: mime.TypeByExtension(path.Ext(name)),
+		Content:     &expansionLimitedReader{reader: content, expander: e},
+		Labels:      e.labels,
+	})
+	e.results = append(e.results, ArchiveEntryResult{Name: name, Err: err})
+	return !errors.Is(err, ErrArchiveLimitExceeded) && !errors.Is(err, ErrMalformedArchive)
+}
+
+// archiveEntryAssetName rejects the absolute paths and the paths out of the archive root (zip slip)
+func archiveEntryAssetName(entryName string) (string, error) {
+	name := strings.TrimPrefix(entryName, "./")
+	if strings.Contains(name, "\\") || !fs.ValidPath(name) {
+		return "", ErrUnsafeArchiveEntry
+	}
+	err := ValidateAssetName(name)
+	if err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// expansionLimitedReader counts the expanded content of all entries against ArchivePolicy,
+// so the decompression stops as soon as the limit is exceeded regardless of the sizes declared by the archive
+type expansionLimitedReader struct {
+	reader   io.Reader
+	expander *archiveExpander
+}
+
+func (r *expansionLimitedReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		e := r.expander
+		e.expanded += int64(n)
+		if e.expanded > e.policy.MaxExpandedSize {
+			return n, fmt.Errorf("%w: max expanded size %v", ErrArchiveLimitExceeded, e.policy.MaxExpandedSize)
+		}
+		if e.expanded > archiveMinExpandedSizeToCheckRatio && e.expanded > e.compressed.count*e.policy.MaxCompressionRatio {
+			return n, fmt.Errorf("%w: max compression ratio %v", ErrArchiveLimitExceeded, e.policy.MaxCompressionRatio)
+		}
+	}
+	if err != nil && err != io.EOF {
+		return n, fmt.Errorf("%w: %w", ErrMalformedArchive, err)
+	}
+	return n, err
+}
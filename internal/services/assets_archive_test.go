@@ -0,0 +1,97 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDetectArchiveFormat(t *testing.T) {
+	var zipped bytes.Buffer
+	zw := zip.NewWriter(&zipped)
+	zw.Close()
+
+	var tarred bytes.Buffer
+	tw := tar.NewWriter(&tarred)
+	tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "file.txt", Size: 1, Mode: 0o644})
+	tw.Write([]byte("a"))
+	tw.Close()
+
+	var gzipped bytes.Buffer
+	gz := gzip.NewWriter(&gzipped)
+	gz.Write(tarred.Bytes())
+	gz.Close()
+
+	tests := []struct {
+		content  []byte
+		expected string
+	}{
+		{zipped.Bytes(), ArchiveFormatZip},
+		{tarred.Bytes(), ArchiveFormatTar},
+		{gzipped.Bytes(), ArchiveFormatTarGz},
+	}
+	for _, test := range tests {
+		actual, err := detectArchiveFormat(bufio.NewReader(bytes.NewReader(test.content)))
+		if err != nil || actual != test.expected {
+			t.Errorf("expected format: %v, actual: %v, error: %v", test.expected, actual, err)
+		}
+	}
+
+	_, err := detectArchiveFormat(bufio.NewReader(strings.NewReader("plain text")))
+	if !errors.Is(err, ErrUnknownArchiveFormat) {
+		t.Errorf("expected error: %v, actual: %v", ErrUnknownArchiveFormat, err)
+	}
+}
+
+func TestArchiveEntryAssetName(t *testing.T) {
+	tests := []struct {
+		entryName string
+		expected  string
+		err       error
+	}{
+		{"docs/report.csv", "docs/report.csv", nil},
+		{"./docs/report.csv", "docs/report.csv", nil},
+		{"../etc/passwd", "", ErrUnsafeArchiveEntry},
+		{"docs/../../etc/passwd", "", ErrUnsafeArchiveEntry},
+		{"/etc/passwd", "", ErrUnsafeArchiveEntry},
+		{"..\\windows\\system.ini", "", ErrUnsafeArchiveEntry},
+		{"docs/-/report.csv", "", ErrWrongAssetName},
+	}
+	for _, test := range tests {
+		actual, err := archiveEntryAssetName(test.entryName)
+		if actual != test.expected || !errors.Is(err, test.err) {
+			t.Errorf("expected name '%v' and error %v for entry '%v', actual: '%v', %v", test.expected, test.err, test.entryName, actual, err)
+		}
+	}
+}
+
+func TestExpansionLimitedReader(t *testing.T) {
+	policy := ArchivePolicy{MaxEntries: 10, MaxExpandedSize: 10 * archiveMinExpandedSizeToCheckRatio, MaxCompressionRatio: 100}
+	bomb := strings.Repeat("0", 2*archiveMinExpandedSizeToCheckRatio)
+
+	// the ratio is checked against the count of the read bytes of the archive
+	expander := &archiveExpander{policy: policy, compressed: &countingReader{count: 1024}}
+	_, err := io.ReadAll(&expansionLimitedReader{reader: strings.NewReader(bomb), expander: expander})
+	if !errors.Is(err, ErrArchiveLimitExceeded) {
+		t.Errorf("expected error: %v, actual: %v", ErrArchiveLimitExceeded, err)
+	}
+
+	expander = &archiveExpander{policy: policy, compressed: &countingReader{count: 1024 * 1024}}
+	_, err = io.ReadAll(&expansionLimitedReader{reader: strings.NewReader(bomb), expander: expander})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	// the size is counted across the entries
+	expander.expanded = policy.MaxExpandedSize - 10
+	_, err = io.ReadAll(&expansionLimitedReader{reader: strings.NewReader(bomb), expander: expander})
+	if !errors.Is(err, ErrArchiveLimitExceeded) {
+		t.Errorf("expected error: %v, actual: %v", ErrArchiveLimitExceeded, err)
+	}
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+
+	pgx "github.com/jackc/pgx/v5"
+)
+
+const (
+	// the assets stored before the checksums were introduced keep the empty checksum if they were too big for the migration,
+	// they are walked by the key after the cursor, so the assets which could not get the checksum do not block the rest
+	getAssetsWithoutChecksumQuery = `SELECT user_uuid, name FROM assets
+		WHERE checksum = '' and ($1::uuid IS NULL or (user_uuid, name) > ($1::uuid, $2))
+		ORDER BY user_uuid, name LIMIT $3`
+	setAssetChecksumQuery = `UPDATE assets SET checksum = $4 WHERE user_uuid = $1 and name = $2 and blob_id = $3 and checksum = ''`
+
+	checksumBackfillBatchSize = 100
+)
+
+// assetKey is the position of the checksums backfill in the assets of the shard
+type assetKey struct {
+	userUuid string
+	name     string
+}
+
+// backfillChecksums calculates the missed checksums of the batch of the assets of the shard after the cursor, each asset
+// is read in its own transaction, so the failed asset does not prevent the others from getting the checksum. It returns
+// the cursor of the next batch, the cursor is nil when the pass is over, so the failed assets are retried by the next pass only.
+func (s *AssetsService) backfillChecksums(client *PostgreSQLService, cursor *assetKey) (*assetKey, error) {
+	var cursorUserUuid *string
+	var cursorName string
+	if cursor != nil {
+		cursorUserUuid = &cursor.userUuid
+		cursorName = cursor.name
+	}
+	result, err := client.Tx(
+		func(tx pgx.Tx, ctx context.Context, cancel context.CancelFunc) (any, error) {
+			rows, internalErr := tx.Query(ctx, getAssetsWithoutChecksumQuery, cursorUserUuid, cursorName, checksumBackfillBatchSize)
+			if internalErr != nil {
+				return nil, internalErr
+			}
+			return pgx.CollectRows(rows, func(row pgx.CollectableRow) (assetKey, error) {
+				var key assetKey
+				scanErr := row.Scan(&key.userUuid, &key.name)
+				return key, scanErr
+			})
+		},
+		pgx.TxOptions{
+			IsoLevel: pgx.ReadCommitted,
+		})()
+	if err != nil {
+		return cursor, fmt.Errorf("unable to get assets without checksum: %w", err)
+	}
+	keys, ok := result.([]assetKey)
+	if !ok {
+		return cursor, fmt.Errorf("unable to convert result into []assetKey")
+	}
+
+	for _, key := range keys {
+		err = s.shardTxWithBlobs(client,
+			func(tx pgx.Tx, ctx context.Context, blobs *blobSession) error {
+				return s.backfillChecksum(ctx, tx, blobs, key.userUuid, key.name)
+			})
+		if err != nil {
+			slog.Error(fmt.Sprintf("unable to calculate checksum of asset '%v' of user '%v': %v", key.name, key.userUuid, err))
+		}
+	}
+	return nextChecksumCursor(keys), nil
+}
+
+// nextChecksumCursor returns the key of the last asset of the full batch, the batch which is not full ends the pass
+func nextChecksumCursor(keys []assetKey) *assetKey {
+	if len(keys) < checksumBackfillBatchSize {
+		return nil
+	}
+	return &keys[len(keys)-1]
+}
+
+func (s *AssetsService) backfillChecksum(ctx context.Context, tx pgx.Tx, blobs *blobSession, userUuid string, name string) error {
+	// the shared lock keeps the content from the replacement while it is read
+	blobId, asset, err := scanAssetInfo(tx.QueryRow(ctx, getAssetInfoForShareQuery, userUuid, name))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(asset.Checksum) > 0 {
+		return nil
+	}
+	content, err := s.openContent(blobs, blobId, asset)
+	if err != nil {
+		return err
+	}
+	checksum, err := sha256Hex(content)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, setAssetChecksumQuery, userUuid, name, blobId, checksum)
+	return err
+}
+
+func sha256Hex(content io.Reader) (string, error) {
+	hasher := sha256.New()
+	_, err := io.Copy(hasher, content)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
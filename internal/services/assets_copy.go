@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	pgx "github.com/jackc/pgx/v5"
+)
+
+const (
+	deleteAssetQuery = `DELETE FROM assets WHERE user_uuid = $1 and name = $2`
+	// the renamed asset takes its history of the versions with it, the history is shifted after the history of the target name,
+	// which is left by the replaced asset or by the trashed ones, so the numbers of the versions keep the order
+	getLastVersionQuery = `SELECT COALESCE(MAX(version), 0) FROM asset_versions WHERE user_uuid = $1 and name = $2`
+	renameAssetQuery    = `UPDATE assets SET name = $3, version = version + $4 WHERE user_uuid = $1 and name = $2
+		RETURNING ` + assetColumns
+	renameAssetVersionsQuery = `UPDATE asset_versions SET name = $3, version = version + $4 WHERE user_uuid = $1 and name = $2`
+)
+
+var ErrSameAssetName = errors.New("same source and target names")
+
+// CopyAsset duplicates the asset inside the blob store of the shard, the existing target is replaced only if overwrite is true,
+// created is false in the last case
+func (s *AssetsService) CopyAsset(name string, userUuid string, target string, overwrite bool) (asset Asset, created bool, err error) {
+	if name == target {
+		return asset, false, fmt.Errorf("copy asset '%v' error: %w", name, ErrSameAssetName)
+	}
+	err = ValidateAssetName(target)
+	if err != nil {
+		return asset, false, err
+	}
+	err = s.txWithBlobs(userUuid,
+		func(tx pgx.Tx, ctx context.Context, blobs *blobSession) error {
+			sourceBlobId, source, internalErr := scanAssetInfo(tx.QueryRow(ctx, getAssetInfoForShareQuery, userUuid, name))
+			if internalErr != nil {
+				if errors.Is(internalErr, pgx.ErrNoRows) {
+					return fmt.Errorf("copy asset '%v' error: %w", name, ErrNotFoundAsset)
+				}
+				return internalErr
+			}
+			targetBlobId, current, exists, internalErr := lockTargetAsset(ctx, tx, userUuid, target, overwrite)
+			if internalErr != nil {
+				return internalErr
+			}
+
+			var freedSize int64
+			if exists && !s.versioning.Enabled {
+				freedSize = current.Size
+			}
+			quota, internalErr := s.startQuotaGuard(ctx, tx, userUuid, freedSize)
+			if internalErr != nil {
+				return internalErr
+			}
+			if !exists {
+				internalErr = quota.reserveFile()
+				if internalErr != nil {
+					return internalErr
+				}
+			}
+			internalErr = quota.reserveSize(source.Size)
+			if internalErr != nil {
+				return internalErr
+			}
+
+			// the content is copied as is, so it keeps the encoding and the data key of the source
+			blobId, internalErr := blobs.Copy(sourceBlobId)
+			if internalErr != nil {
+				return internalErr
+			}
+			content := storedContent{
+				BlobId:      blobId,
+				Size:        source.Size,
+				ContentType: source.ContentType,
+				Checksum:    source.Checksum,
+				Encoding:    source.Encoding,
+				KeyId:       source.keyId,
+				DataKey:     source.dataKey,
+			}
+
+			if !exists {
+				created = true
+				asset, internalErr = insertAsset(ctx, tx, userUuid, target, content, nil)
+				if internalErr != nil {
+					return internalErr
+				}
+				internalErr = copyAssetLabels(ctx, tx, userUuid, name, target)
+				if internalErr != nil {
+					return internalErr
+				}
+				return s.verifyQuota(ctx, tx, userUuid)
+			}
+
+			asset, internalErr = updateAssetContent(ctx, tx, userUuid, target, content)
+			if internalErr != nil {
+				return internalErr
+			}
+			internalErr = copyAssetLabels(ctx, tx, userUuid, name, target)
+			if internalErr != nil {
+				return internalErr
+			}
+			internalErr = s.retireContent(ctx, tx, blobs, userUuid, targetBlobId, current)
+			if internalErr != nil {
+				return internalErr
+			}
+			return s.verifyQuota(ctx, tx, userUuid)
+		})
+
+	if err != nil {
+		return asset, false, fmt.Errorf("unable to copy asset: %w", err)
+	}
+
+	return asset, created, nil
+}
+
+// RenameAsset changes the name of the asset without touching its content, the existing target is replaced only if overwrite is true
+func (s *AssetsService) RenameAsset(name string, userUuid string, target string, overwrite bool) (Asset, error) {
+	if name == target {
+		return Asset{}, fmt.Errorf("rename asset '%v' error: %w", name, ErrSameAssetName)
+	}
+	err := ValidateAssetName(target)
+	if err != nil {
+		return Asset{}, err
+	}
+	var asset Asset
+	err = s.txWithBlobs(userUuid,
+		func(tx pgx.Tx, ctx context.Context, blobs *blobSession) error {
+			_, _, internalErr := scanAssetInfo(tx.QueryRow(ctx, getAssetInfoForUpdateQuery, userUuid, name))
+			if internalErr != nil {
+				if errors.Is(internalErr, pgx.ErrNoRows) {
+					return fmt.Errorf("rename asset '%v' error: %w", name, ErrNotFoundAsset)
+				}
+				return internalErr
+			}
+			targetBlobId, current, exists, internalErr := lockTargetAsset(ctx, tx, userUuid, target, overwrite)
+			if internalErr != nil {
+				return internalErr
+			}
+
+			if exists {
+				_, internalErr = tx.Exec(ctx, deleteAssetQuery, userUuid, target)
+				if internalErr != nil {
+					return fmt.Errorf("user '%v' unable to delete assert with name '%v': %w", userUuid, target, internalErr)
+				}
+				internalErr = s.retireContent(ctx, tx, blobs, userUuid, targetBlobId, current)
+				if internalErr != nil {
+					return internalErr
+				}
+			}
+
+			var shift int
+			internalErr = tx.QueryRow(ctx, getLastVersionQuery, userUuid, target).Scan(&shift)
+			if internalErr != nil {
+				return fmt.Errorf("unable to get versions of asset '%v': %w", target, internalErr)
+			}
+			internalErr = tx.QueryRow(ctx, renameAssetQuery, userUuid, name, target, shift).Scan(asset.scanTargets()...)
+			if internalErr != nil {
+				if isDuplicateError(internalErr) {
+					return fmt.Errorf("rename asset '%v' error: %w", name, ErrDuplicateAsset)
+				}
+				return fmt.Errorf("user '%v' unable to rename assert with name '%v': %w", userUuid, name, internalErr)
+			}
+			_, internalErr = tx.Exec(ctx, renameAssetVersionsQuery, userUuid, name, target, shift)
+			if internalErr != nil {
+				return fmt.Errorf("user '%v' unable to rename versions of asset '%v': %w", userUuid, name, internalErr)
+			}
+			return renameAssetLabels(ctx, tx, userUuid, name, target)
+		})
+
+	if err != nil {
+		return asset, fmt.Errorf("unable to rename asset: %w", err)
+	}
+
+	return asset, nil
+}
+
+// lockTargetAsset locks the target of the copying or the renaming if it exists, the existing target is an error unless overwrite is true
+func lockTargetAsset(ctx context.Context, tx pgx.Tx, userUuid string, target string, overwrite bool) (string, Asset, bool, error) {
+	blobId, current, err := scanAssetInfo(tx.QueryRow(ctx, getAssetInfoForUpdateQuery, userUuid, target))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", current, false, checkAssetNameReserved(ctx, tx, userUuid, target)
+	}
+	if err != nil {
+		return "", current, false, err
+	}
+	if !overwrite {
+		return "", current, true, fmt.Errorf("asset '%v' error: %w", target, ErrDuplicateAsset)
+	}
+	return blobId, current, true, nil
+}
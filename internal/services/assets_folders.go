@@ -0,0 +1,219 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	pgx "github.com/jackc/pgx/v5"
+)
+
+const (
+	// FolderDelimiter separates the folders in the names of the assets, the folders exist only as the prefixes of the names
+	FolderDelimiter = "/"
+	// the segment is reserved for the separation of the name and the sub-resources of the asset in the routes
+	reservedNameSegment = "-"
+	maxAssetNameLength  = 256
+
+	lockFolderQuery        = `SELECT name FROM assets WHERE user_uuid = $1 and name LIKE $2 FOR UPDATE`
+	moveFolderToTrashQuery = `WITH deleted AS (DELETE FROM assets WHERE user_uuid = $1 and name LIKE $2 RETURNING *),
+		labels AS (DELETE FROM asset_labels WHERE user_uuid = $1 and name IN (SELECT name FROM deleted) RETURNING name, ` + trashedLabelColumns + `)
+		INSERT INTO assets_trash (user_uuid, name, blob_id, size, content_type, checksum, encoding, key_id, data_key, create_date, update_date, version, labels)
+		SELECT user_uuid, name, blob_id, size, content_type, checksum, encoding, key_id, data_key, create_date, update_date, version,
+			(SELECT ` + trashedLabelsAggregate + ` FROM labels l WHERE l.name = deleted.name) FROM deleted`
+	getFolderTargetsForUpdateQuery = `SELECT blob_id, ` + assetColumns + ` FROM assets
+		WHERE user_uuid = $1 and name IN (SELECT $4 || substr(name, char_length($3) + 1) FROM assets WHERE user_uuid = $1 and name LIKE $2)
+		FOR UPDATE`
+	// the moved assets take their history of the versions with them the same way as the renamed asset
+	moveFolderQuery = `UPDATE assets a SET name = $4 || substr(a.name, char_length($3) + 1),
+			version = a.version + COALESCE((SELECT MAX(v.version) FROM asset_versions v
+				WHERE v.user_uuid = $1 and v.name = $4 || substr(a.name, char_length($3) + 1)), 0)
+		WHERE a.user_uuid = $1 and a.name LIKE $2`
+	// runs after moveFolderQuery, so the history is moved for the names which are taken by the moved assets
+	moveFolderVersionsQuery = `UPDATE asset_versions v SET name = $4 || substr(v.name, char_length($3) + 1),
+			version = v.version + COALESCE((SELECT MAX(t.version) FROM asset_versions t
+				WHERE t.user_uuid = $1 and t.name = $4 || substr(v.name, char_length($3) + 1)), 0)
+		WHERE v.user_uuid = $1 and v.name LIKE $2
+			and EXISTS (SELECT 1 FROM assets a WHERE a.user_uuid = $1 and a.name = $4 || substr(v.name, char_length($3) + 1))`
+)
+
+var ErrWrongAssetName = errors.New("wrong asset name")
+var ErrNotFoundFolder = errors.New("folder not found")
+var ErrFolderOverlap = errors.New("folder overlaps target folder")
+
+// ValidateAssetName checks that the name is the path of non-empty segments, so it is addressable by the routes and listed by the folders
+func ValidateAssetName(name string) error {
+	if len(name) == 0 {
+		return fmt.Errorf("%w: empty name", ErrWrongAssetName)
+	}
+	if utf8.RuneCountInString(name) > maxAssetNameLength {
+		return fmt.Errorf("%w: name should be at most %v characters", ErrWrongAssetName, maxAssetNameLength)
+	}
+	segments := strings.Split(name, FolderDelimiter)
+	for _, segment := range segments {
+		switch segment {
+		case "", ".", "..", reservedNameSegment:
+			return fmt.Errorf("%w: segment '%v' of name '%v' is not allowed", ErrWrongAssetName, segment, name)
+		}
+	}
+	if isLegacyRoutePath(segments) {
+		return fmt.Errorf("%w: name '%v' matches the path of the sub-resource of the asset '%v'", ErrWrongAssetName, name, segments[0])
+	}
+	return nil
+}
+
+// isLegacyRoutePath checks whether the segments of the name are matched by the routes of the sub-resources
+// of the single segment names without the '-' separator segment, e.g. '/api/asset/{name}/versions/{version}'
+func isLegacyRoutePath(segments []string) bool {
+	switch len(segments) {
+	case 2:
+		switch segments[1] {
+		case "versions", "copy", "rename", "presign":
+			return true
+		}
+	case 3:
+		return segments[1] == "versions"
+	case 4:
+		return segments[1] == "versions" && segments[3] == "restore"
+	}
+	return false
+}
+
+// normalizeFolder returns the prefix of the names of the folder assets, the root folder is not allowed
+func normalizeFolder(folder string) (string, error) {
+	folder = strings.TrimSuffix(folder, FolderDelimiter)
+	err := ValidateAssetName(folder)
+	if err != nil {
+		return "", err
+	}
+	return folder + FolderDelimiter, nil
+}
+
+// DeleteFolder moves all assets of the folder and its subfolders into the trash and returns their count
+func (s *AssetsService) DeleteFolder(folder string, userUuid string) (int64, error) {
+	prefix, err := normalizeFolder(folder)
+	if err != nil {
+		return 0, err
+	}
+	var deleted int64
+	err = s.txWithBlobs(userUuid,
+		func(tx pgx.Tx, ctx context.Context, blobs *blobSession) error {
+			tag, internalErr := tx.Exec(ctx, moveFolderToTrashQuery, userUuid, escapeLikePattern(prefix)+"%")
+			if internalErr != nil {
+				return internalErr
+			}
+			deleted = tag.RowsAffected()
+			if deleted == 0 {
+				return fmt.Errorf("delete folder '%v' error: %w", folder, ErrNotFoundFolder)
+			}
+			return nil
+		})
+
+	if err != nil {
+		return 0, fmt.Errorf("user '%v' unable to delete folder '%v': %w", userUuid, folder, err)
+	}
+
+	return deleted, nil
+}
+
+// MoveFolder renames all assets of the folder and its subfolders, so they keep their paths relative to the target folder,
+// the existing assets with the same names are replaced only if overwrite is true
+func (s *AssetsService) MoveFolder(folder string, userUuid string, target string, overwrite bool) (int64, error) {
+	prefix, err := normalizeFolder(folder)
+	if err != nil {
+		return 0, err
+	}
+	targetPrefix, err := normalizeFolder(target)
+	if err != nil {
+		return 0, err
+	}
+	// the single update could not move the assets into the names of the other assets of the same folder
+	if strings.HasPrefix(prefix, targetPrefix) || strings.HasPrefix(targetPrefix, prefix) {
+		return 0, fmt.Errorf("move folder '%v' to '%v' error: %w", folder, target, ErrFolderOverlap)
+	}
+	pattern := escapeLikePattern(prefix) + "%"
+
+	var moved int64
+	err = s.txWithBlobs(userUuid,
+		func(tx pgx.Tx, ctx context.Context, blobs *blobSession) error {
+			rows, internalErr := tx.Query(ctx, lockFolderQuery, userUuid, pattern)
+			if internalErr != nil {
+				return internalErr
+			}
+			names, internalErr := pgx.CollectRows(rows, pgx.RowTo[string])
+			if internalErr != nil {
+				return fmt.Errorf("unable to lock folder: %w", internalErr)
+			}
+			if len(names) == 0 {
+				return fmt.Errorf("move folder '%v' error: %w", folder, ErrNotFoundFolder)
+			}
+
+			internalErr = s.retireFolderTargets(ctx, tx, blobs, userUuid, pattern, prefix, targetPrefix, overwrite)
+			if internalErr != nil {
+				return internalErr
+			}
+
+			internalErr = moveFolderLabels(ctx, tx, userUuid, pattern, prefix, targetPrefix)
+			if internalErr != nil {
+				return internalErr
+			}
+
+			tag, internalErr := tx.Exec(ctx, moveFolderQuery, userUuid, pattern, prefix, targetPrefix)
+			if internalErr != nil {
+				if isDuplicateError(internalErr) {
+					return fmt.Errorf("move folder '%v' error: %w", folder, ErrDuplicateAsset)
+				}
+				return internalErr
+			}
+			moved = tag.RowsAffected()
+			_, internalErr = tx.Exec(ctx, moveFolderVersionsQuery, userUuid, pattern, prefix, targetPrefix)
+			if internalErr != nil {
+				return fmt.Errorf("unable to move versions: %w", internalErr)
+			}
+			return nil
+		})
+
+	if err != nil {
+		return 0, fmt.Errorf("user '%v' unable to move folder '%v': %w", userUuid, folder, err)
+	}
+
+	return moved, nil
+}
+
+// retireFolderTargets deletes the assets which names are taken by the moved assets, their content is retired the same way as the replaced content
+func (s *AssetsService) retireFolderTargets(ctx context.Context, tx pgx.Tx, blobs *blobSession, userUuid string, pattern string, prefix string, targetPrefix string, overwrite bool) error {
+	rows, err := tx.Query(ctx, getFolderTargetsForUpdateQuery, userUuid, pattern, prefix, targetPrefix)
+	if err != nil {
+		return err
+	}
+	type target struct {
+		blobId string
+		asset  Asset
+	}
+	targets, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (target, error) {
+		var t target
+		var scanErr error
+		t.blobId, t.asset, scanErr = scanAssetInfo(row)
+		return t, scanErr
+	})
+	if err != nil {
+		return fmt.Errorf("unable to scan folder targets: %w", err)
+	}
+	if len(targets) > 0 && !overwrite {
+		return fmt.Errorf("asset '%v' error: %w", targets[0].asset.Name, ErrDuplicateAsset)
+	}
+
+	for _, t := range targets {
+		_, err = tx.Exec(ctx, deleteAssetQuery, userUuid, t.asset.Name)
+		if err != nil {
+			return fmt.Errorf("user '%v' unable to delete assert with name '%v': %w", userUuid, t.asset.Name, err)
+		}
+		err = s.retireContent(ctx, tx, blobs, userUuid, t.blobId, t.asset)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
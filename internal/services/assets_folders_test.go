@@ -0,0 +1,47 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateAssetName(t *testing.T) {
+	for _, name := range []string{"file.txt", "docs/2024/report.csv", "docs/-draft.txt", "docs/a/versions", "a/versions.txt", "a/copy/b", "a/versions/2/b", strings.Repeat("я", maxAssetNameLength)} {
+		err := ValidateAssetName(name)
+		if err != nil {
+			t.Errorf("unexpected error for name '%v': %s", name, err)
+		}
+	}
+	for _, name := range []string{"", "/file.txt", "docs/", "docs//file.txt", "docs/./file.txt", "../file.txt", "docs/-/file.txt", "a/versions", "a/versions/2", "a/copy", "a/rename", "a/presign", "a/versions/2/restore", strings.Repeat("a", maxAssetNameLength+1)} {
+		err := ValidateAssetName(name)
+		if !errors.Is(err, ErrWrongAssetName) {
+			t.Errorf("expected error %v for name '%v', actual: %v", ErrWrongAssetName, name, err)
+		}
+	}
+}
+
+func TestNormalizeFolder(t *testing.T) {
+	for _, folder := range []string{"docs/2024", "docs/2024/"} {
+		prefix, err := normalizeFolder(folder)
+		if err != nil || prefix != "docs/2024/" {
+			t.Errorf("expected prefix: docs/2024/, actual: %v, error: %v", prefix, err)
+		}
+	}
+	for _, folder := range []string{"", "/"} {
+		_, err := normalizeFolder(folder)
+		if !errors.Is(err, ErrWrongAssetName) {
+			t.Errorf("expected error %v for folder '%v', actual: %v", ErrWrongAssetName, folder, err)
+		}
+	}
+}
+
+func TestMoveFolderIntoItself(t *testing.T) {
+	s := &AssetsService{}
+	for _, target := range []string{"docs/2024/old", "docs"} {
+		_, err := s.MoveFolder("docs/2024", "1F615C1D-6BAE-4D8F-EF0B-2FCDC247EF69", target, false)
+		if !errors.Is(err, ErrFolderOverlap) {
+			t.Errorf("expected error %v for target '%v', actual: %v", ErrFolderOverlap, target, err)
+		}
+	}
+}
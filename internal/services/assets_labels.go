@@ -0,0 +1,439 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	pgx "github.com/jackc/pgx/v5"
+)
+
+const (
+	maxLabelKeyLength   = 128
+	maxLabelValueLength = 1024
+	// count of the tags and the metadata keys of the asset together
+	MaxAssetLabels = 64
+	// count of the tag and the metadata filters of the single search
+	MaxLabelFilters = 16
+
+	getAssetsLabelsQuery   = `SELECT name, is_tag, key, value FROM asset_labels WHERE user_uuid = $1 and name = ANY($2) ORDER BY name, key`
+	deleteAssetLabelsQuery = `DELETE FROM asset_labels WHERE user_uuid = $1 and name = $2`
+	insertAssetLabelsQuery = `INSERT INTO asset_labels (user_uuid, name, is_tag, key, value)
+		SELECT $1, $2, l.is_tag, l.key, l.value FROM unnest($3::boolean[], $4::varchar[], $5::varchar[]) AS l(is_tag, key, value)`
+	copyAssetLabelsQuery = `INSERT INTO asset_labels (user_uuid, name, is_tag, key, value)
+		SELECT user_uuid, $3, is_tag, key, value FROM asset_labels WHERE user_uuid = $1 and name = $2`
+	renameAssetLabelsQuery = `UPDATE asset_labels SET name = $3 WHERE user_uuid = $1 and name = $2`
+	// the labels of the replaced targets are deleted, the labels of the moved assets follow them
+	deleteFolderTargetLabelsQuery = `DELETE FROM asset_labels
+		WHERE user_uuid = $1 and name IN (SELECT $4 || substr(name, char_length($3) + 1) FROM assets WHERE user_uuid = $1 and name LIKE $2)`
+	moveFolderLabelsQuery = `UPDATE asset_labels SET name = $4 || substr(name, char_length($3) + 1)
+		WHERE user_uuid = $1 and name IN (SELECT name FROM assets WHERE user_uuid = $1 and name LIKE $2)`
+	// the labels of the trashed asset and of the unfinished upload are kept as JSON array of storedLabel
+	trashedLabelColumns    = `is_tag, key, value`
+	trashedLabelsAggregate = `jsonb_agg(jsonb_build_object('is_tag', l.is_tag, 'key', l.key, 'value', l.value))`
+	// the labels of the trashed assets are kept in the trash, so the labels without the asset are left only by the legacy rows
+	purgeOrphanedLabelsQuery = `DELETE FROM asset_labels WHERE (user_uuid, name, is_tag, key) IN (
+			SELECT l.user_uuid, l.name, l.is_tag, l.key FROM asset_labels l
+			WHERE NOT EXISTS (SELECT 1 FROM assets a WHERE a.user_uuid = l.user_uuid and a.name = l.name)
+			LIMIT $1
+		)`
+)
+
+var ErrWrongAssetLabels = errors.New("wrong asset labels")
+
+// the keys of the metadata are passed in the names of the headers, so they are limited by the case-insensitive subset of the token characters
+var regExpLabelKey = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// AssetLabels are the tags and the user-defined metadata of the asset. They belong to the name of the asset,
+// so they follow the asset on the renaming and are kept while the asset is in the trash.
+type AssetLabels struct {
+	// sorted and unique
+	Tags []string
+	// the keys are lower case
+	Metadata map[string]string
+}
+
+// AssetLabelsPatch changes the labels of the asset, the tags are removed after they are added
+type AssetLabelsPatch struct {
+	AddTags    []string
+	RemoveTags []string
+	// nil value removes the key
+	Metadata map[string]*string
+}
+
+// TagFilter matches the assets with the tag or with any tag which starts with the tag if Prefix is true
+type TagFilter struct {
+	Tag    string
+	Prefix bool
+}
+
+// MetadataFilter matches the assets which value of the key equals the value or starts with it if Prefix is true
+type MetadataFilter struct {
+	Key    string
+	Value  string
+	Prefix bool
+}
+
+// NormalizeAssetLabels validates the labels, drops the duplicated tags and lowercases the keys of the metadata
+func NormalizeAssetLabels(labels AssetLabels) (AssetLabels, error) {
+	result := AssetLabels{Tags: []string{}, Metadata: map[string]string{}}
+	for _, tag := range labels.Tags {
+		tag = strings.TrimSpace(tag)
+		err := validateTag(tag)
+		if err != nil {
+			return result, err
+		}
+		if !slices.Contains(result.Tags, tag) {
+			result.Tags = append(result.Tags, tag)
+		}
+	}
+	slices.Sort(result.Tags)
+	for key, value := range labels.Metadata {
+		key = strings.ToLower(key)
+		err := validateLabelKey(key)
+		if err != nil {
+			return result, err
+		}
+		if _, ok := result.Metadata[key]; ok {
+			return result, fmt.Errorf("%w: duplicated key '%v'", ErrWrongAssetLabels, key)
+		}
+		if utf8.RuneCountInString(value) > maxLabelValueLength || !isPrintable(value) {
+			return result, fmt.Errorf("%w: value of key '%v' should be at most %v printable characters", ErrWrongAssetLabels, key, maxLabelValueLength)
+		}
+		result.Metadata[key] = value
+	}
+	if len(result.Tags)+len(result.Metadata) > MaxAssetLabels {
+		return result, fmt.Errorf("%w: asset could have at most %v tags and metadata keys", ErrWrongAssetLabels, MaxAssetLabels)
+	}
+	return result, nil
+}
+
+// the tags are passed in the comma-separated list of the header
+func validateTag(tag string) error {
+	if len(tag) == 0 || utf8.RuneCountInString(tag) > maxLabelKeyLength || !isPrintable(tag) || strings.Contains(tag, ",") {
+		return fmt.Errorf("%w: tag '%v' should be non-empty, at most %v printable characters without commas", ErrWrongAssetLabels, tag, maxLabelKeyLength)
+	}
+	return nil
+}
+
+func validateLabelKey(key string) error {
+	if len(key) > maxLabelKeyLength || !regExpLabelKey.MatchString(key) {
+		return fmt.Errorf("%w: key '%v' should be at most %v letters, digits, '.', '_' or '-'", ErrWrongAssetLabels, key, maxLabelKeyLength)
+	}
+	return nil
+}
+
+func isPrintable(value string) bool {
+	return strings.IndexFunc(value, unicode.IsControl) < 0
+}
+
+// apply returns the labels changed by the patch, the result is not normalized
+func (p AssetLabelsPatch) apply(labels AssetLabels) AssetLabels {
+	result := AssetLabels{Tags: slices.Clone(labels.Tags), Metadata: map[string]string{}}
+	for key, value := range labels.Metadata {
+		result.Metadata[key] = value
+	}
+	removed := make([]string, 0, len(p.RemoveTags))
+	for _, tag := range p.RemoveTags {
+		removed = append(removed, strings.TrimSpace(tag))
+	}
+	result.Tags = append(result.Tags, p.AddTags...)
+	result.Tags = slices.DeleteFunc(result.Tags, func(tag string) bool {
+		return slices.Contains(removed, strings.TrimSpace(tag))
+	})
+	for key, value := range p.Metadata {
+		key = strings.ToLower(key)
+		if value == nil {
+			delete(result.Metadata, key)
+		} else {
+			result.Metadata[key] = *value
+		}
+	}
+	return result
+}
+
+// GetAssetLabels returns the labels of the existing asset
+func (s *AssetsService) GetAssetLabels(name string, userUuid string) (AssetLabels, error) {
+	result, err := s.client(userUuid).Tx(
+		func(tx pgx.Tx, ctx context.Context, cancel context.CancelFunc) (any, error) {
+			_, _, internalErr := scanAssetInfo(tx.QueryRow(ctx, getAssetInfoQuery, userUuid, name))
+			if internalErr != nil {
+				if errors.Is(internalErr, pgx.ErrNoRows) {
+					return nil, fmt.Errorf("get labels of asset '%v' error: %w", name, ErrNotFoundAsset)
+				}
+				return nil, internalErr
+			}
+			labels, internalErr := loadAssetsLabels(ctx, tx, userUuid, []string{name})
+			if internalErr != nil {
+				return nil, internalErr
+			}
+			return labels[name], nil
+		},
+		pgx.TxOptions{
+			IsoLevel: pgx.RepeatableRead,
+		})()
+
+	if err != nil {
+		return AssetLabels{}, fmt.Errorf("unable to get asset labels: %w", err)
+	}
+
+	labels, ok := result.(AssetLabels)
+	if !ok {
+		return AssetLabels{}, fmt.Errorf("unable to convert result into AssetLabels")
+	}
+	return labels, nil
+}
+
+// GetAssetsLabels returns the labels of the assets by their names, the assets without the labels have the empty labels
+func (s *AssetsService) GetAssetsLabels(userUuid string, names []string) (map[string]AssetLabels, error) {
+	result, err := s.client(userUuid).Tx(
+		func(tx pgx.Tx, ctx context.Context, cancel context.CancelFunc) (any, error) {
+			return loadAssetsLabels(ctx, tx, userUuid, names)
+		},
+		pgx.TxOptions{
+			IsoLevel: pgx.ReadCommitted,
+		})()
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to get assets labels: %w", err)
+	}
+
+	labels, ok := result.(map[string]AssetLabels)
+	if !ok {
+		return nil, fmt.Errorf("unable to convert result into map[string]AssetLabels")
+	}
+	return labels, nil
+}
+
+// PatchAssetLabels changes the labels of the asset under the row lock of the asset, so the concurrent patches are not lost
+func (s *AssetsService) PatchAssetLabels(name string, userUuid string, patch AssetLabelsPatch) (AssetLabels, error) {
+	var labels AssetLabels
+	err := s.client(userUuid).TxVoid(
+		func(tx pgx.Tx, ctx context.Context, cancel context.CancelFunc) error {
+			_, _, internalErr := scanAssetInfo(tx.QueryRow(ctx, getAssetInfoForUpdateQuery, userUuid, name))
+			if internalErr != nil {
+				if errors.Is(internalErr, pgx.ErrNoRows) {
+					return fmt.Errorf("patch labels of asset '%v' error: %w", name, ErrNotFoundAsset)
+				}
+				return internalErr
+			}
+			current, internalErr := loadAssetsLabels(ctx, tx, userUuid, []string{name})
+			if internalErr != nil {
+				return internalErr
+			}
+			labels, internalErr = NormalizeAssetLabels(patch.apply(current[name]))
+			if internalErr != nil {
+				return internalErr
+			}
+			return setAssetLabels(ctx, tx, userUuid, name, &labels)
+		},
+		pgx.TxOptions{
+			IsoLevel: pgx.ReadCommitted,
+		})()
+
+	if err != nil {
+		return AssetLabels{}, fmt.Errorf("unable to patch asset labels: %w", err)
+	}
+	return labels, nil
+}
+
+func loadAssetsLabels(ctx context.Context, tx pgx.Tx, userUuid string, names []string) (map[string]AssetLabels, error) {
+	result := make(map[string]AssetLabels, len(names))
+	for _, name := range names {
+		result[name] = AssetLabels{Tags: []string{}, Metadata: map[string]string{}}
+	}
+	rows, err := tx.Query(ctx, getAssetsLabelsQuery, userUuid, names)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var name, key, value string
+		var isTag bool
+		err := rows.Scan(&name, &isTag, &key, &value)
+		if err != nil {
+			return nil, fmt.Errorf("unable to scan asset labels: %w", err)
+		}
+		labels := result[name]
+		if isTag {
+			labels.Tags = append(labels.Tags, key)
+		} else {
+			labels.Metadata[key] = value
+		}
+		result[name] = labels
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("unable to scan asset labels: %w", err)
+	}
+	return result, nil
+}
+
+// setAssetLabels replaces all labels of the name, nil labels only delete the current ones
+func setAssetLabels(ctx context.Context, tx pgx.Tx, userUuid string, name string, labels *AssetLabels) error {
+	_, err := tx.Exec(ctx, deleteAssetLabelsQuery, userUuid, name)
+	if err != nil {
+		return fmt.Errorf("user '%v' unable to delete labels of asset '%v': %w", userUuid, name, err)
+	}
+	if labels == nil {
+		return nil
+	}
+	normalized, err := NormalizeAssetLabels(*labels)
+	if err != nil {
+		return err
+	}
+	if len(normalized.Tags)+len(normalized.Metadata) == 0 {
+		return nil
+	}
+
+	isTags, keys, values := []bool{}, []string{}, []string{}
+	for _, tag := range normalized.Tags {
+		isTags, keys, values = append(isTags, true), append(keys, tag), append(values, "")
+	}
+	for key, value := range normalized.Metadata {
+		isTags, keys, values = append(isTags, false), append(keys, key), append(values, value)
+	}
+	_, err = tx.Exec(ctx, insertAssetLabelsQuery, userUuid, name, isTags, keys, values)
+	if err != nil {
+		return fmt.Errorf("user '%v' unable to insert labels of asset '%v': %w", userUuid, name, err)
+	}
+	return nil
+}
+
+// storedLabel is the item of JSON array, which keeps the labels of the trashed asset or of the unfinished upload
+type storedLabel struct {
+	IsTag bool   `json:"is_tag"`
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// marshalLabels makes JSON array of the labels, nil labels are kept as NULL
+func marshalLabels(labels *AssetLabels) ([]byte, error) {
+	if labels == nil {
+		return nil, nil
+	}
+	stored := []storedLabel{}
+	for _, tag := range labels.Tags {
+		stored = append(stored, storedLabel{IsTag: true, Key: tag})
+	}
+	for key, value := range labels.Metadata {
+		stored = append(stored, storedLabel{Key: key, Value: value})
+	}
+	return json.Marshal(stored)
+}
+
+// unmarshalLabels reads JSON array of the labels, NULL means no labels
+func unmarshalLabels(data []byte) (*AssetLabels, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var stored []storedLabel
+	err := json.Unmarshal(data, &stored)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read stored labels: %w", err)
+	}
+	labels := AssetLabels{Tags: []string{}, Metadata: map[string]string{}}
+	for _, label := range stored {
+		if label.IsTag {
+			labels.Tags = append(labels.Tags, label.Key)
+		} else {
+			labels.Metadata[label.Key] = label.Value
+		}
+	}
+	return &labels, nil
+}
+
+// copyAssetLabels replaces the labels of the target by the labels of the source
+func copyAssetLabels(ctx context.Context, tx pgx.Tx, userUuid string, name string, target string) error {
+	_, err := tx.Exec(ctx, deleteAssetLabelsQuery, userUuid, target)
+	if err != nil {
+		return fmt.Errorf("user '%v' unable to delete labels of asset '%v': %w", userUuid, target, err)
+	}
+	_, err = tx.Exec(ctx, copyAssetLabelsQuery, userUuid, name, target)
+	if err != nil {
+		return fmt.Errorf("user '%v' unable to copy labels of asset '%v': %w", userUuid, name, err)
+	}
+	return nil
+}
+
+// renameAssetLabels moves the labels of the source to the target, the labels of the target are replaced
+func renameAssetLabels(ctx context.Context, tx pgx.Tx, userUuid string, name string, target string) error {
+	_, err := tx.Exec(ctx, deleteAssetLabelsQuery, userUuid, target)
+	if err != nil {
+		return fmt.Errorf("user '%v' unable to delete labels of asset '%v': %w", userUuid, target, err)
+	}
+	_, err = tx.Exec(ctx, renameAssetLabelsQuery, userUuid, name, target)
+	if err != nil {
+		return fmt.Errorf("user '%v' unable to rename labels of asset '%v': %w", userUuid, name, err)
+	}
+	return nil
+}
+
+// moveFolderLabels moves the labels of the assets of the folder, it has to be called before the assets are moved
+func moveFolderLabels(ctx context.Context, tx pgx.Tx, userUuid string, pattern string, prefix string, targetPrefix string) error {
+	_, err := tx.Exec(ctx, deleteFolderTargetLabelsQuery, userUuid, pattern, prefix, targetPrefix)
+	if err != nil {
+		return fmt.Errorf("user '%v' unable to delete labels of folder targets: %w", userUuid, err)
+	}
+	_, err = tx.Exec(ctx, moveFolderLabelsQuery, userUuid, pattern, prefix, targetPrefix)
+	if err != nil {
+		return fmt.Errorf("user '%v' unable to move labels of folder '%v': %w", userUuid, prefix, err)
+	}
+	return nil
+}
+
+// normalizeLabelFilters validates the filters of the search and lowercases the keys of the metadata
+func normalizeLabelFilters(query AssetsListQuery) (AssetsListQuery, error) {
+	if len(query.Tags)+len(query.Metadata) > MaxLabelFilters {
+		return query, fmt.Errorf("%w: at most %v tag and metadata filters are allowed", ErrWrongAssetsListQuery, MaxLabelFilters)
+	}
+	tags := make([]TagFilter, 0, len(query.Tags))
+	for _, filter := range query.Tags {
+		filter.Tag = strings.TrimSpace(filter.Tag)
+		if len(filter.Tag) == 0 {
+			return query, fmt.Errorf("%w: empty tag filter", ErrWrongAssetsListQuery)
+		}
+		tags = append(tags, filter)
+	}
+	metadata := make([]MetadataFilter, 0, len(query.Metadata))
+	for _, filter := range query.Metadata {
+		filter.Key = strings.ToLower(filter.Key)
+		if validateLabelKey(filter.Key) != nil {
+			return query, fmt.Errorf("%w: wrong metadata key '%v'", ErrWrongAssetsListQuery, filter.Key)
+		}
+		metadata = append(metadata, filter)
+	}
+	query.Tags, query.Metadata = tags, metadata
+	return query, nil
+}
+
+// writeLabelConditions adds the conditions of the tag and the metadata filters on the names of the assets,
+// the user uuid has to be the first argument
+func writeLabelConditions(b *strings.Builder, args []any, query AssetsListQuery) []any {
+	const exists = " AND EXISTS (SELECT 1 FROM asset_labels l WHERE l.user_uuid = $1 AND l.name = assets.name AND l.is_tag = %v AND l.key %v $%v%v)"
+	for _, filter := range query.Tags {
+		if filter.Prefix {
+			args = append(args, escapeLikePattern(filter.Tag)+"%")
+			b.WriteString(fmt.Sprintf(exists, true, "LIKE", len(args), ""))
+		} else {
+			args = append(args, filter.Tag)
+			b.WriteString(fmt.Sprintf(exists, true, "=", len(args), ""))
+		}
+	}
+	for _, filter := range query.Metadata {
+		args = append(args, filter.Key)
+		if filter.Prefix {
+			args = append(args, escapeLikePattern(filter.Value)+"%")
+			b.WriteString(fmt.Sprintf(exists, false, "=", len(args)-1, fmt.Sprintf(" AND l.value LIKE $%v", len(args))))
+		} else {
+			args = append(args, filter.Value)
+			b.WriteString(fmt.Sprintf(exists, false, "=", len(args)-1, fmt.Sprintf(" AND l.value = $%v", len(args))))
+		}
+	}
+	return args
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeAssetLabels(t *testing.T) {
+	labels, err := NormalizeAssetLabels(AssetLabels{
+		Tags:     []string{" release ", "nightly", "release"},
+		Metadata: map[string]string{"Build-Number": "42", "project": "billing"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !slices.Equal(labels.Tags, []string{"nightly", "release"}) {
+		t.Errorf("unexpected tags: %v", labels.Tags)
+	}
+	if labels.Metadata["build-number"] != "42" || labels.Metadata["project"] != "billing" || len(labels.Metadata) != 2 {
+		t.Errorf("unexpected metadata: %v", labels.Metadata)
+	}
+
+	wrong := []AssetLabels{
+		{Tags: []string{""}},
+		{Tags: []string{"a,b"}},
+		{Metadata: map[string]string{"build number": "42"}},
+		{Metadata: map[string]string{"build": "4\n2"}},
+		{Metadata: map[string]string{"Build": "42", "build": "43"}},
+		{Metadata: map[string]string{"build": strings.Repeat("x", maxLabelValueLength+1)}},
+	}
+	for _, item := range wrong {
+		_, err = NormalizeAssetLabels(item)
+		if !errors.Is(err, ErrWrongAssetLabels) {
+			t.Errorf("expected error: %v for labels: %v, actual: %v", ErrWrongAssetLabels, item, err)
+		}
+	}
+}
+
+func TestApplyAssetLabelsPatch(t *testing.T) {
+	prod := "prod"
+	current := AssetLabels{Tags: []string{"nightly"}, Metadata: map[string]string{"build": "42", "project": "billing"}}
+	patch := AssetLabelsPatch{
+		AddTags:    []string{"release"},
+		RemoveTags: []string{"nightly "},
+		Metadata:   map[string]*string{"Environment": &prod, "build": nil},
+	}
+
+	labels, err := NormalizeAssetLabels(patch.apply(current))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !slices.Equal(labels.Tags, []string{"release"}) {
+		t.Errorf("unexpected tags: %v", labels.Tags)
+	}
+	if labels.Metadata["environment"] != "prod" || labels.Metadata["project"] != "billing" || len(labels.Metadata) != 2 {
+		t.Errorf("unexpected metadata: %v", labels.Metadata)
+	}
+	if len(current.Tags) != 1 || len(current.Metadata) != 2 {
+		t.Errorf("expected unchanged current labels, actual: %v", current)
+	}
+}
+
+func TestBuildAssetsListQueryWithLabelFilters(t *testing.T) {
+	query, err := normalizeAssetsListQuery(AssetsListQuery{
+		Tags:     []TagFilter{{Tag: "release"}, {Tag: "v1_", Prefix: true}},
+		Metadata: []MetadataFilter{{Key: "Project", Value: "billing"}, {Key: "build", Value: "42.", Prefix: true}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	sqlQuery, args, err := buildAssetsListQuery("1F615C1D-6BAE-4D8F-EF0B-2FCDC247EF69", query)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := []string{
+		"l.is_tag = true AND l.key = $2)",
+		"l.is_tag = true AND l.key LIKE $3)",
+		"l.is_tag = false AND l.key = $4 AND l.value = $5)",
+		"l.is_tag = false AND l.key = $6 AND l.value LIKE $7)",
+		"ORDER BY name ASC LIMIT $8",
+	}
+	for _, condition := range expected {
+		if !strings.Contains(sqlQuery, condition) {
+			t.Errorf("expected condition: %v, actual query: %v", condition, sqlQuery)
+		}
+	}
+	if args[2] != `v1\_%` || args[3] != "project" || args[6] != "42.%" {
+		t.Errorf("unexpected args: %v", args)
+	}
+}
+
+func TestNormalizeAssetsListQueryWithWrongLabelFilters(t *testing.T) {
+	wrong := []AssetsListQuery{
+		{Tags: []TagFilter{{Tag: " "}}},
+		{Metadata: []MetadataFilter{{Key: "build number", Value: "42"}}},
+		{Tags: make([]TagFilter, MaxLabelFilters+1)},
+	}
+	for _, query := range wrong {
+		_, err := normalizeAssetsListQuery(query)
+		if !errors.Is(err, ErrWrongAssetsListQuery) {
+			t.Errorf("expected error: %v, actual: %v", ErrWrongAssetsListQuery, err)
+		}
+	}
+}
+
+func TestMarshalLabels(t *testing.T) {
+	data, err := marshalLabels(nil)
+	if err != nil || data != nil {
+		t.Errorf("expected NULL for nil labels, actual: %v, error: %v", string(data), err)
+	}
+	labels, err := unmarshalLabels(nil)
+	if err != nil || labels != nil {
+		t.Errorf("expected nil labels for NULL, actual: %v, error: %v", labels, err)
+	}
+
+	expected := AssetLabels{Tags: []string{"draft", "q3"}, Metadata: map[string]string{"build": "42"}}
+	data, err = marshalLabels(&expected)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	labels, err = unmarshalLabels(data)
+	if err != nil || labels == nil {
+		t.Fatalf("expected labels, actual: %v, error: %v", labels, err)
+	}
+	if !slices.Equal(labels.Tags, expected.Tags) || len(labels.Metadata) != 1 || labels.Metadata["build"] != "42" {
+		t.Errorf("expected labels: %+v, actual: %+v", expected, *labels)
+	}
+
+	// the format of the labels aggregated by the queries of the trash
+	labels, err = unmarshalLabels([]byte(`[{"is_tag": true, "key": "draft", "value": ""}, {"is_tag": false, "key": "build", "value": "42"}]`))
+	if err != nil || !slices.Equal(labels.Tags, []string{"draft"}) || labels.Metadata["build"] != "42" {
+		t.Errorf("expected labels of the trash, actual: %+v, error: %v", labels, err)
+	}
+}
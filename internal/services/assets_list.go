@@ -0,0 +1,353 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+)
+
+const (
+	getAssetsByNamesQuery = `SELECT ` + assetColumns + ` FROM assets WHERE user_uuid = $1 AND name = ANY($2) ORDER BY name %v`
+)
+
+const (
+	AssetsSortByName       = "name"
+	AssetsSortBySize       = "size"
+	AssetsSortByCreateDate = "create_date"
+
+	DefaultAssetsListLimit = 100
+	MaxAssetsListLimit     = 1000
+)
+
+var ErrWrongAssetsListQuery = errors.New("wrong assets list query")
+
+type AssetsListQuery struct {
+	Limit int
+	// opaque value of AssetsPage.NextCursor of the previous page, empty for the first page
+	Cursor string
+	Prefix string
+	// the assets which names contain the delimiter after the prefix are rolled up into the common prefixes,
+	// it is supported only for the sorting by name
+	Delimiter  string
+	SortBy     string
+	Descending bool
+	// all filters have to match the labels of the asset
+	Tags     []TagFilter
+	Metadata []MetadataFilter
+}
+
+type AssetsPage struct {
+	Assets []Asset
+	// the names up to the first delimiter after the prefix, they are counted by the limit together with the assets
+	CommonPrefixes []string
+	// empty if there are no more assets
+	NextCursor string
+}
+
+// assetsCursor points to the last asset of the page, the name is used as a tie-breaker for non-unique sort keys
+type assetsCursor struct {
+	SortBy     string `json:"s"`
+	Descending bool   `json:"d"`
+	Value      string `json:"v"`
+	Name       string `json:"n"`
+}
+
+func (s *AssetsService) GetAssetList(userUuid string, query AssetsListQuery) (AssetsPage, error) {
+	query, err := normalizeAssetsListQuery(query)
+	if err != nil {
+		return AssetsPage{}, err
+	}
+	if len(query.Delimiter) > 0 {
+		return s.getAssetEntries(userUuid, query)
+	}
+	sqlQuery, args, err := buildAssetsListQuery(userUuid, query)
+	if err != nil {
+		return AssetsPage{}, err
+	}
+
+	result, err := s.client(userUuid).Tx(
+		func(tx pgx.Tx, ctx context.Context, cancel context.CancelFunc) (any, error) {
+			assetsList := []Asset{}
+			rows, internalErr := tx.Query(ctx, sqlQuery, args...)
+			if internalErr != nil {
+				return nil, internalErr
+			}
+
+			for rows.Next() {
+				var asset Asset
+				internalErr := rows.Scan(asset.scanTargets()...)
+				if internalErr != nil {
+					return nil, fmt.Errorf("unable to scan assets list: %w", internalErr)
+				}
+				assetsList = append(assetsList, asset)
+			}
+
+			internalErr = rows.Err()
+			if internalErr != nil {
+				return nil, fmt.Errorf("unable to scan assets list: %w", internalErr)
+			}
+
+			return assetsList, nil
+		},
+		pgx.TxOptions{
+			IsoLevel: pgx.ReadCommitted,
+		})()
+
+	if err != nil {
+		return AssetsPage{}, fmt.Errorf("unable to get assets list: %w", err)
+	}
+
+	list, ok := result.([]Asset)
+	if !ok {
+		return AssetsPage{}, fmt.Errorf("unable to convert result into []Asset")
+	}
+
+	// one extra row is requested to find out whether the next page exists
+	page := AssetsPage{Assets: list}
+	if len(list) > query.Limit {
+		page.Assets = list[:query.Limit]
+		page.NextCursor = encodeAssetsCursor(query, page.Assets[query.Limit-1])
+	}
+
+	return page, nil
+}
+
+func normalizeAssetsListQuery(query AssetsListQuery) (AssetsListQuery, error) {
+	if query.Limit <= 0 {
+		query.Limit = DefaultAssetsListLimit
+	}
+	if query.Limit > MaxAssetsListLimit {
+		return query, fmt.Errorf("%w: limit should be less than or equal to %v", ErrWrongAssetsListQuery, MaxAssetsListLimit)
+	}
+	if len(query.SortBy) == 0 {
+		query.SortBy = AssetsSortByName
+	}
+	if query.SortBy != AssetsSortByName && query.SortBy != AssetsSortBySize && query.SortBy != AssetsSortByCreateDate {
+		return query, fmt.Errorf("%w: unknown sort field '%v'", ErrWrongAssetsListQuery, query.SortBy)
+	}
+	if len(query.Delimiter) > 0 && query.SortBy != AssetsSortByName {
+		return query, fmt.Errorf("%w: delimiter is supported only for sorting by name", ErrWrongAssetsListQuery)
+	}
+	return normalizeLabelFilters(query)
+}
+
+// buildAssetsListQuery makes the keyset pagination query, the query has to be normalized before
+func buildAssetsListQuery(userUuid string, query AssetsListQuery) (string, []any, error) {
+	var b strings.Builder
+	args := []any{userUuid}
+	b.WriteString(fmt.Sprintf("SELECT %v FROM assets WHERE user_uuid = $1", assetColumns))
+
+	if len(query.Prefix) > 0 {
+		args = append(args, escapeLikePattern(query.Prefix)+"%")
+		b.WriteString(fmt.Sprintf(" AND name LIKE $%v", len(args)))
+	}
+	args = writeLabelConditions(&b, args, query)
+
+	comparison := ">"
+	order := "ASC"
+	if query.Descending {
+		comparison = "<"
+		order = "DESC"
+	}
+
+	if len(query.Cursor) > 0 {
+		cursor, err := decodeAssetsCursor(query)
+		if err != nil {
+			return "", nil, err
+		}
+		switch query.SortBy {
+		case AssetsSortByName:
+			args = append(args, cursor.Name)
+			b.WriteString(fmt.Sprintf(" AND name %v $%v", comparison, len(args)))
+		case AssetsSortBySize:
+			size, err := strconv.ParseInt(cursor.Value, 10, 64)
+			if err != nil {
+				return "", nil, fmt.Errorf("%w: wrong cursor", ErrWrongAssetsListQuery)
+			}
+			args = append(args, size, cursor.Name)
+			b.WriteString(fmt.Sprintf(" AND (size, name) %v ($%v, $%v)", comparison, len(args)-1, len(args)))
+		case AssetsSortByCreateDate:
+			createDate, err := time.Parse(time.RFC3339Nano, cursor.Value)
+			if err != nil {
+				return "", nil, fmt.Errorf("%w: wrong cursor", ErrWrongAssetsListQuery)
+			}
+			args = append(args, createDate.UTC(), cursor.Name)
+			b.WriteString(fmt.Sprintf(" AND (create_date, name) %v ($%v, $%v)", comparison, len(args)-1, len(args)))
+		}
+	}
+
+	if query.SortBy == AssetsSortByName {
+		b.WriteString(fmt.Sprintf(" ORDER BY name %v", order))
+	} else {
+		b.WriteString(fmt.Sprintf(" ORDER BY %v %v, name %v", query.SortBy, order, order))
+	}
+
+	args = append(args, query.Limit+1)
+	b.WriteString(fmt.Sprintf(" LIMIT $%v", len(args)))
+
+	return b.String(), args, nil
+}
+
+// getAssetEntries returns the page of the assets and the common prefixes sorted by name together,
+// the metadata of the assets is requested separately for the entries of the page
+func (s *AssetsService) getAssetEntries(userUuid string, query AssetsListQuery) (AssetsPage, error) {
+	sqlQuery, args, err := buildAssetEntriesQuery(userUuid, query)
+	if err != nil {
+		return AssetsPage{}, err
+	}
+
+	result, err := s.client(userUuid).Tx(
+		func(tx pgx.Tx, ctx context.Context, cancel context.CancelFunc) (any, error) {
+			rows, internalErr := tx.Query(ctx, sqlQuery, args...)
+			if internalErr != nil {
+				return nil, internalErr
+			}
+			entries, internalErr := pgx.CollectRows(rows, func(row pgx.CollectableRow) (assetEntry, error) {
+				var entry assetEntry
+				err := row.Scan(&entry.name, &entry.isPrefix)
+				return entry, err
+			})
+			if internalErr != nil {
+				return nil, fmt.Errorf("unable to scan assets list: %w", internalErr)
+			}
+
+			// one extra entry is requested to find out whether the next page exists
+			page := AssetsPage{Assets: []Asset{}, CommonPrefixes: []string{}}
+			if len(entries) > query.Limit {
+				entries = entries[:query.Limit]
+				page.NextCursor = encodeAssetsCursor(query, Asset{Name: entries[query.Limit-1].name})
+			}
+			names := []string{}
+			for _, entry := range entries {
+				if entry.isPrefix {
+					page.CommonPrefixes = append(page.CommonPrefixes, entry.name)
+				} else {
+					names = append(names, entry.name)
+				}
+			}
+			if len(names) == 0 {
+				return page, nil
+			}
+
+			order := "ASC"
+			if query.Descending {
+				order = "DESC"
+			}
+			rows, internalErr = tx.Query(ctx, fmt.Sprintf(getAssetsByNamesQuery, order), userUuid, names)
+			if internalErr != nil {
+				return nil, internalErr
+			}
+			for rows.Next() {
+				var asset Asset
+				internalErr := rows.Scan(asset.scanTargets()...)
+				if internalErr != nil {
+					return nil, fmt.Errorf("unable to scan assets list: %w", internalErr)
+				}
+				page.Assets = append(page.Assets, asset)
+			}
+			internalErr = rows.Err()
+			if internalErr != nil {
+				return nil, fmt.Errorf("unable to scan assets list: %w", internalErr)
+			}
+
+			return page, nil
+		},
+		pgx.TxOptions{
+			IsoLevel: pgx.RepeatableRead,
+		})()
+
+	if err != nil {
+		return AssetsPage{}, fmt.Errorf("unable to get assets list: %w", err)
+	}
+
+	page, ok := result.(AssetsPage)
+	if !ok {
+		return AssetsPage{}, fmt.Errorf("unable to convert result into AssetsPage")
+	}
+
+	return page, nil
+}
+
+type assetEntry struct {
+	name     string
+	isPrefix bool
+}
+
+// buildAssetEntriesQuery makes the keyset pagination query of the entries, each entry is either the name of the asset
+// or the common prefix which ends with the first delimiter after the prefix, the query has to be normalized before
+func buildAssetEntriesQuery(userUuid string, query AssetsListQuery) (string, []any, error) {
+	args := []any{userUuid, escapeLikePattern(query.Prefix) + "%", query.Prefix, query.Delimiter}
+	// the position of the delimiter in the rest of the name after the prefix
+	delimiterPos := "strpos(substr(name, char_length($3) + 1), $4)"
+	entry := fmt.Sprintf("CASE WHEN %[1]v > 0 THEN left(name, char_length($3) + %[1]v + char_length($4) - 1) ELSE name END", delimiterPos)
+
+	var filters strings.Builder
+	args = writeLabelConditions(&filters, args, query)
+
+	var conditions strings.Builder
+	comparison := ">"
+	order := "ASC"
+	if query.Descending {
+		comparison = "<"
+		order = "DESC"
+	}
+	if len(query.Cursor) > 0 {
+		cursor, err := decodeAssetsCursor(query)
+		if err != nil {
+			return "", nil, err
+		}
+		args = append(args, cursor.Name)
+		conditions.WriteString(fmt.Sprintf(" WHERE entry %v $%v", comparison, len(args)))
+	}
+	args = append(args, query.Limit+1)
+
+	sqlQuery := fmt.Sprintf(`SELECT entry, bool_or(is_prefix) FROM (
+			SELECT %v AS entry, %v > 0 AS is_prefix FROM assets WHERE user_uuid = $1 AND name LIKE $2%v
+		) entries%v
+		GROUP BY entry ORDER BY entry %v LIMIT $%v`, entry, delimiterPos, filters.String(), conditions.String(), order, len(args))
+	return sqlQuery, args, nil
+}
+
+func encodeAssetsCursor(query AssetsListQuery, last Asset) string {
+	cursor := assetsCursor{
+		SortBy:     query.SortBy,
+		Descending: query.Descending,
+		Name:       last.Name,
+	}
+	switch cursor.SortBy {
+	case AssetsSortBySize:
+		cursor.Value = strconv.FormatInt(last.Size, 10)
+	case AssetsSortByCreateDate:
+		cursor.Value = last.CreateDate.UTC().Format(time.RFC3339Nano)
+	}
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeAssetsCursor(query AssetsListQuery) (assetsCursor, error) {
+	var cursor assetsCursor
+	data, err := base64.RawURLEncoding.DecodeString(query.Cursor)
+	if err != nil {
+		return cursor, fmt.Errorf("%w: wrong cursor", ErrWrongAssetsListQuery)
+	}
+	err = json.Unmarshal(data, &cursor)
+	if err != nil {
+		return cursor, fmt.Errorf("%w: wrong cursor", ErrWrongAssetsListQuery)
+	}
+	if cursor.SortBy != query.SortBy || cursor.Descending != query.Descending {
+		return cursor, fmt.Errorf("%w: cursor was created for another sort order", ErrWrongAssetsListQuery)
+	}
+	return cursor, nil
+}
+
+func escapeLikePattern(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return replacer.Replace(value)
+}
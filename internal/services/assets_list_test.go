@@ -0,0 +1,91 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAssetsCursorRoundTrip(t *testing.T) {
+	query := AssetsListQuery{Limit: 10, SortBy: AssetsSortByCreateDate, Descending: true}
+	last := Asset{Name: "file2.txt", CreateDate: time.Date(2024, 7, 1, 10, 0, 0, 123456000, time.UTC)}
+
+	query.Cursor = encodeAssetsCursor(query, last)
+	cursor, err := decodeAssetsCursor(query)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cursor.Name != last.Name {
+		t.Errorf("expected cursor name: %v, actual: %v", last.Name, cursor.Name)
+	}
+	if cursor.Value != "2024-07-01T10:00:00.123456Z" {
+		t.Errorf("unexpected cursor value: %v", cursor.Value)
+	}
+}
+
+func TestAssetsCursorOfAnotherSortOrder(t *testing.T) {
+	query := AssetsListQuery{Limit: 10, SortBy: AssetsSortBySize}
+	query.Cursor = encodeAssetsCursor(query, Asset{Name: "file2.txt", Size: 42})
+	query.SortBy = AssetsSortByName
+
+	_, err := decodeAssetsCursor(query)
+	if !errors.Is(err, ErrWrongAssetsListQuery) {
+		t.Errorf("expected error: %v, actual: %v", ErrWrongAssetsListQuery, err)
+	}
+}
+
+func TestBuildAssetsListQuery(t *testing.T) {
+	query, err := normalizeAssetsListQuery(AssetsListQuery{Prefix: "report_%", SortBy: AssetsSortBySize})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	query.Cursor = encodeAssetsCursor(query, Asset{Name: "file2.txt", Size: 42})
+
+	sqlQuery, args, err := buildAssetsListQuery("1F615C1D-6BAE-4D8F-EF0B-2FCDC247EF69", query)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !strings.Contains(sqlQuery, "AND name LIKE $2 AND (size, name) > ($3, $4) ORDER BY size ASC, name ASC LIMIT $5") {
+		t.Errorf("unexpected query: %v", sqlQuery)
+	}
+	if args[1] != `report\_\%%` {
+		t.Errorf("unexpected prefix pattern: %v", args[1])
+	}
+	if args[4] != DefaultAssetsListLimit+1 {
+		t.Errorf("unexpected limit: %v", args[4])
+	}
+}
+
+func TestNormalizeAssetsListQueryWithUnknownSortField(t *testing.T) {
+	_, err := normalizeAssetsListQuery(AssetsListQuery{SortBy: "checksum"})
+	if !errors.Is(err, ErrWrongAssetsListQuery) {
+		t.Errorf("expected error: %v, actual: %v", ErrWrongAssetsListQuery, err)
+	}
+}
+
+func TestBuildAssetEntriesQuery(t *testing.T) {
+	query, err := normalizeAssetsListQuery(AssetsListQuery{Prefix: "docs/", Delimiter: "/", Descending: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	query.Cursor = encodeAssetsCursor(query, Asset{Name: "docs/2024/"})
+
+	sqlQuery, args, err := buildAssetEntriesQuery("1F615C1D-6BAE-4D8F-EF0B-2FCDC247EF69", query)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !strings.Contains(sqlQuery, "WHERE entry < $5") || !strings.Contains(sqlQuery, "GROUP BY entry ORDER BY entry DESC LIMIT $6") {
+		t.Errorf("unexpected query: %v", sqlQuery)
+	}
+	if args[1] != "docs/%" || args[2] != "docs/" || args[3] != "/" || args[4] != "docs/2024/" {
+		t.Errorf("unexpected args: %v", args)
+	}
+}
+
+func TestNormalizeAssetsListQueryWithDelimiter(t *testing.T) {
+	_, err := normalizeAssetsListQuery(AssetsListQuery{Delimiter: "/", SortBy: AssetsSortBySize})
+	if !errors.Is(err, ErrWrongAssetsListQuery) {
+		t.Errorf("expected error: %v, actual: %v", ErrWrongAssetsListQuery, err)
+	}
+}
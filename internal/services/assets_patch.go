@@ -0,0 +1,210 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+
+	pgx "github.com/jackc/pgx/v5"
+)
+
+const (
+	getAssetHashStateQuery    = `SELECT hash_state FROM assets WHERE user_uuid = $1 and name = $2`
+	updateAssetHashStateQuery = `UPDATE assets SET hash_state = $3 WHERE user_uuid = $1 and name = $2`
+)
+
+var ErrWrongContentRange = errors.New("wrong content range")
+
+// AssetPatch writes the content into the existing asset starting at the offset, the content beyond the end extends the asset
+type AssetPatch struct {
+	Name string
+	// negative offset appends the content to the end of the asset
+	Offset int64
+	// exact length of the content, negative if it is unknown
+	Length int64
+	// expected size of the asset after the patch, negative if it is unknown
+	Total   int64
+	Content io.Reader
+}
+
+// PatchAsset writes the range of the content under the row lock of the asset, so the concurrent patches are applied one by one.
+// The plain content of the transactional blob store is written in place if the versioning is disabled,
+// otherwise the content is rewritten as the new blob, e.g. to compress or to encrypt the whole content again.
+func (s *AssetsService) PatchAsset(userUuid string, patch AssetPatch, precondition AssetPrecondition) (Asset, error) {
+	var asset Asset
+	err := s.txWithBlobs(userUuid,
+		func(tx pgx.Tx, ctx context.Context, blobs *blobSession) error {
+			blobId, current, internalErr := scanAssetInfo(tx.QueryRow(ctx, getAssetInfoForUpdateQuery, userUuid, patch.Name))
+			if internalErr != nil {
+				return internalErr
+			}
+			if precondition != nil && !precondition(&current) {
+				return fmt.Errorf("patch asset '%v' error: %w", patch.Name, ErrPreconditionFailed)
+			}
+			offset := patch.Offset
+			if offset < 0 {
+				offset = current.Size
+			}
+			if offset > current.Size {
+				return fmt.Errorf("%w: offset %v is beyond the size %v of asset '%v'", ErrWrongContentRange, offset, current.Size, patch.Name)
+			}
+
+			var freedSize int64
+			if !s.versioning.Enabled {
+				freedSize = current.Size
+			}
+			quota, internalErr := s.startQuotaGuard(ctx, tx, userUuid, freedSize)
+			if internalErr != nil {
+				return internalErr
+			}
+
+			patchContent := &countingReader{reader: patch.Content}
+			if patch.Length >= 0 {
+				patchContent.reader = &strictLimitedReader{reader: patch.Content, remaining: patch.Length, exceededErr: fmt.Errorf("%w: content is longer than %v bytes", ErrWrongContentRange, patch.Length)}
+			}
+			var content storedContent
+			var hashState []byte
+			if s.blobs.Transactional() && !s.versioning.Enabled && len(current.Encoding) == 0 && len(current.keyId) == 0 {
+				internalErr = tx.QueryRow(ctx, getAssetHashStateQuery, userUuid, patch.Name).Scan(&hashState)
+				if internalErr != nil {
+					return fmt.Errorf("unable to load checksum state of asset '%v': %w", patch.Name, internalErr)
+				}
+				content, hashState, internalErr = s.writeContentInPlace(blobs, blobId, current, offset, quota.limitAt(offset, patchContent), hashState)
+			} else {
+				content, internalErr = s.rewriteContent(blobs, blobId, current, offset, patchContent, quota)
+			}
+			if internalErr != nil {
+				return internalErr
+			}
+			if patch.Length >= 0 && patchContent.count != patch.Length {
+				return fmt.Errorf("%w: expected %v bytes of content, actual: %v", ErrWrongContentRange, patch.Length, patchContent.count)
+			}
+			if patch.Total >= 0 && content.Size != patch.Total {
+				return fmt.Errorf("%w: expected size %v of asset '%v', actual: %v", ErrWrongContentRange, patch.Total, patch.Name, content.Size)
+			}
+
+			asset, internalErr = updateAssetContent(ctx, tx, userUuid, patch.Name, content)
+			if internalErr != nil {
+				return internalErr
+			}
+			if hashState != nil {
+				_, internalErr = tx.Exec(ctx, updateAssetHashStateQuery, userUuid, patch.Name, hashState)
+				if internalErr != nil {
+					return fmt.Errorf("unable to save checksum state of asset '%v': %w", patch.Name, internalErr)
+				}
+			}
+			if content.BlobId != blobId {
+				internalErr = s.retireContent(ctx, tx, blobs, userUuid, blobId, current)
+				if internalErr != nil {
+					return internalErr
+				}
+			}
+			return s.verifyQuota(ctx, tx, userUuid)
+		})
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Asset{}, fmt.Errorf("patch asset '%v' error: %w", patch.Name, ErrNotFoundAsset)
+		}
+		return Asset{}, err
+	}
+	return asset, nil
+}
+
+// writeContentInPlace writes the range into the existing blob and returns the state of SHA-256 of the new content.
+// The append resumes the saved state of the previous content, so only the appended bytes are hashed,
+// otherwise the checksum is calculated by the whole content again.
+func (s *AssetsService) writeContentInPlace(blobs *blobSession, blobId string, current Asset, offset int64, patch io.Reader, hashState []byte) (storedContent, []byte, error) {
+	hasher, resumed := resumeHash(hashState, current.Checksum)
+	resumed = resumed && offset == current.Size
+	if resumed {
+		patch = io.TeeReader(patch, hasher)
+	}
+	written, err := blobs.WriteAt(blobId, offset, patch)
+	if err != nil {
+		return storedContent{}, nil, err
+	}
+	size := max(current.Size, offset+written)
+
+	if !resumed {
+		content, err := blobs.Get(blobId)
+		if err != nil {
+			return storedContent{}, nil, err
+		}
+		hasher = sha256.New()
+		read, err := io.Copy(hasher, content)
+		if err != nil {
+			return storedContent{}, nil, fmt.Errorf("unable to calculate checksum of asset '%v': %w", current.Name, err)
+		}
+		if read != size {
+			return storedContent{}, nil, fmt.Errorf("unexpected size %v of asset '%v' after writing %v bytes at %v", read, current.Name, written, offset)
+		}
+	}
+	state, err := marshalHash(hasher)
+	if err != nil {
+		return storedContent{}, nil, err
+	}
+
+	return storedContent{
+		BlobId:      blobId,
+		Size:        size,
+		ContentType: current.ContentType,
+		Checksum:    hex.EncodeToString(hasher.Sum(nil)),
+	}, state, nil
+}
+
+// resumeHash restores the saved state of SHA-256, the state is valid only if it is the state of the content with the checksum,
+// e.g. it is stale after the content is replaced without the patch
+func resumeHash(state []byte, checksum string) (hash.Hash, bool) {
+	if len(state) == 0 || len(checksum) == 0 {
+		return nil, false
+	}
+	hasher := sha256.New()
+	err := unmarshalHash(hasher, state)
+	if err != nil || hex.EncodeToString(hasher.Sum(nil)) != checksum {
+		return nil, false
+	}
+	return hasher, true
+}
+
+// rewriteContent stores the previous content with the range replaced as the new blob
+func (s *AssetsService) rewriteContent(blobs *blobSession, blobId string, current Asset, offset int64, patch *countingReader, quota *quotaGuard) (storedContent, error) {
+	previous, err := s.openContent(blobs, blobId, current)
+	if err != nil {
+		return storedContent{}, err
+	}
+	// the rest of the previous content follows the patch, so its offset is known only after the patch is read
+	rest := &lazyOffsetReader{content: previous, size: current.Size, offset: func() int64 { return offset + patch.count }}
+	return s.storeContent(blobs, AssetUpload{
+		Name:        current.Name,
+		ContentType: current.ContentType,
+		Content:     io.MultiReader(io.LimitReader(previous, offset), patch, rest),
+	}, quota)
+}
+
+// lazyOffsetReader seeks the content to the offset on the first reading, the offset beyond the size means the end of the content
+type lazyOffsetReader struct {
+	content io.ReadSeeker
+	size    int64
+	offset  func() int64
+	started bool
+}
+
+func (r *lazyOffsetReader) Read(p []byte) (int, error) {
+	if !r.started {
+		offset := r.offset()
+		if offset >= r.size {
+			return 0, io.EOF
+		}
+		_, err := r.content.Seek(offset, io.SeekStart)
+		if err != nil {
+			return 0, err
+		}
+		r.started = true
+	}
+	return r.content.Read(p)
+}
@@ -0,0 +1,316 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	pgx "github.com/jackc/pgx/v5"
+)
+
+const (
+	// NULL columns of the overrides mean the default limits
+	getUserQuotaQuery = `SELECT max_files, max_file_size, max_total_size, deleted FROM user_quotas WHERE user_uuid = $1`
+	// the total size includes the previous versions and the trash, because their content is still stored,
+	// and the declared length of the unfinished uploads, because it is reserved for them
+	getUsageQuery = `SELECT
+			(SELECT COUNT(*) FROM assets WHERE user_uuid = $1),
+			(SELECT COALESCE(SUM(size), 0) FROM assets WHERE user_uuid = $1)
+			+ (SELECT COALESCE(SUM(size), 0) FROM asset_versions WHERE user_uuid = $1)
+			+ (SELECT COALESCE(SUM(size), 0) FROM assets_trash WHERE user_uuid = $1)
+			+ (SELECT COALESCE(SUM(length), 0) FROM uploads WHERE user_uuid = $1 and blob_id IS NOT NULL)`
+	setUserQuotaQuery = `INSERT INTO user_quotas (user_uuid, max_files, max_file_size, max_total_size) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_uuid) DO UPDATE SET max_files = $2, max_file_size = $3, max_total_size = $4`
+	// the versions are not checked, because they are kept only with the assets or the trashed assets
+	hasContentQuery = `SELECT EXISTS (SELECT 1 FROM assets WHERE user_uuid = $1)
+		or EXISTS (SELECT 1 FROM assets_trash WHERE user_uuid = $1)
+		or EXISTS (SELECT 1 FROM uploads WHERE user_uuid = $1 and blob_id IS NOT NULL)`
+	// serializes the final quota checks of the concurrent uploads of the user until the end of the transaction
+	lockUserQuotaQuery    = `SELECT pg_advisory_xact_lock(hashtext($1))`
+	markOwnerDeletedQuery = `INSERT INTO user_quotas (user_uuid, deleted) VALUES ($1, $2)
+		ON CONFLICT (user_uuid) DO UPDATE SET deleted = $2`
+)
+
+var ErrQuotaExceeded = errors.New("quota exceeded")
+var ErrFilesCountQuotaExceeded = fmt.Errorf("%w: max files count", ErrQuotaExceeded)
+var ErrFileSizeQuotaExceeded = fmt.Errorf("%w: max file size", ErrQuotaExceeded)
+var ErrTotalSizeQuotaExceeded = fmt.Errorf("%w: max total size of files", ErrQuotaExceeded)
+var ErrOwnerHasContent = errors.New("owner has content")
+var ErrDeletedOwner = errors.New("owner is deleted")
+
+// Quota limits the storage consumption of the user, 0 means no limit
+type Quota struct {
+	MaxFiles     int64
+	MaxFileSize  int64
+	MaxTotalSize int64
+}
+
+// QuotaOverride replaces the default limits for the user, nil keeps the default limit and 0 means no limit
+type QuotaOverride struct {
+	MaxFiles     *int64
+	MaxFileSize  *int64
+	MaxTotalSize *int64
+}
+
+type Usage struct {
+	Files     int64
+	TotalSize int64
+	Limits    Quota
+	// the owner is marked by DeleteOwner
+	deleted bool
+}
+
+func (s *AssetsService) GetUsage(userUuid string) (Usage, error) {
+	result, err := s.client(userUuid).Tx(
+		func(tx pgx.Tx, ctx context.Context, cancel context.CancelFunc) (any, error) {
+			return s.getUsage(ctx, tx, userUuid)
+		},
+		pgx.TxOptions{
+			IsoLevel: pgx.ReadCommitted,
+		})()
+
+	if err != nil {
+		return Usage{}, fmt.Errorf("unable to get usage: %w", err)
+	}
+
+	usage, ok := result.(Usage)
+	if !ok {
+		return Usage{}, fmt.Errorf("unable to convert result into Usage")
+	}
+
+	return usage, nil
+}
+
+// DeleteOwner marks the owner without any assets, trashed assets or unfinished uploads as deleted on its shard,
+// e.g. before the group is deleted. The mark is checked by verifyQuota under the same lock, so the content of the owner
+// which is stored concurrently either is found by the check or is refused.
+func (s *AssetsService) DeleteOwner(ownerUuid string) error {
+	err := s.client(ownerUuid).TxVoid(
+		func(tx pgx.Tx, ctx context.Context, cancel context.CancelFunc) error {
+			_, internalErr := tx.Exec(ctx, lockUserQuotaQuery, ownerUuid)
+			if internalErr != nil {
+				return fmt.Errorf("unable to lock quota: %w", internalErr)
+			}
+			var exists bool
+			internalErr = tx.QueryRow(ctx, hasContentQuery, ownerUuid).Scan(&exists)
+			if internalErr != nil {
+				return fmt.Errorf("unable to check content: %w", internalErr)
+			}
+			if exists {
+				return ErrOwnerHasContent
+			}
+			_, internalErr = tx.Exec(ctx, markOwnerDeletedQuery, ownerUuid, true)
+			return internalErr
+		},
+		pgx.TxOptions{
+			IsoLevel: pgx.ReadCommitted,
+		})()
+
+	if err != nil {
+		return fmt.Errorf("unable to delete owner '%v': %w", ownerUuid, err)
+	}
+	return nil
+}
+
+// RestoreOwner removes the mark of DeleteOwner, e.g. if the deletion of the group is failed after the mark
+func (s *AssetsService) RestoreOwner(ownerUuid string) error {
+	err := s.client(ownerUuid).TxVoid(
+		func(tx pgx.Tx, ctx context.Context, cancel context.CancelFunc) error {
+			_, internalErr := tx.Exec(ctx, markOwnerDeletedQuery, ownerUuid, false)
+			return internalErr
+		},
+		pgx.TxOptions{
+			IsoLevel: pgx.ReadCommitted,
+		})()
+
+	if err != nil {
+		return fmt.Errorf("unable to restore owner '%v': %w", ownerUuid, err)
+	}
+	return nil
+}
+
+// SetUserQuota stores the overrides of the default limits for the user, the usage above the new limits is kept,
+// but the user is not able to store more until it is below them
+func (s *AssetsService) SetUserQuota(userUuid string, override QuotaOverride) error {
+	err := s.client(userUuid).TxVoid(
+		func(tx pgx.Tx, ctx context.Context, cancel context.CancelFunc) error {
+			_, internalErr := tx.Exec(ctx, setUserQuotaQuery, userUuid, override.MaxFiles, override.MaxFileSize, override.MaxTotalSize)
+			return internalErr
+		},
+		pgx.TxOptions{
+			IsoLevel: pgx.ReadCommitted,
+		})()
+
+	if err != nil {
+		return fmt.Errorf("unable to set quota of user '%v': %w", userUuid, err)
+	}
+
+	return nil
+}
+
+// ParseQuotaLimit parses the limit of the quota override, 'default' means the default limit
+func ParseQuotaLimit(value string) (*int64, error) {
+	if value == "default" {
+		return nil, nil
+	}
+	limit, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || limit < 0 {
+		return nil, fmt.Errorf("limit should be 'default' or a non-negative number: %v", value)
+	}
+	return &limit, nil
+}
+
+func (s *AssetsService) getUsage(ctx context.Context, tx pgx.Tx, userUuid string) (Usage, error) {
+	usage := Usage{Limits: s.defaultQuota}
+
+	var maxFiles, maxFileSize, maxTotalSize *int64
+	err := tx.QueryRow(ctx, getUserQuotaQuery, userUuid).Scan(&maxFiles, &maxFileSize, &maxTotalSize, &usage.deleted)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return usage, fmt.Errorf("unable to get quota of user '%v': %w", userUuid, err)
+	}
+	if maxFiles != nil {
+		usage.Limits.MaxFiles = *maxFiles
+	}
+	if maxFileSize != nil {
+		usage.Limits.MaxFileSize = *maxFileSize
+	}
+	if maxTotalSize != nil {
+		usage.Limits.MaxTotalSize = *maxTotalSize
+	}
+
+	err = tx.QueryRow(ctx, getUsageQuery, userUuid).Scan(&usage.Files, &usage.TotalSize)
+	if err != nil {
+		return usage, fmt.Errorf("unable to get usage of user '%v': %w", userUuid, err)
+	}
+
+	return usage, nil
+}
+
+// quotaGuard enforces the quota of the user within the transaction:
+// the upload is aborted as soon as it exceeds the allowance calculated at the start,
+// and the final usage is verified by verifyQuota before the commit, because the concurrent uploads share the same allowance.
+type quotaGuard struct {
+	limits Quota
+	// remaining count of the new assets, negative means no limit
+	remainingFiles int64
+	// remaining bytes of the total size, negative means no limit
+	remainingTotal int64
+}
+
+// startQuotaGuard calculates the allowance of the transaction,
+// freedSize is the size of the content which is released by the transaction (e.g. the replaced content)
+func (s *AssetsService) startQuotaGuard(ctx context.Context, tx pgx.Tx, userUuid string, freedSize int64) (*quotaGuard, error) {
+	usage, err := s.getUsage(ctx, tx, userUuid)
+	if err != nil {
+		return nil, err
+	}
+	limits := usage.Limits
+
+	remainingFiles := int64(-1)
+	if limits.MaxFiles > 0 {
+		remainingFiles = max(limits.MaxFiles-usage.Files, 0)
+	}
+	remainingTotal := int64(-1)
+	if limits.MaxTotalSize > 0 {
+		remainingTotal = max(limits.MaxTotalSize-usage.TotalSize+freedSize, 0)
+	}
+
+	return &quotaGuard{
+		limits:         limits,
+		remainingFiles: remainingFiles,
+		remainingTotal: remainingTotal,
+	}, nil
+}
+
+// reserveFile checks that the user is able to store one more asset
+func (g *quotaGuard) reserveFile() error {
+	if g.remainingFiles < 0 {
+		return nil
+	}
+	if g.remainingFiles == 0 {
+		return ErrFilesCountQuotaExceeded
+	}
+	g.remainingFiles--
+	return nil
+}
+
+// limit wraps the content of the single asset
+func (g *quotaGuard) limit(content io.Reader) io.Reader {
+	return g.limitAt(0, content)
+}
+
+// limitAt wraps the content which is written at the offset of the single asset, the bytes before the offset are counted too
+func (g *quotaGuard) limitAt(offset int64, content io.Reader) io.Reader {
+	switch {
+	case g.limits.MaxFileSize > 0 && (g.remainingTotal < 0 || g.limits.MaxFileSize <= g.remainingTotal):
+		return &strictLimitedReader{reader: content, remaining: max(g.limits.MaxFileSize-offset, 0), exceededErr: ErrFileSizeQuotaExceeded}
+	case g.remainingTotal >= 0:
+		return &strictLimitedReader{reader: content, remaining: max(g.remainingTotal-offset, 0), exceededErr: ErrTotalSizeQuotaExceeded}
+	default:
+		return content
+	}
+}
+
+// reserveSize checks that the user is able to store the content of the declared size
+func (g *quotaGuard) reserveSize(size int64) error {
+	if g.limits.MaxFileSize > 0 && size > g.limits.MaxFileSize {
+		return ErrFileSizeQuotaExceeded
+	}
+	if g.remainingTotal >= 0 && size > g.remainingTotal {
+		return ErrTotalSizeQuotaExceeded
+	}
+	g.consume(size)
+	return nil
+}
+
+// consume reduces the allowance for the next assets of the same transaction
+func (g *quotaGuard) consume(size int64) {
+	if g.remainingTotal < 0 {
+		return
+	}
+	g.remainingTotal = max(g.remainingTotal-size, 0)
+}
+
+// verifyQuota checks the usage including the changes of the transaction, it has to be called right before the commit
+func (s *AssetsService) verifyQuota(ctx context.Context, tx pgx.Tx, userUuid string) error {
+	_, err := tx.Exec(ctx, lockUserQuotaQuery, userUuid)
+	if err != nil {
+		return fmt.Errorf("unable to lock quota of user '%v': %w", userUuid, err)
+	}
+	usage, err := s.getUsage(ctx, tx, userUuid)
+	if err != nil {
+		return err
+	}
+	if usage.deleted {
+		return fmt.Errorf("owner '%v' error: %w", userUuid, ErrDeletedOwner)
+	}
+	if usage.Limits.MaxFiles > 0 && usage.Files > usage.Limits.MaxFiles {
+		return ErrFilesCountQuotaExceeded
+	}
+	if usage.Limits.MaxTotalSize > 0 && usage.TotalSize > usage.Limits.MaxTotalSize {
+		return ErrTotalSizeQuotaExceeded
+	}
+	return nil
+}
+
+// strictLimitedReader fails with exceededErr if the content is longer than the limit, unlike io.LimitedReader which silently truncates it
+type strictLimitedReader struct {
+	reader      io.Reader
+	remaining   int64
+	exceededErr error
+}
+
+func (r *strictLimitedReader) Read(p []byte) (int, error) {
+	// one extra byte is requested to find out whether the content exceeds the limit
+	if int64(len(p)) > r.remaining+1 {
+		p = p[:r.remaining+1]
+	}
+	n, err := r.reader.Read(p)
+	if int64(n) > r.remaining {
+		return 0, r.exceededErr
+	}
+	r.remaining -= int64(n)
+	return n, err
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestQuotaGuardLimit(t *testing.T) {
+	tests := []struct {
+		name      string
+		guard     quotaGuard
+		content   string
+		expectErr error
+	}{
+		{"no limit", quotaGuard{remainingTotal: -1}, "0123456789", nil},
+		{"exact allowance", quotaGuard{limits: Quota{MaxTotalSize: 10}, remainingTotal: 10}, "0123456789", nil},
+		{"file size", quotaGuard{limits: Quota{MaxFileSize: 5}, remainingTotal: -1}, "0123456789", ErrFileSizeQuotaExceeded},
+		{"total size", quotaGuard{limits: Quota{MaxFileSize: 5, MaxTotalSize: 10}, remainingTotal: 3}, "0123456789", ErrTotalSizeQuotaExceeded},
+		{"empty allowance", quotaGuard{limits: Quota{MaxTotalSize: 10}, remainingTotal: 0}, "0", ErrTotalSizeQuotaExceeded},
+	}
+
+	for _, test := range tests {
+		actual, err := io.ReadAll(test.guard.limit(strings.NewReader(test.content)))
+		if test.expectErr != nil {
+			if !errors.Is(err, test.expectErr) {
+				t.Errorf("%v: expected error: %v, actual: %v", test.name, test.expectErr, err)
+			}
+			if !errors.Is(err, ErrQuotaExceeded) {
+				t.Errorf("%v: expected quota error, actual: %v", test.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: unexpected error: %s", test.name, err)
+		}
+		if string(actual) != test.content {
+			t.Errorf("%v: expected content: %v, actual: %v", test.name, test.content, string(actual))
+		}
+	}
+}
+
+func TestQuotaGuardConsume(t *testing.T) {
+	guard := quotaGuard{limits: Quota{MaxFileSize: 5, MaxTotalSize: 10}, remainingTotal: 10}
+	guard.consume(5)
+	if guard.remainingTotal != 5 {
+		t.Errorf("expected remaining total: 5, actual: %v", guard.remainingTotal)
+	}
+	// the next asset still may have the max file size
+	_, err := io.ReadAll(guard.limit(strings.NewReader("01234")))
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	guard.consume(100)
+	if guard.remainingTotal != 0 {
+		t.Errorf("expected remaining total: 0, actual: %v", guard.remainingTotal)
+	}
+
+	unlimited := quotaGuard{remainingTotal: -1}
+	unlimited.consume(4)
+	if unlimited.remainingTotal != -1 {
+		t.Errorf("expected remaining total: -1, actual: %v", unlimited.remainingTotal)
+	}
+}
+
+func TestQuotaGuardReserveFile(t *testing.T) {
+	guard := quotaGuard{remainingFiles: 1}
+	err := guard.reserveFile()
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	err = guard.reserveFile()
+	if !errors.Is(err, ErrFilesCountQuotaExceeded) {
+		t.Errorf("expected error: %v, actual: %v", ErrFilesCountQuotaExceeded, err)
+	}
+
+	unlimited := quotaGuard{remainingFiles: -1}
+	err = unlimited.reserveFile()
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestParseQuotaLimit(t *testing.T) {
+	limit, err := ParseQuotaLimit("default")
+	if err != nil || limit != nil {
+		t.Errorf("expected the default limit, actual: %v, error: %v", limit, err)
+	}
+	limit, err = ParseQuotaLimit("0")
+	if err != nil || limit == nil || *limit != 0 {
+		t.Errorf("expected no limit, actual: %v, error: %v", limit, err)
+	}
+	limit, err = ParseQuotaLimit("1073741824")
+	if err != nil || limit == nil || *limit != 1073741824 {
+		t.Errorf("expected limit: 1073741824, actual: %v, error: %v", limit, err)
+	}
+	for _, value := range []string{"-1", "1GB", ""} {
+		_, err = ParseQuotaLimit(value)
+		if err == nil {
+			t.Errorf("expected error for the limit '%v'", value)
+		}
+	}
+}
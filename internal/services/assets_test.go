@@ -0,0 +1,126 @@
+package services
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeContentType(t *testing.T) {
+	tests := []struct {
+		declared string
+		expected string
+	}{
+		{"", ""},
+		{"text/plain", "text/plain"},
+		{"Text/HTML; Charset=UTF-8", "text/html; charset=UTF-8"},
+		{"text", ""},
+		{"text/plain; charset", ""},
+		{"multipart/form-data; boundary=abc", ""},
+	}
+	for _, test := range tests {
+		actual := normalizeContentType(test.declared)
+		if actual != test.expected {
+			t.Errorf("expected '%v' for '%v', actual: '%v'", test.expected, test.declared, actual)
+		}
+	}
+}
+
+func TestVerifyContentDigests(t *testing.T) {
+	content := []byte("content")
+	sha := sha256.Sum256(content)
+	md := md5.Sum(content)
+
+	hasher := newContentHasher(ContentDigests{SHA256: sha[:], MD5: md[:]})
+	hasher.Write(content)
+	err := hasher.verify(ContentDigests{SHA256: sha[:], MD5: md[:]})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	wrong := ContentDigests{MD5: make([]byte, md5.Size)}
+	hasher = newContentHasher(wrong)
+	hasher.Write(content)
+	err = hasher.verify(wrong)
+	if !errors.Is(err, ErrDigestMismatch) {
+		t.Errorf("expected error: %v, actual: %v", ErrDigestMismatch, err)
+	}
+}
+
+func TestLazyOffsetReader(t *testing.T) {
+	previous := strings.NewReader("0123456789")
+	patch := &countingReader{reader: strings.NewReader("abc")}
+	rest := &lazyOffsetReader{content: previous, size: previous.Size(), offset: func() int64 { return 4 + patch.count }}
+	actual, err := io.ReadAll(io.MultiReader(io.LimitReader(previous, 4), patch, rest))
+	if err != nil || string(actual) != "0123abc789" {
+		t.Errorf("expected: 0123abc789, actual: %v, error: %v", string(actual), err)
+	}
+
+	// the patch extends the content
+	previous = strings.NewReader("0123")
+	patch = &countingReader{reader: strings.NewReader("abcdef")}
+	rest = &lazyOffsetReader{content: previous, size: previous.Size(), offset: func() int64 { return 2 + patch.count }}
+	actual, err = io.ReadAll(io.MultiReader(io.LimitReader(previous, 2), patch, rest))
+	if err != nil || string(actual) != "01abcdef" {
+		t.Errorf("expected: 01abcdef, actual: %v, error: %v", string(actual), err)
+	}
+}
+
+func TestResumeHash(t *testing.T) {
+	hasher := sha256.New()
+	hasher.Write([]byte("0123"))
+	state, err := marshalHash(hasher)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	checksum := hex.EncodeToString(hasher.Sum(nil))
+
+	resumed, ok := resumeHash(state, checksum)
+	if !ok {
+		t.Fatalf("expected resumed state of checksum %v", checksum)
+	}
+	resumed.Write([]byte("4567"))
+	expected := sha256.Sum256([]byte("01234567"))
+	if actual := hex.EncodeToString(resumed.Sum(nil)); actual != hex.EncodeToString(expected[:]) {
+		t.Errorf("expected checksum: %x, actual: %v", expected, actual)
+	}
+
+	// the state is stale after the content is replaced without the patch
+	other := sha256.Sum256([]byte("other"))
+	for _, test := range []struct {
+		state    []byte
+		checksum string
+	}{
+		{state, hex.EncodeToString(other[:])},
+		{state, ""},
+		{nil, checksum},
+		{[]byte("broken"), checksum},
+	} {
+		_, ok = resumeHash(test.state, test.checksum)
+		if ok {
+			t.Errorf("expected stale state for checksum '%v'", test.checksum)
+		}
+	}
+}
+
+func TestNextChecksumCursor(t *testing.T) {
+	if cursor := nextChecksumCursor(nil); cursor != nil {
+		t.Errorf("expected the empty batch to end the pass, actual: %+v", *cursor)
+	}
+	keys := make([]assetKey, checksumBackfillBatchSize)
+	for i := range keys {
+		keys[i] = assetKey{userUuid: "owner", name: fmt.Sprintf("file%03d", i)}
+	}
+	if cursor := nextChecksumCursor(keys[:len(keys)-1]); cursor != nil {
+		t.Errorf("expected the batch which is not full to end the pass, actual: %+v", *cursor)
+	}
+	cursor := nextChecksumCursor(keys)
+	if cursor == nil || *cursor != keys[len(keys)-1] {
+		t.Errorf("expected the cursor at the last asset of the full batch, actual: %v", cursor)
+	}
+}
@@ -0,0 +1,255 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+)
+
+const (
+	getTrashQuery = `SELECT id, delete_date, ` + assetColumns + ` FROM assets_trash WHERE user_uuid = $1 ORDER BY delete_date DESC, id DESC`
+	// the restored asset gets the next version number, because the history of the name could be continued while the asset was in the trash
+	restoreFromTrashQuery = `WITH restored AS (DELETE FROM assets_trash WHERE user_uuid = $1 and id = $2 RETURNING *)
+		INSERT INTO assets (name, user_uuid, blob_id, size, content_type, checksum, encoding, key_id, data_key, create_date, update_date, version)
+		SELECT name, user_uuid, blob_id, size, content_type, checksum, encoding, key_id, data_key, create_date, update_date,
+			GREATEST(version, COALESCE((SELECT MAX(v.version) FROM asset_versions v WHERE v.user_uuid = restored.user_uuid and v.name = restored.name), 0) + 1)
+		FROM restored
+		RETURNING ` + assetColumns
+	getTrashedLabelsQuery = `SELECT labels FROM assets_trash WHERE user_uuid = $1 and id = $2 FOR UPDATE`
+	purgeTrashQuery       = `DELETE FROM assets_trash WHERE id IN (SELECT id FROM assets_trash WHERE delete_date < $1 LIMIT $2) RETURNING blob_id`
+	// the history of the name is not needed anymore if there are neither the asset nor its trashed copies
+	purgeOrphanedVersionsQuery = `DELETE FROM asset_versions WHERE (user_uuid, name, version) IN (
+			SELECT v.user_uuid, v.name, v.version FROM asset_versions v WHERE
+				NOT EXISTS (SELECT 1 FROM assets a WHERE a.user_uuid = v.user_uuid and a.name = v.name)
+				and NOT EXISTS (SELECT 1 FROM assets_trash t WHERE t.user_uuid = v.user_uuid and t.name = v.name)
+			LIMIT $1
+		) RETURNING blob_id`
+	purgeExpiredVersionsQuery = `DELETE FROM asset_versions WHERE (user_uuid, name, version) IN (
+			SELECT user_uuid, name, version FROM asset_versions WHERE archive_date < $1 LIMIT $2
+		) RETURNING blob_id`
+
+	purgeBatchSize = 1000
+)
+
+var ErrNotFoundTrashedAsset = errors.New("trashed asset not found")
+
+// TrashPolicy defines how long the deleted assets are kept and how often the trash is purged
+type TrashPolicy struct {
+	Retention time.Duration
+	// 0 disables the purge worker
+	PurgeInterval time.Duration
+}
+
+type TrashedAsset struct {
+	Id         int64
+	Asset      Asset
+	DeleteDate time.Time
+}
+
+func (s *AssetsService) GetTrash(userUuid string) ([]TrashedAsset, error) {
+	result, err := s.client(userUuid).Tx(
+		func(tx pgx.Tx, ctx context.Context, cancel context.CancelFunc) (any, error) {
+			trash := []TrashedAsset{}
+			rows, internalErr := tx.Query(ctx, getTrashQuery, userUuid)
+			if internalErr != nil {
+				return nil, internalErr
+			}
+
+			for rows.Next() {
+				var item TrashedAsset
+				internalErr := rows.Scan(append([]any{&item.Id, &item.DeleteDate}, item.Asset.scanTargets()...)...)
+				if internalErr != nil {
+					return nil, fmt.Errorf("unable to scan trash: %w", internalErr)
+				}
+				trash = append(trash, item)
+			}
+
+			internalErr = rows.Err()
+			if internalErr != nil {
+				return nil, fmt.Errorf("unable to scan trash: %w", internalErr)
+			}
+
+			return trash, nil
+		},
+		pgx.TxOptions{
+			IsoLevel: pgx.ReadCommitted,
+		})()
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to get trash: %w", err)
+	}
+
+	trash, ok := result.([]TrashedAsset)
+	if !ok {
+		return nil, fmt.Errorf("unable to convert result into []TrashedAsset")
+	}
+
+	return trash, nil
+}
+
+// RestoreTrashedAsset brings the asset back from the trash, it fails if there is another asset with the same name already
+func (s *AssetsService) RestoreTrashedAsset(trashId int64, userUuid string) (Asset, error) {
+	var asset Asset
+	err := s.txWithBlobs(userUuid,
+		func(tx pgx.Tx, ctx context.Context, blobs *blobSession) error {
+			var storedLabels []byte
+			internalErr := tx.QueryRow(ctx, getTrashedLabelsQuery, userUuid, trashId).Scan(&storedLabels)
+			if internalErr != nil {
+				return internalErr
+			}
+			labels, internalErr := unmarshalLabels(storedLabels)
+			if internalErr != nil {
+				return internalErr
+			}
+			internalErr = tx.QueryRow(ctx, restoreFromTrashQuery, userUuid, trashId).Scan(asset.scanTargets()...)
+			if internalErr != nil {
+				if isDuplicateError(internalErr) {
+					return fmt.Errorf("restore trashed asset %v error: %w", trashId, ErrDuplicateAsset)
+				}
+				return internalErr
+			}
+			internalErr = checkAssetNameReserved(ctx, tx, userUuid, asset.Name)
+			if internalErr != nil {
+				return internalErr
+			}
+			// the labels come back from the row of the trash
+			internalErr = setAssetLabels(ctx, tx, userUuid, asset.Name, labels)
+			if internalErr != nil {
+				return internalErr
+			}
+			return s.verifyQuota(ctx, tx, userUuid)
+		})
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return asset, fmt.Errorf("restore trashed asset %v error: %w", trashId, ErrNotFoundTrashedAsset)
+		}
+		return asset, fmt.Errorf("user '%v' unable to restore trashed asset %v: %w", userUuid, trashId, err)
+	}
+
+	return asset, nil
+}
+
+// StartPurgeWorkers runs the worker on each shard, which unlinks the content of the assets deleted earlier than the retention
+// and the versions and the uploads which are not needed anymore, the worker also calculates the missed checksums of the old assets
+func (s *AssetsService) StartPurgeWorkers() {
+	if s.trash.PurgeInterval <= 0 {
+		return
+	}
+	for i, client := range s.shardedClients {
+		s.purgeWorkers.Add(1)
+		go func() {
+			defer s.purgeWorkers.Done()
+			ticker := time.NewTicker(s.trash.PurgeInterval)
+			defer ticker.Stop()
+			var checksumCursor *assetKey
+			for {
+				select {
+				case <-s.stopPurge:
+					return
+				case <-ticker.C:
+					err := s.purgeShard(client)
+					if err != nil {
+						slog.Error(fmt.Sprintf("unable to purge trash of shard %v: %v", i+1, err))
+					}
+					checksumCursor, err = s.backfillChecksums(client, checksumCursor)
+					if err != nil {
+						slog.Error(fmt.Sprintf("unable to backfill checksums of shard %v: %v", i+1, err))
+					}
+				}
+			}
+		}()
+	}
+}
+
+func (s *AssetsService) purgeShard(client *PostgreSQLService) error {
+	minDeleteDate := time.Now().UTC().Add(-s.trash.Retention)
+	err := purgeInBatches("trashed assets", s.purgeBatchFunc(client, purgeTrashQuery, minDeleteDate))
+	if err != nil {
+		return err
+	}
+	err = purgeInBatches("orphaned versions", s.purgeBatchFunc(client, purgeOrphanedVersionsQuery))
+	if err != nil {
+		return err
+	}
+
+	err = purgeInBatches("orphaned labels", purgeOrphanedLabelsBatchFunc(client))
+	if err != nil {
+		return err
+	}
+
+	err = s.shardTxWithBlobs(client,
+		func(tx pgx.Tx, ctx context.Context, blobs *blobSession) error {
+			rows, internalErr := tx.Query(ctx, purgeUploadsQuery, time.Now().UTC())
+			if internalErr != nil {
+				return fmt.Errorf("unable to purge expired uploads: %w", internalErr)
+			}
+			_, internalErr = deleteReturnedBlobs(rows, blobs)
+			return internalErr
+		})
+	if err != nil {
+		return err
+	}
+
+	// the age rule is applied here as well, so the versions of the assets which are not replaced anymore expire too
+	_, minArchiveDate := s.versioning.pruneLimits(time.Now().UTC())
+	if minArchiveDate == nil {
+		return nil
+	}
+	return purgeInBatches("expired versions", s.purgeBatchFunc(client, purgeExpiredVersionsQuery, *minArchiveDate))
+}
+
+// purgeBatchFunc returns the function which runs the purge query in its own transaction and unlinks the returned blobs,
+// purgeBatchSize is passed to the query as the last argument
+func (s *AssetsService) purgeBatchFunc(client *PostgreSQLService, query string, args ...any) func() (int, error) {
+	return func() (int, error) {
+		purged := 0
+		err := s.shardTxWithBlobs(client,
+			func(tx pgx.Tx, ctx context.Context, blobs *blobSession) error {
+				rows, internalErr := tx.Query(ctx, query, append(args, purgeBatchSize)...)
+				if internalErr != nil {
+					return internalErr
+				}
+				purged, internalErr = deleteReturnedBlobs(rows, blobs)
+				return internalErr
+			})
+		return purged, err
+	}
+}
+
+// purgeOrphanedLabelsBatchFunc returns the function which deletes the batch of the labels without the assets in its own transaction
+func purgeOrphanedLabelsBatchFunc(client *PostgreSQLService) func() (int, error) {
+	return func() (int, error) {
+		var purged int64
+		err := client.TxVoid(
+			func(tx pgx.Tx, ctx context.Context, cancel context.CancelFunc) error {
+				tag, internalErr := tx.Exec(ctx, purgeOrphanedLabelsQuery, purgeBatchSize)
+				purged = tag.RowsAffected()
+				return internalErr
+			},
+			pgx.TxOptions{
+				IsoLevel: pgx.ReadCommitted,
+			})()
+		return int(purged), err
+	}
+}
+
+// purgeInBatches repeats the purge until the batch is not full, so each transaction stays short whatever the backlog is
+func purgeInBatches(what string, purgeBatch func() (int, error)) error {
+	for {
+		purged, err := purgeBatch()
+		if err != nil {
+			return fmt.Errorf("unable to purge %v: %w", what, err)
+		}
+		if purged > 0 {
+			slog.Info(fmt.Sprintf("purged %v %v", purged, what))
+		}
+		if purged < purgeBatchSize {
+			return nil
+		}
+	}
+}
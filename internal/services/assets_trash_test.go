@@ -0,0 +1,59 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPurgeInBatches(t *testing.T) {
+	batches := []int{purgeBatchSize, purgeBatchSize, 10, purgeBatchSize}
+	calls := 0
+	err := purgeInBatches("trashed assets", func() (int, error) {
+		purged := batches[calls]
+		calls++
+		return purged, nil
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected the purge to stop after the first incomplete batch, actual calls: %v", calls)
+	}
+
+	calls = 0
+	err = purgeInBatches("orphaned versions", func() (int, error) {
+		calls++
+		return 0, nil
+	})
+	if err != nil || calls != 1 {
+		t.Errorf("expected the single call for the empty purge, actual calls: %v, error: %v", calls, err)
+	}
+
+	failure := errors.New("connection lost")
+	err = purgeInBatches("expired versions", func() (int, error) {
+		return 0, failure
+	})
+	if !errors.Is(err, failure) {
+		t.Errorf("expected error: %v, actual: %v", failure, err)
+	}
+}
+
+func TestParseTrashPolicy(t *testing.T) {
+	t.Setenv("ASSETS_TRASH_RETENTION", "24h")
+	t.Setenv("ASSETS_TRASH_PURGE_INTERVAL", "0s")
+	policy, err := parseTrashPolicy()
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	expected := TrashPolicy{Retention: 24 * time.Hour}
+	if policy != expected {
+		t.Errorf("expected policy: %+v, actual: %+v", expected, policy)
+	}
+
+	t.Setenv("ASSETS_TRASH_RETENTION", "month")
+	_, err = parseTrashPolicy()
+	if err == nil {
+		t.Errorf("expected error for the wrong retention")
+	}
+}
@@ -0,0 +1,292 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+)
+
+const (
+	assetVersionColumns = `version, size, content_type, checksum, create_date, encoding, key_id, data_key`
+
+	archiveAssetVersionQuery = `INSERT INTO asset_versions (user_uuid, name, version, blob_id, size, content_type, checksum, create_date, encoding, key_id, data_key)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+	getAssetVersionsQuery = `SELECT ` + assetVersionColumns + ` FROM asset_versions WHERE user_uuid = $1 and name = $2 ORDER BY version DESC`
+	getAssetVersionQuery  = `SELECT blob_id, ` + assetVersionColumns + ` FROM asset_versions WHERE user_uuid = $1 and name = $2 and version = $3`
+	// NULL limit and NULL date disable the corresponding rule of the policy
+	pruneAssetVersionsQuery = `DELETE FROM asset_versions WHERE user_uuid = $1 and name = $2 and (
+			version NOT IN (SELECT version FROM asset_versions WHERE user_uuid = $1 and name = $2 ORDER BY version DESC LIMIT $3)
+			OR archive_date < $4
+		) RETURNING blob_id`
+)
+
+var ErrNotFoundAssetVersion = errors.New("asset version not found")
+
+// VersioningPolicy defines whether the replaced content of the assets is kept and for how long
+type VersioningPolicy struct {
+	Enabled bool
+	// the count of the kept previous versions of each asset, 0 means no limit
+	MaxVersions int
+	// the previous versions older than this are pruned, 0 means no limit
+	MaxAge time.Duration
+}
+
+type AssetVersion struct {
+	Version     int
+	Size        int64
+	ContentType string
+	Checksum    string
+	// the time when the content of the version was stored
+	CreateDate time.Time
+	Current    bool
+	Encoding   string
+	keyId      string
+	dataKey    []byte
+}
+
+func (v *AssetVersion) scanTargets() []any {
+	return []any{&v.Version, &v.Size, &v.ContentType, &v.Checksum, &v.CreateDate, &v.Encoding, &v.keyId, &v.dataKey}
+}
+
+func (v AssetVersion) toAsset(name string) Asset {
+	return Asset{
+		Name:        name,
+		Size:        v.Size,
+		ContentType: v.ContentType,
+		Checksum:    v.Checksum,
+		CreateDate:  v.CreateDate,
+		UpdateDate:  v.CreateDate,
+		Version:     v.Version,
+		Encoding:    v.Encoding,
+		keyId:       v.keyId,
+		dataKey:     v.dataKey,
+	}
+}
+
+// GetAssetVersions returns the current version of the asset and its previous versions, the newest versions go first
+func (s *AssetsService) GetAssetVersions(name string, userUuid string) ([]AssetVersion, error) {
+	result, err := s.client(userUuid).Tx(
+		func(tx pgx.Tx, ctx context.Context, cancel context.CancelFunc) (any, error) {
+			_, current, internalErr := scanAssetInfo(tx.QueryRow(ctx, getAssetInfoQuery, userUuid, name))
+			if internalErr != nil {
+				return nil, internalErr
+			}
+			versions := []AssetVersion{{
+				Version:     current.Version,
+				Size:        current.Size,
+				ContentType: current.ContentType,
+				Checksum:    current.Checksum,
+				CreateDate:  current.UpdateDate,
+				Current:     true,
+				Encoding:    current.Encoding,
+			}}
+
+			rows, internalErr := tx.Query(ctx, getAssetVersionsQuery, userUuid, name)
+			if internalErr != nil {
+				return nil, internalErr
+			}
+			for rows.Next() {
+				var version AssetVersion
+				internalErr := rows.Scan(version.scanTargets()...)
+				if internalErr != nil {
+					return nil, fmt.Errorf("unable to scan asset versions: %w", internalErr)
+				}
+				versions = append(versions, version)
+			}
+			internalErr = rows.Err()
+			if internalErr != nil {
+				return nil, fmt.Errorf("unable to scan asset versions: %w", internalErr)
+			}
+
+			return versions, nil
+		},
+		pgx.TxOptions{
+			IsoLevel: pgx.ReadCommitted,
+		})()
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("get asset '%v' versions error: %w", name, ErrNotFoundAsset)
+		}
+		return nil, fmt.Errorf("unable to get asset versions: %w", err)
+	}
+
+	versions, ok := result.([]AssetVersion)
+	if !ok {
+		return nil, fmt.Errorf("unable to convert result into []AssetVersion")
+	}
+
+	return versions, nil
+}
+
+// GetAssetVersion streams the content of the previous or the current version of the asset
+func (s *AssetsService) GetAssetVersion(name string, userUuid string, version int, startStreaming StartStreamingFunc) error {
+	err := s.txWithBlobs(userUuid,
+		func(tx pgx.Tx, ctx context.Context, blobs *blobSession) error {
+			blobId, asset, internalErr := findAssetVersion(ctx, tx, userUuid, name, version)
+			if internalErr != nil {
+				return internalErr
+			}
+
+			content, internalErr := s.openContent(blobs, blobId, asset)
+			if internalErr != nil {
+				return internalErr
+			}
+
+			startStreaming(asset, content)
+
+			return nil
+		})
+
+	if err != nil {
+		return fmt.Errorf("unable to get asset version: %w", err)
+	}
+
+	return nil
+}
+
+// RestoreAssetVersion makes the copy of the previous version content the new current version of the asset,
+// so the history of the asset stays untouched
+func (s *AssetsService) RestoreAssetVersion(name string, userUuid string, version int) (Asset, error) {
+	var asset Asset
+	err := s.txWithBlobs(userUuid,
+		func(tx pgx.Tx, ctx context.Context, blobs *blobSession) error {
+			oldBlobId, current, internalErr := scanAssetInfo(tx.QueryRow(ctx, getAssetInfoForUpdateQuery, userUuid, name))
+			if internalErr != nil {
+				if errors.Is(internalErr, pgx.ErrNoRows) {
+					return fmt.Errorf("restore asset '%v' error: %w", name, ErrNotFoundAsset)
+				}
+				return internalErr
+			}
+			if current.Version == version {
+				asset = current
+				return nil
+			}
+
+			var restored AssetVersion
+			row := tx.QueryRow(ctx, getAssetVersionQuery, userUuid, name, version)
+			var versionBlobId string
+			internalErr = row.Scan(append([]any{&versionBlobId}, restored.scanTargets()...)...)
+			if internalErr != nil {
+				if errors.Is(internalErr, pgx.ErrNoRows) {
+					return fmt.Errorf("restore asset '%v' version %v error: %w", name, version, ErrNotFoundAssetVersion)
+				}
+				return internalErr
+			}
+
+			// the content is copied as is, so it keeps the encoding and the data key of the version
+			blobId, internalErr := blobs.Copy(versionBlobId)
+			if internalErr != nil {
+				return internalErr
+			}
+
+			asset, internalErr = updateAssetContent(ctx, tx, userUuid, name, storedContent{
+				BlobId:      blobId,
+				Size:        restored.Size,
+				ContentType: restored.ContentType,
+				Checksum:    restored.Checksum,
+				Encoding:    restored.Encoding,
+				KeyId:       restored.keyId,
+				DataKey:     restored.dataKey,
+			})
+			if internalErr != nil {
+				return internalErr
+			}
+			internalErr = s.retireContent(ctx, tx, blobs, userUuid, oldBlobId, current)
+			if internalErr != nil {
+				return internalErr
+			}
+			return s.verifyQuota(ctx, tx, userUuid)
+		})
+
+	if err != nil {
+		return asset, fmt.Errorf("unable to restore asset version: %w", err)
+	}
+
+	return asset, nil
+}
+
+func findAssetVersion(ctx context.Context, tx pgx.Tx, userUuid string, name string, version int) (string, Asset, error) {
+	blobId, current, err := scanAssetInfo(tx.QueryRow(ctx, getAssetInfoQuery, userUuid, name))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", current, fmt.Errorf("get asset '%v' error: %w", name, ErrNotFoundAsset)
+		}
+		return "", current, err
+	}
+	if current.Version == version {
+		return blobId, current, nil
+	}
+
+	var result AssetVersion
+	err = tx.QueryRow(ctx, getAssetVersionQuery, userUuid, name, version).Scan(append([]any{&blobId}, result.scanTargets()...)...)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", current, fmt.Errorf("get asset '%v' version %v error: %w", name, version, ErrNotFoundAssetVersion)
+		}
+		return "", current, err
+	}
+	return blobId, result.toAsset(name), nil
+}
+
+// retireContent keeps the replaced content as the previous version of the asset if the versioning is enabled, otherwise the content is deleted
+func (s *AssetsService) retireContent(ctx context.Context, tx pgx.Tx, blobs *blobSession, userUuid string, blobId string, replaced Asset) error {
+	if !s.versioning.Enabled {
+		return blobs.Delete(blobId)
+	}
+
+	_, err := tx.Exec(ctx, archiveAssetVersionQuery, userUuid, replaced.Name, replaced.Version, blobId,
+		replaced.Size, replaced.ContentType, replaced.Checksum, replaced.UpdateDate, replaced.Encoding, replaced.keyId, replaced.dataKey)
+	if err != nil {
+		return fmt.Errorf("unable to archive version %v of asset '%v': %w", replaced.Version, replaced.Name, err)
+	}
+
+	return s.pruneVersions(ctx, tx, blobs, userUuid, replaced.Name)
+}
+
+// pruneLimits returns the arguments of pruneAssetVersionsQuery, nil disables the corresponding rule
+func (p VersioningPolicy) pruneLimits(now time.Time) (*int, *time.Time) {
+	var maxVersions *int
+	if p.MaxVersions > 0 {
+		limit := p.MaxVersions
+		maxVersions = &limit
+	}
+	var minArchiveDate *time.Time
+	if p.MaxAge > 0 {
+		date := now.Add(-p.MaxAge)
+		minArchiveDate = &date
+	}
+	return maxVersions, minArchiveDate
+}
+
+func (s *AssetsService) pruneVersions(ctx context.Context, tx pgx.Tx, blobs *blobSession, userUuid string, name string) error {
+	maxVersions, minArchiveDate := s.versioning.pruneLimits(time.Now().UTC())
+	if maxVersions == nil && minArchiveDate == nil {
+		return nil
+	}
+
+	rows, err := tx.Query(ctx, pruneAssetVersionsQuery, userUuid, name, maxVersions, minArchiveDate)
+	if err != nil {
+		return fmt.Errorf("unable to prune versions of asset '%v': %w", name, err)
+	}
+	_, err = deleteReturnedBlobs(rows, blobs)
+	return err
+}
+
+// deleteReturnedBlobs deletes the blobs which ids are returned by the query and returns their count
+func deleteReturnedBlobs(rows pgx.Rows, blobs *blobSession) (int, error) {
+	blobIds, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return 0, fmt.Errorf("unable to scan blob ids: %w", err)
+	}
+	for _, blobId := range blobIds {
+		err = blobs.Delete(blobId)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(blobIds), nil
+}
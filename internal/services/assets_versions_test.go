@@ -0,0 +1,76 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVersioningPolicyPruneLimits(t *testing.T) {
+	now := time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC)
+
+	maxVersions, minArchiveDate := VersioningPolicy{Enabled: true}.pruneLimits(now)
+	if maxVersions != nil || minArchiveDate != nil {
+		t.Errorf("expected no limits for the unlimited policy, actual: %v, %v", maxVersions, minArchiveDate)
+	}
+
+	maxVersions, minArchiveDate = VersioningPolicy{Enabled: true, MaxVersions: 3, MaxAge: 24 * time.Hour}.pruneLimits(now)
+	if maxVersions == nil || *maxVersions != 3 {
+		t.Errorf("expected max versions: 3, actual: %v", maxVersions)
+	}
+	expectedDate := time.Date(2024, 6, 30, 10, 0, 0, 0, time.UTC)
+	if minArchiveDate == nil || !minArchiveDate.Equal(expectedDate) {
+		t.Errorf("expected min archive date: %v, actual: %v", expectedDate, minArchiveDate)
+	}
+
+	policy := VersioningPolicy{Enabled: true, MaxVersions: 3}
+	maxVersions, _ = policy.pruneLimits(now)
+	*maxVersions = 1
+	if policy.MaxVersions != 3 {
+		t.Errorf("expected the policy to stay untouched, actual max versions: %v", policy.MaxVersions)
+	}
+}
+
+func TestParseVersioningPolicy(t *testing.T) {
+	t.Setenv("ASSETS_VERSIONING_ENABLED", "true")
+	t.Setenv("ASSETS_VERSIONING_MAX_VERSIONS", "5")
+	t.Setenv("ASSETS_VERSIONING_MAX_AGE", "720h")
+	policy, err := parseVersioningPolicy()
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	expected := VersioningPolicy{Enabled: true, MaxVersions: 5, MaxAge: 720 * time.Hour}
+	if policy != expected {
+		t.Errorf("expected policy: %+v, actual: %+v", expected, policy)
+	}
+
+	t.Setenv("ASSETS_VERSIONING_MAX_VERSIONS", "-1")
+	_, err = parseVersioningPolicy()
+	if err == nil {
+		t.Errorf("expected error for the negative max versions")
+	}
+}
+
+func TestAssetVersionToAsset(t *testing.T) {
+	createDate := time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC)
+	version := AssetVersion{
+		Version:     2,
+		Size:        7,
+		ContentType: "text/plain",
+		Checksum:    "abc",
+		CreateDate:  createDate,
+		Encoding:    "gzip",
+		keyId:       "k1",
+		dataKey:     []byte{1, 2, 3},
+	}
+	asset := version.toAsset("file.txt")
+	if asset.Name != "file.txt" || asset.Version != 2 || asset.Size != 7 || asset.Checksum != "abc" || asset.Encoding != "gzip" {
+		t.Errorf("unexpected asset: %+v", asset)
+	}
+	if !asset.UpdateDate.Equal(createDate) {
+		t.Errorf("expected update date: %v, actual: %v", createDate, asset.UpdateDate)
+	}
+	// the restored and the streamed versions are decrypted with the data key of the version, not of the current content
+	if asset.keyId != "k1" || string(asset.dataKey) != string([]byte{1, 2, 3}) {
+		t.Errorf("expected the data key of the version, actual: %v, %v", asset.keyId, asset.dataKey)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/ArtemVoronov/clearway-task-assets-service/internal/app/utils"
@@ -27,9 +29,42 @@ type AccessToken struct {
 	UserUUID   string
 	IpAddress  string
 	CreateDate time.Time
+	// the fields below are set only for the API keys
+	ApiKeyId   int64
+	Scopes     []string
+	NamePrefix string
+	// nil means no expiry
+	ExpireDate *time.Time
+}
+
+// IsApiKey returns true if the token is made of the API key instead of the access token of the login
+func (t *AccessToken) IsApiKey() bool {
+	return t.ApiKeyId != 0
+}
+
+// HasScope returns true for the access tokens of the login, they are not limited by the scopes
+func (t *AccessToken) HasScope(scope string) bool {
+	return !t.IsApiKey() || slices.Contains(t.Scopes, scope)
+}
+
+// AllowsName checks the name of the asset against the name prefix of the API key
+func (t *AccessToken) AllowsName(name string) bool {
+	return strings.HasPrefix(name, t.NamePrefix)
+}
+
+// AllowsFolder checks that all assets of the folder are allowed by the name prefix of the API key
+func (t *AccessToken) AllowsFolder(folder string) bool {
+	if len(t.NamePrefix) == 0 {
+		return true
+	}
+	return strings.HasPrefix(strings.TrimSuffix(folder, FolderDelimiter)+FolderDelimiter, t.NamePrefix)
 }
 
 func (t *AccessToken) IsExpired() bool {
+	if t.IsApiKey() {
+		// the expiry of the API keys is checked on their loading
+		return false
+	}
 	now := time.Now()
 	expireTime := t.CreateDate.Add(instance.AuthService.AccessTokenTTL)
 	return now.After(expireTime)
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+
+	pgx "github.com/jackc/pgx/v5"
+)
+
+const (
+	BlobStoreTypeLargeObjects = "largeobjects"
+	BlobStoreTypeFileSystem   = "filesystem"
+)
+
+var ErrNotFoundBlob = errors.New("blob not found")
+
+// BlobStore keeps the content of the assets, the metadata of the assets stays in the sharded 'assets' table.
+// The transaction of the shard is passed to each method, so the stores that live outside of the database just ignore it.
+type BlobStore interface {
+	// Put stores the content and returns the id of the new blob and the count of the written bytes
+	Put(ctx context.Context, tx pgx.Tx, content io.Reader) (string, int64, error)
+	Get(ctx context.Context, tx pgx.Tx, blobId string) (io.ReadSeeker, error)
+	// Copy duplicates the content of the blob inside the store and returns the id of the new blob
+	Copy(ctx context.Context, tx pgx.Tx, blobId string) (string, error)
+	// WriteAt overwrites the content of the existing blob starting at the offset and returns the count of the written bytes
+	WriteAt(ctx context.Context, tx pgx.Tx, blobId string, offset int64, content io.Reader) (int64, error)
+	Delete(ctx context.Context, tx pgx.Tx, blobId string) error
+	Stat(ctx context.Context, tx pgx.Tx, blobId string) (int64, error)
+	// Transactional reports whether the changes of the store are committed and rolled back together with the transaction
+	Transactional() bool
+}
+
+func CreateBlobStore(storeType string, dir string) (BlobStore, error) {
+	switch storeType {
+	case BlobStoreTypeLargeObjects:
+		return CreateLargeObjectsBlobStore(), nil
+	case BlobStoreTypeFileSystem:
+		return CreateFileSystemBlobStore(dir)
+	default:
+		return nil, fmt.Errorf("unknown blob store type '%v'", storeType)
+	}
+}
+
+// blobSession binds the blob store to the transaction of the shard.
+// For non-transactional stores it removes the blobs created by the rolled back transaction
+// and postpones the deletion of the blobs until the transaction is committed.
+type blobSession struct {
+	ctx     context.Context
+	tx      pgx.Tx
+	store   BlobStore
+	created []string
+	deleted []string
+	opened  []io.Closer
+}
+
+func newBlobSession(ctx context.Context, tx pgx.Tx, store BlobStore) *blobSession {
+	return &blobSession{
+		ctx:   ctx,
+		tx:    tx,
+		store: store,
+	}
+}
+
+func (b *blobSession) Put(content io.Reader) (string, int64, error) {
+	blobId, written, err := b.store.Put(b.ctx, b.tx, content)
+	if err != nil {
+		return "", 0, err
+	}
+	b.created = append(b.created, blobId)
+	return blobId, written, nil
+}
+
+func (b *blobSession) Get(blobId string) (io.ReadSeeker, error) {
+	content, err := b.store.Get(b.ctx, b.tx, blobId)
+	if err != nil {
+		return nil, err
+	}
+	if closer, ok := content.(io.Closer); ok {
+		b.opened = append(b.opened, closer)
+	}
+	return content, nil
+}
+
+func (b *blobSession) Copy(blobId string) (string, error) {
+	copyId, err := b.store.Copy(b.ctx, b.tx, blobId)
+	if err != nil {
+		return "", err
+	}
+	b.created = append(b.created, copyId)
+	return copyId, nil
+}
+
+// WriteAt is not rolled back for non-transactional stores, so the callers have to keep the valid length of the blob in the database
+func (b *blobSession) WriteAt(blobId string, offset int64, content io.Reader) (int64, error) {
+	return b.store.WriteAt(b.ctx, b.tx, blobId, offset, content)
+}
+
+func (b *blobSession) Delete(blobId string) error {
+	if b.store.Transactional() {
+		return b.store.Delete(b.ctx, b.tx, blobId)
+	}
+	b.deleted = append(b.deleted, blobId)
+	return nil
+}
+
+func (b *blobSession) Stat(blobId string) (int64, error) {
+	return b.store.Stat(b.ctx, b.tx, blobId)
+}
+
+func (b *blobSession) closeOpened() {
+	for _, closer := range b.opened {
+		err := closer.Close()
+		if err != nil {
+			slog.Error(fmt.Sprintf("unable to close blob: %v", err))
+		}
+	}
+	b.opened = nil
+}
+
+// complete has to be called after the transaction is finished, txErr is the result of the transaction
+func (b *blobSession) complete(txErr error) {
+	if b.store.Transactional() {
+		return
+	}
+	toDelete := b.deleted
+	if txErr != nil {
+		toDelete = b.created
+	}
+	for _, blobId := range toDelete {
+		err := b.store.Delete(context.Background(), nil, blobId)
+		if err != nil && !errors.Is(err, ErrNotFoundBlob) {
+			slog.Error(fmt.Sprintf("unable to delete blob '%v': %v", blobId, err))
+		}
+	}
+}
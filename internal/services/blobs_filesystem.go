@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/ArtemVoronov/clearway-task-assets-service/internal/app/utils"
+	pgx "github.com/jackc/pgx/v5"
+)
+
+var regExpFileSystemBlobId = regexp.MustCompile("^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$")
+
+// FileSystemBlobStore keeps the content in the files of the local directory, the id of the blob is the name of the file.
+// The files are not the part of the database transaction, see blobSession for the details.
+type FileSystemBlobStore struct {
+	dir string
+}
+
+func CreateFileSystemBlobStore(dir string) (*FileSystemBlobStore, error) {
+	err := os.MkdirAll(dir, 0o750)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create blob store directory '%v': %w", dir, err)
+	}
+	return &FileSystemBlobStore{
+		dir: dir,
+	}, nil
+}
+
+func (s *FileSystemBlobStore) Put(ctx context.Context, tx pgx.Tx, content io.Reader) (string, int64, error) {
+	uuid, err := utils.PseudoUUID()
+	if err != nil {
+		return "", 0, fmt.Errorf("unable to create blob id: %w", err)
+	}
+	blobId := strings.ToLower(uuid)
+	path, err := s.path(blobId)
+	if err != nil {
+		return "", 0, err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0o750)
+	if err != nil {
+		return "", 0, fmt.Errorf("unable to create blob directory: %w", err)
+	}
+
+	// the content is written into the temporary file at first, so the partially written blobs are never visible
+	tmp, err := os.CreateTemp(filepath.Dir(path), blobId+".*.tmp")
+	if err != nil {
+		return "", 0, fmt.Errorf("unable to create blob file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	written, err := io.Copy(tmp, content)
+	if err != nil {
+		tmp.Close()
+		return "", 0, err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return "", 0, fmt.Errorf("unable to close blob file: %w", err)
+	}
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return "", 0, fmt.Errorf("unable to store blob file: %w", err)
+	}
+
+	return blobId, written, nil
+}
+
+func (s *FileSystemBlobStore) Get(ctx context.Context, tx pgx.Tx, blobId string) (io.ReadSeeker, error) {
+	path, err := s.path(blobId)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, wrapFileSystemError(blobId, err)
+	}
+	return file, nil
+}
+
+// Copy writes the new file through the temporary file the same way as Put, so the partially copied blobs are never visible
+func (s *FileSystemBlobStore) Copy(ctx context.Context, tx pgx.Tx, blobId string) (string, error) {
+	path, err := s.path(blobId)
+	if err != nil {
+		return "", err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return "", wrapFileSystemError(blobId, err)
+	}
+	defer file.Close()
+	copyId, _, err := s.Put(ctx, tx, file)
+	return copyId, err
+}
+
+func (s *FileSystemBlobStore) WriteAt(ctx context.Context, tx pgx.Tx, blobId string, offset int64, content io.Reader) (int64, error) {
+	path, err := s.path(blobId)
+	if err != nil {
+		return 0, err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return 0, wrapFileSystemError(blobId, err)
+	}
+
+	_, err = file.Seek(offset, io.SeekStart)
+	if err != nil {
+		file.Close()
+		return 0, wrapFileSystemError(blobId, err)
+	}
+	written, err := io.Copy(file, content)
+	if err != nil {
+		file.Close()
+		return written, err
+	}
+	return written, wrapFileSystemError(blobId, file.Close())
+}
+
+func (s *FileSystemBlobStore) Delete(ctx context.Context, tx pgx.Tx, blobId string) error {
+	path, err := s.path(blobId)
+	if err != nil {
+		return err
+	}
+	return wrapFileSystemError(blobId, os.Remove(path))
+}
+
+func (s *FileSystemBlobStore) Stat(ctx context.Context, tx pgx.Tx, blobId string) (int64, error) {
+	path, err := s.path(blobId)
+	if err != nil {
+		return 0, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, wrapFileSystemError(blobId, err)
+	}
+	return info.Size(), nil
+}
+
+func (s *FileSystemBlobStore) Transactional() bool {
+	return false
+}
+
+func (s *FileSystemBlobStore) path(blobId string) (string, error) {
+	if !regExpFileSystemBlobId.MatchString(blobId) {
+		return "", fmt.Errorf("wrong blob id '%v'", blobId)
+	}
+	return filepath.Join(s.dir, blobId[:2], blobId), nil
+}
+
+func wrapFileSystemError(blobId string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("blob '%v': %w", blobId, ErrNotFoundBlob)
+	}
+	return fmt.Errorf("blob '%v': %w", blobId, err)
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestFileSystemBlobStoreLifecycle(t *testing.T) {
+	store, err := CreateFileSystemBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	ctx := context.Background()
+	expected := "some content"
+
+	blobId, written, err := store.Put(ctx, nil, strings.NewReader(expected))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if written != int64(len(expected)) {
+		t.Errorf("expected written bytes: %v, actual: %v", len(expected), written)
+	}
+
+	size, err := store.Stat(ctx, nil, blobId)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if size != int64(len(expected)) {
+		t.Errorf("expected size: %v, actual: %v", len(expected), size)
+	}
+
+	content, err := store.Get(ctx, nil, blobId)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	actual, err := io.ReadAll(content)
+	content.(io.Closer).Close()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if string(actual) != expected {
+		t.Errorf("expected content: %v, actual: %v", expected, string(actual))
+	}
+
+	err = store.Delete(ctx, nil, blobId)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	_, err = store.Stat(ctx, nil, blobId)
+	if !errors.Is(err, ErrNotFoundBlob) {
+		t.Errorf("expected error: %v, actual: %v", ErrNotFoundBlob, err)
+	}
+}
+
+func TestFileSystemBlobStoreRejectsWrongId(t *testing.T) {
+	store, err := CreateFileSystemBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	_, err = store.Get(context.Background(), nil, "../../etc/passwd")
+	if err == nil {
+		t.Errorf("expected error for wrong blob id")
+	}
+}
+
+func TestFileSystemBlobStoreWriteAt(t *testing.T) {
+	store, err := CreateFileSystemBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	ctx := context.Background()
+
+	blobId, _, err := store.Put(ctx, nil, strings.NewReader(""))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for _, chunk := range []struct {
+		offset  int64
+		content string
+	}{{0, "some "}, {5, "contemt"}, {10, "nt"}} {
+		_, err = store.WriteAt(ctx, nil, blobId, chunk.offset, strings.NewReader(chunk.content))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	content, err := store.Get(ctx, nil, blobId)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	actual, err := io.ReadAll(content)
+	content.(io.Closer).Close()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if string(actual) != "some content" {
+		t.Errorf("expected content: some content, actual: %v", string(actual))
+	}
+}
+
+func TestFileSystemBlobStoreCopy(t *testing.T) {
+	store, err := CreateFileSystemBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	ctx := context.Background()
+
+	blobId, _, err := store.Put(ctx, nil, strings.NewReader("some content"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	copyId, err := store.Copy(ctx, nil, blobId)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if copyId == blobId {
+		t.Errorf("expected new blob id, actual: %v", copyId)
+	}
+
+	// the copy is independent of the original blob
+	err = store.Delete(ctx, nil, blobId)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	content, err := store.Get(ctx, nil, copyId)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	actual, err := io.ReadAll(content)
+	content.(io.Closer).Close()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if string(actual) != "some content" {
+		t.Errorf("expected content: some content, actual: %v", string(actual))
+	}
+
+	_, err = store.Copy(ctx, nil, blobId)
+	if !errors.Is(err, ErrNotFoundBlob) {
+		t.Errorf("expected error: %v, actual: %v", ErrNotFoundBlob, err)
+	}
+}
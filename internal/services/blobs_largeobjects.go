@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+
+	pgx "github.com/jackc/pgx/v5"
+)
+
+const (
+	// the content is copied by the chunks on the database side, so it is neither transferred to the service nor loaded into memory at once
+	copyLargeObjectQuery = `SELECT lo_put($2, chunk_offset, lo_get($1, chunk_offset, $3::int)) FROM generate_series(0, $4::bigint - 1, $3::int) AS chunk_offset`
+
+	copyLargeObjectChunkSize = 1024 * 1024
+)
+
+// LargeObjectsBlobStore keeps the content in PostgreSQL large objects (see for details https://www.postgresql.org/docs/current/largeobjects.html)
+// of the same shard where the metadata is, the id of the blob is the oid of the large object.
+type LargeObjectsBlobStore struct{}
+
+func CreateLargeObjectsBlobStore() *LargeObjectsBlobStore {
+	return &LargeObjectsBlobStore{}
+}
+
+func (s *LargeObjectsBlobStore) Put(ctx context.Context, tx pgx.Tx, content io.Reader) (string, int64, error) {
+	lobs := tx.LargeObjects()
+
+	oid, err := lobs.Create(ctx, 0)
+	if err != nil {
+		return "", 0, err
+	}
+
+	obj, err := lobs.Open(ctx, oid, pgx.LargeObjectModeWrite)
+	if err != nil {
+		return "", 0, err
+	}
+	defer obj.Close()
+
+	written, err := io.Copy(obj, content)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return strconv.FormatUint(uint64(oid), 10), written, nil
+}
+
+func (s *LargeObjectsBlobStore) Get(ctx context.Context, tx pgx.Tx, blobId string) (io.ReadSeeker, error) {
+	oid, err := parseOid(blobId)
+	if err != nil {
+		return nil, err
+	}
+	lobs := tx.LargeObjects()
+	obj, err := lobs.Open(ctx, oid, pgx.LargeObjectModeRead)
+	if err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+func (s *LargeObjectsBlobStore) Copy(ctx context.Context, tx pgx.Tx, blobId string) (string, error) {
+	oid, err := parseOid(blobId)
+	if err != nil {
+		return "", err
+	}
+	size, err := s.Stat(ctx, tx, blobId)
+	if err != nil {
+		return "", err
+	}
+	lobs := tx.LargeObjects()
+	copyOid, err := lobs.Create(ctx, 0)
+	if err != nil {
+		return "", err
+	}
+	_, err = tx.Exec(ctx, copyLargeObjectQuery, oid, copyOid, copyLargeObjectChunkSize, size)
+	if err != nil {
+		return "", fmt.Errorf("unable to copy large object '%v': %w", blobId, err)
+	}
+	return strconv.FormatUint(uint64(copyOid), 10), nil
+}
+
+func (s *LargeObjectsBlobStore) WriteAt(ctx context.Context, tx pgx.Tx, blobId string, offset int64, content io.Reader) (int64, error) {
+	oid, err := parseOid(blobId)
+	if err != nil {
+		return 0, err
+	}
+	lobs := tx.LargeObjects()
+	obj, err := lobs.Open(ctx, oid, pgx.LargeObjectModeWrite)
+	if err != nil {
+		return 0, err
+	}
+	defer obj.Close()
+
+	_, err = obj.Seek(offset, io.SeekStart)
+	if err != nil {
+		return 0, err
+	}
+	return io.Copy(obj, content)
+}
+
+func (s *LargeObjectsBlobStore) Delete(ctx context.Context, tx pgx.Tx, blobId string) error {
+	oid, err := parseOid(blobId)
+	if err != nil {
+		return err
+	}
+	lobs := tx.LargeObjects()
+	return lobs.Unlink(ctx, oid)
+}
+
+func (s *LargeObjectsBlobStore) Stat(ctx context.Context, tx pgx.Tx, blobId string) (int64, error) {
+	oid, err := parseOid(blobId)
+	if err != nil {
+		return 0, err
+	}
+	lobs := tx.LargeObjects()
+	obj, err := lobs.Open(ctx, oid, pgx.LargeObjectModeRead)
+	if err != nil {
+		return 0, err
+	}
+	defer obj.Close()
+	return obj.Seek(0, io.SeekEnd)
+}
+
+func (s *LargeObjectsBlobStore) Transactional() bool {
+	return true
+}
+
+func parseOid(blobId string) (uint32, error) {
+	oid, err := strconv.ParseUint(blobId, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("unable to parse large object id '%v': %w", blobId, err)
+	}
+	return uint32(oid), nil
+}
@@ -0,0 +1,210 @@
+package services
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+// contentCodec compresses the content of the assets, the name of the codec is stored as the encoding of the asset
+// and matches the content coding of HTTP (see RFC 9110, section 8.4.1)
+type contentCodec struct {
+	newWriter func(w io.Writer) (io.WriteCloser, error)
+	newReader func(r io.Reader) (io.ReadCloser, error)
+}
+
+var contentCodecs = map[string]contentCodec{
+	CompressionGzip: {
+		newWriter: func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil },
+		newReader: func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+	},
+	// the content is compressed and decompressed by one goroutine as gzip does, so the concurrent requests do not multiply them
+	CompressionZstd: {
+		newWriter: func(w io.Writer) (io.WriteCloser, error) {
+			return zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+		},
+		newReader: func(r io.Reader) (io.ReadCloser, error) {
+			decoder, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+			if err != nil {
+				return nil, err
+			}
+			return decoder.IOReadCloser(), nil
+		},
+	},
+}
+
+// the content of these types is compressed already, so its compression wastes CPU without saving space
+var incompressibleContentTypePrefixes = []string{
+	"image/jpeg", "image/png", "image/gif", "image/webp", "image/avif", "image/heic",
+	"video/", "audio/", "font/woff",
+	"application/zip", "application/gzip", "application/x-gzip", "application/zstd",
+	"application/x-7z-compressed", "application/x-rar-compressed", "application/vnd.rar",
+	"application/x-bzip2", "application/x-xz", "application/pdf",
+}
+
+func ValidateCompression(compression string) error {
+	if compression == CompressionNone {
+		return nil
+	}
+	if _, ok := contentCodecs[compression]; !ok {
+		return fmt.Errorf("unknown compression '%v', supported: '%v', '%v', '%v'", compression, CompressionNone, CompressionGzip, CompressionZstd)
+	}
+	return nil
+}
+
+// encodingFor returns the encoding of the new content of the given type, empty encoding means the content is stored as is
+func (s *AssetsService) encodingFor(contentType string) string {
+	if s.compression == CompressionNone || len(s.compression) == 0 {
+		return ""
+	}
+	contentType = strings.ToLower(contentType)
+	for _, prefix := range incompressibleContentTypePrefixes {
+		if strings.HasPrefix(contentType, prefix) {
+			return ""
+		}
+	}
+	return s.compression
+}
+
+// encodeContent returns the reader of the compressed content, the compression runs in the separate goroutine,
+// the returned function has to be called after the reading to release it
+func encodeContent(encoding string, content io.Reader) (io.Reader, func()) {
+	codec := contentCodecs[encoding]
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		writer, err := codec.newWriter(pw)
+		if err != nil {
+			pw.CloseWithError(fmt.Errorf("unable to encode content: %w", err))
+			return
+		}
+		_, err = io.Copy(writer, content)
+		if err == nil {
+			err = writer.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr, func() {
+		// unblocks the compression if the reading is stopped before the end of the content
+		pr.CloseWithError(io.ErrClosedPipe)
+		<-done
+	}
+}
+
+// EncodedContent is the content of the compressed asset, which is decompressed on the fly while reading
+type EncodedContent interface {
+	io.ReadSeeker
+	// Raw returns the compressed content and its encoding
+	Raw() (io.ReadSeeker, string)
+}
+
+// decodingReadSeeker decompresses the content on the fly, the size of the decompressed content is known from the metadata,
+// so seeking to the end is free and only the reading after the backward seeking restarts the decompression.
+// The reading at the offset costs O(offset): the forward seeking decompresses and discards the skipped bytes,
+// and the backward seeking decompresses the content from the beginning again, so each range of the request
+// in the descending order costs as much as the reading of the whole content up to it. The checkpoints are not cached,
+// because neither gzip nor zstd decoder is able to resume the decompression from the middle of the stream.
+// The compressed content is available by Raw to pass it to the clients which accept the encoding.
+type decodingReadSeeker struct {
+	raw      io.ReadSeeker
+	codec    contentCodec
+	encoding string
+	size     int64
+	// position requested by Seek
+	pos int64
+	// decompressed content and its position
+	reader    io.ReadCloser
+	readerPos int64
+}
+
+func newDecodingReadSeeker(encoding string, raw io.ReadSeeker, size int64) (*decodingReadSeeker, error) {
+	codec, ok := contentCodecs[encoding]
+	if !ok {
+		return nil, fmt.Errorf("unknown encoding '%v'", encoding)
+	}
+	return &decodingReadSeeker{
+		raw:      raw,
+		codec:    codec,
+		encoding: encoding,
+		size:     size,
+	}, nil
+}
+
+func (d *decodingReadSeeker) Read(p []byte) (int, error) {
+	if d.pos >= d.size {
+		return 0, io.EOF
+	}
+	if d.reader == nil || d.readerPos > d.pos {
+		err := d.restart()
+		if err != nil {
+			return 0, err
+		}
+	}
+	if d.readerPos < d.pos {
+		skipped, err := io.CopyN(io.Discard, d.reader, d.pos-d.readerPos)
+		d.readerPos += skipped
+		if err != nil {
+			return 0, err
+		}
+	}
+	n, err := d.reader.Read(p)
+	d.readerPos += int64(n)
+	d.pos = d.readerPos
+	return n, err
+}
+
+func (d *decodingReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = d.pos + offset
+	case io.SeekEnd:
+		pos = d.size + offset
+	default:
+		return 0, fmt.Errorf("wrong whence %v", whence)
+	}
+	if pos < 0 {
+		return 0, fmt.Errorf("negative position %v", pos)
+	}
+	d.pos = pos
+	return pos, nil
+}
+
+func (d *decodingReadSeeker) Raw() (io.ReadSeeker, string) {
+	return d.raw, d.encoding
+}
+
+func (d *decodingReadSeeker) Close() error {
+	if d.reader != nil {
+		return d.reader.Close()
+	}
+	return nil
+}
+
+func (d *decodingReadSeeker) restart() error {
+	if d.reader != nil {
+		d.reader.Close()
+	}
+	_, err := d.raw.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	d.reader, err = d.codec.newReader(d.raw)
+	if err != nil {
+		return fmt.Errorf("unable to decode content: %w", err)
+	}
+	d.readerPos = 0
+	return nil
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestEncodeAndDecodeContent(t *testing.T) {
+	expected := strings.Repeat("some content of the log file\n", 1000)
+	for _, encoding := range []string{CompressionGzip, CompressionZstd} {
+		encoded, release := encodeContent(encoding, strings.NewReader(expected))
+		compressed, err := io.ReadAll(encoded)
+		release()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(compressed) >= len(expected) {
+			t.Errorf("expected %v compressed size less than %v, actual: %v", encoding, len(expected), len(compressed))
+		}
+
+		decoded, err := newDecodingReadSeeker(encoding, bytes.NewReader(compressed), int64(len(expected)))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		size, err := decoded.Seek(0, io.SeekEnd)
+		if err != nil || size != int64(len(expected)) {
+			t.Errorf("expected size: %v, actual: %v, error: %v", len(expected), size, err)
+		}
+
+		// the reading after the backward seek restarts the decompression
+		for _, offset := range []int64{100, 10, 28000} {
+			_, err = decoded.Seek(offset, io.SeekStart)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			actual := make([]byte, 20)
+			_, err = io.ReadFull(decoded, actual)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if string(actual) != expected[offset:offset+20] {
+				t.Errorf("expected %v content at %v: %v, actual: %v", encoding, offset, expected[offset:offset+20], string(actual))
+			}
+		}
+		decoded.Close()
+	}
+}
+
+func TestEncodeContentPassesReadError(t *testing.T) {
+	encoded, release := encodeContent(CompressionGzip, io.MultiReader(strings.NewReader("some"), &failingReader{ErrFileSizeQuotaExceeded}))
+	_, err := io.ReadAll(encoded)
+	release()
+	if !errors.Is(err, ErrFileSizeQuotaExceeded) {
+		t.Errorf("expected error: %v, actual: %v", ErrFileSizeQuotaExceeded, err)
+	}
+}
+
+func TestEncodingFor(t *testing.T) {
+	s := &AssetsService{compression: CompressionGzip}
+	if s.encodingFor("text/csv; charset=utf-8") != CompressionGzip {
+		t.Errorf("expected compression of text content")
+	}
+	if s.encodingFor("image/jpeg") != "" || s.encodingFor("application/zip") != "" {
+		t.Errorf("expected no compression of compressed content")
+	}
+
+	s = &AssetsService{compression: CompressionNone}
+	if s.encodingFor("text/csv") != "" {
+		t.Errorf("expected no compression if it is disabled")
+	}
+}
+
+func TestValidateCompression(t *testing.T) {
+	for _, compression := range []string{CompressionNone, CompressionGzip, CompressionZstd} {
+		err := ValidateCompression(compression)
+		if err != nil {
+			t.Errorf("unexpected error for '%v': %s", compression, err)
+		}
+	}
+	err := ValidateCompression("brotli")
+	if err == nil {
+		t.Errorf("expected error for the unsupported compression")
+	}
+}
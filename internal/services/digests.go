@@ -0,0 +1,53 @@
+package services
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+)
+
+var ErrDigestMismatch = errors.New("digest mismatch")
+
+// ContentDigests are the digests of the uploaded content declared by the client, the missing digests are not verified.
+// MD5 is supported only for the compatibility with the clients which send 'Content-MD5': it is verified while uploading,
+// but neither stored nor returned by the downloads, which carry only SHA-256.
+type ContentDigests struct {
+	SHA256 []byte
+	MD5    []byte
+}
+
+// contentHasher calculates SHA-256 of the content and MD5 only if it is declared
+type contentHasher struct {
+	sha256 hash.Hash
+	md5    hash.Hash
+	writer io.Writer
+}
+
+func newContentHasher(expected ContentDigests) *contentHasher {
+	result := &contentHasher{sha256: sha256.New()}
+	result.writer = result.sha256
+	if len(expected.MD5) > 0 {
+		result.md5 = md5.New()
+		result.writer = io.MultiWriter(result.sha256, result.md5)
+	}
+	return result
+}
+
+func (h *contentHasher) Write(p []byte) (int, error) {
+	return h.writer.Write(p)
+}
+
+// verify compares the calculated digests with the declared ones
+func (h *contentHasher) verify(expected ContentDigests) error {
+	if len(expected.SHA256) > 0 && !bytes.Equal(expected.SHA256, h.sha256.Sum(nil)) {
+		return fmt.Errorf("%w: sha-256", ErrDigestMismatch)
+	}
+	if h.md5 != nil && !bytes.Equal(expected.MD5, h.md5.Sum(nil)) {
+		return fmt.Errorf("%w: md5", ErrDigestMismatch)
+	}
+	return nil
+}
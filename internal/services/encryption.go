@@ -0,0 +1,486 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	pgx "github.com/jackc/pgx/v5"
+)
+
+const (
+	// size of the plaintext chunk, each chunk is sealed separately, so the content is decrypted from any chunk
+	encryptionChunkSize = 64 * 1024
+	encryptionTagSize   = 16
+	encryptionNonceSize = 12
+	// each chunk is stored with its random nonce and followed by its authentication tag
+	sealedChunkSize = encryptionNonceSize + encryptionChunkSize + encryptionTagSize
+	dataKeySize     = 32
+)
+
+const (
+	// the rows which share the data key (e.g. the restored versions) are rewrapped together
+	getDataKeysToRotateQuery = `SELECT DISTINCT key_id, data_key FROM %v WHERE key_id <> '' and key_id <> $1 LIMIT $2`
+	rotateDataKeyQuery       = `UPDATE %v SET key_id = $3, data_key = $4 WHERE key_id = $1 and data_key = $2`
+
+	rotationBatchSize = 1000
+)
+
+// the tables which keep the data keys of the stored contents
+var encryptedContentTables = []string{"assets", "asset_versions", "assets_trash", "uploads"}
+
+var ErrUnknownEncryptionKey = errors.New("unknown encryption key")
+var ErrEncryptionDisabled = errors.New("encryption is disabled")
+
+// KeyRing keeps the master keys, which wrap the data keys of the assets.
+// The new data keys are wrapped by the active key, the other keys are kept to unwrap the data keys until they are rotated.
+type KeyRing struct {
+	activeKeyId string
+	keys        map[string]cipher.AEAD
+}
+
+// ParseKeyRing parses the list of the master keys separated by commas or new lines,
+// each key has format '<key id>:<base64 encoded 32 bytes>', the first key is the active one
+func ParseKeyRing(spec string) (*KeyRing, error) {
+	ring := &KeyRing{keys: make(map[string]cipher.AEAD)}
+	items := strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == '\n' || r == '\r' })
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if len(item) == 0 || strings.HasPrefix(item, "#") {
+			continue
+		}
+		keyId, encoded, ok := strings.Cut(item, ":")
+		if !ok || len(keyId) == 0 {
+			return nil, fmt.Errorf("wrong format of master key, expected '<key id>:<base64 key>'")
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != dataKeySize {
+			return nil, fmt.Errorf("master key '%v' should be base64 encoded %v bytes", keyId, dataKeySize)
+		}
+		if _, exists := ring.keys[keyId]; exists {
+			return nil, fmt.Errorf("duplicate master key '%v'", keyId)
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		ring.keys[keyId] = aead
+		if len(ring.activeKeyId) == 0 {
+			ring.activeKeyId = keyId
+		}
+	}
+	if len(ring.keys) == 0 {
+		return nil, fmt.Errorf("no master keys")
+	}
+	return ring, nil
+}
+
+// newDataKey returns the new data key and its copy wrapped by the active master key
+func (k *KeyRing) newDataKey() ([]byte, string, []byte, error) {
+	dataKey := make([]byte, dataKeySize)
+	_, err := rand.Read(dataKey)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("unable to generate data key: %w", err)
+	}
+	wrapped, err := k.wrap(k.activeKeyId, dataKey)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return dataKey, k.activeKeyId, wrapped, nil
+}
+
+func (k *KeyRing) wrap(keyId string, dataKey []byte) ([]byte, error) {
+	aead, ok := k.keys[keyId]
+	if !ok {
+		return nil, fmt.Errorf("%w '%v'", ErrUnknownEncryptionKey, keyId)
+	}
+	nonce := make([]byte, encryptionNonceSize)
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate nonce: %w", err)
+	}
+	// the key id is authenticated, so the wrapped key is not accepted with the other master key
+	return aead.Seal(nonce, nonce, dataKey, []byte(keyId)), nil
+}
+
+func (k *KeyRing) unwrap(keyId string, wrapped []byte) ([]byte, error) {
+	aead, ok := k.keys[keyId]
+	if !ok {
+		return nil, fmt.Errorf("%w '%v'", ErrUnknownEncryptionKey, keyId)
+	}
+	if len(wrapped) < encryptionNonceSize {
+		return nil, fmt.Errorf("wrong wrapped data key")
+	}
+	dataKey, err := aead.Open(nil, wrapped[:encryptionNonceSize], wrapped[encryptionNonceSize:], []byte(keyId))
+	if err != nil {
+		return nil, fmt.Errorf("unable to unwrap data key: %w", err)
+	}
+	return dataKey, nil
+}
+
+// rewrap wraps the data key by the active master key, the content encrypted by the data key stays untouched
+func (k *KeyRing) rewrap(keyId string, wrapped []byte) ([]byte, error) {
+	dataKey, err := k.unwrap(keyId, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	return k.wrap(k.activeKeyId, dataKey)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// chunkData authenticates the index of the chunk, so the chunks could not be swapped, and the last chunk has the special flag,
+// so the truncation of the content is detected. The nonce of the chunk is random rather than derived from the index,
+// so the chunk which is sealed again (e.g. by the retried part of the upload after the rollback) never reuses the nonce.
+func chunkData(index int64, last bool) []byte {
+	data := binary.BigEndian.AppendUint64(make([]byte, 0, 9), uint64(index))
+	if last {
+		return append(data, 1)
+	}
+	return append(data, 0)
+}
+
+// sealChunk appends the chunk sealed by the random nonce to dst, the nonce is stored before the ciphertext
+func sealChunk(aead cipher.AEAD, dst []byte, index int64, last bool, plain []byte) ([]byte, error) {
+	start := len(dst)
+	dst = append(dst, make([]byte, encryptionNonceSize)...)
+	nonce := dst[start:]
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate nonce: %w", err)
+	}
+	return aead.Seal(dst, nonce, plain, chunkData(index, last)), nil
+}
+
+// encryptingReader returns the encrypted content by chunks, each chunk is followed by its authentication tag
+type encryptingReader struct {
+	source *bufio.Reader
+	aead   cipher.AEAD
+	index  int64
+	plain  []byte
+	sealed []byte
+	done   bool
+}
+
+func newEncryptingReader(dataKey []byte, content io.Reader) (*encryptingReader, error) {
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &encryptingReader{
+		source: bufio.NewReaderSize(content, encryptionChunkSize),
+		aead:   aead,
+		plain:  make([]byte, encryptionChunkSize),
+	}, nil
+}
+
+func (r *encryptingReader) Read(p []byte) (int, error) {
+	if len(r.sealed) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		err := r.sealNextChunk()
+		if err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.sealed)
+	r.sealed = r.sealed[n:]
+	return n, nil
+}
+
+func (r *encryptingReader) sealNextChunk() error {
+	n, err := io.ReadFull(r.source, r.plain)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	last := n < encryptionChunkSize
+	if !last {
+		// the full chunk is the last one if there is nothing after it
+		_, err = r.source.Peek(1)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		last = err == io.EOF
+	}
+	r.sealed, err = sealChunk(r.aead, r.sealed[:0], r.index, last, r.plain[:n])
+	if err != nil {
+		return err
+	}
+	r.index++
+	r.done = last
+	return nil
+}
+
+// partSealer encrypts the content of the known size which is received by parts, e.g. by the chunks of the resumable upload,
+// into the same format as encryptingReader. The full chunks are sealed as soon as they are received, the incomplete chunk
+// is kept as the tail, which is sealed by the random nonce to store it until the next part, and the last chunk is sealed by finish.
+// The chunks are sealed by the random nonces, so the part which is retried with the other content after the rollback
+// does not reuse the nonces of the chunks which could stay in the blob store.
+type partSealer struct {
+	aead cipher.AEAD
+	// index of the chunk of the tail and the index of the last chunk of the content
+	index     int64
+	lastIndex int64
+	tail      []byte
+	// writes the sealed chunk at the given offset of the encrypted content
+	writeChunk func(offset int64, sealed []byte) error
+}
+
+// newPartSealer restores the state of the encryption of the content of the given size,
+// which is received up to the offset, sealedTail is the result of the sealTail of the previous part
+func newPartSealer(dataKey []byte, size int64, offset int64, sealedTail []byte, writeChunk func(offset int64, sealed []byte) error) (*partSealer, error) {
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	sealer := &partSealer{
+		aead:       aead,
+		lastIndex:  max(size-1, 0) / encryptionChunkSize,
+		tail:       make([]byte, 0, encryptionChunkSize),
+		writeChunk: writeChunk,
+	}
+	if len(sealedTail) > 0 {
+		if len(sealedTail) < encryptionNonceSize {
+			return nil, fmt.Errorf("wrong sealed tail")
+		}
+		sealer.index = (offset - int64(len(sealedTail)-encryptionNonceSize-encryptionTagSize)) / encryptionChunkSize
+		sealer.tail, err = aead.Open(sealer.tail, sealedTail[:encryptionNonceSize], sealedTail[encryptionNonceSize:], sealer.tailData())
+		if err != nil {
+			return nil, fmt.Errorf("unable to decrypt tail: %w", err)
+		}
+	} else {
+		sealer.index = offset / encryptionChunkSize
+	}
+	if sealer.index*encryptionChunkSize+int64(len(sealer.tail)) != offset {
+		return nil, fmt.Errorf("tail does not match offset %v", offset)
+	}
+	return sealer, nil
+}
+
+func (p *partSealer) Write(b []byte) (int, error) {
+	written := 0
+	for len(b) > 0 {
+		n := min(encryptionChunkSize-len(p.tail), len(b))
+		p.tail = append(p.tail, b[:n]...)
+		b = b[n:]
+		written += n
+		// the last chunk is kept until finish even if it is full, because it is sealed by the special nonce
+		if len(p.tail) == encryptionChunkSize && p.index < p.lastIndex {
+			err := p.flush(false)
+			if err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// sealTail returns the incomplete chunk sealed by the random nonce, nil means there is no tail
+func (p *partSealer) sealTail() ([]byte, error) {
+	if len(p.tail) == 0 {
+		return nil, nil
+	}
+	nonce := make([]byte, encryptionNonceSize)
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate nonce: %w", err)
+	}
+	return p.aead.Seal(nonce, nonce, p.tail, p.tailData()), nil
+}
+
+// finish seals the last chunk, it is called after the whole content is received
+func (p *partSealer) finish() error {
+	if p.index != p.lastIndex {
+		return fmt.Errorf("content is incomplete, chunk %v of %v", p.index, p.lastIndex)
+	}
+	return p.flush(true)
+}
+
+func (p *partSealer) flush(last bool) error {
+	sealed, err := sealChunk(p.aead, nil, p.index, last, p.tail)
+	if err != nil {
+		return err
+	}
+	err = p.writeChunk(p.index*sealedChunkSize, sealed)
+	if err != nil {
+		return err
+	}
+	p.index++
+	p.tail = p.tail[:0]
+	return nil
+}
+
+// tailData authenticates the index of the tail, so the tail is not accepted for the other chunk
+func (p *partSealer) tailData() []byte {
+	return binary.BigEndian.AppendUint64([]byte("tail:"), uint64(p.index))
+}
+
+// decryptingReadSeeker decrypts the content by chunks, so any position of the content is available without decrypting the previous chunks
+type decryptingReadSeeker struct {
+	raw  io.ReadSeeker
+	aead cipher.AEAD
+	// count of the chunks and the size of the decrypted content
+	chunks int64
+	size   int64
+	pos    int64
+	// the decrypted chunk
+	chunk      []byte
+	chunkIndex int64
+}
+
+func newDecryptingReadSeeker(dataKey []byte, raw io.ReadSeeker) (*decryptingReadSeeker, error) {
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	rawSize, err := raw.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	chunks := (rawSize + sealedChunkSize - 1) / sealedChunkSize
+	size := rawSize - chunks*(encryptionNonceSize+encryptionTagSize)
+	if chunks == 0 || size < 0 {
+		return nil, fmt.Errorf("wrong size of encrypted content: %v", rawSize)
+	}
+	return &decryptingReadSeeker{
+		raw:        raw,
+		aead:       aead,
+		chunks:     chunks,
+		size:       size,
+		chunkIndex: -1,
+	}, nil
+}
+
+func (d *decryptingReadSeeker) Read(p []byte) (int, error) {
+	if d.pos >= d.size {
+		return 0, io.EOF
+	}
+	index := d.pos / encryptionChunkSize
+	if index != d.chunkIndex {
+		err := d.openChunk(index)
+		if err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.chunk[d.pos-index*encryptionChunkSize:])
+	d.pos += int64(n)
+	return n, nil
+}
+
+func (d *decryptingReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = d.pos + offset
+	case io.SeekEnd:
+		pos = d.size + offset
+	default:
+		return 0, fmt.Errorf("wrong whence %v", whence)
+	}
+	if pos < 0 {
+		return 0, fmt.Errorf("negative position %v", pos)
+	}
+	d.pos = pos
+	return pos, nil
+}
+
+func (d *decryptingReadSeeker) openChunk(index int64) error {
+	_, err := d.raw.Seek(index*sealedChunkSize, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	sealed := make([]byte, sealedChunkSize)
+	n, err := io.ReadFull(d.raw, sealed)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	if n < encryptionNonceSize {
+		return fmt.Errorf("unable to decrypt chunk %v: wrong size %v", index, n)
+	}
+	d.chunk, err = d.aead.Open(d.chunk[:0], sealed[:encryptionNonceSize], sealed[encryptionNonceSize:n], chunkData(index, index == d.chunks-1))
+	if err != nil {
+		return fmt.Errorf("unable to decrypt chunk %v: %w", index, err)
+	}
+	d.chunkIndex = index
+	return nil
+}
+
+// RotateDataKeys wraps the data keys of all contents by the active master key and returns the count of the rewrapped keys,
+// the contents are not rewritten, so the previous master keys could be removed from the config after the rotation
+func (s *AssetsService) RotateDataKeys() (int, error) {
+	if s.keys == nil {
+		return 0, ErrEncryptionDisabled
+	}
+	total := 0
+	for i, client := range s.shardedClients {
+		for _, table := range encryptedContentTables {
+			for {
+				rotated := 0
+				err := client.TxVoid(
+					func(tx pgx.Tx, ctx context.Context, cancel context.CancelFunc) error {
+						var internalErr error
+						rotated, internalErr = s.rotateDataKeysBatch(ctx, tx, table)
+						return internalErr
+					},
+					pgx.TxOptions{
+						IsoLevel: pgx.ReadCommitted,
+					})()
+				if err != nil {
+					return total, fmt.Errorf("unable to rotate data keys of table '%v' of shard %v: %w", table, i+1, err)
+				}
+				total += rotated
+				if rotated < rotationBatchSize {
+					break
+				}
+			}
+		}
+	}
+	return total, nil
+}
+
+func (s *AssetsService) rotateDataKeysBatch(ctx context.Context, tx pgx.Tx, table string) (int, error) {
+	rows, err := tx.Query(ctx, fmt.Sprintf(getDataKeysToRotateQuery, table), s.keys.activeKeyId, rotationBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	type wrappedDataKey struct {
+		keyId   string
+		dataKey []byte
+	}
+	keys, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (wrappedDataKey, error) {
+		var key wrappedDataKey
+		err := row.Scan(&key.keyId, &key.dataKey)
+		return key, err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("unable to scan data keys: %w", err)
+	}
+	for _, key := range keys {
+		rewrapped, err := s.keys.rewrap(key.keyId, key.dataKey)
+		if err != nil {
+			return 0, err
+		}
+		_, err = tx.Exec(ctx, fmt.Sprintf(rotateDataKeyQuery, table), key.keyId, key.dataKey, s.keys.activeKeyId, rewrapped)
+		if err != nil {
+			return 0, fmt.Errorf("unable to update data key: %w", err)
+		}
+	}
+	return len(keys), nil
+}
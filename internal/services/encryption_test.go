@@ -0,0 +1,291 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func testMasterKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, dataKeySize))
+}
+
+func encryptContent(t *testing.T, dataKey []byte, content string) []byte {
+	encrypting, err := newEncryptingReader(dataKey, strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	encrypted, err := io.ReadAll(encrypting)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return encrypted
+}
+
+func TestEncryptAndDecryptContent(t *testing.T) {
+	dataKey := bytes.Repeat([]byte{7}, dataKeySize)
+	for _, size := range []int{0, 10, encryptionChunkSize, 2*encryptionChunkSize + 100} {
+		expected := strings.Repeat("x", size/2) + strings.Repeat("y", size-size/2)
+		encrypted := encryptContent(t, dataKey, expected)
+		if bytes.Contains(encrypted, []byte("xxxx")) {
+			t.Errorf("expected encrypted content of size %v", size)
+		}
+
+		decrypted, err := newDecryptingReadSeeker(dataKey, bytes.NewReader(encrypted))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		end, err := decrypted.Seek(0, io.SeekEnd)
+		if err != nil || end != int64(size) {
+			t.Errorf("expected size: %v, actual: %v, error: %v", size, end, err)
+		}
+		_, err = decrypted.Seek(0, io.SeekStart)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		actual, err := io.ReadAll(decrypted)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if string(actual) != expected {
+			t.Errorf("expected decrypted content of size %v, actual size: %v", size, len(actual))
+		}
+	}
+}
+
+func TestDecryptContentRange(t *testing.T) {
+	dataKey := bytes.Repeat([]byte{7}, dataKeySize)
+	expected := strings.Repeat("some content of the log file\n", 10000)
+	decrypted, err := newDecryptingReadSeeker(dataKey, bytes.NewReader(encryptContent(t, dataKey, expected)))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// the ranges cross the chunks and go backward
+	for _, offset := range []int64{encryptionChunkSize - 10, 100, 2*encryptionChunkSize + 5} {
+		_, err = decrypted.Seek(offset, io.SeekStart)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		actual := make([]byte, 20)
+		_, err = io.ReadFull(decrypted, actual)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if string(actual) != expected[offset:offset+20] {
+			t.Errorf("expected content at %v: %v, actual: %v", offset, expected[offset:offset+20], string(actual))
+		}
+	}
+}
+
+func TestDecryptTamperedContent(t *testing.T) {
+	dataKey := bytes.Repeat([]byte{7}, dataKeySize)
+	encrypted := encryptContent(t, dataKey, strings.Repeat("a", 2*encryptionChunkSize+100))
+
+	tampered := bytes.Clone(encrypted)
+	tampered[10] ^= 1
+	// the content cut at the chunk boundary is detected, because the last chunk is sealed differently
+	truncated := encrypted[:2*sealedChunkSize]
+	// the chunks are bound to their indexes
+	swapped := append(append(bytes.Clone(encrypted[sealedChunkSize:2*sealedChunkSize]), encrypted[:sealedChunkSize]...), encrypted[2*sealedChunkSize:]...)
+	wrongKey := bytes.Repeat([]byte{8}, dataKeySize)
+
+	cases := []struct {
+		name    string
+		dataKey []byte
+		content []byte
+	}{
+		{"tampered", dataKey, tampered},
+		{"truncated", dataKey, truncated},
+		{"swapped", dataKey, swapped},
+		{"wrong key", wrongKey, encrypted},
+	}
+	for _, c := range cases {
+		decrypted, err := newDecryptingReadSeeker(c.dataKey, bytes.NewReader(c.content))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		_, err = io.ReadAll(decrypted)
+		if err == nil {
+			t.Errorf("expected decryption error of %v content", c.name)
+		}
+	}
+}
+
+func TestParseKeyRing(t *testing.T) {
+	keys, err := ParseKeyRing("new:" + testMasterKey(1) + ", old:" + testMasterKey(2))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if keys.activeKeyId != "new" {
+		t.Errorf("expected active key: new, actual: %v", keys.activeKeyId)
+	}
+
+	keys, err = ParseKeyRing("# rotated at 2024-01-01\nnew:" + testMasterKey(1) + "\n\nold:" + testMasterKey(2) + "\n")
+	if err != nil || len(keys.keys) != 2 {
+		t.Errorf("expected 2 keys from file, actual: %v, error: %v", keys, err)
+	}
+
+	for _, spec := range []string{"", "new", "new:short", "new:" + testMasterKey(1) + ",new:" + testMasterKey(2)} {
+		_, err = ParseKeyRing(spec)
+		if err == nil {
+			t.Errorf("expected error for key ring '%v'", spec)
+		}
+	}
+}
+
+func TestRewrapDataKey(t *testing.T) {
+	oldKeys, err := ParseKeyRing("old:" + testMasterKey(2))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	dataKey, keyId, wrapped, err := oldKeys.newDataKey()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if keyId != "old" || bytes.Contains(wrapped, dataKey) {
+		t.Errorf("expected data key wrapped by old key, actual key id: %v", keyId)
+	}
+
+	keys, err := ParseKeyRing("new:" + testMasterKey(1) + ",old:" + testMasterKey(2))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	rewrapped, err := keys.rewrap(keyId, wrapped)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	unwrapped, err := keys.unwrap("new", rewrapped)
+	if err != nil || !bytes.Equal(unwrapped, dataKey) {
+		t.Errorf("expected the same data key after rotation, error: %v", err)
+	}
+
+	// the wrapped key is bound to the id of its master key
+	_, err = keys.unwrap("old", rewrapped)
+	if err == nil {
+		t.Errorf("expected error of unwrapping by the wrong master key")
+	}
+	_, err = keys.unwrap("unknown", rewrapped)
+	if !errors.Is(err, ErrUnknownEncryptionKey) {
+		t.Errorf("expected error: %v, actual: %v", ErrUnknownEncryptionKey, err)
+	}
+}
+
+// sealByParts encrypts the content by partSealer as the resumable upload does: each part restores the sealer from the sealed tail
+func sealByParts(t *testing.T, dataKey []byte, content string, partSize int) []byte {
+	var encrypted []byte
+	writeChunk := func(offset int64, sealed []byte) error {
+		if int64(len(encrypted)) < offset+int64(len(sealed)) {
+			encrypted = append(encrypted, make([]byte, offset+int64(len(sealed))-int64(len(encrypted)))...)
+		}
+		copy(encrypted[offset:], sealed)
+		return nil
+	}
+	var tail []byte
+	offset := 0
+	for {
+		sealer, err := newPartSealer(dataKey, int64(len(content)), int64(offset), tail, writeChunk)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if offset == len(content) {
+			err = sealer.finish()
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			return encrypted
+		}
+		end := min(offset+partSize, len(content))
+		_, err = io.Copy(sealer, strings.NewReader(content[offset:end]))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		offset = end
+		tail, err = sealer.sealTail()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if bytes.Contains(tail, []byte("xxxx")) {
+			t.Errorf("expected encrypted tail")
+		}
+	}
+}
+
+func TestPartSealerMatchesEncryptingReader(t *testing.T) {
+	dataKey := bytes.Repeat([]byte{7}, dataKeySize)
+	for _, size := range []int{0, 10, encryptionChunkSize, 2*encryptionChunkSize + 100} {
+		content := strings.Repeat("x", size/2) + strings.Repeat("y", size-size/2)
+		expected := encryptContent(t, dataKey, content)
+		for _, partSize := range []int{1000, encryptionChunkSize, encryptionChunkSize + 1, 3 * encryptionChunkSize} {
+			actual := sealByParts(t, dataKey, content, partSize)
+			if len(expected) != len(actual) {
+				t.Errorf("expected the encrypted content of size %v for parts of size %v, actual size: %v", len(expected), partSize, len(actual))
+			}
+			decrypted, err := newDecryptingReadSeeker(dataKey, bytes.NewReader(actual))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			plain, err := io.ReadAll(decrypted)
+			if err != nil || string(plain) != content {
+				t.Errorf("expected the content of size %v for parts of size %v, actual size: %v, error: %v", size, partSize, len(plain), err)
+			}
+		}
+	}
+}
+
+func TestPartSealerResealsByNewNonce(t *testing.T) {
+	dataKey := bytes.Repeat([]byte{7}, dataKeySize)
+	// the part is sealed twice as if its first transaction was rolled back and the part was retried
+	var sealed [][]byte
+	for attempt := 0; attempt < 2; attempt++ {
+		sealer, err := newPartSealer(dataKey, 2*encryptionChunkSize, 0, nil, func(offset int64, chunk []byte) error {
+			sealed = append(sealed, chunk)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		_, err = sealer.Write(bytes.Repeat([]byte{'x'}, encryptionChunkSize))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if len(sealed) != 2 || len(sealed[0]) != sealedChunkSize {
+		t.Fatalf("expected 2 sealed chunks, actual: %v", len(sealed))
+	}
+	if bytes.Equal(sealed[0][:encryptionNonceSize], sealed[1][:encryptionNonceSize]) {
+		t.Errorf("expected the chunk sealed again to have the other nonce")
+	}
+}
+
+func TestPartSealerRejectsWrongTail(t *testing.T) {
+	dataKey := bytes.Repeat([]byte{7}, dataKeySize)
+	sealer, err := newPartSealer(dataKey, 100, 0, nil, func(offset int64, sealed []byte) error { return nil })
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	_, err = sealer.Write([]byte("0123456789"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	tail, err := sealer.sealTail()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	_, err = newPartSealer(dataKey, 100, 11, tail, nil)
+	if err == nil {
+		t.Errorf("expected error for the tail of the other offset")
+	}
+	_, err = newPartSealer(bytes.Repeat([]byte{8}, dataKeySize), 100, 10, tail, nil)
+	if err == nil {
+		t.Errorf("expected error for the tail of the other data key")
+	}
+	sealer, _ = newPartSealer(dataKey, 2*encryptionChunkSize, 10, tail, nil)
+	if sealer.finish() == nil {
+		t.Errorf("expected error for the incomplete content")
+	}
+}
@@ -0,0 +1,274 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	pgx "github.com/jackc/pgx/v5"
+)
+
+const (
+	GrantPermissionRead      = "read"
+	GrantPermissionReadWrite = "read-write"
+)
+
+const (
+	getUserUuidByLoginQuery = `SELECT uuid FROM users WHERE login = $1`
+	// the repeated grant of the same asset or folder changes the permission of the existing one
+	createGrantQuery = `INSERT INTO asset_grants (owner_uuid, grantee_uuid, name, is_folder, permission) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (owner_uuid, grantee_uuid, name, is_folder) DO UPDATE SET permission = EXCLUDED.permission
+		RETURNING id, create_date, (SELECT login FROM users WHERE uuid = $1)`
+	grantColumns   = `g.id, g.owner_uuid, o.login, g.grantee_uuid, u.login, g.name, g.is_folder, g.permission, g.create_date`
+	getGrantsQuery = `SELECT ` + grantColumns + ` FROM asset_grants g JOIN users o ON o.uuid = g.owner_uuid JOIN users u ON u.uuid = g.grantee_uuid
+		WHERE g.owner_uuid = $1 ORDER BY g.id`
+	getSharedGrantsQuery = `SELECT ` + grantColumns + ` FROM asset_grants g JOIN users o ON o.uuid = g.owner_uuid JOIN users u ON u.uuid = g.grantee_uuid
+		WHERE g.grantee_uuid = $1 ORDER BY o.login, g.name`
+	deleteGrantQuery = `DELETE FROM asset_grants WHERE owner_uuid = $1 and id = $2`
+	// the grants of the name itself and of its parent folders, they are matched by grantCovers
+	getCoveringGrantsQuery = `SELECT name, is_folder, permission FROM asset_grants WHERE owner_uuid = $1 and grantee_uuid = $2 and name = ANY($3)`
+)
+
+var ErrNotFoundGrant = errors.New("grant not found")
+var ErrReadOnlyGrant = errors.New("read only grant")
+var ErrSelfGrant = errors.New("grant to the owner")
+var ErrWrongGrantPermission = errors.New("wrong grant permission")
+
+// GrantsService keeps the grants in the unsharded database, so both the owner's and the grantee's grants are found
+// regardless of the shards of their assets
+type GrantsService struct {
+	client *PostgreSQLService
+}
+
+// Grant allows the grantee to access the owner's asset or the assets of the owner's folder
+type Grant struct {
+	Id           int64
+	OwnerUuid    string
+	OwnerLogin   string
+	GranteeUuid  string
+	GranteeLogin string
+	// name of the asset or the folder
+	Name       string
+	Folder     bool
+	Permission string
+	CreateDate time.Time
+}
+
+func (g *Grant) scanTargets() []any {
+	return []any{&g.Id, &g.OwnerUuid, &g.OwnerLogin, &g.GranteeUuid, &g.GranteeLogin, &g.Name, &g.Folder, &g.Permission, &g.CreateDate}
+}
+
+func CreateGrantsService(client *PostgreSQLService) *GrantsService {
+	return &GrantsService{
+		client: client,
+	}
+}
+
+func (s *GrantsService) Shutdown() error {
+	return nil
+}
+
+// CreateGrant grants the access to the asset or the folder of the owner for the user with the login
+func (s *GrantsService) CreateGrant(ownerUuid string, granteeLogin string, name string, folder bool, permission string) (Grant, error) {
+	grant := Grant{OwnerUuid: ownerUuid, GranteeLogin: granteeLogin, Folder: folder, Permission: permission}
+	switch permission {
+	case GrantPermissionRead, GrantPermissionReadWrite:
+	default:
+		return grant, fmt.Errorf("%w: %v", ErrWrongGrantPermission, permission)
+	}
+	if folder {
+		name = strings.TrimSuffix(name, FolderDelimiter)
+	}
+	err := ValidateAssetName(name)
+	if err != nil {
+		return grant, err
+	}
+	grant.Name = name
+
+	err = s.client.TxVoid(
+		func(tx pgx.Tx, ctx context.Context, cancel context.CancelFunc) error {
+			internalErr := tx.QueryRow(ctx, getUserUuidByLoginQuery, granteeLogin).Scan(&grant.GranteeUuid)
+			if internalErr != nil {
+				if errors.Is(internalErr, pgx.ErrNoRows) {
+					return fmt.Errorf("grant to user '%v' error: %w", granteeLogin, ErrUserNotFound)
+				}
+				return internalErr
+			}
+			if grant.GranteeUuid == ownerUuid {
+				return ErrSelfGrant
+			}
+			return tx.QueryRow(ctx, createGrantQuery, ownerUuid, grant.GranteeUuid, grant.Name, grant.Folder, grant.Permission).Scan(&grant.Id, &grant.CreateDate, &grant.OwnerLogin)
+		},
+		pgx.TxOptions{
+			IsoLevel: pgx.ReadCommitted,
+		})()
+
+	if err != nil {
+		return grant, fmt.Errorf("user '%v' unable to grant access to '%v': %w", ownerUuid, name, err)
+	}
+	return grant, nil
+}
+
+// GetGrants returns the grants created by the owner
+func (s *GrantsService) GetGrants(ownerUuid string) ([]Grant, error) {
+	return s.getGrants(getGrantsQuery, ownerUuid)
+}
+
+// GetSharedGrants returns the grants of the other users for the grantee
+func (s *GrantsService) GetSharedGrants(granteeUuid string) ([]Grant, error) {
+	return s.getGrants(getSharedGrantsQuery, granteeUuid)
+}
+
+func (s *GrantsService) getGrants(query string, userUuid string) ([]Grant, error) {
+	result, err := s.client.Tx(
+		func(tx pgx.Tx, ctx context.Context, cancel context.CancelFunc) (any, error) {
+			grants := []Grant{}
+			rows, internalErr := tx.Query(ctx, query, userUuid)
+			if internalErr != nil {
+				return nil, internalErr
+			}
+
+			for rows.Next() {
+				var grant Grant
+				internalErr := rows.Scan(grant.scanTargets()...)
+				if internalErr != nil {
+					return nil, fmt.Errorf("unable to scan grants: %w", internalErr)
+				}
+				grants = append(grants, grant)
+			}
+
+			internalErr = rows.Err()
+			if internalErr != nil {
+				return nil, fmt.Errorf("unable to scan grants: %w", internalErr)
+			}
+
+			return grants, nil
+		},
+		pgx.TxOptions{
+			IsoLevel: pgx.ReadCommitted,
+		})()
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to get grants: %w", err)
+	}
+
+	grants, ok := result.([]Grant)
+	if !ok {
+		return nil, fmt.Errorf("unable to convert result into []Grant")
+	}
+
+	return grants, nil
+}
+
+// DeleteGrant revokes the owner's grant
+func (s *GrantsService) DeleteGrant(ownerUuid string, grantId int64) error {
+	return s.client.TxVoid(
+		func(tx pgx.Tx, ctx context.Context, cancel context.CancelFunc) error {
+			tag, internalErr := tx.Exec(ctx, deleteGrantQuery, ownerUuid, grantId)
+			if internalErr != nil {
+				return fmt.Errorf("unable to delete grant %v: %w", grantId, internalErr)
+			}
+			if tag.RowsAffected() == 0 {
+				return fmt.Errorf("delete grant %v error: %w", grantId, ErrNotFoundGrant)
+			}
+			return nil
+		},
+		pgx.TxOptions{
+			IsoLevel: pgx.ReadCommitted,
+		})()
+}
+
+// CheckAccess returns the uuid of the owner with the login if the user is the owner itself or the owner granted the access to the asset.
+// The name is the prefix of the names if folderOnly is set, so only the grants of the folders are taken into account.
+func (s *GrantsService) CheckAccess(ownerLogin string, userUuid string, name string, folderOnly bool, permission string) (string, error) {
+	result, err := s.client.Tx(
+		func(tx pgx.Tx, ctx context.Context, cancel context.CancelFunc) (any, error) {
+			var ownerUuid string
+			internalErr := tx.QueryRow(ctx, getUserUuidByLoginQuery, ownerLogin).Scan(&ownerUuid)
+			if internalErr != nil {
+				if errors.Is(internalErr, pgx.ErrNoRows) {
+					return nil, ErrNotFoundGrant
+				}
+				return nil, internalErr
+			}
+			if ownerUuid == userUuid {
+				return ownerUuid, nil
+			}
+
+			rows, internalErr := tx.Query(ctx, getCoveringGrantsQuery, ownerUuid, userUuid, grantCandidates(name))
+			if internalErr != nil {
+				return nil, internalErr
+			}
+			grants := []Grant{}
+			for rows.Next() {
+				var grant Grant
+				internalErr = rows.Scan(&grant.Name, &grant.Folder, &grant.Permission)
+				if internalErr != nil {
+					return nil, fmt.Errorf("unable to scan grants: %w", internalErr)
+				}
+				grants = append(grants, grant)
+			}
+			internalErr = rows.Err()
+			if internalErr != nil {
+				return nil, fmt.Errorf("unable to scan grants: %w", internalErr)
+			}
+			internalErr = checkGrants(grants, name, folderOnly, permission)
+			if internalErr != nil {
+				return nil, internalErr
+			}
+			return ownerUuid, nil
+		},
+		pgx.TxOptions{
+			IsoLevel: pgx.ReadCommitted,
+		})()
+
+	if err != nil {
+		return "", fmt.Errorf("user '%v' unable to access '%v' of '%v': %w", userUuid, name, ownerLogin, err)
+	}
+
+	ownerUuid, ok := result.(string)
+	if !ok {
+		return "", fmt.Errorf("unable to convert result into string")
+	}
+	return ownerUuid, nil
+}
+
+// grantCandidates returns the names of the grants which could cover the name: the name itself and its parent folders
+func grantCandidates(name string) []string {
+	result := []string{name}
+	for i := 1; i < len(name); i++ {
+		if name[i] == '/' {
+			result = append(result, name[:i])
+		}
+	}
+	return result
+}
+
+// grantCovers checks that the grant covers the name, the folder grant covers the assets of the subfolders too.
+// The name is the prefix of the names if folderOnly is set, so only the grants of the folders are taken into account.
+func grantCovers(grant Grant, name string, folderOnly bool) bool {
+	if grant.Folder {
+		return strings.HasPrefix(name, grant.Name+FolderDelimiter)
+	}
+	return !folderOnly && grant.Name == name
+}
+
+// checkGrants checks that one of the grants covers the name with the permission, the read-write grant allows reading too
+func checkGrants(grants []Grant, name string, folderOnly bool, permission string) error {
+	found := false
+	for _, grant := range grants {
+		if !grantCovers(grant, name, folderOnly) {
+			continue
+		}
+		if grant.Permission == GrantPermissionReadWrite || permission != GrantPermissionReadWrite {
+			return nil
+		}
+		found = true
+	}
+	if found {
+		return ErrReadOnlyGrant
+	}
+	return ErrNotFoundGrant
+}
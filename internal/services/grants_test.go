@@ -0,0 +1,73 @@
+package services
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestCreateGrantValidation(t *testing.T) {
+	s := &GrantsService{}
+	_, err := s.CreateGrant("owner", "bob", "docs/report.csv", false, "write")
+	if !errors.Is(err, ErrWrongGrantPermission) {
+		t.Errorf("expected error %v, actual: %v", ErrWrongGrantPermission, err)
+	}
+	for _, name := range []string{"docs/../report.csv", "/", "docs//"} {
+		_, err = s.CreateGrant("owner", "bob", name, true, GrantPermissionRead)
+		if !errors.Is(err, ErrWrongAssetName) {
+			t.Errorf("expected error %v for name '%v', actual: %v", ErrWrongAssetName, name, err)
+		}
+	}
+}
+
+func TestGrantCandidates(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected []string
+	}{
+		{"report.csv", []string{"report.csv"}},
+		{"docs/2024/report.csv", []string{"docs/2024/report.csv", "docs", "docs/2024"}},
+		{"docs/2024/", []string{"docs/2024/", "docs", "docs/2024"}},
+	}
+	for _, test := range tests {
+		actual := grantCandidates(test.name)
+		if !slices.Equal(actual, test.expected) {
+			t.Errorf("expected candidates %v for name '%v', actual: %v", test.expected, test.name, actual)
+		}
+	}
+}
+
+func TestCheckGrants(t *testing.T) {
+	folder := Grant{Name: "docs", Folder: true, Permission: GrantPermissionRead}
+	asset := Grant{Name: "docs/report.csv", Permission: GrantPermissionReadWrite}
+	tests := []struct {
+		grants     []Grant
+		name       string
+		folderOnly bool
+		permission string
+		expected   error
+	}{
+		// the folder grant covers the subfolders, but not the folders with the same prefix
+		{[]Grant{folder}, "docs/2024/report.csv", false, GrantPermissionRead, nil},
+		{[]Grant{folder}, "docs2/report.csv", false, GrantPermissionRead, ErrNotFoundGrant},
+		{[]Grant{folder}, "docs", false, GrantPermissionRead, ErrNotFoundGrant},
+		{[]Grant{folder}, "docs/2024/", true, GrantPermissionRead, nil},
+		// the asset grant covers only the asset itself and never the listing by the prefix
+		{[]Grant{asset}, "docs/report.csv", false, GrantPermissionRead, nil},
+		{[]Grant{asset}, "docs/report.csv/", true, GrantPermissionRead, ErrNotFoundGrant},
+		{[]Grant{{Name: "docs", Permission: GrantPermissionRead}}, "docs/report.csv", false, GrantPermissionRead, ErrNotFoundGrant},
+		{[]Grant{{Name: "docs/report.csv", Folder: true, Permission: GrantPermissionRead}}, "docs/report.csv", false, GrantPermissionRead, ErrNotFoundGrant},
+		// the read grant does not allow writing, the read-write grant allows reading too
+		{[]Grant{folder}, "docs/report.csv", false, GrantPermissionReadWrite, ErrReadOnlyGrant},
+		{[]Grant{asset}, "docs/report.csv", false, GrantPermissionReadWrite, nil},
+		{[]Grant{folder, asset}, "docs/report.csv", false, GrantPermissionReadWrite, nil},
+		{[]Grant{folder, asset}, "docs/other.csv", false, GrantPermissionReadWrite, ErrReadOnlyGrant},
+		{nil, "docs/report.csv", false, GrantPermissionRead, ErrNotFoundGrant},
+	}
+	for _, test := range tests {
+		err := checkGrants(test.grants, test.name, test.folderOnly, test.permission)
+		if !errors.Is(err, test.expected) {
+			t.Errorf("expected error %v for name '%v' with %v permission, actual: %v", test.expected, test.name, test.permission, err)
+		}
+	}
+}
@@ -0,0 +1,400 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ArtemVoronov/clearway-task-assets-service/internal/app/utils"
+	pgx "github.com/jackc/pgx/v5"
+)
+
+// the roles in the order of their rights, each role has the rights of the previous ones
+const (
+	GroupRoleViewer = "viewer"
+	GroupRoleEditor = "editor"
+	GroupRoleAdmin  = "admin"
+)
+
+var groupRoles = []string{GroupRoleViewer, GroupRoleEditor, GroupRoleAdmin}
+
+const (
+	maxGroupNameLength = 256
+
+	createGroupQuery       = `INSERT INTO groups (uuid, name) VALUES ($1, $2) RETURNING uuid, name, create_date`
+	createGroupMemberQuery = `INSERT INTO group_members (group_uuid, user_uuid, role) VALUES ($1, $2, $3)`
+	getGroupsQuery         = `SELECT g.uuid, g.name, m.role, g.create_date FROM groups g JOIN group_members m ON m.group_uuid = g.uuid
+		WHERE m.user_uuid = $1 ORDER BY g.name, g.create_date`
+	getGroupRoleQuery    = `SELECT group_uuid, role FROM group_members WHERE group_uuid = $1 and user_uuid = $2`
+	getGroupMembersQuery = `SELECT m.user_uuid, u.login, m.role, m.create_date FROM group_members m JOIN users u ON u.uuid = m.user_uuid
+		WHERE m.group_uuid = $1 ORDER BY u.login`
+	// the repeated adding of the same member changes the role
+	setGroupMemberQuery = `INSERT INTO group_members (group_uuid, user_uuid, role) VALUES ($1, $2, $3)
+		ON CONFLICT (group_uuid, user_uuid) DO UPDATE SET role = EXCLUDED.role RETURNING create_date`
+	deleteGroupMemberQuery = `DELETE FROM group_members WHERE group_uuid = $1 and user_uuid = $2`
+	// the admins are locked, so the concurrent changes of the roles could not leave the group without the admins
+	countGroupAdminsQuery   = `SELECT user_uuid FROM group_members WHERE group_uuid = $1 and role = '` + GroupRoleAdmin + `' FOR UPDATE`
+	deleteGroupMembersQuery = `DELETE FROM group_members WHERE group_uuid = $1`
+	deleteGroupQuery        = `DELETE FROM groups WHERE uuid = $1`
+	lockGroupQuery          = `SELECT uuid FROM groups WHERE uuid = $1 FOR UPDATE`
+)
+
+var regExpGroupUuid = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+var ErrNotFoundGroup = errors.New("group not found")
+var ErrGroupRole = errors.New("group role does not allow the action")
+var ErrWrongGroupRole = errors.New("wrong group role")
+var ErrWrongGroupName = errors.New("wrong group name")
+var ErrLastGroupAdmin = errors.New("last admin of the group")
+var ErrGroupNotEmpty = errors.New("group is not empty")
+
+// GroupsService keeps the groups and their members in the unsharded database. The assets of the group are kept
+// on the shard of the group like the assets of the user, the uuid of the group takes the place of the uuid of the user.
+type GroupsService struct {
+	client *PostgreSQLService
+}
+
+// Group with the role of the user who requested it
+type Group struct {
+	Uuid       string
+	Name       string
+	Role       string
+	CreateDate time.Time
+}
+
+type GroupMember struct {
+	UserUuid   string
+	Login      string
+	Role       string
+	CreateDate time.Time
+}
+
+// GroupRoleAllows returns true if the role has the rights of the required role
+func GroupRoleAllows(role string, required string) bool {
+	return slices.Index(groupRoles, role) >= slices.Index(groupRoles, required)
+}
+
+func CreateGroupsService(client *PostgreSQLService) *GroupsService {
+	return &GroupsService{
+		client: client,
+	}
+}
+
+func (s *GroupsService) Shutdown() error {
+	return nil
+}
+
+// CreateGroup creates the group with the user as its admin
+func (s *GroupsService) CreateGroup(userUuid string, name string) (Group, error) {
+	var group Group
+	if len(strings.TrimSpace(name)) == 0 || len(name) > maxGroupNameLength {
+		return group, fmt.Errorf("%w: '%v'", ErrWrongGroupName, name)
+	}
+	groupUuid, err := utils.PseudoUUID()
+	if err != nil {
+		return group, fmt.Errorf("unable to create uuid for group: %w", err)
+	}
+
+	err = s.client.TxVoid(
+		func(tx pgx.Tx, ctx context.Context, cancel context.CancelFunc) error {
+			internalErr := tx.QueryRow(ctx, createGroupQuery, groupUuid, name).Scan(&group.Uuid, &group.Name, &group.CreateDate)
+			if internalErr != nil {
+				return internalErr
+			}
+			group.Role = GroupRoleAdmin
+			_, internalErr = tx.Exec(ctx, createGroupMemberQuery, group.Uuid, userUuid, group.Role)
+			return internalErr
+		},
+		pgx.TxOptions{
+			IsoLevel: pgx.ReadCommitted,
+		})()
+
+	if err != nil {
+		return group, fmt.Errorf("user '%v' unable to create group '%v': %w", userUuid, name, err)
+	}
+	return group, nil
+}
+
+// GetGroups returns the groups of the user with the user's roles
+func (s *GroupsService) GetGroups(userUuid string) ([]Group, error) {
+	result, err := s.client.Tx(
+		func(tx pgx.Tx, ctx context.Context, cancel context.CancelFunc) (any, error) {
+			groups := []Group{}
+			rows, internalErr := tx.Query(ctx, getGroupsQuery, userUuid)
+			if internalErr != nil {
+				return nil, internalErr
+			}
+
+			for rows.Next() {
+				var group Group
+				internalErr := rows.Scan(&group.Uuid, &group.Name, &group.Role, &group.CreateDate)
+				if internalErr != nil {
+					return nil, fmt.Errorf("unable to scan groups: %w", internalErr)
+				}
+				groups = append(groups, group)
+			}
+
+			internalErr = rows.Err()
+			if internalErr != nil {
+				return nil, fmt.Errorf("unable to scan groups: %w", internalErr)
+			}
+
+			return groups, nil
+		},
+		pgx.TxOptions{
+			IsoLevel: pgx.ReadCommitted,
+		})()
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to get groups: %w", err)
+	}
+
+	groups, ok := result.([]Group)
+	if !ok {
+		return nil, fmt.Errorf("unable to convert result into []Group")
+	}
+
+	return groups, nil
+}
+
+// CheckRole returns the uuid of the group as it is stored if the user is the member of the group with the required role.
+// The group is not found for the users who are not its members.
+func (s *GroupsService) CheckRole(groupUuid string, userUuid string, required string) (string, error) {
+	if !regExpGroupUuid.MatchString(groupUuid) {
+		return "", fmt.Errorf("user '%v' unable to access group '%v': %w", userUuid, groupUuid, ErrNotFoundGroup)
+	}
+	result, err := s.client.Tx(
+		func(tx pgx.Tx, ctx context.Context, cancel context.CancelFunc) (any, error) {
+			return checkGroupRole(tx, ctx, groupUuid, userUuid, required)
+		},
+		pgx.TxOptions{
+			IsoLevel: pgx.ReadCommitted,
+		})()
+
+	if err != nil {
+		return "", fmt.Errorf("user '%v' unable to access group '%v': %w", userUuid, groupUuid, err)
+	}
+
+	storedUuid, ok := result.(string)
+	if !ok {
+		return "", fmt.Errorf("unable to convert result into string")
+	}
+	return storedUuid, nil
+}
+
+func checkGroupRole(tx pgx.Tx, ctx context.Context, groupUuid string, userUuid string, required string) (string, error) {
+	if !regExpGroupUuid.MatchString(groupUuid) {
+		return "", ErrNotFoundGroup
+	}
+	var storedUuid, role string
+	err := tx.QueryRow(ctx, getGroupRoleQuery, groupUuid, userUuid).Scan(&storedUuid, &role)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrNotFoundGroup
+		}
+		return "", err
+	}
+	err = requireGroupRole(role, required)
+	if err != nil {
+		return "", err
+	}
+	return storedUuid, nil
+}
+
+func requireGroupRole(role string, required string) error {
+	if !GroupRoleAllows(role, required) {
+		return fmt.Errorf("%w: role '%v', required '%v'", ErrGroupRole, role, required)
+	}
+	return nil
+}
+
+// GetMembers returns the members of the group to any of its members
+func (s *GroupsService) GetMembers(userUuid string, groupUuid string) ([]GroupMember, error) {
+	result, err := s.client.Tx(
+		func(tx pgx.Tx, ctx context.Context, cancel context.CancelFunc) (any, error) {
+			storedUuid, internalErr := checkGroupRole(tx, ctx, groupUuid, userUuid, GroupRoleViewer)
+			if internalErr != nil {
+				return nil, internalErr
+			}
+
+			members := []GroupMember{}
+			rows, internalErr := tx.Query(ctx, getGroupMembersQuery, storedUuid)
+			if internalErr != nil {
+				return nil, internalErr
+			}
+
+			for rows.Next() {
+				var member GroupMember
+				internalErr := rows.Scan(&member.UserUuid, &member.Login, &member.Role, &member.CreateDate)
+				if internalErr != nil {
+					return nil, fmt.Errorf("unable to scan group members: %w", internalErr)
+				}
+				members = append(members, member)
+			}
+
+			internalErr = rows.Err()
+			if internalErr != nil {
+				return nil, fmt.Errorf("unable to scan group members: %w", internalErr)
+			}
+
+			return members, nil
+		},
+		pgx.TxOptions{
+			IsoLevel: pgx.ReadCommitted,
+		})()
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to get members of group '%v': %w", groupUuid, err)
+	}
+
+	members, ok := result.([]GroupMember)
+	if !ok {
+		return nil, fmt.Errorf("unable to convert result into []GroupMember")
+	}
+
+	return members, nil
+}
+
+// SetMember adds the user with the login to the group or changes the role of the member, only the admins could do it
+func (s *GroupsService) SetMember(adminUuid string, groupUuid string, login string, role string) (GroupMember, error) {
+	member := GroupMember{Login: login, Role: role}
+	if !slices.Contains(groupRoles, role) {
+		return member, fmt.Errorf("%w: %v", ErrWrongGroupRole, role)
+	}
+
+	err := s.client.TxVoid(
+		func(tx pgx.Tx, ctx context.Context, cancel context.CancelFunc) error {
+			storedUuid, internalErr := checkGroupRole(tx, ctx, groupUuid, adminUuid, GroupRoleAdmin)
+			if internalErr != nil {
+				return internalErr
+			}
+			internalErr = tx.QueryRow(ctx, getUserUuidByLoginQuery, login).Scan(&member.UserUuid)
+			if internalErr != nil {
+				if errors.Is(internalErr, pgx.ErrNoRows) {
+					return fmt.Errorf("add user '%v' to group error: %w", login, ErrUserNotFound)
+				}
+				return internalErr
+			}
+			if role != GroupRoleAdmin {
+				internalErr = checkOtherGroupAdmins(tx, ctx, storedUuid, member.UserUuid)
+				if internalErr != nil {
+					return internalErr
+				}
+			}
+			return tx.QueryRow(ctx, setGroupMemberQuery, storedUuid, member.UserUuid, role).Scan(&member.CreateDate)
+		},
+		pgx.TxOptions{
+			IsoLevel: pgx.ReadCommitted,
+		})()
+
+	if err != nil {
+		return member, fmt.Errorf("user '%v' unable to set role of '%v' in group '%v': %w", adminUuid, login, groupUuid, err)
+	}
+	return member, nil
+}
+
+// DeleteMember removes the user with the login from the group, the admins remove any member and the members could leave the group
+func (s *GroupsService) DeleteMember(userUuid string, groupUuid string, login string) error {
+	return s.client.TxVoid(
+		func(tx pgx.Tx, ctx context.Context, cancel context.CancelFunc) error {
+			storedUuid, internalErr := checkGroupRole(tx, ctx, groupUuid, userUuid, GroupRoleViewer)
+			if internalErr != nil {
+				return internalErr
+			}
+			var memberUuid string
+			internalErr = tx.QueryRow(ctx, getUserUuidByLoginQuery, login).Scan(&memberUuid)
+			if internalErr != nil {
+				if errors.Is(internalErr, pgx.ErrNoRows) {
+					return fmt.Errorf("remove user '%v' from group error: %w", login, ErrUserNotFound)
+				}
+				return internalErr
+			}
+			if memberUuid != userUuid {
+				_, internalErr = checkGroupRole(tx, ctx, storedUuid, userUuid, GroupRoleAdmin)
+				if internalErr != nil {
+					return internalErr
+				}
+			}
+			internalErr = checkOtherGroupAdmins(tx, ctx, storedUuid, memberUuid)
+			if internalErr != nil {
+				return internalErr
+			}
+			tag, internalErr := tx.Exec(ctx, deleteGroupMemberQuery, storedUuid, memberUuid)
+			if internalErr != nil {
+				return internalErr
+			}
+			if tag.RowsAffected() == 0 {
+				return fmt.Errorf("remove user '%v' from group error: %w", login, ErrUserNotFound)
+			}
+			return nil
+		},
+		pgx.TxOptions{
+			IsoLevel: pgx.ReadCommitted,
+		})()
+}
+
+// checkOtherGroupAdmins fails if the member is the only admin of the group, so the group keeps at least one admin
+func checkOtherGroupAdmins(tx pgx.Tx, ctx context.Context, groupUuid string, memberUuid string) error {
+	rows, err := tx.Query(ctx, countGroupAdminsQuery, groupUuid)
+	if err != nil {
+		return err
+	}
+	admins, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return fmt.Errorf("unable to scan group admins: %w", err)
+	}
+	return requireOtherGroupAdmins(admins, memberUuid)
+}
+
+func requireOtherGroupAdmins(admins []string, memberUuid string) error {
+	if len(admins) == 1 && admins[0] == memberUuid {
+		return ErrLastGroupAdmin
+	}
+	return nil
+}
+
+// GroupContentDeletion marks the group as the deleted owner on its shard, so its content is not stored any more,
+// it fails if the group keeps any content there. The returned function removes the mark.
+type GroupContentDeletion func(groupUuid string) (restore func(), err error)
+
+// DeleteGroup removes the group with its members, only the admins could do it. The group is locked while its content is checked
+// and marked as deleted on the shard, so the group is not deleted with the content which is not reachable any more.
+// The mark is removed if the group is not deleted after it.
+func (s *GroupsService) DeleteGroup(adminUuid string, groupUuid string, deleteContent GroupContentDeletion) error {
+	var restore func()
+	err := s.client.TxVoid(
+		func(tx pgx.Tx, ctx context.Context, cancel context.CancelFunc) error {
+			storedUuid, internalErr := checkGroupRole(tx, ctx, groupUuid, adminUuid, GroupRoleAdmin)
+			if internalErr != nil {
+				return internalErr
+			}
+			internalErr = tx.QueryRow(ctx, lockGroupQuery, storedUuid).Scan(&storedUuid)
+			if internalErr != nil {
+				return fmt.Errorf("unable to lock group '%v': %w", groupUuid, internalErr)
+			}
+			restore, internalErr = deleteContent(storedUuid)
+			if internalErr != nil {
+				return internalErr
+			}
+			_, internalErr = tx.Exec(ctx, deleteGroupMembersQuery, storedUuid)
+			if internalErr != nil {
+				return fmt.Errorf("unable to delete members of group '%v': %w", groupUuid, internalErr)
+			}
+			_, internalErr = tx.Exec(ctx, deleteGroupQuery, storedUuid)
+			if internalErr != nil {
+				return fmt.Errorf("unable to delete group '%v': %w", groupUuid, internalErr)
+			}
+			return nil
+		},
+		pgx.TxOptions{
+			IsoLevel: pgx.ReadCommitted,
+		})()
+	if err != nil && restore != nil {
+		restore()
+	}
+	return err
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestGroupRoleAllows(t *testing.T) {
+	tests := []struct {
+		role     string
+		required string
+		allows   bool
+	}{
+		{GroupRoleViewer, GroupRoleViewer, true},
+		{GroupRoleViewer, GroupRoleEditor, false},
+		{GroupRoleViewer, GroupRoleAdmin, false},
+		{GroupRoleEditor, GroupRoleViewer, true},
+		{GroupRoleEditor, GroupRoleEditor, true},
+		{GroupRoleEditor, GroupRoleAdmin, false},
+		{GroupRoleAdmin, GroupRoleViewer, true},
+		{GroupRoleAdmin, GroupRoleAdmin, true},
+	}
+	for _, test := range tests {
+		actual := GroupRoleAllows(test.role, test.required)
+		if actual != test.allows {
+			t.Errorf("expected role '%v' allows '%v': %v, actual: %v", test.role, test.required, test.allows, actual)
+		}
+	}
+}
+
+func TestCheckRoleOfMalformedGroup(t *testing.T) {
+	s := &GroupsService{}
+	for _, groupUuid := range []string{"", "design", "0b2c7c3e-5d1a-4f8e-9a51", "0b2c7c3e-5d1a-4f8e-9a51-0c6d2f3e4a5b' or '1'='1"} {
+		_, err := s.CheckRole(groupUuid, "1F615C1D-6BAE-4D8F-EF0B-2FCDC247EF69", GroupRoleViewer)
+		if !errors.Is(err, ErrNotFoundGroup) {
+			t.Errorf("expected error %v for group '%v', actual: %v", ErrNotFoundGroup, groupUuid, err)
+		}
+	}
+}
+
+func TestRequireGroupRole(t *testing.T) {
+	tests := []struct {
+		role     string
+		required string
+		expected error
+	}{
+		{GroupRoleViewer, GroupRoleViewer, nil},
+		{GroupRoleViewer, GroupRoleEditor, ErrGroupRole},
+		{GroupRoleEditor, GroupRoleAdmin, ErrGroupRole},
+		{GroupRoleAdmin, GroupRoleEditor, nil},
+		{"owner", GroupRoleViewer, ErrGroupRole},
+	}
+	for _, test := range tests {
+		err := requireGroupRole(test.role, test.required)
+		if !errors.Is(err, test.expected) {
+			t.Errorf("expected error %v for role '%v' and required '%v', actual: %v", test.expected, test.role, test.required, err)
+		}
+	}
+}
+
+func TestRequireOtherGroupAdmins(t *testing.T) {
+	tests := []struct {
+		admins   []string
+		member   string
+		expected error
+	}{
+		{[]string{"alice"}, "alice", ErrLastGroupAdmin},
+		{[]string{"alice"}, "bob", nil},
+		{[]string{"alice", "bob"}, "alice", nil},
+		{[]string{}, "alice", nil},
+	}
+	for _, test := range tests {
+		err := requireOtherGroupAdmins(test.admins, test.member)
+		if !errors.Is(err, test.expected) {
+			t.Errorf("expected error %v for member '%v' of admins %v, actual: %v", test.expected, test.member, test.admins, err)
+		}
+	}
+}
@@ -0,0 +1,388 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ArtemVoronov/clearway-task-assets-service/internal/app/utils"
+	pgx "github.com/jackc/pgx/v5"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	linkColumns     = `id, token, owner_uuid, name, is_folder, password_hash, expire_date, max_downloads, downloads, last_download_date, create_date`
+	createLinkQuery = `INSERT INTO share_links (token, owner_uuid, name, is_folder, password_hash, expire_date, max_downloads)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING ` + linkColumns
+	getLinksQuery   = `SELECT ` + linkColumns + ` FROM share_links WHERE owner_uuid = $1 ORDER BY id`
+	getLinkQuery    = `SELECT ` + linkColumns + ` FROM share_links WHERE token = $1`
+	deleteLinkQuery = `DELETE FROM share_links WHERE owner_uuid = $1 and id = $2`
+	// the limits are checked by the update itself, so the concurrent downloads could not exceed them
+	registerDownloadQuery = `UPDATE share_links SET downloads = downloads + 1, last_download_date = $2
+		WHERE id = $1 and (expire_date IS NULL or expire_date > $2) and (max_downloads IS NULL or downloads < max_downloads)`
+
+	linkPasswordSaltSize = 16
+	// the iterations of PBKDF2-HMAC-SHA256 recommended by OWASP, the count is kept in the hash, so it could be raised later
+	linkPasswordIterations = 600000
+	linkPasswordHashPrefix = "pbkdf2-sha256"
+	// the checked password is remembered by the access token, so PBKDF2 runs once per the period rather than per request
+	LinkAccessPeriod = time.Hour
+	// the password attempts are limited per link and per client address in the window, so the guessing could not take the CPU
+	LinkPasswordAttemptsWindow = time.Minute
+	maxLinkPasswordAttempts    = 10
+)
+
+var ErrNotFoundLink = errors.New("link not found")
+var ErrExpiredLink = errors.New("link expired")
+var ErrWrongLinkOptions = errors.New("wrong link options")
+var ErrWrongLinkPassword = errors.New("wrong link password")
+var ErrLinkPasswordAttempts = errors.New("too many link password attempts")
+
+// LinksService keeps the public links in the unsharded database, so the link is resolved by its token
+// and then the asset is loaded from the shard of the owner
+type LinksService struct {
+	client   *PostgreSQLService
+	attempts passwordAttempts
+}
+
+// Link is the public link to the asset or the folder, the token is the only secret of the link without the password
+type Link struct {
+	Id        int64
+	Token     string
+	OwnerUuid string
+	// name of the asset or the folder
+	Name   string
+	Folder bool
+	// empty if the link is not protected by the password
+	passwordHash string
+	// nil means no limit
+	ExpireDate       *time.Time
+	MaxDownloads     *int64
+	Downloads        int64
+	LastDownloadDate *time.Time
+	CreateDate       time.Time
+}
+
+func (l *Link) scanTargets() []any {
+	return []any{&l.Id, &l.Token, &l.OwnerUuid, &l.Name, &l.Folder, &l.passwordHash, &l.ExpireDate, &l.MaxDownloads, &l.Downloads, &l.LastDownloadDate, &l.CreateDate}
+}
+
+func (l Link) Protected() bool {
+	return len(l.passwordHash) > 0
+}
+
+// CheckPassword returns true if the link is not protected or the password matches
+func (l Link) CheckPassword(password string) bool {
+	if !l.Protected() {
+		return true
+	}
+	parts := strings.Split(l.passwordHash, "$")
+	if len(parts) != 4 || parts[0] != linkPasswordHashPrefix {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(hashLinkPassword(salt, password, iterations)), []byte(l.passwordHash)) == 1
+}
+
+// AccessToken makes the token of the link with the checked password, the token is valid till the expiry
+func (l Link) AccessToken(expiry time.Time) string {
+	expiryStr := strconv.FormatInt(expiry.Unix(), 10)
+	return expiryStr + "." + l.accessSignature(expiryStr)
+}
+
+// CheckAccessToken returns true if the token is made by AccessToken of the protected link and it is not expired
+func (l Link) CheckAccessToken(token string, now time.Time) bool {
+	if !l.Protected() {
+		return false
+	}
+	expiryStr, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	expiry, err := strconv.ParseInt(expiryStr, 10, 64)
+	if err != nil || !now.Before(time.Unix(expiry, 0)) {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(l.accessSignature(expiryStr)))
+}
+
+// accessSignature signs the access token by the password hash, the hash is known only to the service, so the token
+// could not be forged without it and is checked without PBKDF2
+func (l Link) accessSignature(expiry string) string {
+	h := hmac.New(sha256.New, []byte(l.passwordHash))
+	fmt.Fprintf(h, "%v:%v:%v", l.Id, l.Token, expiry)
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// Available checks the expiry and the downloads limit of the link
+func (l Link) Available(now time.Time) bool {
+	if l.ExpireDate != nil && !now.Before(*l.ExpireDate) {
+		return false
+	}
+	return l.MaxDownloads == nil || l.Downloads < *l.MaxDownloads
+}
+
+// LinkOptions are the settings of the new link, the empty password and the nil limits mean no protection and no limits
+type LinkOptions struct {
+	Name         string
+	Folder       bool
+	Password     string
+	ExpireDate   *time.Time
+	MaxDownloads *int64
+}
+
+// hashLinkPassword makes PBKDF2-HMAC-SHA256 (RFC 8018) of the password in the format '<prefix>$<iterations>$<base64 salt>$<base64 hash>'
+func hashLinkPassword(salt []byte, password string, iterations int) string {
+	key := pbkdf2.Key([]byte(password), salt, iterations, sha256.Size, sha256.New)
+	return fmt.Sprintf("%v$%v$%v$%v", linkPasswordHashPrefix, iterations, base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+}
+
+// passwordAttempts counts the password attempts by the keys in the fixed windows, the attempts are kept in memory,
+// so every instance of the service limits them on its own
+type passwordAttempts struct {
+	mutex   sync.Mutex
+	windows map[string]*attemptsWindow
+}
+
+type attemptsWindow struct {
+	start time.Time
+	count int
+}
+
+// take counts the attempt by all keys, it returns false and counts nothing if any key has no attempts left in its window
+func (a *passwordAttempts) take(keys []string, now time.Time) bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.windows == nil {
+		a.windows = make(map[string]*attemptsWindow)
+	}
+	for _, key := range keys {
+		window, ok := a.windows[key]
+		if ok && now.Sub(window.start) < LinkPasswordAttemptsWindow && window.count >= maxLinkPasswordAttempts {
+			return false
+		}
+	}
+	for _, key := range keys {
+		window, ok := a.windows[key]
+		if !ok || now.Sub(window.start) >= LinkPasswordAttemptsWindow {
+			window = &attemptsWindow{start: now}
+			a.windows[key] = window
+		}
+		window.count++
+	}
+	// the windows of the keys without the recent attempts are dropped, so the map does not grow with every client
+	for key, window := range a.windows {
+		if now.Sub(window.start) >= LinkPasswordAttemptsWindow {
+			delete(a.windows, key)
+		}
+	}
+	return true
+}
+
+// release returns the successful attempt, so only the failed attempts are limited
+func (a *passwordAttempts) release(keys []string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	for _, key := range keys {
+		if window, ok := a.windows[key]; ok && window.count > 0 {
+			window.count--
+		}
+	}
+}
+
+func CreateLinksService(client *PostgreSQLService) *LinksService {
+	return &LinksService{
+		client: client,
+	}
+}
+
+func (s *LinksService) Shutdown() error {
+	return nil
+}
+
+// CreateLink creates the public link to the owner's asset or folder with the random token
+func (s *LinksService) CreateLink(ownerUuid string, options LinkOptions, now time.Time) (Link, error) {
+	var link Link
+	name := options.Name
+	if options.Folder {
+		name = strings.TrimSuffix(name, FolderDelimiter)
+	}
+	err := ValidateAssetName(name)
+	if err != nil {
+		return link, err
+	}
+	if options.ExpireDate != nil && !options.ExpireDate.After(now) {
+		return link, fmt.Errorf("%w: expire date %v is in the past", ErrWrongLinkOptions, options.ExpireDate)
+	}
+	if options.MaxDownloads != nil && *options.MaxDownloads <= 0 {
+		return link, fmt.Errorf("%w: max downloads %v should be positive", ErrWrongLinkOptions, *options.MaxDownloads)
+	}
+	var passwordHash string
+	if len(options.Password) > 0 {
+		salt := make([]byte, linkPasswordSaltSize)
+		_, err = rand.Read(salt)
+		if err != nil {
+			return link, fmt.Errorf("unable to generate salt: %w", err)
+		}
+		passwordHash = hashLinkPassword(salt, options.Password, linkPasswordIterations)
+	}
+
+	err = s.client.TxVoid(
+		func(tx pgx.Tx, ctx context.Context, cancel context.CancelFunc) error {
+			return tx.QueryRow(ctx, createLinkQuery, utils.GenerateToken(), ownerUuid, name, options.Folder, passwordHash, options.ExpireDate, options.MaxDownloads).Scan(link.scanTargets()...)
+		},
+		pgx.TxOptions{
+			IsoLevel: pgx.ReadCommitted,
+		})()
+
+	if err != nil {
+		return link, fmt.Errorf("user '%v' unable to create link to '%v': %w", ownerUuid, name, err)
+	}
+	return link, nil
+}
+
+// GetLinks returns the links of the owner with their usage
+func (s *LinksService) GetLinks(ownerUuid string) ([]Link, error) {
+	result, err := s.client.Tx(
+		func(tx pgx.Tx, ctx context.Context, cancel context.CancelFunc) (any, error) {
+			links := []Link{}
+			rows, internalErr := tx.Query(ctx, getLinksQuery, ownerUuid)
+			if internalErr != nil {
+				return nil, internalErr
+			}
+
+			for rows.Next() {
+				var link Link
+				internalErr := rows.Scan(link.scanTargets()...)
+				if internalErr != nil {
+					return nil, fmt.Errorf("unable to scan links: %w", internalErr)
+				}
+				links = append(links, link)
+			}
+
+			internalErr = rows.Err()
+			if internalErr != nil {
+				return nil, fmt.Errorf("unable to scan links: %w", internalErr)
+			}
+
+			return links, nil
+		},
+		pgx.TxOptions{
+			IsoLevel: pgx.ReadCommitted,
+		})()
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to get links: %w", err)
+	}
+
+	links, ok := result.([]Link)
+	if !ok {
+		return nil, fmt.Errorf("unable to convert result into []Link")
+	}
+
+	return links, nil
+}
+
+// DeleteLink revokes the owner's link
+func (s *LinksService) DeleteLink(ownerUuid string, linkId int64) error {
+	return s.client.TxVoid(
+		func(tx pgx.Tx, ctx context.Context, cancel context.CancelFunc) error {
+			tag, internalErr := tx.Exec(ctx, deleteLinkQuery, ownerUuid, linkId)
+			if internalErr != nil {
+				return fmt.Errorf("unable to delete link %v: %w", linkId, internalErr)
+			}
+			if tag.RowsAffected() == 0 {
+				return fmt.Errorf("delete link %v error: %w", linkId, ErrNotFoundLink)
+			}
+			return nil
+		},
+		pgx.TxOptions{
+			IsoLevel: pgx.ReadCommitted,
+		})()
+}
+
+// GetLink resolves the link by its token, the expired links and the links without the downloads left are not returned
+func (s *LinksService) GetLink(token string, now time.Time) (Link, error) {
+	result, err := s.client.Tx(
+		func(tx pgx.Tx, ctx context.Context, cancel context.CancelFunc) (any, error) {
+			var link Link
+			internalErr := tx.QueryRow(ctx, getLinkQuery, token).Scan(link.scanTargets()...)
+			if internalErr != nil {
+				return nil, internalErr
+			}
+			return link, nil
+		},
+		pgx.TxOptions{
+			IsoLevel: pgx.ReadCommitted,
+		})()
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Link{}, ErrNotFoundLink
+		}
+		return Link{}, fmt.Errorf("unable to get link: %w", err)
+	}
+
+	link, ok := result.(Link)
+	if !ok {
+		return Link{}, fmt.Errorf("unable to convert result into Link")
+	}
+	if !link.Available(now) {
+		return link, fmt.Errorf("link %v error: %w", link.Id, ErrExpiredLink)
+	}
+	return link, nil
+}
+
+// CheckPassword checks the password of the protected link requested from the client address, the attempts are taken
+// before PBKDF2 runs, so the failed attempts over the limit do not hash the password
+func (s *LinksService) CheckPassword(link Link, password string, client string, now time.Time) error {
+	if !link.Protected() {
+		return nil
+	}
+	if len(password) == 0 {
+		return fmt.Errorf("link %v error: %w", link.Id, ErrWrongLinkPassword)
+	}
+	keys := []string{"link:" + strconv.FormatInt(link.Id, 10), "client:" + client}
+	if !s.attempts.take(keys, now) {
+		return fmt.Errorf("link %v error: %w", link.Id, ErrLinkPasswordAttempts)
+	}
+	if !link.CheckPassword(password) {
+		return fmt.Errorf("link %v error: %w", link.Id, ErrWrongLinkPassword)
+	}
+	s.attempts.release(keys)
+	return nil
+}
+
+// RegisterDownload counts the download by the link, it fails if the link is expired or has no downloads left since it was resolved
+func (s *LinksService) RegisterDownload(linkId int64, now time.Time) error {
+	return s.client.TxVoid(
+		func(tx pgx.Tx, ctx context.Context, cancel context.CancelFunc) error {
+			tag, internalErr := tx.Exec(ctx, registerDownloadQuery, linkId, now)
+			if internalErr != nil {
+				return fmt.Errorf("unable to register download of link %v: %w", linkId, internalErr)
+			}
+			if tag.RowsAffected() == 0 {
+				return fmt.Errorf("link %v error: %w", linkId, ErrExpiredLink)
+			}
+			return nil
+		},
+		pgx.TxOptions{
+			IsoLevel: pgx.ReadCommitted,
+		})()
+}
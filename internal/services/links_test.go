@@ -0,0 +1,157 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLinkCheckPassword(t *testing.T) {
+	link := Link{}
+	if !link.CheckPassword("") || link.Protected() {
+		t.Errorf("expected the link without the password to be accessible")
+	}
+
+	salt := []byte("0123456789abcdef")
+	link.passwordHash = hashLinkPassword(salt, "secret", 1000)
+	if !link.Protected() {
+		t.Errorf("expected the link to be protected")
+	}
+	if !link.CheckPassword("secret") {
+		t.Errorf("expected the password to match hash '%v'", link.passwordHash)
+	}
+	for _, password := range []string{"", "Secret", "secret "} {
+		if link.CheckPassword(password) {
+			t.Errorf("expected the password '%v' not to match hash '%v'", password, link.passwordHash)
+		}
+	}
+	if hashLinkPassword([]byte("fedcba9876543210"), "secret", 1000) == hashLinkPassword(salt, "secret", 1000) {
+		t.Errorf("expected the hashes with the different salts to differ")
+	}
+	for _, passwordHash := range []string{"pbkdf2-sha256$0$AAAA$AAAA", "pbkdf2-sha256$1000$AAAA", "md5$1000$AAAA$AAAA"} {
+		link.passwordHash = passwordHash
+		if link.CheckPassword("secret") {
+			t.Errorf("expected the malformed hash '%v' not to match", passwordHash)
+		}
+	}
+}
+
+func TestHashLinkPassword(t *testing.T) {
+	// the test vector of PBKDF2-HMAC-SHA256 from RFC 7914, section 11
+	expected, _ := hex.DecodeString("55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc")
+	actual := hashLinkPassword([]byte("salt"), "passwd", 1)
+	if actual != "pbkdf2-sha256$1$c2FsdA$"+base64.RawStdEncoding.EncodeToString(expected) {
+		t.Errorf("expected hash of the test vector, actual: %v", actual)
+	}
+	salt := make([]byte, linkPasswordSaltSize)
+	if size := len(hashLinkPassword(salt, "secret", linkPasswordIterations)); size > 97 {
+		t.Errorf("expected hash to fit the column of 97 characters, actual: %v", size)
+	}
+}
+
+func TestLinkAvailable(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Minute)
+	maxDownloads := int64(2)
+	tests := []struct {
+		link      Link
+		available bool
+	}{
+		{Link{}, true},
+		{Link{ExpireDate: &future}, true},
+		{Link{ExpireDate: &past}, false},
+		{Link{MaxDownloads: &maxDownloads, Downloads: 1}, true},
+		{Link{MaxDownloads: &maxDownloads, Downloads: 2}, false},
+	}
+	for _, test := range tests {
+		if test.link.Available(now) != test.available {
+			t.Errorf("expected availability %v of link %+v", test.available, test.link)
+		}
+	}
+}
+
+func TestCreateLinkValidation(t *testing.T) {
+	s := &LinksService{}
+	now := time.Now()
+	past := now.Add(-time.Minute)
+	zero := int64(0)
+	for _, options := range []LinkOptions{
+		{Name: "report.csv", ExpireDate: &past},
+		{Name: "report.csv", MaxDownloads: &zero},
+	} {
+		_, err := s.CreateLink("owner", options, now)
+		if !errors.Is(err, ErrWrongLinkOptions) {
+			t.Errorf("expected error %v for %+v, actual: %v", ErrWrongLinkOptions, options, err)
+		}
+	}
+	_, err := s.CreateLink("owner", LinkOptions{Name: "docs/../report.csv"}, now)
+	if !errors.Is(err, ErrWrongAssetName) {
+		t.Errorf("expected error %v, actual: %v", ErrWrongAssetName, err)
+	}
+}
+
+func TestLinkAccessToken(t *testing.T) {
+	now := time.Now()
+	link := Link{Id: 1, Token: "token", passwordHash: hashLinkPassword([]byte("0123456789abcdef"), "secret", 1000)}
+	token := link.AccessToken(now.Add(time.Hour))
+	if !link.CheckAccessToken(token, now) {
+		t.Errorf("expected the access token to be valid")
+	}
+	if link.CheckAccessToken(token, now.Add(time.Hour)) {
+		t.Errorf("expected the access token to expire")
+	}
+	expiry, signature, _ := strings.Cut(token, ".")
+	for _, wrongToken := range []string{"", signature, expiry + ".", fmt.Sprintf("%v.%v", now.Add(2*time.Hour).Unix(), signature)} {
+		if link.CheckAccessToken(wrongToken, now) {
+			t.Errorf("expected the access token '%v' to be invalid", wrongToken)
+		}
+	}
+	for _, other := range []Link{
+		{Id: 2, Token: link.Token, passwordHash: link.passwordHash},
+		{Id: link.Id, Token: link.Token, passwordHash: hashLinkPassword([]byte("fedcba9876543210"), "secret", 1000)},
+		{Id: link.Id, Token: link.Token},
+	} {
+		if other.CheckAccessToken(token, now) {
+			t.Errorf("expected the access token not to be valid for link %+v", other)
+		}
+	}
+}
+
+func TestLinksServiceCheckPassword(t *testing.T) {
+	s := &LinksService{}
+	now := time.Now()
+	link := Link{Id: 1, passwordHash: hashLinkPassword([]byte("0123456789abcdef"), "secret", 1000)}
+	if err := s.CheckPassword(Link{Id: 2}, "", "10.0.0.1", now); err != nil {
+		t.Errorf("expected the link without the password to be accessible, actual: %v", err)
+	}
+	for i := 0; i < 2*maxLinkPasswordAttempts; i++ {
+		if err := s.CheckPassword(link, "secret", "10.0.0.1", now); err != nil {
+			t.Fatalf("expected the successful attempts not to be limited, actual: %v", err)
+		}
+	}
+	for i := 0; i < maxLinkPasswordAttempts; i++ {
+		if err := s.CheckPassword(link, "wrong", "10.0.0.1", now); !errors.Is(err, ErrWrongLinkPassword) {
+			t.Fatalf("expected error %v, actual: %v", ErrWrongLinkPassword, err)
+		}
+	}
+	for _, client := range []string{"10.0.0.1", "10.0.0.2"} {
+		if err := s.CheckPassword(link, "secret", client, now); !errors.Is(err, ErrLinkPasswordAttempts) {
+			t.Errorf("expected error %v for client %v, actual: %v", ErrLinkPasswordAttempts, client, err)
+		}
+	}
+	other := Link{Id: 3, passwordHash: link.passwordHash}
+	if err := s.CheckPassword(other, "secret", "10.0.0.1", now); !errors.Is(err, ErrLinkPasswordAttempts) {
+		t.Errorf("expected error %v for the client of the failed attempts, actual: %v", ErrLinkPasswordAttempts, err)
+	}
+	if err := s.CheckPassword(other, "secret", "10.0.0.2", now); err != nil {
+		t.Errorf("expected the other link to be accessible from the other client, actual: %v", err)
+	}
+	if err := s.CheckPassword(link, "secret", "10.0.0.1", now.Add(LinkPasswordAttemptsWindow)); err != nil {
+		t.Errorf("expected the attempts to be allowed in the next window, actual: %v", err)
+	}
+}
@@ -2,12 +2,14 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"time"
 
 	"github.com/ArtemVoronov/clearway-task-assets-service/internal/app"
 	pgx "github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -60,6 +62,11 @@ func (s *PostgreSQLService) Shutdown() error {
 	return nil
 }
 
+func isDuplicateError(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == DuplicateErrorCode
+}
+
 type SqlQueryFunc func(tx pgx.Tx, ctx context.Context, cancel context.CancelFunc) (any, error)
 
 type SqlQueryFuncVoid func(tx pgx.Tx, ctx context.Context, cancel context.CancelFunc) error
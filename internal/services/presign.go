@@ -0,0 +1,115 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// min size of the signing key, it is the size of SHA-256 output
+const minSigningKeySize = sha256.Size
+
+var ErrWrongSignature = errors.New("wrong signature")
+var ErrExpiredSignature = errors.New("expired signature")
+var ErrWrongPresignExpiry = errors.New("wrong presign expiry")
+
+// PresignedURL grants the access to the single asset without the access token until the expiry
+type PresignedURL struct {
+	// http method of the operation, 'GET' or 'PUT'
+	Method    string
+	OwnerUuid string
+	Name      string
+	Expires   time.Time
+	// id of the key which signed the URL
+	KeyId string
+	// base64url encoded HMAC-SHA256
+	Signature string
+}
+
+// URLSigner signs the presigned URLs by HMAC-SHA256. The first key is active, the rest ones only verify the URLs signed before
+// the rotation, so the key is removed from the configuration after the max expiry since it became inactive.
+type URLSigner struct {
+	keys        map[string][]byte
+	activeKeyId string
+	maxExpiry   time.Duration
+}
+
+// ParseURLSigner parses the signing keys in the format '<key id>:<base64 key>' separated by commas or new lines
+func ParseURLSigner(spec string, maxExpiry time.Duration) (*URLSigner, error) {
+	signer := &URLSigner{keys: make(map[string][]byte), maxExpiry: maxExpiry}
+	items := strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == '\n' || r == '\r' })
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if len(item) == 0 || strings.HasPrefix(item, "#") {
+			continue
+		}
+		keyId, encoded, ok := strings.Cut(item, ":")
+		if !ok || len(keyId) == 0 {
+			return nil, fmt.Errorf("wrong format of signing key, expected '<key id>:<base64 key>'")
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) < minSigningKeySize {
+			return nil, fmt.Errorf("signing key '%v' should be base64 encoded %v bytes at least", keyId, minSigningKeySize)
+		}
+		if _, exists := signer.keys[keyId]; exists {
+			return nil, fmt.Errorf("duplicate signing key '%v'", keyId)
+		}
+		signer.keys[keyId] = key
+		if len(signer.activeKeyId) == 0 {
+			signer.activeKeyId = keyId
+		}
+	}
+	if len(signer.keys) == 0 {
+		return nil, fmt.Errorf("no signing keys")
+	}
+	if maxExpiry <= 0 {
+		return nil, fmt.Errorf("max expiry of presigned URLs should be positive")
+	}
+	return signer, nil
+}
+
+func (s *URLSigner) MaxExpiry() time.Duration {
+	return s.maxExpiry
+}
+
+// Sign signs the URL by the active key, the expiry should not be later than the max expiry since now
+func (s *URLSigner) Sign(url PresignedURL, now time.Time) (PresignedURL, error) {
+	if !url.Expires.After(now) || url.Expires.Sub(now) > s.maxExpiry {
+		return url, fmt.Errorf("%w: expiry should be in %v at most", ErrWrongPresignExpiry, s.maxExpiry)
+	}
+	url.Expires = url.Expires.Truncate(time.Second)
+	url.KeyId = s.activeKeyId
+	url.Signature = base64.RawURLEncoding.EncodeToString(s.mac(s.keys[url.KeyId], url))
+	return url, nil
+}
+
+// Verify checks the signature by the key of the URL and its expiry
+func (s *URLSigner) Verify(url PresignedURL, now time.Time) error {
+	key, ok := s.keys[url.KeyId]
+	if !ok {
+		return fmt.Errorf("%w: unknown key '%v'", ErrWrongSignature, url.KeyId)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(url.Signature)
+	if err != nil || !hmac.Equal(signature, s.mac(key, url)) {
+		return ErrWrongSignature
+	}
+	if !now.Before(url.Expires) {
+		return fmt.Errorf("%w at %v", ErrExpiredSignature, url.Expires)
+	}
+	return nil
+}
+
+// mac authenticates all fields of the URL, so the URL could not be used for the other operation, asset or owner
+func (s *URLSigner) mac(key []byte, url PresignedURL) []byte {
+	h := hmac.New(sha256.New, key)
+	for _, field := range []string{url.Method, url.OwnerUuid, url.Name, strconv.FormatInt(url.Expires.Unix(), 10), url.KeyId} {
+		h.Write([]byte(field))
+		h.Write([]byte{0})
+	}
+	return h.Sum(nil)
+}
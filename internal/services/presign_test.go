@@ -0,0 +1,92 @@
+package services
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestURLSignerRotation(t *testing.T) {
+	oldKey := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("o", minSigningKeySize)))
+	newKey := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("n", minSigningKeySize)))
+	oldSigner, err := ParseURLSigner("k1:"+oldKey, time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	now := time.Now()
+	signed, err := oldSigner.Sign(PresignedURL{Method: "GET", OwnerUuid: "owner", Name: "docs/report.csv", Expires: now.Add(time.Minute)}, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// the URLs signed by the previous key stay valid after the rotation
+	rotated, err := ParseURLSigner("k2:"+newKey+",k1:"+oldKey, time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	err = rotated.Verify(signed, now)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	resigned, err := rotated.Sign(signed, now)
+	if err != nil || resigned.KeyId != "k2" {
+		t.Errorf("expected key: k2, actual: %v, error: %v", resigned.KeyId, err)
+	}
+
+	removed, err := ParseURLSigner("k2:"+newKey, time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	err = removed.Verify(signed, now)
+	if !errors.Is(err, ErrWrongSignature) {
+		t.Errorf("expected error %v, actual: %v", ErrWrongSignature, err)
+	}
+}
+
+func TestURLSignerVerify(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", minSigningKeySize)))
+	signer, err := ParseURLSigner("k1:"+key, time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	now := time.Now()
+	signed, err := signer.Sign(PresignedURL{Method: "GET", OwnerUuid: "owner", Name: "report.csv", Expires: now.Add(time.Minute)}, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tampered := []PresignedURL{signed, signed, signed, signed}
+	tampered[0].Method = "PUT"
+	tampered[1].OwnerUuid = "another"
+	tampered[2].Name = "report.csv.bak"
+	tampered[3].Expires = signed.Expires.Add(time.Hour)
+	for _, url := range tampered {
+		err = signer.Verify(url, now)
+		if !errors.Is(err, ErrWrongSignature) {
+			t.Errorf("expected error %v for %+v, actual: %v", ErrWrongSignature, url, err)
+		}
+	}
+
+	err = signer.Verify(signed, now.Add(2*time.Minute))
+	if !errors.Is(err, ErrExpiredSignature) {
+		t.Errorf("expected error %v, actual: %v", ErrExpiredSignature, err)
+	}
+
+	_, err = signer.Sign(PresignedURL{Method: "GET", OwnerUuid: "owner", Name: "report.csv", Expires: now.Add(2 * time.Hour)}, now)
+	if !errors.Is(err, ErrWrongPresignExpiry) {
+		t.Errorf("expected error %v, actual: %v", ErrWrongPresignExpiry, err)
+	}
+}
+
+func TestParseURLSignerWrongKeys(t *testing.T) {
+	short := base64.StdEncoding.EncodeToString([]byte("short"))
+	key := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", minSigningKeySize)))
+	for _, spec := range []string{"", "k1:" + short, "k1", "k1:" + key + ",k1:" + key} {
+		_, err := ParseURLSigner(spec, time.Hour)
+		if err == nil {
+			t.Errorf("expected error for keys '%v'", spec)
+		}
+	}
+}
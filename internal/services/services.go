@@ -16,7 +16,13 @@ import (
 type Services struct {
 	AuthService    *AuthService
 	UsersService   *UsersService
+	GrantsService  *GrantsService
+	LinksService   *LinksService
+	ApiKeysService *ApiKeysService
+	GroupsService  *GroupsService
 	AssetsService  *AssetsService
+	// nil if the signing keys are not configured, so the presigned URLs are disabled
+	URLSigner      *URLSigner
 	pgForAssets    []*PostgreSQLService
 	pgForUnsharded *PostgreSQLService
 }
@@ -50,11 +56,54 @@ func createServices() (*Services, error) {
 	if err != nil {
 		return nil, fmt.Errorf("unable to init access token TTL: %w", err)
 	}
+	blobStore, err := initBlobStore()
+	if err != nil {
+		return nil, fmt.Errorf("unable to init blob store for assets: %w", err)
+	}
+	versioningPolicy, err := parseVersioningPolicy()
+	if err != nil {
+		return nil, fmt.Errorf("unable to init versioning policy for assets: %w", err)
+	}
+	trashPolicy, err := parseTrashPolicy()
+	if err != nil {
+		return nil, fmt.Errorf("unable to init trash policy for assets: %w", err)
+	}
+	uploadsPolicy, err := parseUploadsPolicy()
+	if err != nil {
+		return nil, fmt.Errorf("unable to init uploads policy for assets: %w", err)
+	}
+	archivePolicy, err := parseArchivePolicy()
+	if err != nil {
+		return nil, fmt.Errorf("unable to init archive policy for assets: %w", err)
+	}
+	defaultQuota, err := parseDefaultQuota()
+	if err != nil {
+		return nil, fmt.Errorf("unable to init default quota for assets: %w", err)
+	}
+	compression, err := parseCompression()
+	if err != nil {
+		return nil, fmt.Errorf("unable to init compression for assets: %w", err)
+	}
+	keys, err := parseEncryptionKeys()
+	if err != nil {
+		return nil, fmt.Errorf("unable to init encryption for assets: %w", err)
+	}
+	urlSigner, err := parseURLSigner()
+	if err != nil {
+		return nil, fmt.Errorf("unable to init signer of presigned URLs: %w", err)
+	}
+	// the purge workers are started by the server only, so the commands of the CLI do not run them
+	assetsService := CreateAssetsService(pgForAssets, blobStore, versioningPolicy, trashPolicy, uploadsPolicy, archivePolicy, defaultQuota, compression, keys)
 
 	return &Services{
 		AuthService:    CreateAuthService(pgForUnsharded, accessTokenTTL),
 		UsersService:   CreateUsersService(pgForUnsharded),
-		AssetsService:  CreateAssetsService(pgForAssets),
+		GrantsService:  CreateGrantsService(pgForUnsharded),
+		LinksService:   CreateLinksService(pgForUnsharded),
+		ApiKeysService: CreateApiKeysService(pgForUnsharded),
+		GroupsService:  CreateGroupsService(pgForUnsharded),
+		AssetsService:  assetsService,
+		URLSigner:      urlSigner,
 		pgForAssets:    pgForAssets,
 		pgForUnsharded: pgForUnsharded,
 	}, nil
@@ -81,6 +130,22 @@ func (s *Services) Shutdown() error {
 	if err != nil {
 		result = append(result, err)
 	}
+	err = s.GrantsService.Shutdown()
+	if err != nil {
+		result = append(result, err)
+	}
+	err = s.LinksService.Shutdown()
+	if err != nil {
+		result = append(result, err)
+	}
+	err = s.ApiKeysService.Shutdown()
+	if err != nil {
+		result = append(result, err)
+	}
+	err = s.GroupsService.Shutdown()
+	if err != nil {
+		result = append(result, err)
+	}
 	err = s.AssetsService.Shutdown()
 	if err != nil {
 		result = append(result, err)
@@ -172,6 +237,72 @@ func initPostgreServiceBySuffix(databaseSuffix string) (*PostgreSQLService, erro
 	return result, nil
 }
 
+func initBlobStore() (BlobStore, error) {
+	storeType, ok := os.LookupEnv("ASSETS_BLOB_STORE_TYPE")
+	if !ok {
+		storeType = app.DefaultAssetsBlobStoreType
+	}
+	storeDir, ok := os.LookupEnv("ASSETS_BLOB_STORE_DIR")
+	if !ok {
+		storeDir = app.DefaultAssetsBlobStoreDir
+	}
+	return CreateBlobStore(storeType, storeDir)
+}
+
+func parseVersioningPolicy() (VersioningPolicy, error) {
+	result := VersioningPolicy{
+		MaxVersions: app.DefaultAssetsVersioningMaxVersions,
+	}
+	enabledStr, ok := os.LookupEnv("ASSETS_VERSIONING_ENABLED")
+	if ok {
+		enabled, err := strconv.ParseBool(enabledStr)
+		if err != nil {
+			return result, fmt.Errorf("unable to parse 'ASSETS_VERSIONING_ENABLED' parameter: %w", err)
+		}
+		result.Enabled = enabled
+	}
+	maxVersionsStr, ok := os.LookupEnv("ASSETS_VERSIONING_MAX_VERSIONS")
+	if ok {
+		maxVersions, err := strconv.Atoi(maxVersionsStr)
+		if err != nil || maxVersions < 0 {
+			return result, fmt.Errorf("unable to parse 'ASSETS_VERSIONING_MAX_VERSIONS' parameter: %v", maxVersionsStr)
+		}
+		result.MaxVersions = maxVersions
+	}
+	maxAgeStr, ok := os.LookupEnv("ASSETS_VERSIONING_MAX_AGE")
+	if ok {
+		maxAge, err := time.ParseDuration(maxAgeStr)
+		if err != nil {
+			return result, fmt.Errorf("unable to parse 'ASSETS_VERSIONING_MAX_AGE' parameter: %w", err)
+		}
+		result.MaxAge = maxAge
+	}
+	return result, nil
+}
+
+func parseTrashPolicy() (TrashPolicy, error) {
+	retentionStr, ok := os.LookupEnv("ASSETS_TRASH_RETENTION")
+	if !ok {
+		retentionStr = app.DefaultAssetsTrashRetention
+	}
+	retention, err := time.ParseDuration(retentionStr)
+	if err != nil {
+		return TrashPolicy{}, fmt.Errorf("unable to parse 'ASSETS_TRASH_RETENTION' parameter: %w", err)
+	}
+	purgeIntervalStr, ok := os.LookupEnv("ASSETS_TRASH_PURGE_INTERVAL")
+	if !ok {
+		purgeIntervalStr = app.DefaultAssetsTrashPurgeInterval
+	}
+	purgeInterval, err := time.ParseDuration(purgeIntervalStr)
+	if err != nil {
+		return TrashPolicy{}, fmt.Errorf("unable to parse 'ASSETS_TRASH_PURGE_INTERVAL' parameter: %w", err)
+	}
+	return TrashPolicy{
+		Retention:     retention,
+		PurgeInterval: purgeInterval,
+	}, nil
+}
+
 func parseAccessTokenTTL() (time.Duration, error) {
 	accessTokenTTLStr, ok := os.LookupEnv("AUTH_ACCESS_TOKEN_TTL")
 	if !ok {
@@ -197,3 +328,142 @@ func parseDatabaseShardsCount() (int, error) {
 
 	return result, nil
 }
+
+func parseUploadsPolicy() (UploadsPolicy, error) {
+	expirationStr, ok := os.LookupEnv("ASSETS_UPLOADS_EXPIRATION")
+	if !ok {
+		expirationStr = app.DefaultAssetsUploadsExpiration
+	}
+	expiration, err := time.ParseDuration(expirationStr)
+	if err != nil {
+		return UploadsPolicy{}, fmt.Errorf("unable to parse 'ASSETS_UPLOADS_EXPIRATION' parameter: %w", err)
+	}
+	return UploadsPolicy{
+		Expiration: expiration,
+	}, nil
+}
+
+func parseArchivePolicy() (ArchivePolicy, error) {
+	result := ArchivePolicy{
+		MaxEntries:          app.DefaultAssetsArchiveMaxEntries,
+		MaxExpandedSize:     app.DefaultAssetsArchiveMaxExpandedSize,
+		MaxCompressionRatio: app.DefaultAssetsArchiveMaxCompressionRatio,
+	}
+	limits := []struct {
+		name  string
+		value *int64
+	}{
+		{"ASSETS_ARCHIVE_MAX_ENTRIES", &result.MaxEntries},
+		{"ASSETS_ARCHIVE_MAX_EXPANDED_SIZE_IN_BYTES", &result.MaxExpandedSize},
+		{"ASSETS_ARCHIVE_MAX_COMPRESSION_RATIO", &result.MaxCompressionRatio},
+	}
+	for _, limit := range limits {
+		valueStr, ok := os.LookupEnv(limit.name)
+		if !ok {
+			continue
+		}
+		value, err := strconv.ParseInt(valueStr, 10, 64)
+		if err != nil || value <= 0 {
+			return result, fmt.Errorf("unable to parse '%v' parameter: %v", limit.name, valueStr)
+		}
+		*limit.value = value
+	}
+	return result, nil
+}
+
+func parseCompression() (string, error) {
+	compression, ok := os.LookupEnv("ASSETS_COMPRESSION")
+	if !ok {
+		compression = app.DefaultAssetsCompression
+	}
+	err := ValidateCompression(compression)
+	if err != nil {
+		return "", fmt.Errorf("unable to parse 'ASSETS_COMPRESSION' parameter: %w", err)
+	}
+	return compression, nil
+}
+
+// parseEncryptionKeys returns nil if the encryption is disabled, the keys from the file take precedence over the keys from the parameter
+func parseEncryptionKeys() (*KeyRing, error) {
+	keysFile, ok := os.LookupEnv("ASSETS_ENCRYPTION_KEYS_FILE")
+	if ok && len(keysFile) > 0 {
+		spec, err := os.ReadFile(keysFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read 'ASSETS_ENCRYPTION_KEYS_FILE' file: %w", err)
+		}
+		keys, err := ParseKeyRing(string(spec))
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse 'ASSETS_ENCRYPTION_KEYS_FILE' file: %w", err)
+		}
+		return keys, nil
+	}
+	spec, ok := os.LookupEnv("ASSETS_ENCRYPTION_KEYS")
+	if !ok || len(spec) == 0 {
+		return nil, nil
+	}
+	keys, err := ParseKeyRing(spec)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse 'ASSETS_ENCRYPTION_KEYS' parameter: %w", err)
+	}
+	return keys, nil
+}
+
+func parseURLSigner() (*URLSigner, error) {
+	maxExpiryStr, ok := os.LookupEnv("ASSETS_PRESIGN_MAX_EXPIRY")
+	if !ok {
+		maxExpiryStr = app.DefaultAssetsPresignMaxExpiry
+	}
+	maxExpiry, err := time.ParseDuration(maxExpiryStr)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse 'ASSETS_PRESIGN_MAX_EXPIRY' parameter: %w", err)
+	}
+	keysFile, ok := os.LookupEnv("ASSETS_PRESIGN_KEYS_FILE")
+	if ok && len(keysFile) > 0 {
+		spec, err := os.ReadFile(keysFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read 'ASSETS_PRESIGN_KEYS_FILE' file: %w", err)
+		}
+		signer, err := ParseURLSigner(string(spec), maxExpiry)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse 'ASSETS_PRESIGN_KEYS_FILE' file: %w", err)
+		}
+		return signer, nil
+	}
+	spec, ok := os.LookupEnv("ASSETS_PRESIGN_KEYS")
+	if !ok || len(spec) == 0 {
+		return nil, nil
+	}
+	signer, err := ParseURLSigner(spec, maxExpiry)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse 'ASSETS_PRESIGN_KEYS' parameter: %w", err)
+	}
+	return signer, nil
+}
+
+func parseDefaultQuota() (Quota, error) {
+	result := Quota{
+		MaxFiles:     app.DefaultQuotaMaxFiles,
+		MaxFileSize:  app.DefaultQuotaMaxFileSize,
+		MaxTotalSize: app.DefaultQuotaMaxTotalSize,
+	}
+	limits := []struct {
+		name  string
+		value *int64
+	}{
+		{"QUOTA_MAX_FILES", &result.MaxFiles},
+		{"QUOTA_MAX_FILE_SIZE_IN_BYTES", &result.MaxFileSize},
+		{"QUOTA_MAX_TOTAL_SIZE_IN_BYTES", &result.MaxTotalSize},
+	}
+	for _, limit := range limits {
+		valueStr, ok := os.LookupEnv(limit.name)
+		if !ok {
+			continue
+		}
+		value, err := strconv.ParseInt(valueStr, 10, 64)
+		if err != nil || value < 0 {
+			return result, fmt.Errorf("unable to parse '%v' parameter: %v", limit.name, valueStr)
+		}
+		*limit.value = value
+	}
+	return result, nil
+}
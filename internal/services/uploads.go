@@ -0,0 +1,448 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ArtemVoronov/clearway-task-assets-service/internal/app/utils"
+	pgx "github.com/jackc/pgx/v5"
+)
+
+const (
+	uploadColumns = `id, name, content_type, length, upload_offset, create_date, expire_date`
+
+	createUploadQuery = `INSERT INTO uploads (id, user_uuid, name, content_type, length, blob_id, hash_state, key_id, data_key, labels, expire_date)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING ` + uploadColumns
+	// the expired uploads are not available even if they are not purged yet
+	getUploadQuery = `SELECT blob_id, hash_state, key_id, data_key, tail, labels, ` + uploadColumns + ` FROM uploads
+		WHERE user_uuid = $1 and id = $2 and expire_date > $3`
+	getUploadForUpdateQuery = getUploadQuery + ` FOR UPDATE`
+	// each chunk prolongs the upload
+	updateUploadProgressQuery = `UPDATE uploads SET upload_offset = $3, content_type = $4, hash_state = $5, tail = $6, expire_date = $7
+		WHERE user_uuid = $1 and id = $2
+		RETURNING ` + uploadColumns
+	// the blob belongs to the asset after the upload is completed, the record is kept to report the progress of the completed upload
+	completeUploadQuery = `UPDATE uploads SET blob_id = NULL, hash_state = NULL, key_id = '', data_key = NULL, tail = NULL, labels = NULL WHERE user_uuid = $1 and id = $2`
+	deleteUploadQuery   = `DELETE FROM uploads WHERE user_uuid = $1 and id = $2 RETURNING blob_id`
+	purgeUploadsQuery   = `WITH deleted AS (DELETE FROM uploads WHERE expire_date < $1 RETURNING blob_id)
+		SELECT blob_id FROM deleted WHERE blob_id IS NOT NULL`
+	existsAssetQuery = `SELECT EXISTS (SELECT 1 FROM assets WHERE user_uuid = $1 and name = $2)`
+	// the unfinished upload reserves the name of the asset until it is completed, deleted or expired
+	existsUploadQuery = `SELECT EXISTS (SELECT 1 FROM uploads WHERE user_uuid = $1 and name = $2 and blob_id IS NOT NULL and expire_date > $3)`
+	// serializes the creation of the asset and the reservation of its name by the upload until the end of the transaction
+	lockAssetNameQuery = `SELECT pg_advisory_xact_lock(hashtext($1::text || '/' || $2::text))`
+)
+
+var regExpUploadId = regexp.MustCompile("^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$")
+
+var ErrNotFoundUpload = errors.New("upload not found")
+var ErrUploadOffsetMismatch = errors.New("upload offset mismatch")
+var ErrUploadLengthExceeded = errors.New("upload length exceeded")
+var ErrAssetNameReserved = fmt.Errorf("%w: the asset is being uploaded", ErrDuplicateAsset)
+
+// UploadsPolicy defines how long the unfinished resumable uploads are kept after the last chunk
+type UploadsPolicy struct {
+	Expiration time.Duration
+}
+
+// Upload is the resumable upload of the asset, the asset is created when the last byte of the declared length is written
+type Upload struct {
+	Id          string
+	Name        string
+	ContentType string
+	Length      int64
+	Offset      int64
+	CreateDate  time.Time
+	ExpireDate  time.Time
+}
+
+func (u *Upload) scanTargets() []any {
+	return []any{&u.Id, &u.Name, &u.ContentType, &u.Length, &u.Offset, &u.CreateDate, &u.ExpireDate}
+}
+
+func (u Upload) Completed() bool {
+	return u.Offset == u.Length
+}
+
+// uploadState is the state of the unfinished upload which is continued by the next chunk
+type uploadState struct {
+	// nil for the completed upload
+	blobId *string
+	// state of the checksum calculation
+	hashState []byte
+	// the data key of the encrypted upload and the incomplete encryption chunk sealed by partSealer
+	keyId   string
+	dataKey []byte
+	tail    []byte
+	// the labels of the asset declared on the creation of the upload
+	labels []byte
+}
+
+// CreateUpload reserves the declared length in the quota and creates the empty blob for the content,
+// the upload of the empty content is completed immediately
+func (s *AssetsService) CreateUpload(userUuid string, name string, contentType string, length int64, labels *AssetLabels) (Upload, error) {
+	err := ValidateAssetName(name)
+	if err != nil {
+		return Upload{}, err
+	}
+	if labels != nil {
+		normalized, err := NormalizeAssetLabels(*labels)
+		if err != nil {
+			return Upload{}, err
+		}
+		labels = &normalized
+	}
+	storedLabels, err := marshalLabels(labels)
+	if err != nil {
+		return Upload{}, err
+	}
+	var upload Upload
+	err = s.txWithBlobs(userUuid,
+		func(tx pgx.Tx, ctx context.Context, blobs *blobSession) error {
+			internalErr := checkAssetNameReserved(ctx, tx, userUuid, name)
+			if internalErr != nil {
+				return internalErr
+			}
+			var exists bool
+			internalErr = tx.QueryRow(ctx, existsAssetQuery, userUuid, name).Scan(&exists)
+			if internalErr != nil {
+				return internalErr
+			}
+			if exists {
+				return fmt.Errorf("upload asset '%v' error: %w", name, ErrDuplicateAsset)
+			}
+
+			quota, internalErr := s.startQuotaGuard(ctx, tx, userUuid, 0)
+			if internalErr != nil {
+				return internalErr
+			}
+			internalErr = quota.reserveFile()
+			if internalErr != nil {
+				return internalErr
+			}
+			internalErr = quota.reserveSize(length)
+			if internalErr != nil {
+				return internalErr
+			}
+
+			uploadId, internalErr := utils.PseudoUUID()
+			if internalErr != nil {
+				return fmt.Errorf("unable to create upload id: %w", internalErr)
+			}
+			blobId, _, internalErr := blobs.Put(strings.NewReader(""))
+			if internalErr != nil {
+				return internalErr
+			}
+			hashState, internalErr := marshalHash(sha256.New())
+			if internalErr != nil {
+				return internalErr
+			}
+			var dataKey, wrappedKey []byte
+			var keyId string
+			if s.keys != nil {
+				dataKey, keyId, wrappedKey, internalErr = s.keys.newDataKey()
+				if internalErr != nil {
+					return internalErr
+				}
+			}
+
+			contentType = normalizeContentType(contentType)
+			if length == 0 && len(contentType) == 0 {
+				contentType = http.DetectContentType(nil)
+			}
+			expireDate := time.Now().UTC().Add(s.uploads.Expiration)
+			internalErr = tx.QueryRow(ctx, createUploadQuery, strings.ToLower(uploadId), userUuid, name, contentType, length, blobId, hashState, keyId, wrappedKey, storedLabels, expireDate).
+				Scan(upload.scanTargets()...)
+			if internalErr != nil {
+				return fmt.Errorf("user '%v' unable to create upload of asset '%v': %w", userUuid, name, internalErr)
+			}
+
+			if length == 0 {
+				state := uploadState{blobId: &blobId, keyId: keyId, dataKey: wrappedKey, labels: storedLabels}
+				var sealer *partSealer
+				if len(keyId) > 0 {
+					sealer, internalErr = newPartSealer(dataKey, 0, 0, nil, blobWriter(blobs, blobId))
+					if internalErr != nil {
+						return internalErr
+					}
+				}
+				return s.completeUpload(ctx, tx, userUuid, upload, state, sha256.New(), sealer)
+			}
+			return s.verifyQuota(ctx, tx, userUuid)
+		})
+
+	return upload, err
+}
+
+func (s *AssetsService) GetUpload(uploadId string, userUuid string) (Upload, error) {
+	if !regExpUploadId.MatchString(uploadId) {
+		return Upload{}, fmt.Errorf("get upload '%v' error: %w", uploadId, ErrNotFoundUpload)
+	}
+	result, err := s.client(userUuid).Tx(
+		func(tx pgx.Tx, ctx context.Context, cancel context.CancelFunc) (any, error) {
+			_, upload, internalErr := scanUpload(tx.QueryRow(ctx, getUploadQuery, userUuid, uploadId, time.Now().UTC()))
+			return upload, internalErr
+		},
+		pgx.TxOptions{
+			IsoLevel: pgx.ReadCommitted,
+		})()
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Upload{}, fmt.Errorf("get upload '%v' error: %w", uploadId, ErrNotFoundUpload)
+		}
+		return Upload{}, fmt.Errorf("unable to get upload: %w", err)
+	}
+
+	upload, ok := result.(Upload)
+	if !ok {
+		return Upload{}, fmt.Errorf("unable to convert result into Upload")
+	}
+
+	return upload, nil
+}
+
+// WriteUpload appends the chunk to the upload, offset has to be equal to the current offset of the upload.
+// If the client is disconnected in the middle of the chunk, the received part of the chunk is kept, so the client is able to resume from it.
+func (s *AssetsService) WriteUpload(uploadId string, userUuid string, offset int64, content io.Reader) (Upload, error) {
+	if !regExpUploadId.MatchString(uploadId) {
+		return Upload{}, fmt.Errorf("write upload '%v' error: %w", uploadId, ErrNotFoundUpload)
+	}
+	chunk := &interruptibleReader{reader: content}
+	var upload Upload
+	err := s.txWithBlobs(userUuid,
+		func(tx pgx.Tx, ctx context.Context, blobs *blobSession) error {
+			state, current, internalErr := scanUpload(tx.QueryRow(ctx, getUploadForUpdateQuery, userUuid, uploadId, time.Now().UTC()))
+			if internalErr != nil {
+				if errors.Is(internalErr, pgx.ErrNoRows) {
+					return fmt.Errorf("write upload '%v' error: %w", uploadId, ErrNotFoundUpload)
+				}
+				return internalErr
+			}
+			upload = current
+			if offset != current.Offset {
+				return fmt.Errorf("write upload '%v' error: %w: expected %v, actual %v", uploadId, ErrUploadOffsetMismatch, current.Offset, offset)
+			}
+			if current.Completed() {
+				return nil
+			}
+
+			hasher := sha256.New()
+			internalErr = unmarshalHash(hasher, state.hashState)
+			if internalErr != nil {
+				return internalErr
+			}
+			var sealer *partSealer
+			if len(state.keyId) > 0 {
+				sealer, internalErr = s.openPartSealer(blobs, current, state)
+				if internalErr != nil {
+					return internalErr
+				}
+			}
+
+			reader := bufio.NewReaderSize(&strictLimitedReader{reader: chunk, remaining: current.Length - current.Offset, exceededErr: ErrUploadLengthExceeded}, sniffLen)
+			contentType := current.ContentType
+			if current.Offset == 0 && len(contentType) == 0 {
+				contentType, internalErr = detectContentType(reader)
+				if internalErr != nil {
+					return fmt.Errorf("unable to detect content type of asset '%v': %w", current.Name, internalErr)
+				}
+			}
+
+			var written int64
+			if sealer != nil {
+				written, internalErr = io.Copy(sealer, io.TeeReader(reader, hasher))
+			} else {
+				written, internalErr = blobs.WriteAt(*state.blobId, current.Offset, io.TeeReader(reader, hasher))
+			}
+			if internalErr != nil {
+				return internalErr
+			}
+			hashState, internalErr := marshalHash(hasher)
+			if internalErr != nil {
+				return internalErr
+			}
+			var tail []byte
+			if sealer != nil {
+				tail, internalErr = sealer.sealTail()
+				if internalErr != nil {
+					return internalErr
+				}
+			}
+
+			expireDate := time.Now().UTC().Add(s.uploads.Expiration)
+			internalErr = tx.QueryRow(ctx, updateUploadProgressQuery, userUuid, uploadId, current.Offset+written, contentType, hashState, tail, expireDate).
+				Scan(upload.scanTargets()...)
+			if internalErr != nil {
+				return fmt.Errorf("user '%v' unable to update upload '%v': %w", userUuid, uploadId, internalErr)
+			}
+
+			if upload.Completed() {
+				return s.completeUpload(ctx, tx, userUuid, upload, state, hasher, sealer)
+			}
+			return nil
+		})
+
+	if err != nil {
+		return upload, err
+	}
+	if chunk.err != nil {
+		slog.Info(fmt.Sprintf("upload '%v' is interrupted at offset %v: %v", uploadId, upload.Offset, chunk.err))
+	}
+
+	return upload, nil
+}
+
+// completeUpload makes the asset from the content of the upload, the content is stored by the chunks as is or encrypted by sealer,
+// so it is not rewritten at the end, and it is not compressed
+func (s *AssetsService) completeUpload(ctx context.Context, tx pgx.Tx, userUuid string, upload Upload, state uploadState, hasher hash.Hash, sealer *partSealer) error {
+	content := storedContent{
+		BlobId:      *state.blobId,
+		Size:        upload.Length,
+		ContentType: upload.ContentType,
+		Checksum:    hex.EncodeToString(hasher.Sum(nil)),
+	}
+	if sealer != nil {
+		err := sealer.finish()
+		if err != nil {
+			return err
+		}
+		content.KeyId = state.keyId
+		content.DataKey = state.dataKey
+	}
+	// the upload releases the reservation of the name before the asset is created
+	_, err := tx.Exec(ctx, completeUploadQuery, userUuid, upload.Id)
+	if err != nil {
+		return fmt.Errorf("user '%v' unable to complete upload '%v': %w", userUuid, upload.Id, err)
+	}
+	labels, err := unmarshalLabels(state.labels)
+	if err != nil {
+		return err
+	}
+	_, err = insertAsset(ctx, tx, userUuid, upload.Name, content, labels)
+	if err != nil {
+		return err
+	}
+	return s.verifyQuota(ctx, tx, userUuid)
+}
+
+// checkAssetNameReserved locks the name of the asset until the end of the transaction and fails if the name is reserved by the unfinished upload
+func checkAssetNameReserved(ctx context.Context, tx pgx.Tx, userUuid string, name string) error {
+	_, err := tx.Exec(ctx, lockAssetNameQuery, userUuid, name)
+	if err != nil {
+		return fmt.Errorf("unable to lock name '%v' of user '%v': %w", name, userUuid, err)
+	}
+	var reserved bool
+	err = tx.QueryRow(ctx, existsUploadQuery, userUuid, name, time.Now().UTC()).Scan(&reserved)
+	if err != nil {
+		return fmt.Errorf("unable to check uploads of asset '%v': %w", name, err)
+	}
+	if reserved {
+		return fmt.Errorf("upload asset '%v' error: %w", name, ErrAssetNameReserved)
+	}
+	return nil
+}
+
+// openPartSealer continues the encryption of the upload from its current offset
+func (s *AssetsService) openPartSealer(blobs *blobSession, upload Upload, state uploadState) (*partSealer, error) {
+	if s.keys == nil {
+		return nil, fmt.Errorf("unable to encrypt upload '%v': %w", upload.Id, ErrUnknownEncryptionKey)
+	}
+	dataKey, err := s.keys.unwrap(state.keyId, state.dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encrypt upload '%v': %w", upload.Id, err)
+	}
+	return newPartSealer(dataKey, upload.Length, upload.Offset, state.tail, blobWriter(blobs, *state.blobId))
+}
+
+// blobWriter writes the sealed chunks of partSealer into the blob
+func blobWriter(blobs *blobSession, blobId string) func(offset int64, sealed []byte) error {
+	return func(offset int64, sealed []byte) error {
+		_, err := blobs.WriteAt(blobId, offset, bytes.NewReader(sealed))
+		return err
+	}
+}
+
+// DeleteUpload terminates the upload and deletes its content, the asset of the completed upload is kept
+func (s *AssetsService) DeleteUpload(uploadId string, userUuid string) error {
+	if !regExpUploadId.MatchString(uploadId) {
+		return fmt.Errorf("delete upload '%v' error: %w", uploadId, ErrNotFoundUpload)
+	}
+	err := s.txWithBlobs(userUuid,
+		func(tx pgx.Tx, ctx context.Context, blobs *blobSession) error {
+			var blobId *string
+			internalErr := tx.QueryRow(ctx, deleteUploadQuery, userUuid, uploadId).Scan(&blobId)
+			if internalErr != nil {
+				return internalErr
+			}
+			if blobId == nil {
+				return nil
+			}
+			return blobs.Delete(*blobId)
+		})
+
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("delete upload '%v' error: %w", uploadId, ErrNotFoundUpload)
+		}
+		return fmt.Errorf("user '%v' unable to delete upload '%v': %w", userUuid, uploadId, err)
+	}
+
+	return nil
+}
+
+func scanUpload(row pgx.Row) (uploadState, Upload, error) {
+	var state uploadState
+	var upload Upload
+	err := row.Scan(append([]any{&state.blobId, &state.hashState, &state.keyId, &state.dataKey, &state.tail, &state.labels}, upload.scanTargets()...)...)
+	return state, upload, err
+}
+
+// marshalHash saves the state of the checksum calculation, so it is continued by the next chunk
+func marshalHash(hasher hash.Hash) ([]byte, error) {
+	marshaler, ok := hasher.(encoding.BinaryMarshaler)
+	if !ok {
+		return nil, fmt.Errorf("unable to save checksum state")
+	}
+	return marshaler.MarshalBinary()
+}
+
+func unmarshalHash(hasher hash.Hash, state []byte) error {
+	unmarshaler, ok := hasher.(encoding.BinaryUnmarshaler)
+	if !ok {
+		return fmt.Errorf("unable to restore checksum state")
+	}
+	return unmarshaler.UnmarshalBinary(state)
+}
+
+// interruptibleReader ends the content at the first read error instead of failing, the error is kept for logging
+type interruptibleReader struct {
+	reader io.Reader
+	err    error
+}
+
+func (r *interruptibleReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, io.EOF
+	}
+	n, err := r.reader.Read(p)
+	if err != nil && err != io.EOF {
+		r.err = err
+		return n, io.EOF
+	}
+	return n, err
+}
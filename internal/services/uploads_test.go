@@ -0,0 +1,55 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestInterruptibleReader(t *testing.T) {
+	interruption := errors.New("connection reset")
+	reader := &interruptibleReader{reader: io.MultiReader(strings.NewReader("some "), &failingReader{interruption})}
+
+	actual, err := io.ReadAll(reader)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if string(actual) != "some " {
+		t.Errorf("expected content: 'some ', actual: '%v'", string(actual))
+	}
+	if !errors.Is(reader.err, interruption) {
+		t.Errorf("expected error: %v, actual: %v", interruption, reader.err)
+	}
+}
+
+func TestHashStateContinuesChecksum(t *testing.T) {
+	hasher := sha256.New()
+	hasher.Write([]byte("some "))
+	state, err := marshalHash(hasher)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	restored := sha256.New()
+	err = unmarshalHash(restored, state)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	restored.Write([]byte("content"))
+
+	expected := sha256.Sum256([]byte("some content"))
+	if hex.EncodeToString(restored.Sum(nil)) != hex.EncodeToString(expected[:]) {
+		t.Errorf("expected checksum: %x, actual: %x", expected, restored.Sum(nil))
+	}
+}
+
+type failingReader struct {
+	err error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
@@ -100,7 +100,7 @@ func (s *UsersService) GetUser(login string) (User, error) {
 			if internalErr != nil && !errors.Is(internalErr, pgx.ErrNoRows) {
 				return "", fmt.Errorf("unable to get user with login '%v': %w", login, internalErr)
 			}
-			return user, internalErr
+			return u, internalErr
 		},
 		pgx.TxOptions{
 			IsoLevel: pgx.ReadCommitted,
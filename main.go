@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"net/http"
 	"os"
@@ -13,6 +14,21 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "rotate-keys" {
+		err := rotateKeys()
+		if err != nil {
+			log.Fatalf("error during data keys rotation: %s", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "set-quota" {
+		err := setQuota(os.Args[2:])
+		if err != nil {
+			log.Fatalf("error during quota setting: %s", err)
+		}
+		return
+	}
+
 	readAppConfig()
 	initAppServices()
 	initAppMonitoring()
@@ -38,7 +54,49 @@ func readAppConfig() {
 }
 
 func initAppServices() {
-	services.Instance()
+	services.Instance().AssetsService.StartPurgeWorkers()
+}
+
+// rotateKeys rewraps the data keys of the encrypted assets by the active master key,
+// the errors are returned, so the services are shut down before the exit
+func rotateKeys() error {
+	readAppConfig()
+	defer onShutdown()
+
+	rotated, err := services.Instance().AssetsService.RotateDataKeys()
+	log.Printf("rotated %v data keys\n", rotated)
+	return err
+}
+
+// setQuota overrides the default quota of the user: set-quota <login> <max files> <max file size> <max total size>,
+// each limit is a number of files or bytes, '0' for no limit or 'default' for the default limit
+func setQuota(args []string) error {
+	if len(args) != 4 {
+		return fmt.Errorf("usage: set-quota <login> <max files> <max file size> <max total size>")
+	}
+	var override services.QuotaOverride
+	limits := []**int64{&override.MaxFiles, &override.MaxFileSize, &override.MaxTotalSize}
+	for i, limit := range limits {
+		value, err := services.ParseQuotaLimit(args[i+1])
+		if err != nil {
+			return err
+		}
+		*limit = value
+	}
+
+	readAppConfig()
+	defer onShutdown()
+
+	user, err := services.Instance().UsersService.GetUser(args[0])
+	if err != nil {
+		return fmt.Errorf("unable to find user '%v': %w", args[0], err)
+	}
+	err = services.Instance().AssetsService.SetUserQuota(user.UUID, override)
+	if err != nil {
+		return err
+	}
+	log.Printf("quota of user '%v' is set\n", args[0])
+	return nil
 }
 
 func initAppMonitoring() {
@@ -58,19 +116,154 @@ func initAppMonitoring() {
 }
 
 func initRestApiRoutes() (http.Handler, error) {
+	assetsListV1Compatibility, err := app.ParseAssetsListV1Compatibility()
+	if err != nil {
+		return nil, err
+	}
+	loadAssetsList := v1.LoadAssetsList
+	if assetsListV1Compatibility {
+		loadAssetsList = v1.LoadAssetsNamesList
+	}
+
+	// the names of the assets could contain '/', so the sub-resources of the asset follow v1.AssetPathSeparator
+	assetRoutes := http.NewServeMux()
+	assetRoutes.Handle("GET /{$}", v1.AuthRequired(v1.LoadAsset, services.ScopeAssetsRead))
+	assetRoutes.Handle("PUT /{$}", v1.AuthRequired(v1.ReplaceAsset, services.ScopeAssetsWrite))
+	assetRoutes.Handle("PATCH /{$}", v1.AuthRequired(v1.PatchAsset, services.ScopeAssetsWrite))
+	assetRoutes.Handle("DELETE /{$}", v1.AuthRequired(v1.DeleteAsset, services.ScopeAssetsDelete))
+	assetRoutes.Handle("POST /copy", v1.AuthRequired(v1.CopyAsset, services.ScopeAssetsWrite))
+	assetRoutes.Handle("POST /rename", v1.AuthRequired(v1.RenameAsset, services.ScopeAssetsWrite, services.ScopeAssetsDelete))
+	assetRoutes.Handle("POST /presign", v1.AuthRequired(v1.PresignAsset, services.ScopeAssetsRead))
+	assetRoutes.Handle("GET /versions", v1.AuthRequired(v1.LoadAssetVersions, services.ScopeAssetsRead))
+	assetRoutes.Handle("GET /versions/{version}", v1.AuthRequired(v1.LoadAssetVersion, services.ScopeAssetsRead))
+	assetRoutes.Handle("POST /versions/{version}/restore", v1.AuthRequired(v1.RestoreAssetVersion, services.ScopeAssetsWrite))
+	assetRoutes.Handle("GET /labels", v1.AuthRequired(v1.LoadAssetLabels, services.ScopeAssetsRead))
+	assetRoutes.Handle("PATCH /labels", v1.AuthRequired(v1.PatchAssetLabels, services.ScopeAssetsWrite))
+	folderRoutes := http.NewServeMux()
+	folderRoutes.Handle("DELETE /{$}", v1.AuthRequired(v1.DeleteFolder, services.ScopeAssetsDelete))
+	folderRoutes.Handle("POST /move", v1.AuthRequired(v1.MoveFolder, services.ScopeAssetsWrite, services.ScopeAssetsDelete))
+	// the assets of the groups are routed the same way, the uuid of the group takes the place of the uuid of the user
+	viewer, editor := services.GroupRoleViewer, services.GroupRoleEditor
+	groupAssetRoutes := http.NewServeMux()
+	groupAssetRoutes.Handle("GET /{$}", v1.AuthRequired(v1.GroupRequired(v1.LoadAsset, viewer), services.ScopeAssetsRead))
+	groupAssetRoutes.Handle("PUT /{$}", v1.AuthRequired(v1.GroupRequired(v1.ReplaceAsset, editor), services.ScopeAssetsWrite))
+	groupAssetRoutes.Handle("PATCH /{$}", v1.AuthRequired(v1.GroupRequired(v1.PatchAsset, editor), services.ScopeAssetsWrite))
+	groupAssetRoutes.Handle("DELETE /{$}", v1.AuthRequired(v1.GroupRequired(v1.DeleteAsset, editor), services.ScopeAssetsDelete))
+	groupAssetRoutes.Handle("POST /copy", v1.AuthRequired(v1.GroupRequired(v1.CopyAsset, editor), services.ScopeAssetsWrite))
+	groupAssetRoutes.Handle("POST /rename", v1.AuthRequired(v1.GroupRequired(v1.RenameAsset, editor), services.ScopeAssetsWrite, services.ScopeAssetsDelete))
+	groupAssetRoutes.Handle("GET /versions", v1.AuthRequired(v1.GroupRequired(v1.LoadAssetVersions, viewer), services.ScopeAssetsRead))
+	groupAssetRoutes.Handle("GET /versions/{version}", v1.AuthRequired(v1.GroupRequired(v1.LoadAssetVersion, viewer), services.ScopeAssetsRead))
+	groupAssetRoutes.Handle("POST /versions/{version}/restore", v1.AuthRequired(v1.GroupRequired(v1.RestoreAssetVersion, editor), services.ScopeAssetsWrite))
+	groupAssetRoutes.Handle("GET /labels", v1.AuthRequired(v1.GroupRequired(v1.LoadAssetLabels, viewer), services.ScopeAssetsRead))
+	groupAssetRoutes.Handle("PATCH /labels", v1.AuthRequired(v1.GroupRequired(v1.PatchAssetLabels, editor), services.ScopeAssetsWrite))
+	groupFolderRoutes := http.NewServeMux()
+	groupFolderRoutes.Handle("DELETE /{$}", v1.AuthRequired(v1.GroupRequired(v1.DeleteFolder, editor), services.ScopeAssetsDelete))
+	groupFolderRoutes.Handle("POST /move", v1.AuthRequired(v1.GroupRequired(v1.MoveFolder, editor), services.ScopeAssetsWrite, services.ScopeAssetsDelete))
+
 	routes := http.NewServeMux()
-	routes.Handle("GET /api/assets", v1.AuthRequired(v1.LoadAssetsList))
-	routes.Handle("POST /api/upload-asset/{name}", v1.AuthRequired(v1.StoreAsset))
-	routes.Handle("GET /api/asset/{name}", v1.AuthRequired(v1.LoadAsset))
-	routes.Handle("DELETE /api/asset/{name}", v1.AuthRequired(v1.DeleteAsset))
+	routes.Handle("GET /api/assets", v1.AuthRequired(loadAssetsList, services.ScopeAssetsRead))
+	routes.Handle("POST /api/assets/archive", v1.AuthRequired(v1.LoadAssetsArchive, services.ScopeAssetsRead))
+	routes.Handle("GET /api/assets/search", v1.AuthRequired(v1.SearchAssets, services.ScopeAssetsRead))
+	routes.Handle("POST /api/upload-asset/{name...}", v1.AuthRequired(v1.StoreAsset, services.ScopeAssetsWrite))
+	routes.Handle("POST /api/upload-archive", v1.AuthRequired(v1.StoreAssetsArchive, services.ScopeAssetsWrite))
+	// the methods are listed, because the pattern without the method conflicts with 'GET /api/'
+	assetPathHandler := v1.NewAssetPathHandler(assetRoutes)
+	for _, method := range []string{"GET", "PUT", "PATCH", "POST", "DELETE"} {
+		routes.Handle(method+" /api/asset/{path...}", assetPathHandler)
+	}
+	// the sub-resources of the single segment names keep the routes without the separator
+	routes.Handle("POST /api/asset/{name}/copy", v1.AuthRequired(v1.CopyAsset, services.ScopeAssetsWrite))
+	routes.Handle("POST /api/asset/{name}/rename", v1.AuthRequired(v1.RenameAsset, services.ScopeAssetsWrite, services.ScopeAssetsDelete))
+	routes.Handle("POST /api/asset/{name}/presign", v1.AuthRequired(v1.PresignAsset, services.ScopeAssetsRead))
+	routes.Handle("GET /api/asset/{name}/versions", v1.AuthRequired(v1.LoadAssetVersions, services.ScopeAssetsRead))
+	routes.Handle("GET /api/asset/{name}/versions/{version}", v1.AuthRequired(v1.LoadAssetVersion, services.ScopeAssetsRead))
+	routes.Handle("POST /api/asset/{name}/versions/{version}/restore", v1.AuthRequired(v1.RestoreAssetVersion, services.ScopeAssetsWrite))
+	folderPathHandler := v1.NewAssetPathHandler(folderRoutes)
+	for _, method := range []string{"POST", "DELETE"} {
+		routes.Handle(method+" /api/folder/{path...}", folderPathHandler)
+	}
+	// the presigned URLs are checked by the signature instead of the access token
+	routes.Handle("GET /api/presigned/{name...}", v1.PresignRequired(v1.LoadAsset))
+	routes.Handle("PUT /api/presigned/{name...}", v1.PresignRequired(v1.ReplaceAsset))
+	routes.Handle("GET /api/trash", v1.AuthRequired(v1.LoadTrash, services.ScopeAssetsRead))
+	routes.Handle("POST /api/trash/{id}/restore", v1.AuthRequired(v1.RestoreTrashedAsset, services.ScopeAssetsWrite))
+	routes.Handle("GET /api/usage", v1.AuthRequired(v1.LoadUsage, services.ScopeAssetsRead))
+	// the routes without the scopes are not accessible by the API keys
+	routes.Handle("POST /api/grants", v1.AuthRequired(v1.CreateGrant))
+	routes.Handle("GET /api/grants", v1.AuthRequired(v1.LoadGrants))
+	routes.Handle("DELETE /api/grants/{id}", v1.AuthRequired(v1.DeleteGrant))
+	routes.Handle("GET /api/shared", v1.AuthRequired(v1.LoadShared))
+	routes.Handle("POST /api/links", v1.AuthRequired(v1.CreateLink))
+	routes.Handle("GET /api/links", v1.AuthRequired(v1.LoadLinks))
+	routes.Handle("DELETE /api/links/{id}", v1.AuthRequired(v1.DeleteLink))
+	routes.Handle("POST /api/api-keys", v1.AuthRequired(v1.CreateApiKey))
+	routes.Handle("GET /api/api-keys", v1.AuthRequired(v1.LoadApiKeys))
+	routes.Handle("DELETE /api/api-keys/{id}", v1.AuthRequired(v1.DeleteApiKey))
+	routes.Handle("POST /api/groups", v1.AuthRequired(v1.CreateGroup))
+	routes.Handle("GET /api/groups", v1.AuthRequired(v1.LoadGroups))
+	routes.Handle("DELETE /api/groups/{id}", v1.AuthRequired(v1.DeleteGroup))
+	routes.Handle("GET /api/groups/{id}/members", v1.AuthRequired(v1.LoadGroupMembers))
+	routes.Handle("PUT /api/groups/{id}/members/{login}", v1.AuthRequired(v1.SetGroupMember))
+	routes.Handle("DELETE /api/groups/{id}/members/{login}", v1.AuthRequired(v1.DeleteGroupMember))
+	routes.Handle("GET /api/groups/{id}/assets", v1.NewGroupPathHandler(v1.AuthRequired(v1.GroupRequired(loadAssetsList, viewer), services.ScopeAssetsRead)))
+	// the search is not under the assets of the group, because it would hide the asset with the same name
+	routes.Handle("GET /api/groups/{id}/search", v1.NewGroupPathHandler(v1.AuthRequired(v1.GroupRequired(v1.SearchAssets, viewer), services.ScopeAssetsRead)))
+	routes.Handle("GET /api/groups/{id}/trash", v1.NewGroupPathHandler(v1.AuthRequired(v1.GroupRequired(v1.LoadTrash, viewer), services.ScopeAssetsRead)))
+	// the id of the trashed asset takes the 'id' path value, so the group id is named by v1.GroupPathValue
+	routes.Handle("POST /api/groups/{group}/trash/{id}/restore", v1.AuthRequired(v1.GroupRequired(v1.RestoreTrashedAsset, editor), services.ScopeAssetsWrite))
+	groupAssetPathHandler := v1.NewGroupPathHandler(v1.NewAssetPathHandler(groupAssetRoutes))
+	for _, method := range []string{"GET", "PUT", "PATCH", "POST", "DELETE"} {
+		routes.Handle(method+" /api/groups/{id}/assets/{path...}", groupAssetPathHandler)
+	}
+	groupFolderPathHandler := v1.NewGroupPathHandler(v1.NewAssetPathHandler(groupFolderRoutes))
+	for _, method := range []string{"POST", "DELETE"} {
+		routes.Handle(method+" /api/groups/{id}/folder/{path...}", groupFolderPathHandler)
+	}
+	// the public links are checked by the token and the password instead of the access token
+	routes.Handle("GET /api/public/{token}", v1.ErrorHandleRequired(v1.LoadPublicAsset))
+	routes.Handle("GET /api/public/{token}/{name...}", v1.ErrorHandleRequired(v1.LoadPublicAsset))
+	routes.Handle("POST /api/uploads", v1.AuthRequired(v1.CreateUpload, services.ScopeAssetsWrite))
+	routes.Handle("HEAD /api/uploads/{id}", v1.AuthRequired(v1.LoadUploadProgress, services.ScopeAssetsWrite))
+	routes.Handle("PATCH /api/uploads/{id}", v1.AuthRequired(v1.WriteUpload, services.ScopeAssetsWrite))
+	routes.Handle("DELETE /api/uploads/{id}", v1.AuthRequired(v1.DeleteUpload, services.ScopeAssetsWrite))
 	routes.Handle("POST /api/auth", v1.ErrorHandleRequired(v1.Authenicate))
 	routes.Handle("POST /api/users", v1.ErrorHandleRequired(v1.CreateUser))
 
 	// CORS
 	processOptionsRequestsFunc := v1.NewProcessOptionsRequestsFunc()
 	routes.HandleFunc("OPTIONS /api/assets", processOptionsRequestsFunc)
-	routes.HandleFunc("OPTIONS /api/upload-asset/{name}", processOptionsRequestsFunc)
-	routes.HandleFunc("OPTIONS /api/asset/{name}", processOptionsRequestsFunc)
+	routes.HandleFunc("OPTIONS /api/assets/archive", processOptionsRequestsFunc)
+	routes.HandleFunc("OPTIONS /api/assets/search", processOptionsRequestsFunc)
+	routes.HandleFunc("OPTIONS /api/upload-asset/{name...}", processOptionsRequestsFunc)
+	routes.HandleFunc("OPTIONS /api/upload-archive", processOptionsRequestsFunc)
+	routes.HandleFunc("OPTIONS /api/asset/{path...}", processOptionsRequestsFunc)
+	routes.HandleFunc("OPTIONS /api/folder/{path...}", processOptionsRequestsFunc)
+	routes.HandleFunc("OPTIONS /api/presigned/{name...}", processOptionsRequestsFunc)
+	routes.HandleFunc("OPTIONS /api/trash", processOptionsRequestsFunc)
+	routes.HandleFunc("OPTIONS /api/trash/{id}/restore", processOptionsRequestsFunc)
+	routes.HandleFunc("OPTIONS /api/usage", processOptionsRequestsFunc)
+	routes.HandleFunc("OPTIONS /api/grants", processOptionsRequestsFunc)
+	routes.HandleFunc("OPTIONS /api/grants/{id}", processOptionsRequestsFunc)
+	routes.HandleFunc("OPTIONS /api/shared", processOptionsRequestsFunc)
+	routes.HandleFunc("OPTIONS /api/links", processOptionsRequestsFunc)
+	routes.HandleFunc("OPTIONS /api/links/{id}", processOptionsRequestsFunc)
+	routes.HandleFunc("OPTIONS /api/api-keys", processOptionsRequestsFunc)
+	routes.HandleFunc("OPTIONS /api/api-keys/{id}", processOptionsRequestsFunc)
+	routes.HandleFunc("OPTIONS /api/groups", processOptionsRequestsFunc)
+	routes.HandleFunc("OPTIONS /api/groups/{id}", processOptionsRequestsFunc)
+	routes.HandleFunc("OPTIONS /api/groups/{id}/members", processOptionsRequestsFunc)
+	routes.HandleFunc("OPTIONS /api/groups/{id}/members/{login}", processOptionsRequestsFunc)
+	routes.HandleFunc("OPTIONS /api/groups/{id}/assets", processOptionsRequestsFunc)
+	routes.HandleFunc("OPTIONS /api/groups/{id}/assets/{path...}", processOptionsRequestsFunc)
+	routes.HandleFunc("OPTIONS /api/groups/{id}/search", processOptionsRequestsFunc)
+	routes.HandleFunc("OPTIONS /api/groups/{id}/trash", processOptionsRequestsFunc)
+	routes.HandleFunc("OPTIONS /api/groups/{group}/trash/{id}/restore", processOptionsRequestsFunc)
+	routes.HandleFunc("OPTIONS /api/groups/{id}/folder/{path...}", processOptionsRequestsFunc)
+	routes.HandleFunc("OPTIONS /api/public/{token}", processOptionsRequestsFunc)
+	routes.HandleFunc("OPTIONS /api/public/{token}/{name...}", processOptionsRequestsFunc)
+	processTusOptionsRequestsFunc := v1.NewProcessTusOptionsRequestsFunc(processOptionsRequestsFunc)
+	routes.HandleFunc("OPTIONS /api/uploads", processTusOptionsRequestsFunc)
+	routes.HandleFunc("OPTIONS /api/uploads/{id}", processTusOptionsRequestsFunc)
 	routes.HandleFunc("OPTIONS /api/auth", processOptionsRequestsFunc)
 	routes.HandleFunc("OPTIONS /api/users", processOptionsRequestsFunc)
 
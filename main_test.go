@@ -0,0 +1,11 @@
+package main
+
+import "testing"
+
+func TestInitRestApiRoutes(t *testing.T) {
+	// the conflicting patterns panic on the registration
+	_, err := initRestApiRoutes()
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
@@ -53,6 +53,14 @@ dbunsharded() {
     docker exec -it assets-service-database psql -d assets_service_db_unsharded -U assets_service_user
 }
 
+rotateKeys() {
+    docker exec -it assets-service-api ./clearway-task-assets-service rotate-keys
+}
+
+setQuota() {
+    docker exec -it assets-service-api ./clearway-task-assets-service set-quota "$@"
+}
+
 initDefaultDockerEnv() {
   cp .env.dev .env
 }
@@ -90,6 +98,13 @@ case "$1" in
   swagger)
     generateSwagger
     ;;
+  rotatekeys)
+    rotateKeys
+    ;;
+  setquota)
+    shift
+    setQuota "$@"
+    ;;
   redocandstart)
     down
     generateSwagger
@@ -103,5 +118,5 @@ case "$1" in
     generateSelfSignedCerts
   ;;
   *)
-    echo "Usage: $0 {start|stop|tail|purge|certs|db1|db2|dbunsharded|swagger|redocandstart|prepare}"
+    echo "Usage: $0 {start|stop|tail|purge|certs|db1|db2|dbunsharded|swagger|rotatekeys|setquota|redocandstart|prepare}"
 esac
\ No newline at end of file